@@ -2,6 +2,7 @@ package root
 
 import (
 	"log/slog"
+	"path/filepath"
 
 	acpsdk "github.com/coder/acp-go-sdk"
 	"github.com/spf13/cobra"
@@ -9,10 +10,13 @@ import (
 	"github.com/rumpl/rb/pkg/acp"
 	"github.com/rumpl/rb/pkg/agentfile"
 	"github.com/rumpl/rb/pkg/config"
+	"github.com/rumpl/rb/pkg/paths"
+	"github.com/rumpl/rb/pkg/session"
 )
 
 type acpFlags struct {
 	runConfig config.RuntimeConfig
+	sessionDB string
 }
 
 func newACPCmd() *cobra.Command {
@@ -31,6 +35,7 @@ func newACPCmd() *cobra.Command {
 	}
 
 	addRuntimeConfigFlags(cmd, &flags.runConfig)
+	cmd.PersistentFlags().StringVar(&flags.sessionDB, "session-db", filepath.Join(paths.GetDataDir(), "session.db"), "Path to the database the sessions are saved in, empty to not save them")
 
 	return cmd
 }
@@ -45,7 +50,13 @@ func (f *acpFlags) runACPCommand(cmd *cobra.Command, args []string) error {
 
 	slog.Debug("Starting ACP server", "agent_file", agentFilename)
 
-	acpAgent := acp.NewAgent(agentFilename, f.runConfig)
+	var sessionStore session.Store
+	if store := openSessionStore(f.sessionDB); store != nil {
+		defer store.Close()
+		sessionStore = store
+	}
+
+	acpAgent := acp.NewAgent(agentFilename, f.runConfig, sessionStore)
 	conn := acpsdk.NewAgentSideConnection(acpAgent, cmd.OutOrStdout(), cmd.InOrStdin())
 	conn.SetLogger(slog.Default())
 	acpAgent.SetAgentConnection(conn)
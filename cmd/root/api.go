@@ -10,6 +10,8 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/rumpl/rb/pkg/agentfile"
+	"github.com/rumpl/rb/pkg/artifacts"
+	"github.com/rumpl/rb/pkg/auth"
 	"github.com/rumpl/rb/pkg/config"
 	"github.com/rumpl/rb/pkg/remote"
 	"github.com/rumpl/rb/pkg/server"
@@ -21,6 +23,10 @@ type apiFlags struct {
 	listenAddr       string
 	sessionDB        string
 	pullIntervalMins int
+	authTokensFile   string
+	authJWKSFile     string
+	authJWTIssuer    string
+	authJWTAudience  string
 	runConfig        config.RuntimeConfig
 }
 
@@ -39,6 +45,10 @@ func newAPICmd() *cobra.Command {
 	cmd.PersistentFlags().StringVarP(&flags.listenAddr, "listen", "l", ":8080", "Address to listen on")
 	cmd.PersistentFlags().StringVarP(&flags.sessionDB, "session-db", "s", "session.db", "Path to the session database")
 	cmd.PersistentFlags().IntVar(&flags.pullIntervalMins, "pull-interval", 0, "Auto-pull OCI reference every N minutes (0 = disabled)")
+	cmd.PersistentFlags().StringVar(&flags.authTokensFile, "auth-tokens", "", "Require callers to authenticate with one of the bearer tokens of this YAML file")
+	cmd.PersistentFlags().StringVar(&flags.authJWKSFile, "auth-jwks", "", "Require callers to authenticate with a JWT signed by one of the keys of this JWKS file")
+	cmd.PersistentFlags().StringVar(&flags.authJWTIssuer, "auth-jwt-issuer", "", "Only accept the JWTs issued by this issuer")
+	cmd.PersistentFlags().StringVar(&flags.authJWTAudience, "auth-jwt-audience", "", "Only accept the JWTs issued for this audience")
 	addRuntimeConfigFlags(cmd, &flags.runConfig)
 
 	return cmd
@@ -73,13 +83,23 @@ func (f *apiFlags) runAPICommand(cmd *cobra.Command, args []string) error {
 
 	slog.Debug("Starting server", "agents", resolvedPath)
 
-	sessionStore, err := session.NewSQLiteSessionStore(f.sessionDB)
+	sessionStore, err := session.NewSQLiteSessionStore(f.sessionDB, session.WithToolOutputStore(artifacts.NewStore(artifacts.ToolOutputsDir())))
 	if err != nil {
 		return fmt.Errorf("failed to create session store: %w", err)
 	}
 
 	var opts []server.Opt
 
+	authenticator, err := f.authenticator()
+	if err != nil {
+		return err
+	}
+	if authenticator != nil {
+		opts = append(opts, server.WithAuthenticator(authenticator))
+	} else {
+		slog.Warn("Authentication is disabled, anyone who can reach the server can use it. Use --auth-tokens or --auth-jwks to enable it.")
+	}
+
 	stat, err := os.Stat(resolvedPath)
 	if err != nil {
 		return fmt.Errorf("failed to stat agents path: %w", err)
@@ -145,3 +165,31 @@ func (f *apiFlags) runAPICommand(cmd *cobra.Command, args []string) error {
 
 	return s.Serve(ctx, ln)
 }
+
+// authenticator returns the authenticator configured with the --auth-* flags, or nil if authentication is disabled
+func (f *apiFlags) authenticator() (auth.Authenticator, error) {
+	if (f.authJWTIssuer != "" || f.authJWTAudience != "") && f.authJWKSFile == "" {
+		return nil, fmt.Errorf("--auth-jwt-issuer and --auth-jwt-audience require --auth-jwks")
+	}
+
+	var authenticators []auth.Authenticator
+	if f.authTokensFile != "" {
+		tokens, err := auth.LoadTokens(f.authTokensFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tokens: %w", err)
+		}
+		authenticators = append(authenticators, tokens)
+	}
+	if f.authJWKSFile != "" {
+		jwt, err := auth.LoadJWKS(f.authJWKSFile, auth.WithIssuer(f.authJWTIssuer), auth.WithAudience(f.authJWTAudience))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JWKS: %w", err)
+		}
+		authenticators = append(authenticators, jwt)
+	}
+
+	if len(authenticators) == 0 {
+		return nil, nil
+	}
+	return auth.Chain(authenticators...), nil
+}
@@ -1,18 +1,28 @@
 package root
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/rumpl/rb/pkg/agentfile"
 	"github.com/rumpl/rb/pkg/config"
 	"github.com/rumpl/rb/pkg/evaluation"
+	"github.com/rumpl/rb/pkg/team"
 	"github.com/rumpl/rb/pkg/teamloader"
 )
 
 type evalFlags struct {
-	runConfig config.RuntimeConfig
+	runConfig   config.RuntimeConfig
+	concurrency int
+	repeat      int
+	junitReport string
+	jsonReport  string
 }
 
 func newEvalCmd() *cobra.Command {
@@ -27,6 +37,10 @@ func newEvalCmd() *cobra.Command {
 	}
 
 	addRuntimeConfigFlags(cmd, &flags.runConfig)
+	cmd.Flags().IntVar(&flags.concurrency, "concurrency", 1, "Number of evals to run at the same time")
+	cmd.Flags().IntVar(&flags.repeat, "repeat", 1, "Number of times to run every eval, to detect flaky ones")
+	cmd.Flags().StringVar(&flags.junitReport, "junit", "", "Write a JUnit XML report to this file")
+	cmd.Flags().StringVar(&flags.jsonReport, "json", "", "Write a JSON report to this file")
 
 	return cmd
 }
@@ -39,21 +53,87 @@ func (f *evalFlags) runEvalCommand(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	agents, err := teamloader.Load(cmd.Context(), agentFilename, f.runConfig)
+	// Check the agents before running the evals, every run then loads its own team
+	if _, err := teamloader.Load(ctx, agentFilename, f.runConfig); err != nil {
+		return err
+	}
+	loadTeam := func(ctx context.Context) (*team.Team, error) {
+		return teamloader.Load(ctx, agentFilename, f.runConfig)
+	}
+
+	judge, err := evaluation.LoadJudge(ctx, args[1], f.runConfig)
 	if err != nil {
 		return err
 	}
 
-	evalResults, err := evaluation.Evaluate(cmd.Context(), agents, args[1])
+	evalResults, err := evaluation.Evaluate(ctx, loadTeam, args[1],
+		evaluation.WithConcurrency(f.concurrency),
+		evaluation.WithRepeat(f.repeat),
+		evaluation.WithJudge(judge),
+	)
 	if err != nil {
 		return err
 	}
 
-	for _, evalResult := range evalResults {
-		fmt.Printf("Eval file: %s\n", evalResult.EvalFile)
-		fmt.Printf("Tool trajectory score: %f\n", evalResult.Score.ToolTrajectoryScore)
-		fmt.Printf("Rouge-1 score: %f\n", evalResult.Score.Rouge1Score)
+	out := cmd.OutOrStdout()
+	for i := range evalResults {
+		evalResult := &evalResults[i]
+		status := "PASS"
+		if !evalResult.Passed() {
+			status = "FAIL"
+		}
+		fmt.Fprintf(out, "%s %s (run %d, %s, $%.4f)\n", status, evalResult.EvalFile, evalResult.Run, evalResult.Duration.Round(time.Millisecond), evalResult.Cost)
+		if evalResult.Error != "" {
+			fmt.Fprintf(out, "  error: %s\n", evalResult.Error)
+		}
+		for _, assertion := range evalResult.Assertions {
+			if !assertion.Passed {
+				fmt.Fprintf(out, "  %s: %s\n", assertion.Assertion.String(), assertion.Message)
+			}
+		}
+		fmt.Fprintf(out, "  Tool trajectory score: %f\n", evalResult.Score.ToolTrajectoryScore)
+		fmt.Fprintf(out, "  Rouge-1 score: %f\n", evalResult.Score.Rouge1Score)
+		if judgment := evalResult.Judgment; judgment != nil {
+			if judgment.Error != "" {
+				fmt.Fprintf(out, "  Judge error: %s\n", judgment.Error)
+			} else {
+				fmt.Fprintf(out, "  Judge score: %f (%s)\n", judgment.Score, judgment.Rationale)
+			}
+		}
+	}
+
+	if err := writeReport(f.junitReport, evalResults, evaluation.WriteJUnitReport); err != nil {
+		return err
+	}
+	if err := writeReport(f.jsonReport, evalResults, evaluation.WriteJSONReport); err != nil {
+		return err
+	}
+
+	summary := evaluation.Summarize(evalResults)
+	fmt.Fprintf(out, "\n%d runs, %d passed, %d failed\n", summary.Runs, summary.Passed, summary.Failed)
+	if len(summary.Flaky) > 0 {
+		fmt.Fprintf(out, "Flaky: %s\n", strings.Join(summary.Flaky, ", "))
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d of %d eval runs failed", summary.Failed, summary.Runs)
 	}
 
 	return nil
 }
+
+// writeReport writes a report to a file, if a path is given
+func writeReport(path string, results []evaluation.Result, write func(io.Writer, []evaluation.Result) error) error {
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file, results); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -1,7 +1,17 @@
 package root
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
 	"github.com/spf13/cobra"
+
+	"github.com/rumpl/rb/pkg/runtime"
+	"github.com/rumpl/rb/pkg/session"
 )
 
 func newExecCmd() *cobra.Command {
@@ -10,11 +20,17 @@ func newExecCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "exec <agent-file>|<registry-ref>",
 		Short: "Execute an agent",
-		Long:  "Execute an agent (Single user message / No TUI)",
+		Long: `Execute an agent (Single user message / No TUI)
+
+The answer of the agent is printed on the standard output. Since nobody can
+confirm tool calls, they have to be approved with --yolo. The run stops when
+the agent reaches its maximum number of iterations, and exits with code 3
+when the session uses its cost or token budget.`,
 		Example: `  rb exec ./agent.yaml
   rb exec ./team.yaml --agent root
   rb exec ./echo.yaml "INSTRUCTIONS"
-  echo "INSTRUCTIONS" | rb exec ./echo.yaml -`,
+  echo "INSTRUCTIONS" | rb exec ./echo.yaml -
+  rb exec ./agent.yaml --yolo --max-cost 0.5 "Fix the tests"`,
 		GroupID: "core",
 		Args:    cobra.RangeArgs(1, 2),
 		RunE:    flags.runExecCommand,
@@ -27,6 +43,86 @@ func newExecCmd() *cobra.Command {
 }
 
 func (f *runExecFlags) runExecCommand(cmd *cobra.Command, args []string) error {
-	// TODO: ???
-	return f.run(cmd.Context(), args)
+	return f.run(cmd.Context(), args, execMode(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+}
+
+// execMode runs the agent without the TUI. Nobody can answer the questions of
+// the runtime, so the run is stopped instead.
+func execMode(stdout, stderr io.Writer) runMode {
+	return func(ctx context.Context, agentFilename string, rt runtime.Runtime, sess *session.Session, store session.Store, args []string) error {
+		firstMessage, err := readInitialMessage(args)
+		if err != nil {
+			return err
+		}
+		switch {
+		case firstMessage != nil:
+			sess.AddMessage(session.UserMessage(agentFilename, *firstMessage))
+		case len(sess.Messages) == 0:
+			sess.AddMessage(session.ImplicitUserMessage(agentFilename, "Follow the default instructions"))
+		}
+
+		// The runtime stops waiting for an answer when its context is cancelled
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var (
+			stopErr   error
+			runErr    error
+			lastChunk string
+		)
+		for event := range rt.RunStream(runCtx, sess) {
+			switch e := event.(type) {
+			case *runtime.AgentChoiceEvent:
+				fmt.Fprint(stdout, e.Content)
+				lastChunk = e.Content
+			case *runtime.ToolCallEvent:
+				fmt.Fprintf(stderr, "Calling %s\n", e.ToolCall.Function.Name)
+			case *runtime.WarningEvent:
+				fmt.Fprintf(stderr, "Warning: %s\n", e.Message)
+			case *runtime.ErrorEvent:
+				runErr = errors.New(e.Error)
+			case *runtime.ToolCallConfirmationEvent:
+				stopErr = fmt.Errorf("the tool call %s needs to be confirmed, run with --yolo to approve all the tool calls", e.ToolCall.Function.Name)
+				cancel()
+			case *runtime.ElicitationRequestEvent:
+				stopErr = errors.New("a tool asked for user input, which isn't possible with rb exec")
+				cancel()
+			case *runtime.MaxIterationsReachedEvent:
+				stopErr = fmt.Errorf("the agent reached its maximum number of iterations (%d)", e.MaxIterations)
+				cancel()
+			case *runtime.BudgetExceededEvent:
+				stopErr = ExitError{
+					Code: ExitCodeBudgetExceeded,
+					Err:  fmt.Errorf("the session used its %s budget (%s of %s)", e.Budget, runtime.FormatBudget(e.Budget, e.Used), runtime.FormatBudget(e.Budget, e.Limit)),
+				}
+				cancel()
+			}
+		}
+		if lastChunk != "" && !strings.HasSuffix(lastChunk, "\n") {
+			fmt.Fprintln(stdout)
+		}
+
+		saveSession(context.WithoutCancel(ctx), store, sess)
+
+		switch {
+		case stopErr != nil:
+			return stopErr
+		case runErr != nil:
+			fmt.Fprintf(stderr, "Error: %s\n", runErr)
+			return RuntimeError{Err: runErr}
+		default:
+			return ctx.Err()
+		}
+	}
+}
+
+// saveSession saves the session in the store, if any
+func saveSession(ctx context.Context, store session.Store, sess *session.Session) {
+	if store == nil || len(sess.Messages) == 0 {
+		return
+	}
+
+	if err := session.Save(ctx, store, sess); err != nil {
+		slog.Error("Failed to save session", "session_id", sess.ID, "error", err)
+	}
 }
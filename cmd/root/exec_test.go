@@ -0,0 +1,88 @@
+package root
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/agent"
+	latest "github.com/rumpl/rb/pkg/config/v2"
+	"github.com/rumpl/rb/pkg/model/provider/fake"
+	"github.com/rumpl/rb/pkg/modelsdev"
+	"github.com/rumpl/rb/pkg/runtime"
+	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/team"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+type noModelStore struct{}
+
+func (noModelStore) GetModel(context.Context, string) (*modelsdev.Model, error) {
+	return nil, nil
+}
+
+func newFakeRuntime(t *testing.T, script string) runtime.Runtime {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "script.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(script), 0o644))
+	model, err := fake.NewClient(t.Context(), &latest.ModelConfig{Provider: "fake", Model: path})
+	require.NoError(t, err)
+
+	echo := tools.Tool{
+		Name:       "echo",
+		Parameters: map[string]any{},
+		Handler: func(context.Context, tools.ToolCall) (*tools.ToolCallResult, error) {
+			return &tools.ToolCallResult{Output: "echo"}, nil
+		},
+	}
+	root := agent.New("root", "You are a test agent", agent.WithModel(model), agent.WithTools(echo))
+
+	rt, err := runtime.New(team.New(team.WithAgents(root)), runtime.WithModelStore(noModelStore{}), runtime.WithSessionCompaction(false))
+	require.NoError(t, err)
+	return rt
+}
+
+const echoScript = `
+turns:
+  - tool_calls:
+      - name: echo
+    usage: {input_tokens: 40, output_tokens: 20}
+  - content: Done
+    usage: {input_tokens: 70, output_tokens: 5}
+`
+
+func TestExecMode(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	sess := session.New(session.WithTitle("Exec"), session.WithToolsApproved(true))
+
+	err := execMode(&stdout, &stderr)(t.Context(), "agent.yaml", newFakeRuntime(t, echoScript), sess, nil, []string{"agent.yaml", "Say done"})
+	require.NoError(t, err)
+	assert.Equal(t, "Done\n", stdout.String())
+	assert.Equal(t, "Calling echo\n", stderr.String())
+}
+
+func TestExecModeBudgetExceeded(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	sess := session.New(session.WithTitle("Exec"), session.WithToolsApproved(true), session.WithMaxTokensTotal(50))
+
+	err := execMode(&stdout, &stderr)(t.Context(), "agent.yaml", newFakeRuntime(t, echoScript), sess, nil, []string{"agent.yaml", "Say done"})
+	var exitErr ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, ExitCodeBudgetExceeded, exitErr.Code)
+	assert.EqualError(t, err, "the session used its tokens budget (60 tokens of 50 tokens)")
+	assert.Empty(t, stdout.String())
+}
+
+func TestExecModeNeedsConfirmation(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	sess := session.New(session.WithTitle("Exec"))
+
+	err := execMode(&stdout, &stderr)(t.Context(), "agent.yaml", newFakeRuntime(t, echoScript), sess, nil, []string{"agent.yaml", "Say done"})
+	require.ErrorContains(t, err, "--yolo")
+}
@@ -21,6 +21,9 @@ func addRuntimeConfigFlags(cmd *cobra.Command, runConfig *config.RuntimeConfig)
 	addGatewayFlags(cmd, runConfig)
 	cmd.PersistentFlags().StringSliceVar(&runConfig.EnvFiles, "env-from-file", nil, "Set environment variables from file")
 	cmd.PersistentFlags().BoolVar(&runConfig.GlobalCodeMode, "code-mode-tools", false, "Provide a single tool to call other tools via Javascript")
+	cmd.PersistentFlags().StringVar(&runConfig.Cassette, "cassette", "", "Record the responses of the models to this file and replay them")
+	cmd.PersistentFlags().StringVar(&runConfig.CassetteMode, "cassette-mode", "replay", "How the cassette is used: replay (record what wasn't recorded yet), record (record everything again) or strict (fail on requests that weren't recorded)")
+	cmd.PersistentFlags().BoolVar(&runConfig.Sandbox, "sandbox", false, "Run shell and script tools in a sandbox with a read-only filesystem, except for the working directory, and no network (Linux only)")
 }
 
 func setupWorkingDirectory(workingDir string) error {
@@ -7,7 +7,9 @@ import (
 	"time"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
@@ -15,7 +17,8 @@ import (
 
 const AppName = "rb"
 
-// initOTelSDK initializes OpenTelemetry SDK with OTLP exporter
+// initOTelSDK initializes OpenTelemetry SDK with OTLP exporters for the traces
+// and the metrics
 func initOTelSDK(ctx context.Context) (err error) {
 	res, err := resource.Merge(
 		resource.Default(),
@@ -30,6 +33,7 @@ func initOTelSDK(ctx context.Context) (err error) {
 	}
 
 	var traceExporter trace.SpanExporter
+	var metricExporter sdkmetric.Exporter
 	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
 
 	// Only initialize if endpoint is configured
@@ -41,6 +45,14 @@ func initOTelSDK(ctx context.Context) (err error) {
 		if err != nil {
 			return fmt.Errorf("failed to create trace exporter: %w", err)
 		}
+
+		metricExporter, err = otlpmetrichttp.New(ctx,
+			otlpmetrichttp.WithEndpoint(endpoint),
+			otlpmetrichttp.WithInsecure(), // TODO: make configurable
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create metric exporter: %w", err)
+		}
 	}
 
 	// Configure tracer provider
@@ -59,9 +71,23 @@ func initOTelSDK(ctx context.Context) (err error) {
 	tp := trace.NewTracerProvider(tracerProviderOpts...)
 	otel.SetTracerProvider(tp)
 
+	// Configure meter provider
+	meterProviderOpts := []sdkmetric.Option{sdkmetric.WithResource(res)}
+	if metricExporter != nil {
+		meterProviderOpts = append(meterProviderOpts,
+			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter,
+				sdkmetric.WithInterval(30*time.Second),
+			)),
+		)
+	}
+
+	mp := sdkmetric.NewMeterProvider(meterProviderOpts...)
+	otel.SetMeterProvider(mp)
+
 	go func() {
 		<-ctx.Done()
 		_ = tp.Shutdown(context.Background())
+		_ = mp.Shutdown(context.Background())
 	}()
 
 	return nil
@@ -84,6 +84,7 @@ func NewRootCmd() *cobra.Command {
 	cmd.AddCommand(newMCPCmd())
 	cmd.AddCommand(newA2ACmd())
 	cmd.AddCommand(newEvalCmd())
+	cmd.AddCommand(newSessionCmd())
 	cmd.AddCommand(newPushCmd())
 	cmd.AddCommand(newPullCmd())
 
@@ -105,6 +106,7 @@ func Execute(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, arg
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		envErr := &environment.RequiredEnvError{}
 		runtimeErr := RuntimeError{}
+		exitErr := ExitError{}
 
 		switch {
 		case ctx.Err() != nil:
@@ -115,6 +117,8 @@ func Execute(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, arg
 				fmt.Fprintf(stderr, " - %s\n", v)
 			}
 			fmt.Fprintln(stderr, "\nEither:\n - Set those environment variables before running rb\n - Run rb with --env-from-file\n - Store those secrets using one of the built-in environment variable providers.")
+		case errors.As(err, &exitErr):
+			fmt.Fprintln(stderr, err)
 		case errors.As(err, &runtimeErr):
 			// Runtime errors have already been printed by the command itself
 			// Don't print them again or show usage
@@ -174,3 +178,20 @@ func (e RuntimeError) Error() string {
 func (e RuntimeError) Unwrap() error {
 	return e.Err
 }
+
+// ExitCodeBudgetExceeded is the exit code of rb exec when the session used its cost or token budget
+const ExitCodeBudgetExceeded = 3
+
+// ExitError is an error that exits rb with a specific code
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e ExitError) Unwrap() error {
+	return e.Err
+}
@@ -2,10 +2,12 @@ package root
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
 
 	tea "charm.land/bubbletea/v2"
 	"github.com/spf13/cobra"
@@ -13,7 +15,9 @@ import (
 
 	"github.com/rumpl/rb/pkg/agentfile"
 	"github.com/rumpl/rb/pkg/app"
+	"github.com/rumpl/rb/pkg/artifacts"
 	"github.com/rumpl/rb/pkg/config"
+	"github.com/rumpl/rb/pkg/paths"
 	"github.com/rumpl/rb/pkg/runtime"
 	"github.com/rumpl/rb/pkg/session"
 	"github.com/rumpl/rb/pkg/team"
@@ -27,10 +31,18 @@ type runExecFlags struct {
 	autoApprove    bool
 	attachmentPath string
 	remoteAddress  string
+	sessionDB      string
+	resumeID       string
+	continueLast   bool
 	modelOverrides []string
+	maxCost        float64
+	maxTokensTotal int
 	runConfig      config.RuntimeConfig
 }
 
+// runMode runs the agent once the runtime and the session are ready
+type runMode func(ctx context.Context, agentFilename string, rt runtime.Runtime, sess *session.Session, store session.Store, args []string) error
+
 func newRunCmd() *cobra.Command {
 	var flags runExecFlags
 
@@ -59,14 +71,20 @@ func addRunOrExecFlags(cmd *cobra.Command, flags *runExecFlags) {
 	cmd.PersistentFlags().BoolVar(&flags.autoApprove, "yolo", false, "Automatically approve all tool calls without prompting")
 	cmd.PersistentFlags().StringVar(&flags.attachmentPath, "attach", "", "Attach an image file to the message")
 	cmd.PersistentFlags().StringArrayVar(&flags.modelOverrides, "model", nil, "Override agent model: [agent=]provider/model (repeatable)")
-	cmd.PersistentFlags().StringVar(&flags.remoteAddress, "remote", "", "Use remote runtime with specified address")
+	cmd.PersistentFlags().StringVar(&flags.remoteAddress, "remote", "", "Use remote runtime with specified address (authenticate with the RB_API_TOKEN environment variable)")
+	cmd.PersistentFlags().StringVar(&flags.resumeID, "resume", "", "Resume the saved session with the given ID")
+	cmd.PersistentFlags().BoolVar(&flags.continueLast, "continue", false, "Continue the most recent saved session of the agent file")
+	cmd.MarkFlagsMutuallyExclusive("resume", "continue")
+	cmd.PersistentFlags().Float64Var(&flags.maxCost, "max-cost", 0, "Maximum cost of the session in dollars, overrides the agent's max_cost")
+	cmd.PersistentFlags().IntVar(&flags.maxTokensTotal, "max-tokens-total", 0, "Maximum number of tokens used by the session, overrides the agent's max_tokens_total")
+	cmd.PersistentFlags().StringVar(&flags.sessionDB, "session-db", filepath.Join(paths.GetDataDir(), "session.db"), "Path to the database the sessions are saved in, empty to not save them")
 }
 
 func (f *runExecFlags) runRunCommand(cmd *cobra.Command, args []string) error {
-	return f.run(cmd.Context(), args)
+	return f.run(cmd.Context(), args, handleRunMode)
 }
 
-func (f *runExecFlags) run(ctx context.Context, args []string) error {
+func (f *runExecFlags) run(ctx context.Context, args []string, mode runMode) error {
 	slog.Debug("Starting agent", "agent", f.agentName)
 
 	if err := f.setupWorkingDirectory(); err != nil {
@@ -77,8 +95,12 @@ func (f *runExecFlags) run(ctx context.Context, args []string) error {
 
 	var rt runtime.Runtime
 	var sess *session.Session
+	var sessionStore session.Store
 	var err error
 	switch {
+	case f.remoteAddress != "" && (f.resumeID != "" || f.continueLast):
+		return errors.New("--resume and --continue can't be used with --remote")
+
 	case f.remoteAddress != "":
 		agentFileName = args[0]
 		rt, sess, err = f.createRemoteRuntimeAndSession(ctx, agentFileName)
@@ -97,13 +119,39 @@ func (f *runExecFlags) run(ctx context.Context, args []string) error {
 			return err
 		}
 
-		rt, sess, err = f.createLocalRuntimeAndSession(t)
+		// Remote sessions are saved by the API server
+		if store := openSessionStore(f.sessionDB); store != nil {
+			defer store.Close()
+			sessionStore = store
+		}
+
+		rt, sess, err = f.createLocalRuntimeAndSession(ctx, t, sessionStore, agentFileName)
 		if err != nil {
 			return err
 		}
 	}
 
-	return handleRunMode(ctx, agentFileName, rt, sess, args)
+	return mode(ctx, agentFileName, rt, sess, sessionStore, args)
+}
+
+// openSessionStore opens the local session database. Failing to open it isn't
+// fatal, the sessions just aren't saved.
+func openSessionStore(path string) *session.SQLiteSessionStore {
+	if path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		slog.Warn("Failed to create the session database directory, sessions won't be saved", "path", path, "error", err)
+		return nil
+	}
+	store, err := session.NewSQLiteSessionStore(path, session.WithToolOutputStore(artifacts.NewStore(artifacts.ToolOutputsDir())))
+	if err != nil {
+		slog.Warn("Failed to open the session database, sessions won't be saved", "path", path, "error", err)
+		return nil
+	}
+
+	return store.(*session.SQLiteSessionStore)
 }
 
 func (f *runExecFlags) setupWorkingDirectory() error {
@@ -136,13 +184,21 @@ func (f *runExecFlags) loadAgentFrom(ctx context.Context, source teamloader.Agen
 }
 
 func (f *runExecFlags) createRemoteRuntimeAndSession(ctx context.Context, originalFilename string) (runtime.Runtime, *session.Session, error) {
-	remoteClient, err := runtime.NewClient(f.remoteAddress)
+	var clientOpts []runtime.ClientOption
+	if token := os.Getenv("RB_API_TOKEN"); token != "" {
+		clientOpts = append(clientOpts, runtime.WithToken(token))
+	}
+
+	remoteClient, err := runtime.NewClient(f.remoteAddress, clientOpts...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create remote client: %w", err)
 	}
 
+	// The server uses the budgets of the agent when they aren't set
 	sessTemplate := session.New(
 		session.WithToolsApproved(f.autoApprove),
+		session.WithMaxCost(f.maxCost),
+		session.WithMaxTokensTotal(f.maxTokensTotal),
 	)
 
 	sess, err := remoteClient.CreateSession(ctx, sessTemplate)
@@ -162,21 +218,31 @@ func (f *runExecFlags) createRemoteRuntimeAndSession(ctx context.Context, origin
 	return remoteRt, sess, nil
 }
 
-func (f *runExecFlags) createLocalRuntimeAndSession(t *team.Team) (runtime.Runtime, *session.Session, error) {
+func (f *runExecFlags) createLocalRuntimeAndSession(ctx context.Context, t *team.Team, store session.Store, agentFilename string) (runtime.Runtime, *session.Session, error) {
 	agent, err := t.Agent(f.agentName)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	sess := session.New(
-		session.WithMaxIterations(agent.MaxIterations()),
-		session.WithToolsApproved(f.autoApprove),
-	)
+	sess, err := f.savedSession(ctx, store, agentFilename)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sess == nil {
+		sess = session.New(
+			session.WithMaxIterations(agent.MaxIterations()),
+			session.WithMaxCost(agent.MaxCost()),
+			session.WithMaxTokensTotal(agent.MaxTokensTotal()),
+			session.WithToolsApproved(f.autoApprove),
+		)
+	}
+	f.applyBudgets(sess)
 
 	localRt, err := runtime.New(t,
 		runtime.WithCurrentAgent(f.agentName),
 		runtime.WithTracer(otel.Tracer(AppName)),
 		runtime.WithRootSessionID(sess.ID),
+		runtime.WithToolOutputStore(session.ToolOutputStore(store)),
 	)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create runtime: %w", err)
@@ -186,6 +252,51 @@ func (f *runExecFlags) createLocalRuntimeAndSession(t *team.Team) (runtime.Runti
 	return localRt, sess, nil
 }
 
+// savedSession returns the session to resume with --resume or --continue, or nil
+// to start a new session
+func (f *runExecFlags) savedSession(ctx context.Context, store session.Store, agentFilename string) (*session.Session, error) {
+	if f.resumeID == "" && !f.continueLast {
+		return nil, nil
+	}
+	if store == nil {
+		return nil, errors.New("can't resume a session: the session database isn't available")
+	}
+
+	var sess *session.Session
+	if f.resumeID != "" {
+		var err error
+		sess, err = store.GetSession(ctx, f.resumeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resume session %s: %w", f.resumeID, err)
+		}
+	} else {
+		sessions, err := store.GetSessionsByAgent(ctx, agentFilename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the sessions of %s: %w", agentFilename, err)
+		}
+		if len(sessions) == 0 {
+			return nil, fmt.Errorf("no saved session to continue for %s", agentFilename)
+		}
+		// Sessions are sorted from the most recent
+		sess = sessions[0]
+	}
+
+	sess.ToolsApproved = sess.ToolsApproved || f.autoApprove
+	slog.Debug("Resuming session", "session_id", sess.ID, "title", sess.Title)
+
+	return sess, nil
+}
+
+// applyBudgets overrides the budgets of the session with the ones of the command line
+func (f *runExecFlags) applyBudgets(sess *session.Session) {
+	if f.maxCost > 0 {
+		sess.MaxCost = f.maxCost
+	}
+	if f.maxTokensTotal > 0 {
+		sess.MaxTokensTotal = f.maxTokensTotal
+	}
+}
+
 func readInitialMessage(args []string) (*string, error) {
 	if len(args) < 2 {
 		return nil, nil
@@ -203,13 +314,18 @@ func readInitialMessage(args []string) (*string, error) {
 	return &args[1], nil
 }
 
-func handleRunMode(ctx context.Context, agentFilename string, rt runtime.Runtime, sess *session.Session, args []string) error {
+func handleRunMode(ctx context.Context, agentFilename string, rt runtime.Runtime, sess *session.Session, store session.Store, args []string) error {
 	firstMessage, err := readInitialMessage(args)
 	if err != nil {
 		return err
 	}
 
-	a := app.New(agentFilename, rt, sess, firstMessage)
+	var appOpts []app.Opt
+	if store != nil {
+		appOpts = append(appOpts, app.WithSessionStore(store))
+	}
+
+	a := app.New(agentFilename, rt, sess, firstMessage, appOpts...)
 	m := tui.New(a)
 
 	progOpts := []tea.ProgramOption{tea.WithContext(ctx)}
@@ -0,0 +1,52 @@
+package root
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/session"
+)
+
+func TestSavedSession(t *testing.T) {
+	store, err := session.NewSQLiteSessionStore(filepath.Join(t.TempDir(), "session.db"))
+	require.NoError(t, err)
+
+	older := session.New(session.WithUserMessage("/agents/pirate.yaml", "Ahoy"))
+	older.CreatedAt = time.Now().Add(-time.Hour)
+	newer := session.New(session.WithUserMessage("/agents/pirate.yaml", "Arr"))
+	other := session.New(session.WithUserMessage("/agents/other.yaml", "Hello"))
+	for _, sess := range []*session.Session{older, newer, other} {
+		require.NoError(t, store.AddSession(t.Context(), sess))
+	}
+
+	f := runExecFlags{}
+	sess, err := f.savedSession(t.Context(), store, "/agents/pirate.yaml")
+	require.NoError(t, err)
+	assert.Nil(t, sess)
+
+	f = runExecFlags{continueLast: true, autoApprove: true}
+	sess, err = f.savedSession(t.Context(), store, "/agents/pirate.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, newer.ID, sess.ID)
+	assert.True(t, sess.ToolsApproved)
+
+	f = runExecFlags{resumeID: older.ID}
+	sess, err = f.savedSession(t.Context(), store, "/agents/pirate.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, older.ID, sess.ID)
+	assert.False(t, sess.ToolsApproved)
+
+	f = runExecFlags{resumeID: "missing"}
+	_, err = f.savedSession(t.Context(), store, "/agents/pirate.yaml")
+	require.ErrorIs(t, err, session.ErrNotFound)
+
+	f = runExecFlags{continueLast: true}
+	_, err = f.savedSession(t.Context(), store, "/agents/unknown.yaml")
+	require.Error(t, err)
+	_, err = f.savedSession(t.Context(), nil, "/agents/pirate.yaml")
+	require.Error(t, err)
+}
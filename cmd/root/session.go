@@ -0,0 +1,112 @@
+package root
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rumpl/rb/pkg/paths"
+	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/session/export"
+)
+
+type sessionFlags struct {
+	sessionDB string
+	format    string
+	output    string
+}
+
+func newSessionCmd() *cobra.Command {
+	var flags sessionFlags
+
+	cmd := &cobra.Command{
+		Use:     "session",
+		Short:   "Export and import saved sessions",
+		GroupID: "advanced",
+	}
+	cmd.PersistentFlags().StringVar(&flags.sessionDB, "session-db", filepath.Join(paths.GetDataDir(), "session.db"), "Path to the database the sessions are saved in")
+
+	exportCmd := &cobra.Command{
+		Use:   "export <session-id>",
+		Short: "Export a session as JSON, Markdown or HTML",
+		Long:  "Export a saved session. JSON exports can be imported back with `rb session import` or used as eval files with `rb eval`.",
+		Args:  cobra.ExactArgs(1),
+		RunE:  flags.runExportCommand,
+	}
+	exportCmd.Flags().StringVarP(&flags.format, "format", "f", string(export.FormatJSON), "Export format: json, markdown or html")
+	exportCmd.Flags().StringVar(&flags.output, "output", "", "File to write the export to (default: stdout)")
+
+	importCmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import a session exported as JSON",
+		Args:  cobra.ExactArgs(1),
+		RunE:  flags.runImportCommand,
+	}
+
+	cmd.AddCommand(exportCmd, importCmd)
+
+	return cmd
+}
+
+func (f *sessionFlags) runExportCommand(cmd *cobra.Command, args []string) error {
+	format, err := export.ParseFormat(f.format)
+	if err != nil {
+		return err
+	}
+
+	store, err := session.NewSQLiteSessionStore(f.sessionDB)
+	if err != nil {
+		return fmt.Errorf("failed to open the session database: %w", err)
+	}
+	defer store.(*session.SQLiteSessionStore).Close()
+
+	sess, err := store.GetSession(cmd.Context(), args[0])
+	if err != nil {
+		return fmt.Errorf("failed to get session %s: %w", args[0], err)
+	}
+
+	if f.output == "" {
+		return export.Write(cmd.OutOrStdout(), sess, format)
+	}
+
+	file, err := os.Create(f.output)
+	if err != nil {
+		return err
+	}
+	if err := export.Write(file, sess, format); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (f *sessionFlags) runImportCommand(cmd *cobra.Command, args []string) error {
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	sess, err := export.Read(file)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.sessionDB), 0o755); err != nil {
+		return err
+	}
+	store, err := session.NewSQLiteSessionStore(f.sessionDB)
+	if err != nil {
+		return fmt.Errorf("failed to open the session database: %w", err)
+	}
+	defer store.(*session.SQLiteSessionStore).Close()
+
+	if err := export.Import(cmd.Context(), store, sess); err != nil {
+		return fmt.Errorf("failed to import session: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Imported session %s\n", sess.ID)
+	return nil
+}
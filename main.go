@@ -2,18 +2,32 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/rumpl/rb/cmd/root"
+	"github.com/rumpl/rb/pkg/sandbox"
 )
 
 func main() {
+	// Sandboxed tool commands are started through rb, see pkg/sandbox
+	if len(os.Args) > 1 && os.Args[1] == sandbox.ExecArg {
+		err := sandbox.Main(os.Args[2:])
+		fmt.Fprintln(os.Stderr, "rb sandbox:", err)
+		os.Exit(126)
+	}
+
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 
 	if err := root.Execute(ctx, os.Stdin, os.Stdout, os.Stderr, os.Args[1:]...); err != nil {
 		cancel()
+		var exitErr root.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		os.Exit(1)
 	} else {
 		cancel()
@@ -3,12 +3,12 @@ package acp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
 
 	"github.com/coder/acp-go-sdk"
-	"github.com/google/uuid"
 
 	"github.com/rumpl/rb/pkg/config"
 	"github.com/rumpl/rb/pkg/runtime"
@@ -24,8 +24,15 @@ type Agent struct {
 	team          *team.Team
 	agentFilename string
 	runtimeConfig config.RuntimeConfig
+	sessionStore  session.Store
 	sessions      map[string]*Session
 	mu            sync.Mutex
+
+	// clientCapabilities are the file and terminal capabilities of the client
+	clientCapabilities acp.ClientCapabilities
+	// terminals maps the tool calls that run in a terminal of the client to their terminal
+	terminals   map[string]string
+	terminalsMu sync.Mutex
 }
 
 var _ acp.Agent = (*Agent)(nil)
@@ -36,14 +43,21 @@ type Session struct {
 	sess   *session.Session
 	rt     runtime.Runtime
 	cancel context.CancelFunc
+	// turn counts the turns, a turn only resets cancel if no other turn replaced it
+	turn int
+	// pendingMode is the mode set during a turn, it's applied once the turn is over
+	pendingMode acp.SessionModeId
 }
 
-// NewAgent creates a new ACP agent
-func NewAgent(agentFilename string, runtimeConfig config.RuntimeConfig) *Agent {
+// NewAgent creates a new ACP agent, its sessions are saved in the session
+// store so that they can be loaded again. A nil store doesn't save them.
+func NewAgent(agentFilename string, runtimeConfig config.RuntimeConfig, sessionStore session.Store) *Agent {
 	return &Agent{
 		agentFilename: agentFilename,
 		runtimeConfig: runtimeConfig,
+		sessionStore:  sessionStore,
 		sessions:      make(map[string]*Session),
+		terminals:     make(map[string]string),
 	}
 }
 
@@ -69,6 +83,7 @@ func (a *Agent) Initialize(ctx context.Context, params acp.InitializeRequest) (a
 
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	a.clientCapabilities = params.ClientCapabilities
 	slog.Debug("Loading teams", "agent_file", a.agentFilename)
 	t, err := teamloader.Load(ctx, a.agentFilename, a.runtimeConfig, teamloader.WithToolsetRegistry(createToolsetRegistry(a)))
 	if err != nil {
@@ -80,7 +95,7 @@ func (a *Agent) Initialize(ctx context.Context, params acp.InitializeRequest) (a
 	return acp.InitializeResponse{
 		ProtocolVersion: acp.ProtocolVersionNumber,
 		AgentCapabilities: acp.AgentCapabilities{
-			LoadSession: false,
+			LoadSession: a.sessionStore != nil,
 			PromptCapabilities: acp.PromptCapabilities{
 				EmbeddedContext: true,
 			},
@@ -90,23 +105,41 @@ func (a *Agent) Initialize(ctx context.Context, params acp.InitializeRequest) (a
 
 // NewSession implements [acp.Agent]
 func (a *Agent) NewSession(ctx context.Context, params acp.NewSessionRequest) (acp.NewSessionResponse, error) {
-	sid := uuid.New().String()
-	slog.Debug("ACP NewSession called", "session_id", sid)
+	sess := session.New(session.WithWorkingDir(params.Cwd))
+	sess.Title = "ACP Session " + sess.ID
+	slog.Debug("ACP NewSession called", "session_id", sess.ID)
+
+	if _, err := a.addSession(sess); err != nil {
+		return acp.NewSessionResponse{}, err
+	}
+
+	return acp.NewSessionResponse{
+		SessionId: acp.SessionId(sess.ID),
+		Modes:     sessionModes(sess),
+	}, nil
+}
 
-	rt, err := runtime.New(a.team, runtime.WithCurrentAgent("root"))
+// addSession creates the runtime of a session and registers it
+func (a *Agent) addSession(sess *session.Session) (*Session, error) {
+	rt, err := runtime.New(a.team,
+		runtime.WithCurrentAgent("root"),
+		runtime.WithToolOutputStore(session.ToolOutputStore(a.sessionStore)),
+	)
 	if err != nil {
-		return acp.NewSessionResponse{}, fmt.Errorf("failed to create runtime: %w", err)
+		return nil, fmt.Errorf("failed to create runtime: %w", err)
 	}
 
-	a.mu.Lock()
-	a.sessions[sid] = &Session{
-		id:   sid,
-		sess: session.New(session.WithTitle("ACP Session " + sid)),
+	acpSess := &Session{
+		id:   sess.ID,
+		sess: sess,
 		rt:   rt,
 	}
+
+	a.mu.Lock()
+	a.sessions[sess.ID] = acpSess
 	a.mu.Unlock()
 
-	return acp.NewSessionResponse{SessionId: acp.SessionId(sid)}, nil
+	return acpSess, nil
 }
 
 // Authenticate implements [acp.Agent]
@@ -115,10 +148,39 @@ func (a *Agent) Authenticate(ctx context.Context, params acp.AuthenticateRequest
 	return acp.AuthenticateResponse{}, nil
 }
 
-// LoadSession implements [acp.Agent] (optional, not supported)
+// LoadSession implements [acp.Agent], it restores a saved session and replays
+// its history to the client
 func (a *Agent) LoadSession(ctx context.Context, params acp.LoadSessionRequest) (acp.LoadSessionResponse, error) {
-	slog.Debug("ACP LoadSession called (not supported)")
-	return acp.LoadSessionResponse{}, fmt.Errorf("load session not supported")
+	sid := string(params.SessionId)
+	slog.Debug("ACP LoadSession called", "session_id", sid)
+
+	if a.sessionStore == nil {
+		return acp.LoadSessionResponse{}, errors.New("load session not supported, sessions aren't saved")
+	}
+
+	sess, err := a.sessionStore.GetSession(ctx, sid)
+	if err != nil {
+		return acp.LoadSessionResponse{}, fmt.Errorf("failed to load session %s: %w", sid, err)
+	}
+	if params.Cwd != "" {
+		sess.WorkingDir = params.Cwd
+	}
+
+	acpSess, err := a.addSession(sess)
+	if err != nil {
+		return acp.LoadSessionResponse{}, err
+	}
+
+	for _, update := range historyUpdates(sess) {
+		if err := a.conn.SessionUpdate(ctx, acp.SessionNotification{
+			SessionId: acp.SessionId(acpSess.id),
+			Update:    update,
+		}); err != nil {
+			return acp.LoadSessionResponse{}, err
+		}
+	}
+
+	return acp.LoadSessionResponse{Modes: sessionModes(sess)}, nil
 }
 
 // Cancel implements [acp.Agent]
@@ -164,7 +226,25 @@ func (a *Agent) Prompt(ctx context.Context, params acp.PromptRequest) (acp.Promp
 	turnCtx, cancel := context.WithCancel(context.Background())
 	a.mu.Lock()
 	acpSess.cancel = cancel
+	acpSess.turn++
+	turn := acpSess.turn
+	mode := applyPendingMode(acpSess)
 	a.mu.Unlock()
+	a.sendModeUpdate(ctx, acpSess, mode)
+
+	defer func() {
+		cancel()
+
+		var mode acp.SessionModeId
+		a.mu.Lock()
+		if acpSess.turn == turn {
+			acpSess.cancel = nil
+			mode = applyPendingMode(acpSess)
+		}
+		a.mu.Unlock()
+		a.sendModeUpdate(ctx, acpSess, mode)
+		a.saveSession(ctx, acpSess)
+	}()
 
 	// Add the user message to the session
 	var userContent string
@@ -193,19 +273,77 @@ func (a *Agent) Prompt(ctx context.Context, params acp.PromptRequest) (acp.Promp
 		return acp.PromptResponse{}, err
 	}
 
-	a.mu.Lock()
-	acpSess.cancel = nil
-	a.mu.Unlock()
-
 	return acp.PromptResponse{StopReason: acp.StopReasonEndTurn}, nil
 }
 
-// SetSessionMode implements acp.Agent (optional)
+// SetSessionMode implements [acp.Agent], the modes decide whether the tool
+// calls need the approval of the user
 func (a *Agent) SetSessionMode(ctx context.Context, params acp.SetSessionModeRequest) (acp.SetSessionModeResponse, error) {
-	// We don't implement session modes, rb agents have only one mode (for now? ;) ).
+	sid := string(params.SessionId)
+	slog.Debug("ACP SetSessionMode called", "session_id", sid, "mode", params.ModeId)
+
+	if params.ModeId != modeAsk && params.ModeId != modeAutoApprove {
+		return acp.SetSessionModeResponse{}, fmt.Errorf("unknown session mode %q", params.ModeId)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	acpSess, ok := a.sessions[sid]
+	if !ok {
+		return acp.SetSessionModeResponse{}, fmt.Errorf("session %s not found", sid)
+	}
+
+	// The runtime reads the session during a turn, the mode changes once it's over
+	acpSess.pendingMode = params.ModeId
+	if acpSess.cancel == nil {
+		applyPendingMode(acpSess)
+		a.saveSession(ctx, acpSess)
+	}
+
 	return acp.SetSessionModeResponse{}, nil
 }
 
+// applyPendingMode changes the tool approval of the session to the mode set
+// during the last turn, if any, and returns that mode. It must be called with
+// the agent's lock held and while no turn is running.
+func applyPendingMode(acpSess *Session) acp.SessionModeId {
+	mode := acpSess.pendingMode
+	switch mode {
+	case modeAsk:
+		acpSess.sess.ToolsApproved = false
+	case modeAutoApprove:
+		acpSess.sess.ToolsApproved = true
+	}
+	acpSess.pendingMode = ""
+	return mode
+}
+
+// sendModeUpdate tells the client that the mode set during a turn was applied
+func (a *Agent) sendModeUpdate(ctx context.Context, acpSess *Session, mode acp.SessionModeId) {
+	if mode == "" {
+		return
+	}
+
+	if err := a.conn.SessionUpdate(ctx, acp.SessionNotification{
+		SessionId: acp.SessionId(acpSess.id),
+		Update:    acp.SessionUpdate{CurrentModeUpdate: &acp.SessionCurrentModeUpdate{CurrentModeId: mode}},
+	}); err != nil {
+		slog.Error("Failed to send the session mode update", "session_id", acpSess.id, "error", err)
+	}
+}
+
+// saveSession saves the session in the session store, if any
+func (a *Agent) saveSession(ctx context.Context, acpSess *Session) {
+	if a.sessionStore == nil || len(acpSess.sess.Messages) == 0 {
+		return
+	}
+
+	if err := session.Save(ctx, a.sessionStore, acpSess.sess); err != nil {
+		slog.Error("Failed to save session", "session_id", acpSess.id, "error", err)
+	}
+}
+
 // runAgent runs a single agent loop and streams updates to the ACP client
 func (a *Agent) runAgent(ctx context.Context, acpSess *Session) error {
 	slog.Debug("Running agent turn", "session_id", acpSess.id)
@@ -244,7 +382,7 @@ func (a *Agent) runAgent(ctx context.Context, acpSess *Session) error {
 		case *runtime.ToolCallResponseEvent:
 			if err := a.conn.SessionUpdate(ctx, acp.SessionNotification{
 				SessionId: acp.SessionId(acpSess.id),
-				Update:    buildToolCallComplete(e.ToolCall, e.Response),
+				Update:    buildToolCallComplete(e.ToolCall, e.Response, a.takeTerminal(e.ToolCall.ID)),
 			}); err != nil {
 				return err
 			}
@@ -261,6 +399,11 @@ func (a *Agent) runAgent(ctx context.Context, acpSess *Session) error {
 			if err := a.handleMaxIterationsReached(ctx, acpSess, e); err != nil {
 				return err
 			}
+
+		case *runtime.BudgetExceededEvent:
+			if err := a.handleBudgetExceeded(ctx, acpSess, e); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -282,9 +425,14 @@ func (a *Agent) handleToolCallConfirmation(ctx context.Context, acpSess *Session
 			},
 			{
 				Kind:     acp.PermissionOptionKindAllowAlways,
-				Name:     "Allow and remember my choice",
+				Name:     "Always allow this action with these arguments in this session",
 				OptionId: acp.PermissionOptionId("allow-always"),
 			},
+			{
+				Kind:     acp.PermissionOptionKindAllowAlways,
+				Name:     fmt.Sprintf("Always allow %s in this session, whatever its arguments", e.ToolCall.Function.Name),
+				OptionId: acp.PermissionOptionId("allow-tool"),
+			},
 			{
 				Kind:     acp.PermissionOptionKindRejectOnce,
 				Name:     "Skip this action",
@@ -310,7 +458,9 @@ func (a *Agent) handleToolCallConfirmation(ctx context.Context, acpSess *Session
 	case "allow":
 		acpSess.rt.Resume(ctx, runtime.ResumeTypeApprove)
 	case "allow-always":
-		acpSess.rt.Resume(ctx, runtime.ResumeTypeApproveSession)
+		acpSess.rt.Resume(ctx, runtime.ResumeTypeApproveCall)
+	case "allow-tool":
+		acpSess.rt.Resume(ctx, runtime.ResumeTypeApproveTool)
 	case "reject":
 		acpSess.rt.Resume(ctx, runtime.ResumeTypeReject)
 	default:
@@ -322,11 +472,22 @@ func (a *Agent) handleToolCallConfirmation(ctx context.Context, acpSess *Session
 
 // handleMaxIterationsReached handles max iterations events
 func (a *Agent) handleMaxIterationsReached(ctx context.Context, acpSess *Session, e *runtime.MaxIterationsReachedEvent) error {
+	return a.askToContinue(ctx, acpSess, "max_iterations", fmt.Sprintf("Maximum iterations (%d) reached", e.MaxIterations))
+}
+
+// handleBudgetExceeded handles budget exceeded events
+func (a *Agent) handleBudgetExceeded(ctx context.Context, acpSess *Session, e *runtime.BudgetExceededEvent) error {
+	title := fmt.Sprintf("Session %s budget (%s) used", e.Budget, runtime.FormatBudget(e.Budget, e.Limit))
+	return a.askToContinue(ctx, acpSess, "budget_exceeded", title)
+}
+
+// askToContinue asks the client whether the paused runtime should continue or stop
+func (a *Agent) askToContinue(ctx context.Context, acpSess *Session, id, title string) error {
 	permResp, err := a.conn.RequestPermission(ctx, acp.RequestPermissionRequest{
 		SessionId: acp.SessionId(acpSess.id),
 		ToolCall: acp.RequestPermissionToolCall{
-			ToolCallId: acp.ToolCallId("max_iterations"),
-			Title:      acp.Ptr(fmt.Sprintf("Maximum iterations (%d) reached", e.MaxIterations)),
+			ToolCallId: acp.ToolCallId(id),
+			Title:      acp.Ptr(title),
 			Kind:       acp.Ptr(acp.ToolKindExecute),
 			Status:     acp.Ptr(acp.ToolCallStatusPending),
 		},
@@ -379,12 +540,34 @@ func buildToolCallStart(toolCall tools.ToolCall, tool tools.Tool) acp.SessionUpd
 	)
 }
 
-// buildToolCallComplete creates a tool call completion update
-func buildToolCallComplete(toolCall tools.ToolCall, output string) acp.SessionUpdate {
+// addTerminal records the terminal of the client a tool call runs in
+func (a *Agent) addTerminal(toolCallID, terminalID string) {
+	a.terminalsMu.Lock()
+	defer a.terminalsMu.Unlock()
+	a.terminals[toolCallID] = terminalID
+}
+
+// takeTerminal returns and forgets the terminal a tool call ran in, if any
+func (a *Agent) takeTerminal(toolCallID string) string {
+	a.terminalsMu.Lock()
+	defer a.terminalsMu.Unlock()
+	terminalID := a.terminals[toolCallID]
+	delete(a.terminals, toolCallID)
+	return terminalID
+}
+
+// buildToolCallComplete creates a tool call completion update, the terminal
+// the tool call ran in, if any, stays in the content of the tool call
+func buildToolCallComplete(toolCall tools.ToolCall, output, terminalID string) acp.SessionUpdate {
+	content := []acp.ToolCallContent{acp.ToolContent(acp.TextBlock(output))}
+	if terminalID != "" {
+		content = []acp.ToolCallContent{acp.ToolTerminalRef(terminalID)}
+	}
+
 	return acp.UpdateToolCall(
 		acp.ToolCallId(toolCall.ID),
 		acp.WithUpdateStatus(acp.ToolCallStatusCompleted),
-		acp.WithUpdateContent([]acp.ToolCallContent{acp.ToolContent(acp.TextBlock(output))}),
+		acp.WithUpdateContent(content),
 		acp.WithUpdateRawOutput(map[string]any{"content": output}),
 	)
 }
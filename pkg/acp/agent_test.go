@@ -0,0 +1,308 @@
+package acp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coder/acp-go-sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/config"
+	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+// testClient records the session updates it receives, serves files from
+// memory and runs every command in a terminal that prints output
+type testClient struct {
+	mu      sync.Mutex
+	updates []acp.SessionUpdate
+
+	files    map[string]string
+	output   string
+	exitCode int
+	terminal *acp.CreateTerminalRequest
+	released bool
+}
+
+func (c *testClient) SessionUpdate(_ context.Context, params acp.SessionNotification) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updates = append(c.updates, params.Update)
+	return nil
+}
+
+func (c *testClient) Updates() []acp.SessionUpdate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]acp.SessionUpdate(nil), c.updates...)
+}
+
+func (c *testClient) ReadTextFile(_ context.Context, params acp.ReadTextFileRequest) (acp.ReadTextFileResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	content, ok := c.files[params.Path]
+	if !ok {
+		return acp.ReadTextFileResponse{}, errors.New("file not found")
+	}
+	return acp.ReadTextFileResponse{Content: content}, nil
+}
+
+func (c *testClient) WriteTextFile(_ context.Context, params acp.WriteTextFileRequest) (acp.WriteTextFileResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[params.Path] = params.Content
+	return acp.WriteTextFileResponse{}, nil
+}
+
+func (c *testClient) RequestPermission(context.Context, acp.RequestPermissionRequest) (acp.RequestPermissionResponse, error) {
+	return acp.RequestPermissionResponse{}, errors.New("not supported")
+}
+
+func (c *testClient) CreateTerminal(_ context.Context, params acp.CreateTerminalRequest) (acp.CreateTerminalResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.terminal = &params
+	return acp.CreateTerminalResponse{TerminalId: "term_1"}, nil
+}
+
+func (c *testClient) KillTerminalCommand(context.Context, acp.KillTerminalCommandRequest) (acp.KillTerminalCommandResponse, error) {
+	return acp.KillTerminalCommandResponse{}, nil
+}
+
+func (c *testClient) TerminalOutput(context.Context, acp.TerminalOutputRequest) (acp.TerminalOutputResponse, error) {
+	return acp.TerminalOutputResponse{Output: c.output}, nil
+}
+
+func (c *testClient) ReleaseTerminal(context.Context, acp.ReleaseTerminalRequest) (acp.ReleaseTerminalResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.released = true
+	return acp.ReleaseTerminalResponse{}, nil
+}
+
+func (c *testClient) WaitForTerminalExit(context.Context, acp.WaitForTerminalExitRequest) (acp.WaitForTerminalExitResponse, error) {
+	return acp.WaitForTerminalExitResponse{ExitCode: acp.Ptr(c.exitCode)}, nil
+}
+
+const testAgent = `
+agents:
+  root:
+    model: scripted
+    instruction: You are a test agent
+models:
+  scripted:
+    provider: fake
+    model: script.yaml
+`
+
+// connect connects a test client to an initialized agent that uses the session store
+func connect(t *testing.T, store session.Store, capabilities acp.ClientCapabilities) (*acp.ClientSideConnection, *testClient, *Agent) {
+	t.Helper()
+
+	dir := t.TempDir()
+	agentFile := filepath.Join(dir, "agent.yaml")
+	require.NoError(t, os.WriteFile(agentFile, []byte(testAgent), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "script.yaml"), []byte("turns: []\n"), 0o644))
+
+	clientReader, agentWriter := io.Pipe()
+	agentReader, clientWriter := io.Pipe()
+	t.Cleanup(func() {
+		_ = agentWriter.Close()
+		_ = clientWriter.Close()
+	})
+
+	a := NewAgent(agentFile, config.RuntimeConfig{}, store)
+	a.SetAgentConnection(acp.NewAgentSideConnection(a, agentWriter, agentReader))
+	t.Cleanup(func() { a.Stop(context.WithoutCancel(t.Context())) })
+
+	client := &testClient{files: map[string]string{}}
+	conn := acp.NewClientSideConnection(client, clientWriter, clientReader)
+
+	init, err := conn.Initialize(t.Context(), acp.InitializeRequest{ProtocolVersion: acp.ProtocolVersionNumber, ClientCapabilities: capabilities})
+	require.NoError(t, err)
+	assert.Equal(t, store != nil, init.AgentCapabilities.LoadSession)
+
+	return conn, client, a
+}
+
+// toJSON marshals session updates, the updates sent and received differ only
+// in the fields the SDK fills when it marshals them
+func toJSON(t *testing.T, updates []acp.SessionUpdate) []string {
+	t.Helper()
+
+	var out []string
+	for _, update := range updates {
+		buf, err := json.Marshal(update)
+		require.NoError(t, err)
+		out = append(out, string(buf))
+	}
+	return out
+}
+
+func TestSessionModes(t *testing.T) {
+	store, err := session.NewSQLiteSessionStore(filepath.Join(t.TempDir(), "session.db"))
+	require.NoError(t, err)
+	conn, _, _ := connect(t, store, acp.ClientCapabilities{})
+
+	resp, err := conn.NewSession(t.Context(), acp.NewSessionRequest{Cwd: t.TempDir(), McpServers: []acp.McpServer{}})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Modes)
+	assert.Equal(t, modeAsk, resp.Modes.CurrentModeId)
+	assert.Len(t, resp.Modes.AvailableModes, 2)
+
+	_, err = conn.SetSessionMode(t.Context(), acp.SetSessionModeRequest{SessionId: resp.SessionId, ModeId: modeAutoApprove})
+	require.NoError(t, err)
+
+	_, err = conn.SetSessionMode(t.Context(), acp.SetSessionModeRequest{SessionId: resp.SessionId, ModeId: "yolo"})
+	require.Error(t, err)
+
+	_, err = conn.SetSessionMode(t.Context(), acp.SetSessionModeRequest{SessionId: "unknown", ModeId: modeAsk})
+	require.Error(t, err)
+}
+
+func TestSessionModeDuringTurn(t *testing.T) {
+	conn, client, a := connect(t, nil, acp.ClientCapabilities{})
+
+	resp, err := conn.NewSession(t.Context(), acp.NewSessionRequest{Cwd: t.TempDir(), McpServers: []acp.McpServer{}})
+	require.NoError(t, err)
+
+	a.mu.Lock()
+	acpSess := a.sessions[string(resp.SessionId)]
+	acpSess.cancel = func() {}
+	a.mu.Unlock()
+
+	_, err = conn.SetSessionMode(t.Context(), acp.SetSessionModeRequest{SessionId: resp.SessionId, ModeId: modeAutoApprove})
+	require.NoError(t, err)
+
+	a.mu.Lock()
+	assert.False(t, acpSess.sess.ToolsApproved)
+	acpSess.cancel = nil
+	a.mu.Unlock()
+
+	_, err = conn.Prompt(t.Context(), acp.PromptRequest{SessionId: resp.SessionId, Prompt: []acp.ContentBlock{acp.TextBlock("hello")}})
+	require.NoError(t, err)
+
+	a.mu.Lock()
+	assert.True(t, acpSess.sess.ToolsApproved)
+	assert.Nil(t, acpSess.cancel)
+	a.mu.Unlock()
+
+	// The client learns that the mode changed
+	modeUpdate := toJSON(t, []acp.SessionUpdate{{CurrentModeUpdate: &acp.SessionCurrentModeUpdate{CurrentModeId: modeAutoApprove}}})[0]
+	require.Eventually(t, func() bool { return slices.Contains(toJSON(t, client.Updates()), modeUpdate) }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestLoadSession(t *testing.T) {
+	store, err := session.NewSQLiteSessionStore(filepath.Join(t.TempDir(), "session.db"))
+	require.NoError(t, err)
+
+	toolCall := tools.ToolCall{ID: "call_1", Type: "function", Function: tools.FunctionCall{Name: "shell", Arguments: `{"cmd":"ls"}`}}
+	sess := session.New(session.WithToolsApproved(true))
+	sess.AddMessage(session.UserMessage("agent.yaml", "List the files"))
+	sess.AddMessage(session.ImplicitUserMessage("agent.yaml", "hidden"))
+	sess.AddMessage(&session.Message{AgentName: "root", Message: chat.Message{
+		Role:             chat.MessageRoleAssistant,
+		ReasoningContent: "The user wants a file listing",
+		ToolCalls:        []tools.ToolCall{toolCall},
+	}})
+	sess.AddMessage(&session.Message{AgentName: "root", Message: chat.Message{Role: chat.MessageRoleTool, ToolCallID: "call_1", Content: "main.go"}})
+	sess.AddMessage(&session.Message{AgentName: "root", Message: chat.Message{Role: chat.MessageRoleAssistant, Content: "There is one file"}})
+	require.NoError(t, store.AddSession(t.Context(), sess))
+
+	conn, client, _ := connect(t, store, acp.ClientCapabilities{})
+
+	resp, err := conn.LoadSession(t.Context(), acp.LoadSessionRequest{SessionId: acp.SessionId(sess.ID), Cwd: t.TempDir(), McpServers: []acp.McpServer{}})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Modes)
+	assert.Equal(t, modeAutoApprove, resp.Modes.CurrentModeId)
+
+	// Notifications are handled concurrently, they may arrive in any order
+	expected := []acp.SessionUpdate{
+		acp.UpdateUserMessageText("List the files"),
+		acp.UpdateAgentThoughtText("The user wants a file listing"),
+		buildToolCallStart(toolCall, tools.Tool{}),
+		buildToolCallComplete(toolCall, "main.go", ""),
+		acp.UpdateAgentMessageText("There is one file"),
+	}
+	require.Eventually(t, func() bool { return len(client.Updates()) == len(expected) }, 5*time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, toJSON(t, expected), toJSON(t, client.Updates()))
+
+	_, err = conn.LoadSession(t.Context(), acp.LoadSessionRequest{SessionId: "unknown", Cwd: t.TempDir(), McpServers: []acp.McpServer{}})
+	require.Error(t, err)
+}
+
+func TestLoadSessionWithoutStore(t *testing.T) {
+	conn, _, _ := connect(t, nil, acp.ClientCapabilities{})
+
+	_, err := conn.LoadSession(t.Context(), acp.LoadSessionRequest{SessionId: "session", Cwd: t.TempDir(), McpServers: []acp.McpServer{}})
+	require.Error(t, err)
+}
+
+func TestClientFiles(t *testing.T) {
+	_, client, a := connect(t, nil, acp.ClientCapabilities{Fs: acp.FileSystemCapability{ReadTextFile: true, WriteTextFile: true}})
+	client.files["/work/main.go"] = "package main"
+
+	files := &clientFiles{agent: a, workingDir: "/work"}
+	ctx := withSessionID(t.Context(), "session")
+
+	assert.False(t, files.Buffered("main.go"))
+	content, err := files.ReadFile(ctx, "main.go")
+	require.NoError(t, err)
+	assert.Equal(t, "package main", string(content))
+	assert.True(t, files.Buffered("/work/main.go"))
+
+	require.NoError(t, files.WriteFile(ctx, "/work/README.md", []byte("# Work")))
+	assert.Equal(t, "# Work", client.files["/work/README.md"])
+	assert.True(t, files.Buffered("README.md"))
+	assert.False(t, files.Buffered("go.mod"))
+}
+
+func TestClientFilesWithoutCapabilities(t *testing.T) {
+	_, client, a := connect(t, nil, acp.ClientCapabilities{})
+
+	dir := t.TempDir()
+	files := &clientFiles{agent: a, workingDir: dir}
+	ctx := withSessionID(t.Context(), "session")
+
+	require.NoError(t, files.WriteFile(ctx, "cmd/main.go", []byte("package main")))
+	content, err := files.ReadFile(ctx, filepath.Join(dir, "cmd", "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main", string(content))
+	assert.Empty(t, client.files)
+	assert.False(t, files.Buffered("cmd/main.go"))
+}
+
+func TestClientTerminal(t *testing.T) {
+	_, client, a := connect(t, nil, acp.ClientCapabilities{Terminal: true})
+	client.output = "main.go\n"
+
+	terminal := &clientTerminal{agent: a, workingDir: "/work", env: []acp.EnvVariable{{Name: "FOO", Value: "bar"}}}
+	ctx := tools.WithToolCallID(withSessionID(t.Context(), "session"), "call_1")
+
+	output, err := terminal.RunCommand(ctx, "ls", "src")
+	require.NoError(t, err)
+	assert.Equal(t, "main.go\n", output)
+
+	require.NotNil(t, client.terminal)
+	assert.Equal(t, []string{"-c", "ls"}, client.terminal.Args)
+	assert.Equal(t, "/work/src", *client.terminal.Cwd)
+	assert.Equal(t, []acp.EnvVariable{{Name: "FOO", Value: "bar"}}, client.terminal.Env)
+	assert.True(t, client.released)
+	assert.Equal(t, "term_1", a.takeTerminal("call_1"))
+
+	client.exitCode = 2
+	output, err = terminal.RunCommand(ctx, "false", "")
+	require.EqualError(t, err, "exit status 2")
+	assert.Equal(t, "main.go\n", output)
+}
@@ -2,14 +2,12 @@ package acp
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"os"
 	"path/filepath"
-	"strings"
+	"sync"
 
 	"github.com/coder/acp-go-sdk"
 
-	"github.com/rumpl/rb/pkg/tools"
 	"github.com/rumpl/rb/pkg/tools/builtin"
 )
 
@@ -28,127 +26,85 @@ func getSessionID(ctx context.Context) (string, bool) {
 	return sid, ok
 }
 
-// FilesystemToolset wraps a standard FilesystemTool and overrides read_file, write_file,
-// and edit_file to use the ACP connection for file operations
-type FilesystemToolset struct {
-	*builtin.FilesystemTool
-	agent       *Agent
-	workindgDir string
+// clientFiles reads and writes the files of the filesystem tools through the
+// ACP client, so that the unsaved buffers of the editor are used. It uses the
+// local disk when the client can't read or write files.
+type clientFiles struct {
+	agent      *Agent
+	workingDir string
+
+	mu sync.Mutex
+	// buffered are the files read or written through the client, the only
+	// ones known to be opened in the editor
+	buffered map[string]bool
 }
 
-var _ tools.ToolSet = (*FilesystemToolset)(nil)
+var _ builtin.FileAccess = (*clientFiles)(nil)
 
-// NewFilesystemToolset creates a new ACP-specific filesystem toolset
-func NewFilesystemToolset(agent *Agent, workingDir string, opts ...builtin.FileSystemOpt) *FilesystemToolset {
-	return &FilesystemToolset{
-		FilesystemTool: builtin.NewFilesystemTool([]string{workingDir}, opts...),
-		agent:          agent,
-		workindgDir:    workingDir,
-	}
-}
-
-// Tools returns the tool definitions with ACP-specific overrides
-func (t *FilesystemToolset) Tools(ctx context.Context) ([]tools.Tool, error) {
-	baseTools, err := t.FilesystemTool.Tools(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	for i := range baseTools {
-		switch baseTools[i].Name {
-		case builtin.ToolNameReadFile:
-			baseTools[i].Handler = t.handleReadFile
-		case builtin.ToolNameWriteFile:
-			baseTools[i].Handler = t.handleWriteFile
-		case builtin.ToolNameEditFile:
-			baseTools[i].Handler = t.handleEditFile
-		}
-	}
-
-	return baseTools, nil
-}
-
-func (t *FilesystemToolset) handleReadFile(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
-	var args builtin.ReadFileArgs
-	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-		return nil, fmt.Errorf("failed to parse arguments: %w", err)
-	}
+// ReadFile implements [builtin.FileAccess].
+func (f *clientFiles) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	path = absPath(f.workingDir, path)
 
 	sessionID, ok := getSessionID(ctx)
-	if !ok {
-		return &tools.ToolCallResult{Output: "Error: session ID not found in context"}, nil
+	if !ok || !f.agent.clientCapabilities.Fs.ReadTextFile {
+		return os.ReadFile(path)
 	}
 
-	resp, err := t.agent.conn.ReadTextFile(ctx, acp.ReadTextFileRequest{
+	resp, err := f.agent.conn.ReadTextFile(ctx, acp.ReadTextFileRequest{
 		SessionId: acp.SessionId(sessionID),
-		Path:      filepath.Join(t.workindgDir, args.Path),
+		Path:      path,
 	})
 	if err != nil {
-		return &tools.ToolCallResult{Output: fmt.Sprintf("Error reading file: %s", err)}, nil
+		return nil, err
 	}
-
-	return &tools.ToolCallResult{Output: resp.Content}, nil
+	f.setBuffered(path)
+	return []byte(resp.Content), nil
 }
 
-func (t *FilesystemToolset) handleWriteFile(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
-	var args builtin.WriteFileArgs
-	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-		return nil, fmt.Errorf("failed to parse arguments: %w", err)
-	}
+// WriteFile implements [builtin.FileAccess].
+func (f *clientFiles) WriteFile(ctx context.Context, path string, data []byte) error {
+	path = absPath(f.workingDir, path)
 
 	sessionID, ok := getSessionID(ctx)
-	if !ok {
-		return &tools.ToolCallResult{Output: "Error: session ID not found in context"}, nil
+	if !ok || !f.agent.clientCapabilities.Fs.WriteTextFile {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0o644)
 	}
 
-	_, err := t.agent.conn.WriteTextFile(ctx, acp.WriteTextFileRequest{
+	if _, err := f.agent.conn.WriteTextFile(ctx, acp.WriteTextFileRequest{
 		SessionId: acp.SessionId(sessionID),
-		Path:      args.Path,
-		Content:   args.Content,
-	})
-	if err != nil {
-		return &tools.ToolCallResult{Output: fmt.Sprintf("Error writing file: %s", err)}, nil
+		Path:      path,
+		Content:   string(data),
+	}); err != nil {
+		return err
 	}
-
-	return &tools.ToolCallResult{Output: "File written successfully"}, nil
+	f.setBuffered(path)
+	return nil
 }
 
-func (t *FilesystemToolset) handleEditFile(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
-	var args builtin.EditFileArgs
-	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-		return nil, fmt.Errorf("failed to parse arguments: %w", err)
-	}
-
-	sessionID, ok := getSessionID(ctx)
-	if !ok {
-		return &tools.ToolCallResult{Output: "Error: session ID not found in context"}, nil
-	}
-
-	resp, err := t.agent.conn.ReadTextFile(ctx, acp.ReadTextFileRequest{
-		SessionId: acp.SessionId(sessionID),
-		Path:      filepath.Join(t.workindgDir, args.Path),
-	})
-	if err != nil {
-		return &tools.ToolCallResult{Output: fmt.Sprintf("Error reading file: %s", err)}, nil
-	}
-
-	modifiedContent := resp.Content
+// Buffered implements [builtin.FileAccess].
+func (f *clientFiles) Buffered(path string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.buffered[absPath(f.workingDir, path)]
+}
 
-	for i, edit := range args.Edits {
-		if !strings.Contains(modifiedContent, edit.OldText) {
-			return &tools.ToolCallResult{Output: fmt.Sprintf("Edit %d failed: old text not found", i+1)}, nil
-		}
-		modifiedContent = strings.Replace(modifiedContent, edit.OldText, edit.NewText, 1)
+func (f *clientFiles) setBuffered(path string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.buffered == nil {
+		f.buffered = map[string]bool{}
 	}
+	f.buffered[path] = true
+}
 
-	_, err = t.agent.conn.WriteTextFile(ctx, acp.WriteTextFileRequest{
-		SessionId: acp.SessionId(sessionID),
-		Path:      filepath.Join(t.workindgDir, args.Path),
-		Content:   modifiedContent,
-	})
-	if err != nil {
-		return &tools.ToolCallResult{Output: fmt.Sprintf("Error writing file: %s", err)}, nil
+// absPath resolves a path relative to the working directory, ACP clients only
+// accept absolute paths
+func absPath(workingDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
 	}
-
-	return &tools.ToolCallResult{Output: "File edited successfully"}, nil
+	return filepath.Join(workingDir, path)
 }
@@ -2,31 +2,87 @@ package acp
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
 	"os"
+	"strings"
+
+	"github.com/coder/acp-go-sdk"
 
 	"github.com/rumpl/rb/pkg/config"
 	latest "github.com/rumpl/rb/pkg/config/v2"
 	"github.com/rumpl/rb/pkg/environment"
 	"github.com/rumpl/rb/pkg/teamloader"
 	"github.com/rumpl/rb/pkg/tools"
+	"github.com/rumpl/rb/pkg/tools/builtin"
 )
 
-// createToolsetRegistry creates a custom toolset registry with ACP-specific filesystem toolset
+// createToolsetRegistry creates a custom toolset registry where the filesystem
+// and shell toolsets use the files and the terminals of the client, when the
+// client supports them
 func createToolsetRegistry(agent *Agent) *teamloader.ToolsetRegistry {
 	registry := teamloader.NewDefaultToolsetRegistry()
 
 	registry.Register("filesystem", func(ctx context.Context, toolset latest.Toolset, parentDir string, envProvider environment.Provider, runtimeConfig config.RuntimeConfig) (tools.ToolSet, error) {
-		wd := runtimeConfig.WorkingDir
-		if wd == "" {
-			var err error
-			wd, err = os.Getwd()
+		var opts []builtin.FileSystemOpt
+		if fs := agent.clientCapabilities.Fs; fs.ReadTextFile || fs.WriteTextFile {
+			wd, err := workingDir(runtimeConfig)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, builtin.WithFileAccess(&clientFiles{agent: agent, workingDir: wd}))
+		}
+
+		t, err := teamloader.NewFilesystemTool(toolset, runtimeConfig, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+
+	registry.Register("shell", func(ctx context.Context, toolset latest.Toolset, parentDir string, envProvider environment.Provider, runtimeConfig config.RuntimeConfig) (tools.ToolSet, error) {
+		var opts []builtin.ShellOpt
+		switch {
+		case !agent.clientCapabilities.Terminal:
+			// The client has no terminals, the commands run locally
+		case toolset.Persistent, toolset.Sandbox != nil, runtimeConfig.Sandbox:
+			slog.Debug("Running shell commands locally, persistent and sandboxed shells can't run in the client's terminals")
+		default:
+			wd, err := workingDir(runtimeConfig)
 			if err != nil {
 				return nil, err
 			}
+			env, err := environment.ExpandAll(ctx, environment.ToValues(toolset.Env), envProvider)
+			if err != nil {
+				return nil, fmt.Errorf("failed to expand the tool's environment variables: %w", err)
+			}
+			opts = append(opts, builtin.WithCommandRunner(&clientTerminal{agent: agent, workingDir: wd, env: envVariables(env)}))
 		}
 
-		return NewFilesystemToolset(agent, wd), nil
+		t, err := teamloader.NewShellTool(ctx, toolset, envProvider, runtimeConfig, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
 	})
 
 	return registry
 }
+
+// workingDir returns the directory relative paths are resolved against
+func workingDir(runtimeConfig config.RuntimeConfig) (string, error) {
+	if runtimeConfig.WorkingDir != "" {
+		return runtimeConfig.WorkingDir, nil
+	}
+	return os.Getwd()
+}
+
+// envVariables converts KEY=VALUE pairs to ACP environment variables
+func envVariables(env []string) []acp.EnvVariable {
+	var vars []acp.EnvVariable
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		vars = append(vars, acp.EnvVariable{Name: name, Value: value})
+	}
+	return vars
+}
@@ -0,0 +1,74 @@
+package acp
+
+import (
+	"github.com/coder/acp-go-sdk"
+
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+// Session modes, they map onto the tool approval of the session
+const (
+	modeAsk         acp.SessionModeId = "ask"
+	modeAutoApprove acp.SessionModeId = "auto-approve"
+)
+
+// sessionModes returns the modes a session can be in and its current mode
+func sessionModes(sess *session.Session) *acp.SessionModeState {
+	current := modeAsk
+	if sess.ToolsApproved {
+		current = modeAutoApprove
+	}
+
+	return &acp.SessionModeState{
+		CurrentModeId: current,
+		AvailableModes: []acp.SessionMode{
+			{
+				Id:          modeAsk,
+				Name:        "Ask",
+				Description: acp.Ptr("Ask before running tools that aren't read-only"),
+			},
+			{
+				Id:          modeAutoApprove,
+				Name:        "Auto-approve",
+				Description: acp.Ptr("Run all the tools without asking"),
+			},
+		},
+	}
+}
+
+// historyUpdates converts the conversation of a session into the updates the
+// client would have received while it happened. Implicit and system messages
+// aren't shown, sub-sessions appear through the tool calls that started them.
+func historyUpdates(sess *session.Session) []acp.SessionUpdate {
+	var updates []acp.SessionUpdate
+	for _, item := range sess.Messages {
+		if !item.IsMessage() || item.Message.Implicit {
+			continue
+		}
+
+		msg := item.Message.Message
+		switch msg.Role {
+		case chat.MessageRoleUser:
+			if msg.Content != "" {
+				updates = append(updates, acp.UpdateUserMessageText(msg.Content))
+			}
+
+		case chat.MessageRoleAssistant:
+			if msg.ReasoningContent != "" {
+				updates = append(updates, acp.UpdateAgentThoughtText(msg.ReasoningContent))
+			}
+			if msg.Content != "" {
+				updates = append(updates, acp.UpdateAgentMessageText(msg.Content))
+			}
+			for _, toolCall := range msg.ToolCalls {
+				updates = append(updates, buildToolCallStart(toolCall, tools.Tool{}))
+			}
+
+		case chat.MessageRoleTool:
+			updates = append(updates, buildToolCallComplete(tools.ToolCall{ID: msg.ToolCallID}, msg.Content, ""))
+		}
+	}
+	return updates
+}
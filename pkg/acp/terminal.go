@@ -0,0 +1,110 @@
+package acp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+
+	"github.com/coder/acp-go-sdk"
+
+	"github.com/rumpl/rb/pkg/tools"
+	"github.com/rumpl/rb/pkg/tools/builtin"
+)
+
+// clientTerminal runs the commands of the shell tool in terminals of the ACP
+// client, so that the user sees them in the editor
+type clientTerminal struct {
+	agent      *Agent
+	workingDir string
+	env        []acp.EnvVariable
+}
+
+var _ builtin.CommandRunner = (*clientTerminal)(nil)
+
+// RunCommand implements [builtin.CommandRunner].
+func (c *clientTerminal) RunCommand(ctx context.Context, command, cwd string) (string, error) {
+	sessionID, ok := getSessionID(ctx)
+	if !ok {
+		return "", errors.New("session ID not found in context")
+	}
+	sid := acp.SessionId(sessionID)
+
+	shell, args := shellCommand(command)
+	resp, err := c.agent.conn.CreateTerminal(ctx, acp.CreateTerminalRequest{
+		SessionId: sid,
+		Command:   shell,
+		Args:      args,
+		Cwd:       acp.Ptr(absPath(c.workingDir, cwd)),
+		Env:       c.env,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create terminal: %w", err)
+	}
+	terminalID := resp.TerminalId
+
+	// The terminal is killed and released even when the command is cancelled
+	cleanupCtx := context.WithoutCancel(ctx)
+	defer func() {
+		if _, err := c.agent.conn.ReleaseTerminal(cleanupCtx, acp.ReleaseTerminalRequest{SessionId: sid, TerminalId: terminalID}); err != nil {
+			slog.Warn("Failed to release terminal", "terminal_id", terminalID, "error", err)
+		}
+	}()
+
+	// Show the terminal in the tool call, it stays there once the call completes
+	if toolCallID := tools.ToolCallID(ctx); toolCallID != "" {
+		c.agent.addTerminal(toolCallID, terminalID)
+		if err := c.agent.conn.SessionUpdate(ctx, acp.SessionNotification{
+			SessionId: sid,
+			Update: acp.UpdateToolCall(
+				acp.ToolCallId(toolCallID),
+				acp.WithUpdateStatus(acp.ToolCallStatusInProgress),
+				acp.WithUpdateContent([]acp.ToolCallContent{acp.ToolTerminalRef(terminalID)}),
+			),
+		}); err != nil {
+			slog.Warn("Failed to show terminal in tool call", "terminal_id", terminalID, "error", err)
+		}
+	}
+
+	exit, waitErr := c.agent.conn.WaitForTerminalExit(ctx, acp.WaitForTerminalExitRequest{SessionId: sid, TerminalId: terminalID})
+	if waitErr != nil && ctx.Err() != nil {
+		if _, err := c.agent.conn.KillTerminalCommand(cleanupCtx, acp.KillTerminalCommandRequest{SessionId: sid, TerminalId: terminalID}); err != nil {
+			slog.Warn("Failed to kill terminal command", "terminal_id", terminalID, "error", err)
+		}
+	}
+
+	out, err := c.agent.conn.TerminalOutput(cleanupCtx, acp.TerminalOutputRequest{SessionId: sid, TerminalId: terminalID})
+	if err != nil {
+		return "", fmt.Errorf("failed to get terminal output: %w", err)
+	}
+
+	switch {
+	case waitErr != nil:
+		return out.Output, waitErr
+	case exit.Signal != nil:
+		return out.Output, fmt.Errorf("signal: %s", *exit.Signal)
+	case exit.ExitCode != nil && *exit.ExitCode != 0:
+		return out.Output, fmt.Errorf("exit status %d", *exit.ExitCode)
+	}
+	return out.Output, nil
+}
+
+// shellCommand returns the program and the arguments that run a command in
+// the shell of the user
+func shellCommand(command string) (string, []string) {
+	if runtime.GOOS == "windows" {
+		shell := os.Getenv("ComSpec")
+		if shell == "" {
+			shell = "cmd.exe"
+		}
+		return shell, []string{"/C", command}
+	}
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	return shell, []string{"-c", command}
+}
@@ -6,8 +6,11 @@ import (
 	"log/slog"
 	"math/rand"
 	"strings"
+	"sync"
 
+	"github.com/rumpl/rb/pkg/contextwindow"
 	"github.com/rumpl/rb/pkg/model/provider"
+	"github.com/rumpl/rb/pkg/permissions"
 	"github.com/rumpl/rb/pkg/tools"
 )
 
@@ -17,22 +20,43 @@ type Agent struct {
 	description         string
 	welcomeMessage      string
 	instruction         string
-	expandedInstruction string // cached expanded instruction
-	instructionExpanded bool   // flag to track if expansion was done
-	expansionError      error  // any error during expansion
+	instructionMu       sync.Mutex // guards the expansion, sessions can run the agent concurrently
+	expandedInstruction string     // cached expanded instruction
+	instructionExpanded bool       // flag to track if expansion was done
+	expansionError      error      // any error during expansion
 	toolsets            []*StartableToolSet
 	models              []provider.Provider
+	fallbackModels      []provider.Provider
 	subAgents           []*Agent
 	handoffs            []*Agent
 	parents             []*Agent
 	addDate             bool
 	addEnvironmentInfo  bool
 	maxIterations       int
+	maxCost             float64
+	maxTokensTotal      int
 	numHistoryItems     int
+	maxParallelTools    int
+	maxRetries          int
 	addPromptFiles      []string
 	tools               []tools.Tool
 	commands            map[string]string
+	permissions         *permissions.Checker
+	contextWindow       contextwindow.Config
+	compaction          Compaction
 	pendingWarnings     []string
+	resourcesMu         sync.Mutex                        // guards the resource providers
+	resourceProviders   map[string]tools.ResourceProvider // toolset of each resource, from the last listing
+}
+
+// Compaction configures how the agent's session is summarized when it's compacted
+type Compaction struct {
+	// Structured asks for a summary organized in sections that also covers the tool results
+	Structured bool
+	// KeepTurns is the number of most recent turns kept verbatim after the summary
+	KeepTurns int
+	// Model generates the summary, the agent's model is used when nil
+	Model provider.Provider
 }
 
 // New creates a new agent
@@ -57,6 +81,9 @@ func (a *Agent) Name() string {
 // Template expressions use ${tool_name({args})} syntax.
 // The expansion is performed once and cached for subsequent calls.
 func (a *Agent) Instruction(ctx context.Context) string {
+	a.instructionMu.Lock()
+	defer a.instructionMu.Unlock()
+
 	// Return cached result if already expanded
 	if a.instructionExpanded {
 		return a.expandedInstruction
@@ -108,10 +135,38 @@ func (a *Agent) MaxIterations() int {
 	return a.maxIterations
 }
 
+// MaxCost returns the budget of a session of the agent in dollars, 0 means no limit
+func (a *Agent) MaxCost() float64 {
+	return a.maxCost
+}
+
+// MaxTokensTotal returns the budget of a session of the agent in tokens, 0 means no limit
+func (a *Agent) MaxTokensTotal() int {
+	return a.maxTokensTotal
+}
+
 func (a *Agent) NumHistoryItems() int {
 	return a.numHistoryItems
 }
 
+// defaultMaxParallelToolCalls is the number of tool calls that can run
+// concurrently when the agent doesn't configure it
+const defaultMaxParallelToolCalls = 4
+
+// MaxParallelToolCalls returns the maximum number of tool calls that can run concurrently.
+// Tool calls run one at a time when a model of the agent sets parallel_tool_calls to false.
+func (a *Agent) MaxParallelToolCalls() int {
+	for _, model := range a.models {
+		if parallel := model.BaseConfig().ModelConfig.ParallelToolCalls; parallel != nil && !*parallel {
+			return 1
+		}
+	}
+	if a.maxParallelTools <= 0 {
+		return defaultMaxParallelToolCalls
+	}
+	return a.maxParallelTools
+}
+
 func (a *Agent) AddPromptFiles() []string {
 	return a.addPromptFiles
 }
@@ -150,11 +205,38 @@ func (a *Agent) Model() provider.Provider {
 	return a.models[rand.Intn(len(a.models))]
 }
 
+// FallbackModels returns the models to try, in order, when the agent's model keeps failing.
+func (a *Agent) FallbackModels() []provider.Provider {
+	return a.fallbackModels
+}
+
+// MaxRetries returns how many times a transient model error is retried before
+// switching to the next fallback model.
+func (a *Agent) MaxRetries() int {
+	return a.maxRetries
+}
+
+// ContextWindow returns how the agent's conversation is kept within the model's context window.
+func (a *Agent) ContextWindow() contextwindow.Config {
+	return a.contextWindow
+}
+
+// Compaction returns how the agent's session is summarized when it's compacted.
+func (a *Agent) Compaction() Compaction {
+	return a.compaction
+}
+
 // Commands returns the named commands configured for this agent.
 func (a *Agent) Commands() map[string]string {
 	return a.commands
 }
 
+// Permissions returns the tool permission rules configured for this agent.
+// It can be nil if the agent has no rules.
+func (a *Agent) Permissions() *permissions.Checker {
+	return a.permissions
+}
+
 // Tools returns the tools available to this agent
 func (a *Agent) Tools(ctx context.Context) ([]tools.Tool, error) {
 	a.ensureToolSetsAreStarted(ctx)
@@ -189,6 +271,31 @@ func (a *Agent) ToolSets() []tools.ToolSet {
 	return toolSets
 }
 
+// ToolSetsContext returns what the toolsets of the agent add to its system
+// prompt for the last message of the user, like the relevant memories
+func (a *Agent) ToolSetsContext(ctx context.Context, query string) []string {
+	ctx = tools.WithAgentName(ctx, a.name)
+
+	var contexts []string
+	for _, ts := range a.toolsets {
+		provider, ok := tools.As[tools.ContextProvider](ts.ToolSet)
+		if !ok {
+			continue
+		}
+
+		content, err := provider.ProvideContext(ctx, query)
+		if err != nil {
+			slog.Warn("Failed to get the context of a toolset", "agent", a.Name(), "error", err)
+			continue
+		}
+		if content != "" {
+			contexts = append(contexts, content)
+		}
+	}
+
+	return contexts
+}
+
 func (a *Agent) ensureToolSetsAreStarted(ctx context.Context) {
 	for _, toolSet := range a.toolsets {
 		// Skip if toolset is already started
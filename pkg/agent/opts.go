@@ -3,7 +3,9 @@ package agent
 import (
 	"sync/atomic"
 
+	"github.com/rumpl/rb/pkg/contextwindow"
 	"github.com/rumpl/rb/pkg/model/provider"
+	"github.com/rumpl/rb/pkg/permissions"
 	"github.com/rumpl/rb/pkg/tools"
 )
 
@@ -52,6 +54,18 @@ func WithModel(model provider.Provider) Opt {
 	}
 }
 
+func WithFallbackModels(models ...provider.Provider) Opt {
+	return func(a *Agent) {
+		a.fallbackModels = append(a.fallbackModels, models...)
+	}
+}
+
+func WithMaxRetries(maxRetries int) Opt {
+	return func(a *Agent) {
+		a.maxRetries = maxRetries
+	}
+}
+
 func WithSubAgents(subAgents ...*Agent) Opt {
 	return func(a *Agent) {
 		a.subAgents = subAgents
@@ -91,18 +105,54 @@ func WithMaxIterations(maxIterations int) Opt {
 	}
 }
 
+func WithMaxCost(maxCost float64) Opt {
+	return func(a *Agent) {
+		a.maxCost = maxCost
+	}
+}
+
+func WithMaxTokensTotal(maxTokensTotal int) Opt {
+	return func(a *Agent) {
+		a.maxTokensTotal = maxTokensTotal
+	}
+}
+
 func WithNumHistoryItems(numHistoryItems int) Opt {
 	return func(a *Agent) {
 		a.numHistoryItems = numHistoryItems
 	}
 }
 
+func WithMaxParallelToolCalls(maxParallelToolCalls int) Opt {
+	return func(a *Agent) {
+		a.maxParallelTools = maxParallelToolCalls
+	}
+}
+
 func WithCommands(commands map[string]string) Opt {
 	return func(a *Agent) {
 		a.commands = commands
 	}
 }
 
+func WithPermissions(checker *permissions.Checker) Opt {
+	return func(a *Agent) {
+		a.permissions = checker
+	}
+}
+
+func WithContextWindow(cfg contextwindow.Config) Opt {
+	return func(a *Agent) {
+		a.contextWindow = cfg
+	}
+}
+
+func WithCompaction(compaction Compaction) Opt {
+	return func(a *Agent) {
+		a.compaction = compaction
+	}
+}
+
 func WithLoadTimeWarnings(warnings []string) Opt {
 	return func(a *Agent) {
 		for _, w := range warnings {
@@ -115,3 +165,7 @@ type StartableToolSet struct {
 	tools.ToolSet
 	started atomic.Bool
 }
+
+func (s *StartableToolSet) Unwrap() tools.ToolSet {
+	return s.ToolSet
+}
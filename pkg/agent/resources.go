@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+// Resources returns the resources of the started toolsets of the agent, like
+// the resources of its MCP servers. The toolsets are asked for their resources
+// on every call, reading a resource uses the toolsets of the last listing.
+func (a *Agent) Resources(ctx context.Context) []tools.Resource {
+	var resources []tools.Resource
+	providers := map[string]tools.ResourceProvider{}
+	for _, provider := range startedToolSets[tools.ResourceProvider](a) {
+		r, err := provider.Resources(ctx)
+		if err != nil {
+			slog.Warn("Failed to list the resources of a toolset", "agent", a.Name(), "error", err)
+			continue
+		}
+		resources = append(resources, r...)
+		for _, resource := range r {
+			if _, ok := providers[resource.URI]; !ok {
+				providers[resource.URI] = provider
+			}
+		}
+	}
+
+	a.resourcesMu.Lock()
+	a.resourceProviders = providers
+	a.resourcesMu.Unlock()
+
+	return resources
+}
+
+// ReadResource returns the content of one of the resources of the agent
+func (a *Agent) ReadResource(ctx context.Context, uri string) (string, error) {
+	provider, err := a.resourceProvider(ctx, uri)
+	if err != nil {
+		return "", err
+	}
+	return provider.ReadResource(ctx, uri)
+}
+
+// SubscribeResource calls handler when one of the resources of the agent is
+// updated
+func (a *Agent) SubscribeResource(ctx context.Context, uri string, handler func(uri string)) error {
+	provider, err := a.resourceProvider(ctx, uri)
+	if err != nil {
+		return err
+	}
+	provider.SetResourceUpdatedHandler(handler)
+	return provider.SubscribeResource(ctx, uri)
+}
+
+// resourceProvider returns the toolset that listed the resource, the resources
+// are only listed here when they haven't been listed yet
+func (a *Agent) resourceProvider(ctx context.Context, uri string) (tools.ResourceProvider, error) {
+	a.resourcesMu.Lock()
+	listed := a.resourceProviders != nil
+	a.resourcesMu.Unlock()
+	if !listed {
+		a.Resources(ctx)
+	}
+
+	a.resourcesMu.Lock()
+	defer a.resourcesMu.Unlock()
+	if provider, ok := a.resourceProviders[uri]; ok {
+		return provider, nil
+	}
+	return nil, fmt.Errorf("resource %s not found", uri)
+}
+
+// Prompts returns the prompts of the started toolsets of the agent, when
+// several toolsets have a prompt with the same name the first one is kept
+func (a *Agent) Prompts(ctx context.Context) []tools.Prompt {
+	var prompts []tools.Prompt
+	seen := map[string]bool{}
+	for _, provider := range startedToolSets[tools.PromptProvider](a) {
+		p, err := provider.Prompts(ctx)
+		if err != nil {
+			slog.Warn("Failed to list the prompts of a toolset", "agent", a.Name(), "error", err)
+			continue
+		}
+		for _, prompt := range p {
+			if !seen[prompt.Name] {
+				seen[prompt.Name] = true
+				prompts = append(prompts, prompt)
+			}
+		}
+	}
+	return prompts
+}
+
+// GetPrompt returns the text of one of the prompts of the agent
+func (a *Agent) GetPrompt(ctx context.Context, name string, arguments map[string]string) (string, error) {
+	for _, provider := range startedToolSets[tools.PromptProvider](a) {
+		prompts, err := provider.Prompts(ctx)
+		if err != nil {
+			continue
+		}
+		for _, prompt := range prompts {
+			if prompt.Name == name {
+				return provider.GetPrompt(ctx, name, arguments)
+			}
+		}
+	}
+	return "", fmt.Errorf("prompt %s not found", name)
+}
+
+// startedToolSets returns the started toolsets of the agent that implement T.
+// The toolsets that haven't started yet are skipped rather than started, their
+// resources and prompts appear once the agent has run.
+func startedToolSets[T any](a *Agent) []T {
+	var toolSets []T
+	for _, ts := range a.toolsets {
+		if !ts.started.Load() {
+			continue
+		}
+		if t, ok := tools.As[T](ts.ToolSet); ok {
+			toolSets = append(toolSets, t)
+		}
+	}
+	return toolSets
+}
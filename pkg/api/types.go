@@ -125,6 +125,13 @@ type SessionsResponse struct {
 	WorkingDir                 string `json:"working_dir,omitempty"`
 }
 
+// SessionSearchResponse represents a session matching a search
+type SessionSearchResponse struct {
+	SessionsResponse
+	// Snippet is an excerpt of the session around the matched terms, enclosed in square brackets
+	Snippet string `json:"snippet"`
+}
+
 // SessionResponse represents a detailed session
 type SessionResponse struct {
 	ID            string              `json:"id"`
@@ -150,6 +157,13 @@ type ResumeSessionRequest struct {
 	Confirmation string `json:"confirmation"`
 }
 
+// ForkSessionRequest represents a request to fork a session
+type ForkSessionRequest struct {
+	// MessageIndex is the index of the last session item to copy into the fork.
+	// When not set, the whole session is copied.
+	MessageIndex *int `json:"message_index,omitempty"`
+}
+
 // DesktopTokenResponse represents the response from getting a desktop token
 type DesktopTokenResponse struct {
 	Token string `json:"token"`
@@ -2,7 +2,11 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"os/exec"
+	"sync"
 	"time"
 
 	tea "charm.land/bubbletea/v2"
@@ -19,17 +23,40 @@ type App struct {
 	events           chan tea.Msg
 	throttleDuration time.Duration
 	cancel           context.CancelFunc
+	// done is closed when the current run stopped
+	done         chan struct{}
+	sessionStore session.Store
+
+	// attachedResources are the resources mentioned in the session, true when
+	// they were updated since they were last sent
+	attachedResources map[string]bool
+	resourcesMu       sync.Mutex
 }
 
-func New(agentFilename string, rt runtime.Runtime, sess *session.Session, firstMessage *string) *App {
-	return &App{
-		agentFilename:    agentFilename,
-		runtime:          rt,
-		session:          sess,
-		firstMessage:     firstMessage,
-		events:           make(chan tea.Msg, 128),
-		throttleDuration: 50 * time.Millisecond, // Throttle rapid events
+type Opt func(*App)
+
+// WithSessionStore saves the sessions in the store after each run and lets the
+// user search the saved sessions
+func WithSessionStore(store session.Store) Opt {
+	return func(a *App) {
+		a.sessionStore = store
+	}
+}
+
+func New(agentFilename string, rt runtime.Runtime, sess *session.Session, firstMessage *string, opts ...Opt) *App {
+	a := &App{
+		agentFilename:     agentFilename,
+		runtime:           rt,
+		session:           sess,
+		firstMessage:      firstMessage,
+		events:            make(chan tea.Msg, 128),
+		throttleDuration:  50 * time.Millisecond, // Throttle rapid events
+		attachedResources: map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(a)
 	}
+	return a
 }
 
 func (a *App) FirstMessage() *string {
@@ -46,25 +73,80 @@ func (a *App) CurrentAgentCommands(ctx context.Context) map[string]string {
 	return a.runtime.CurrentAgentCommands(ctx)
 }
 
-// ResolveCommand converts /command to its prompt text
-func (a *App) ResolveCommand(ctx context.Context, userInput string) string {
+// ResolveCommand converts /command to its prompt text, and /prompt to the text
+// of the prompt of the MCP servers
+func (a *App) ResolveCommand(ctx context.Context, userInput string) (string, error) {
 	return runtime.ResolveCommand(ctx, a.runtime, userInput)
 }
 
 // Run one agent loop
 func (a *App) Run(ctx context.Context, cancel context.CancelFunc, message string) {
 	a.cancel = cancel
+	done := make(chan struct{})
+	a.done = done
+	sess := a.session
 	go func() {
-		a.session.AddMessage(session.UserMessage(a.agentFilename, message))
-		for event := range a.runtime.RunStream(ctx, a.session) {
+		defer close(done)
+		defer a.saveSession(context.WithoutCancel(ctx), sess)
+
+		message, err := a.ResolveCommand(ctx, message)
+		if err != nil {
+			a.sendEvent(ctx, runtime.Error(err.Error()))
+			return
+		}
+
+		for _, msg := range a.resourceMessages(ctx, message) {
+			sess.AddMessage(msg)
+		}
+		sess.AddMessage(session.UserMessage(a.agentFilename, message))
+		for event := range a.runtime.RunStream(ctx, sess) {
+			// Keep draining the events so that the session is only saved once the runtime is done with it
 			if ctx.Err() != nil {
-				return
+				continue
 			}
-			a.events <- event
+			a.sendEvent(ctx, event)
 		}
 	}()
 }
 
+// stopRun cancels the current run and waits for it to stop adding messages to
+// its session
+func (a *App) stopRun() {
+	if a.cancel != nil {
+		a.cancel()
+		a.cancel = nil
+	}
+	if a.done != nil {
+		<-a.done
+		a.done = nil
+	}
+}
+
+// StopRun cancels the current run without waiting for it: the returned command
+// waits for the run to stop adding messages to its session and then sends msg
+func (a *App) StopRun(msg tea.Msg) tea.Cmd {
+	if a.cancel != nil {
+		a.cancel()
+		a.cancel = nil
+	}
+	done := a.done
+	return func() tea.Msg {
+		if done != nil {
+			<-done
+		}
+		return msg
+	}
+}
+
+// sendEvent sends an event to the UI, unless ctx is done: the UI may be
+// waiting for the run to stop
+func (a *App) sendEvent(ctx context.Context, msg tea.Msg) {
+	select {
+	case a.events <- msg:
+	case <-ctx.Done():
+	}
+}
+
 func (a *App) RunBangCommand(ctx context.Context, command string) {
 	out, _ := exec.CommandContext(ctx, "/bin/sh", "-c", command).CombinedOutput()
 	a.events <- runtime.ShellOutput("$ " + command + "\n" + string(out))
@@ -93,17 +175,107 @@ func (a *App) Resume(resumeType runtime.ResumeType) {
 }
 
 func (a *App) NewSession() {
-	if a.cancel != nil {
-		a.cancel()
-		a.cancel = nil
-	}
+	a.stopRun()
 	a.session = session.New()
+	a.resetResources()
+}
+
+// ForkSession replaces the current session with a copy of its whole conversation
+// so that it can be continued without altering the original session
+func (a *App) ForkSession() error {
+	a.stopRun()
+
+	if len(a.session.Messages) == 0 {
+		return errors.New("the conversation is empty")
+	}
+
+	forked, err := a.session.Fork(len(a.session.Messages) - 1)
+	if err != nil {
+		return err
+	}
+
+	a.session = forked
+	a.saveSession(context.Background(), forked)
+
+	return nil
+}
+
+// EditUserMessage replaces the n-th user message of the session with content,
+// discards everything that follows it and runs the agent again.
+// When keepFork is true, the conversation as it was before the edit is kept as a fork.
+// The current run is stopped first so that it doesn't add messages to the session being edited,
+// use StopRun beforehand not to wait for it.
+func (a *App) EditUserMessage(ctx context.Context, cancel context.CancelFunc, n int, content string, keepFork bool) error {
+	a.stopRun()
+
+	index, ok := a.session.UserMessageIndex(n)
+	if !ok {
+		return fmt.Errorf("user message %d not found", n)
+	}
+
+	if keepFork {
+		forked, err := a.session.Fork(len(a.session.Messages) - 1)
+		if err != nil {
+			return err
+		}
+		a.saveSession(context.WithoutCancel(ctx), forked)
+	}
+
+	a.session.Truncate(index)
+	a.Run(ctx, cancel, content)
+
+	return nil
 }
 
 func (a *App) Session() *session.Session {
 	return a.session
 }
 
+// SearchSessions searches the saved sessions, the best matches come first
+func (a *App) SearchSessions(ctx context.Context, query string) ([]session.SearchResult, error) {
+	if a.sessionStore == nil {
+		return nil, errors.New("sessions are not saved")
+	}
+	return a.sessionStore.SearchSessions(ctx, query, session.SearchFilters{Limit: 20})
+}
+
+// Sessions returns the saved sessions of the agent file, the most recent first
+func (a *App) Sessions(ctx context.Context) ([]*session.Session, error) {
+	if a.sessionStore == nil {
+		return nil, errors.New("sessions are not saved")
+	}
+	return a.sessionStore.GetSessionsByAgent(ctx, a.agentFilename)
+}
+
+// LoadSession replaces the current session with a saved session
+func (a *App) LoadSession(ctx context.Context, id string) error {
+	if a.sessionStore == nil {
+		return errors.New("sessions are not saved")
+	}
+
+	sess, err := a.sessionStore.GetSession(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	a.stopRun()
+	a.session = sess
+	a.resetResources()
+
+	return nil
+}
+
+// saveSession adds or updates the session in the session store, if any
+func (a *App) saveSession(ctx context.Context, sess *session.Session) {
+	if a.sessionStore == nil || len(sess.Messages) == 0 {
+		return
+	}
+
+	if err := session.Save(ctx, a.sessionStore, sess); err != nil {
+		slog.Error("Failed to save session", "session_id", sess.ID, "error", err)
+	}
+}
+
 func (a *App) CompactSession() {
 	if a.runtime != nil && a.session != nil {
 		events := make(chan runtime.Event, 100)
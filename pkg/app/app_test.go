@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/runtime"
+	"github.com/rumpl/rb/pkg/session"
+)
+
+// slowRuntime keeps adding a message to the session after its run is cancelled
+type slowRuntime struct {
+	runtime.Runtime
+	running    atomic.Int32
+	overlapped atomic.Bool
+}
+
+func (r *slowRuntime) RunStream(ctx context.Context, sess *session.Session) <-chan runtime.Event {
+	events := make(chan runtime.Event)
+	if r.running.Add(1) > 1 {
+		r.overlapped.Store(true)
+	}
+
+	go func() {
+		defer close(events)
+		defer r.running.Add(-1)
+
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		sess.AddMessage(session.UserMessage("agent.yaml", "late"))
+	}()
+
+	return events
+}
+
+func TestEditUserMessageWaitsForTheRunToStop(t *testing.T) {
+	t.Parallel()
+
+	rt := &slowRuntime{}
+	a := New("agent.yaml", rt, session.New(), nil)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	a.Run(ctx, cancel, "first")
+	require.Eventually(t, func() bool { return rt.running.Load() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel = context.WithCancel(t.Context())
+	require.NoError(t, a.EditUserMessage(ctx, cancel, 0, "edited", false))
+	require.Eventually(t, func() bool { return rt.running.Load() == 1 }, time.Second, time.Millisecond)
+	assert.False(t, rt.overlapped.Load())
+
+	messages := a.Session().GetAllMessages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "edited", messages[0].Message.Content)
+
+	a.stopRun()
+}
+
+func TestStopRunDoesNotWaitForTheRun(t *testing.T) {
+	t.Parallel()
+
+	rt := &slowRuntime{}
+	a := New("agent.yaml", rt, session.New(), nil)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	a.Run(ctx, cancel, "first")
+	require.Eventually(t, func() bool { return rt.running.Load() == 1 }, time.Second, time.Millisecond)
+
+	type stoppedMsg struct{}
+	cmd := a.StopRun(stoppedMsg{})
+
+	assert.Equal(t, stoppedMsg{}, cmd())
+	assert.Equal(t, int32(0), rt.running.Load())
+}
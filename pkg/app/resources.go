@@ -0,0 +1,125 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/rumpl/rb/pkg/runtime"
+	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+// ResourceUpdatedMsg is sent when a resource attached to the conversation is
+// updated, its new content is sent with the next message
+type ResourceUpdatedMsg struct {
+	URI string
+}
+
+// CurrentAgentResources returns the resources the user can attach to a message
+// with @uri
+func (a *App) CurrentAgentResources(ctx context.Context) []tools.Resource {
+	return a.runtime.CurrentAgentResources(ctx)
+}
+
+// CurrentAgentPrompts returns the prompts of the MCP servers of the active agent
+func (a *App) CurrentAgentPrompts(ctx context.Context) []tools.Prompt {
+	return a.runtime.CurrentAgentPrompts(ctx)
+}
+
+// resourceMessages reads the resources the message mentions and the attached
+// resources that were updated, and returns their content as implicit messages.
+// The mentioned resources are subscribed to.
+func (a *App) resourceMessages(ctx context.Context, message string) []*session.Message {
+	var uris []string
+
+	if strings.Contains(message, "@") {
+		for _, uri := range mentionedResources(message, a.runtime.CurrentAgentResources(ctx)) {
+			a.resourcesMu.Lock()
+			_, attached := a.attachedResources[uri]
+			a.resourcesMu.Unlock()
+			if attached {
+				continue
+			}
+			uris = append(uris, uri)
+
+			if err := a.runtime.SubscribeResource(ctx, uri, a.resourceUpdated); err != nil {
+				slog.Warn("Failed to subscribe to resource", "uri", uri, "error", err)
+			}
+		}
+	}
+
+	a.resourcesMu.Lock()
+	for uri, updated := range a.attachedResources {
+		if updated {
+			uris = append(uris, uri)
+		}
+	}
+	a.resourcesMu.Unlock()
+
+	var messages []*session.Message
+	for _, uri := range uris {
+		content, err := a.runtime.ReadResource(ctx, uri)
+		if err != nil {
+			a.sendEvent(ctx, runtime.Warning(fmt.Sprintf("Failed to read resource %s: %v", uri, err), a.runtime.CurrentAgentName()))
+			continue
+		}
+
+		a.resourcesMu.Lock()
+		_, attached := a.attachedResources[uri]
+		a.attachedResources[uri] = false
+		a.resourcesMu.Unlock()
+
+		text := fmt.Sprintf("Content of the resource %s:\n\n%s", uri, content)
+		if attached {
+			text = fmt.Sprintf("The resource %s was updated, its content is now:\n\n%s", uri, content)
+		}
+		messages = append(messages, session.ImplicitUserMessage(a.agentFilename, text))
+	}
+	return messages
+}
+
+// resourceUpdated marks an attached resource as updated, it is called by the
+// MCP clients
+func (a *App) resourceUpdated(uri string) {
+	a.resourcesMu.Lock()
+	_, attached := a.attachedResources[uri]
+	if attached {
+		a.attachedResources[uri] = true
+	}
+	a.resourcesMu.Unlock()
+
+	if attached {
+		a.events <- ResourceUpdatedMsg{URI: uri}
+	}
+}
+
+// resetResources forgets the resources attached to the previous session
+func (a *App) resetResources() {
+	a.resourcesMu.Lock()
+	a.attachedResources = map[string]bool{}
+	a.resourcesMu.Unlock()
+}
+
+// mentionedResources returns the URIs of the resources mentioned with @uri in
+// the message
+func mentionedResources(message string, resources []tools.Resource) []string {
+	var uris []string
+	for word := range strings.FieldsSeq(message) {
+		uri, ok := strings.CutPrefix(word, "@")
+		if !ok {
+			continue
+		}
+		uri = strings.TrimRight(uri, ".,;:!?)")
+
+		for _, r := range resources {
+			if r.URI == uri && !slices.Contains(uris, uri) {
+				uris = append(uris, uri)
+				break
+			}
+		}
+	}
+	return uris
+}
@@ -0,0 +1,21 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+func TestMentionedResources(t *testing.T) {
+	t.Parallel()
+
+	resources := []tools.Resource{
+		{URI: "docs://readme", Name: "readme"},
+		{URI: "file:///tmp/notes.txt", Name: "notes"},
+	}
+
+	assert.Equal(t, []string{"docs://readme", "file:///tmp/notes.txt"}, mentionedResources("Compare @docs://readme with @file:///tmp/notes.txt, and @docs://readme.", resources))
+	assert.Empty(t, mentionedResources("Read @docs://other and docs://readme", resources))
+}
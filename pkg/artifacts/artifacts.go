@@ -0,0 +1,84 @@
+// Package artifacts stores large contents, like tool outputs, outside of the
+// session so that they don't fill the model's context window.
+package artifacts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/google/uuid"
+
+	"github.com/rumpl/rb/pkg/paths"
+)
+
+// ErrNotFound is returned when an artifact doesn't exist
+var ErrNotFound = errors.New("artifact not found")
+
+// validName matches the session IDs and handles that are safe to use as file names
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Store saves artifacts as files, in one directory per session
+type Store struct {
+	dir string
+}
+
+// ToolOutputsDir is where the large tool outputs are saved by default
+func ToolOutputsDir() string {
+	return filepath.Join(paths.GetDataDir(), "tool-outputs")
+}
+
+// NewStore creates a store that keeps its artifacts under dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save stores content for the given session and returns the handle to read it back
+func (s *Store) Save(sessionID, content string) (string, error) {
+	if !validName.MatchString(sessionID) {
+		return "", fmt.Errorf("invalid session ID %q", sessionID)
+	}
+
+	dir := filepath.Join(s.dir, sessionID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("creating artifacts directory: %w", err)
+	}
+
+	handle := uuid.New().String()
+	if err := os.WriteFile(filepath.Join(dir, handle), []byte(content), 0o600); err != nil {
+		return "", fmt.Errorf("writing artifact: %w", err)
+	}
+
+	return handle, nil
+}
+
+// Read returns the content of an artifact of the given session
+func (s *Store) Read(sessionID, handle string) (string, error) {
+	if !validName.MatchString(sessionID) || !validName.MatchString(handle) {
+		return "", ErrNotFound
+	}
+
+	buf, err := os.ReadFile(filepath.Join(s.dir, sessionID, handle))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading artifact: %w", err)
+	}
+
+	return string(buf), nil
+}
+
+// Delete removes all the artifacts of the given session
+func (s *Store) Delete(sessionID string) error {
+	if !validName.MatchString(sessionID) {
+		return fmt.Errorf("invalid session ID %q", sessionID)
+	}
+
+	if err := os.RemoveAll(filepath.Join(s.dir, sessionID)); err != nil {
+		return fmt.Errorf("deleting artifacts: %w", err)
+	}
+	return nil
+}
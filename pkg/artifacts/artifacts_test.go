@@ -0,0 +1,60 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveAndRead(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	handle, err := store.Save("session-1", "some large output")
+	require.NoError(t, err)
+
+	content, err := store.Read("session-1", handle)
+	require.NoError(t, err)
+	assert.Equal(t, "some large output", content)
+
+	// Artifacts are scoped to their session
+	_, err = store.Read("session-2", handle)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestReadInvalidHandle(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	_, err := store.Read("session-1", "unknown")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = store.Read("session-1", "../../etc/passwd")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSaveInvalidSessionID(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	_, err := store.Save("../session", "content")
+	require.Error(t, err)
+}
+
+func TestDelete(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	handle, err := store.Save("session-1", "some large output")
+	require.NoError(t, err)
+	other, err := store.Save("session-2", "another output")
+	require.NoError(t, err)
+
+	require.NoError(t, store.Delete("session-1"))
+	_, err = store.Read("session-1", handle)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = store.Read("session-2", other)
+	require.NoError(t, err)
+
+	// Deleting a session without artifacts is a no-op
+	require.NoError(t, store.Delete("session-3"))
+	require.Error(t, store.Delete("../session"))
+}
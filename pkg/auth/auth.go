@@ -0,0 +1,93 @@
+// Package auth authenticates the callers of the API server.
+//
+// Callers send a bearer token that is either one of the static tokens of a
+// token file or a JWT signed by one of the keys of a JWKS file. Each token
+// grants a set of scopes and identifies a subject, the owner of the sessions
+// created with it.
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// Scope is a permission granted to a token
+type Scope string
+
+const (
+	// ScopeReadSessions allows listing and reading sessions
+	ScopeReadSessions Scope = "sessions:read"
+	// ScopeRunAgents allows listing agents, creating sessions and running agents in them
+	ScopeRunAgents Scope = "agents:run"
+	// ScopeEditAgents allows creating, editing, importing, pulling and pushing agents
+	ScopeEditAgents Scope = "agents:edit"
+)
+
+// Scopes are all the known scopes
+var Scopes = []Scope{ScopeReadSessions, ScopeRunAgents, ScopeEditAgents}
+
+// ErrInvalidToken is returned when a token isn't valid
+var ErrInvalidToken = errors.New("invalid token")
+
+// Principal is an authenticated caller
+type Principal struct {
+	// Subject identifies the caller, sessions are owned by their creator's subject
+	Subject string
+	Scopes  []Scope
+}
+
+// HasScope returns true if the principal was granted the scope
+func (p *Principal) HasScope(scope Scope) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// Authenticator validates bearer tokens
+type Authenticator interface {
+	// Authenticate returns the principal a token belongs to, or ErrInvalidToken
+	Authenticate(ctx context.Context, token string) (*Principal, error)
+}
+
+// Chain returns an authenticator that accepts the tokens accepted by any of the authenticators
+func Chain(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+type chain []Authenticator
+
+func (c chain) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	for _, a := range c {
+		principal, err := a.Authenticate(ctx, token)
+		if err == nil {
+			return principal, nil
+		}
+		if !errors.Is(err, ErrInvalidToken) {
+			return nil, err
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+func parseScopes(scopes []string) ([]Scope, error) {
+	parsed := make([]Scope, 0, len(scopes))
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, Scope(scope)) {
+			return nil, errors.New("unknown scope " + scope)
+		}
+		parsed = append(parsed, Scope(scope))
+	}
+	return parsed, nil
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated caller
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated caller, or nil if authentication is disabled
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestStaticTokens(t *testing.T) {
+	hash := sha256.Sum256([]byte("ci-token"))
+	path := writeFile(t, "tokens.yaml", `tokens:
+  - subject: alice
+    token: alice-token
+    scopes: [sessions:read, agents:run]
+  - subject: ci
+    token_sha256: `+hex.EncodeToString(hash[:])+`
+    scopes: [agents:run]
+`)
+
+	tokens, err := LoadTokens(path)
+	require.NoError(t, err)
+
+	principal, err := tokens.Authenticate(t.Context(), "alice-token")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", principal.Subject)
+	assert.True(t, principal.HasScope(ScopeReadSessions))
+	assert.False(t, principal.HasScope(ScopeEditAgents))
+
+	principal, err = tokens.Authenticate(t.Context(), "ci-token")
+	require.NoError(t, err)
+	assert.Equal(t, "ci", principal.Subject)
+
+	_, err = tokens.Authenticate(t.Context(), "other")
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestStaticTokens_Invalid(t *testing.T) {
+	_, err := LoadTokens(writeFile(t, "tokens.yaml", "tokens:\n  - subject: alice\n    token: x\n    scopes: [admin]\n"))
+	require.ErrorContains(t, err, "unknown scope admin")
+
+	_, err = LoadTokens(writeFile(t, "tokens.yaml", "tokens:\n  - subject: alice\n    scopes: [agents:run]\n"))
+	require.ErrorContains(t, err, "token or token_sha256 is required")
+}
+
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+
+	buf, err := json.Marshal(v)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func signJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+
+	signed := encodeSegment(t, map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encodeSegment(t, claims)
+
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256([]byte(signed))
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		sum := sha256.Sum256([]byte(signed))
+		r, s, err := ecdsa.Sign(rand.Reader, key, sum[:])
+		require.NoError(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(signed))
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	jwks := map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "alg": "RS256", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edPublic)},
+	}}
+	buf, err := json.Marshal(jwks)
+	require.NoError(t, err)
+
+	verifier, err := LoadJWKS(writeFile(t, "jwks.json", string(buf)), WithIssuer("https://issuer"), WithAudience("rb"))
+	require.NoError(t, err)
+
+	now := time.Now()
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"sub":   "alice",
+			"iss":   "https://issuer",
+			"aud":   []string{"rb", "other"},
+			"exp":   now.Add(time.Hour).Unix(),
+			"scope": "openid sessions:read agents:run",
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	for _, tc := range []struct {
+		alg, kid string
+		key      crypto.Signer
+	}{
+		{"RS256", "rsa", rsaKey},
+		{"ES256", "ec", ecKey},
+		{"EdDSA", "ed", edKey},
+	} {
+		principal, err := verifier.Authenticate(t.Context(), signJWT(t, tc.alg, tc.kid, tc.key, claims(nil)))
+		require.NoError(t, err, tc.alg)
+		assert.Equal(t, "alice", principal.Subject)
+		assert.Equal(t, []Scope{ScopeReadSessions, ScopeRunAgents}, principal.Scopes)
+	}
+
+	principal, err := verifier.Authenticate(t.Context(), signJWT(t, "ES256", "", ecKey, claims(map[string]any{"scope": nil, "scp": []string{"agents:edit"}, "aud": "rb"})))
+	require.NoError(t, err)
+	assert.Equal(t, []Scope{ScopeEditAgents}, principal.Scopes)
+
+	for name, token := range map[string]string{
+		"expired":       signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]any{"exp": now.Add(-time.Hour).Unix()})),
+		"not yet valid": signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]any{"nbf": now.Add(time.Hour).Unix()})),
+		"wrong issuer":  signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]any{"iss": "https://other"})),
+		"wrong aud":     signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]any{"aud": "other"})),
+		"no subject":    signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]any{"sub": ""})),
+		"wrong key":     signJWT(t, "ES256", "rsa", ecKey, claims(nil)),
+		"malformed":     "not.a.jwt",
+		"unsigned":      encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, claims(nil)) + ".",
+	} {
+		_, err := verifier.Authenticate(t.Context(), token)
+		require.ErrorIs(t, err, ErrInvalidToken, name)
+	}
+
+	// Tampered claims
+	token := signJWT(t, "EdDSA", "ed", edKey, claims(nil))
+	parts := strings.Split(token, ".")
+	parts[1] = encodeSegment(t, claims(map[string]any{"sub": "mallory"}))
+	_, err = verifier.Authenticate(t.Context(), parts[0]+"."+parts[1]+"."+parts[2])
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestChain(t *testing.T) {
+	path := writeFile(t, "tokens.yaml", "tokens:\n  - subject: alice\n    token: alice-token\n    scopes: [agents:run]\n")
+	tokens, err := LoadTokens(path)
+	require.NoError(t, err)
+	other, err := LoadTokens(writeFile(t, "other.yaml", "tokens:\n  - subject: bob\n    token: bob-token\n    scopes: [agents:run]\n"))
+	require.NoError(t, err)
+
+	chain := Chain(tokens, other)
+
+	principal, err := chain.Authenticate(t.Context(), "bob-token")
+	require.NoError(t, err)
+	assert.Equal(t, "bob", principal.Subject)
+
+	_, err = chain.Authenticate(t.Context(), "unknown")
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
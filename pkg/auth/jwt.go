@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// jwtLeeway is the clock skew tolerated when checking the validity period of a JWT
+const jwtLeeway = time.Minute
+
+// JWT authenticates callers with JWTs signed by one of the keys of a JWKS file.
+// The subject is read from the "sub" claim and the scopes from the "scope"
+// claim, a space separated list, or from the "scopes" or "scp" claims.
+type JWT struct {
+	keys     jwk.Set
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+type JWTOpt func(*JWT)
+
+// WithIssuer only accepts the JWTs with the given "iss" claim
+func WithIssuer(issuer string) JWTOpt {
+	return func(j *JWT) {
+		j.issuer = issuer
+	}
+}
+
+// WithAudience only accepts the JWTs with the given audience in their "aud" claim
+func WithAudience(audience string) JWTOpt {
+	return func(j *JWT) {
+		j.audience = audience
+	}
+}
+
+// LoadJWKS reads the keys used to verify JWTs from a JWKS file
+func LoadJWKS(path string, opts ...JWTOpt) (*JWT, error) {
+	set, err := jwk.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("parsing JWKS file %s: %w", path, err)
+	}
+
+	j := &JWT{keys: jwk.NewSet(), now: time.Now}
+	for i := range set.Len() {
+		key, _ := set.Key(i)
+		if use := key.KeyUsage(); use != "" && use != string(jwk.ForSignature) {
+			continue
+		}
+		if err := j.keys.AddKey(key); err != nil {
+			return nil, fmt.Errorf("key %q of %s: %w", key.KeyID(), path, err)
+		}
+	}
+	if j.keys.Len() == 0 {
+		return nil, fmt.Errorf("no signing keys found in %s", path)
+	}
+
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	return j, nil
+}
+
+func (j *JWT) Authenticate(_ context.Context, token string) (*Principal, error) {
+	options := []jwt.ParseOption{
+		jwt.WithKeyProvider(jws.KeyProviderFunc(j.fetchKeys)),
+		jwt.WithValidate(true),
+		jwt.WithClock(jwt.ClockFunc(j.now)),
+		jwt.WithAcceptableSkew(jwtLeeway),
+		jwt.WithRequiredClaim(jwt.ExpirationKey),
+	}
+	if j.issuer != "" {
+		options = append(options, jwt.WithIssuer(j.issuer))
+	}
+	if j.audience != "" {
+		options = append(options, jwt.WithAudience(j.audience))
+	}
+
+	parsed, err := jwt.ParseString(token, options...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if parsed.Subject() == "" {
+		return nil, fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+
+	return &Principal{Subject: parsed.Subject(), Scopes: scopes(parsed.PrivateClaims())}, nil
+}
+
+// fetchKeys gives the key named by the "kid" header of a token to verify its
+// signature, or all the keys when the token doesn't name one. Keys without an
+// algorithm accept the algorithms of their type.
+func (j *JWT) fetchKeys(_ context.Context, sink jws.KeySink, sig *jws.Signature, _ *jws.Message) error {
+	kid := sig.ProtectedHeaders().KeyID()
+	alg := sig.ProtectedHeaders().Algorithm()
+	for i := range j.keys.Len() {
+		key, _ := j.keys.Key(i)
+		if kid != "" && key.KeyID() != kid {
+			continue
+		}
+		if keyAlg := key.Algorithm().String(); keyAlg != "" && keyAlg != alg.String() {
+			continue
+		}
+		if algs, err := jws.AlgorithmsForKey(key); err == nil && slices.Contains(algs, alg) {
+			sink.Key(alg, key)
+		}
+	}
+	return nil
+}
+
+// scopes returns the known scopes of the token, other scopes are ignored since
+// tokens issued by an identity provider often carry scopes for other services.
+func scopes(claims map[string]any) []Scope {
+	var names []string
+	for _, claim := range []string{"scope", "scopes", "scp"} {
+		names = append(names, stringOrList(claims[claim])...)
+	}
+
+	var scopes []Scope
+	for _, name := range names {
+		if slices.Contains(Scopes, Scope(name)) && !slices.Contains(scopes, Scope(name)) {
+			scopes = append(scopes, Scope(name))
+		}
+	}
+	return scopes
+}
+
+// stringOrList reads a claim that is either a space separated string or a list of strings
+func stringOrList(claim any) []string {
+	switch claim := claim.(type) {
+	case string:
+		return strings.Fields(claim)
+	case []any:
+		var list []string
+		for _, item := range claim {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
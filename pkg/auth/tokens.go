@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/goccy/go-yaml"
+)
+
+// tokenFile is the format of the static token file:
+//
+//	tokens:
+//	  - subject: alice
+//	    token: some-secret-token
+//	    scopes: [sessions:read, agents:run]
+//	  - subject: ci
+//	    token_sha256: 5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8
+//	    scopes: [agents:run]
+type tokenFile struct {
+	Tokens []tokenEntry `json:"tokens"`
+}
+
+type tokenEntry struct {
+	Subject string `json:"subject"`
+	// Token is the token in clear, TokenSHA256 is the hex encoded SHA-256 of the token
+	Token       string   `json:"token,omitempty"`
+	TokenSHA256 string   `json:"token_sha256,omitempty"`
+	Scopes      []string `json:"scopes"`
+}
+
+type staticToken struct {
+	hash      [sha256.Size]byte
+	principal Principal
+}
+
+// StaticTokens authenticates callers with a fixed list of tokens
+type StaticTokens struct {
+	tokens []staticToken
+}
+
+// LoadTokens reads the static tokens from a YAML file
+func LoadTokens(path string) (*StaticTokens, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file tokenFile
+	if err := yaml.Unmarshal(buf, &file); err != nil {
+		return nil, fmt.Errorf("parsing token file %s: %w", path, err)
+	}
+
+	tokens := &StaticTokens{}
+	for i, entry := range file.Tokens {
+		token, err := parseTokenEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("token %d of %s: %w", i+1, path, err)
+		}
+		tokens.tokens = append(tokens.tokens, token)
+	}
+
+	return tokens, nil
+}
+
+func parseTokenEntry(entry tokenEntry) (staticToken, error) {
+	if entry.Subject == "" {
+		return staticToken{}, errors.New("subject is required")
+	}
+
+	scopes, err := parseScopes(entry.Scopes)
+	if err != nil {
+		return staticToken{}, err
+	}
+
+	token := staticToken{
+		principal: Principal{Subject: entry.Subject, Scopes: scopes},
+	}
+
+	switch {
+	case entry.Token != "" && entry.TokenSHA256 != "":
+		return staticToken{}, errors.New("token and token_sha256 are mutually exclusive")
+	case entry.Token != "":
+		token.hash = sha256.Sum256([]byte(entry.Token))
+	case entry.TokenSHA256 != "":
+		hash, err := hex.DecodeString(entry.TokenSHA256)
+		if err != nil || len(hash) != sha256.Size {
+			return staticToken{}, errors.New("token_sha256 must be a hex encoded SHA-256 hash")
+		}
+		copy(token.hash[:], hash)
+	default:
+		return staticToken{}, errors.New("token or token_sha256 is required")
+	}
+
+	return token, nil
+}
+
+func (t *StaticTokens) Authenticate(_ context.Context, token string) (*Principal, error) {
+	hash := sha256.Sum256([]byte(token))
+
+	// Compare with all the tokens so that the time taken doesn't depend on which one matches
+	var principal *Principal
+	for i := range t.tokens {
+		if subtle.ConstantTimeCompare(hash[:], t.tokens[i].hash[:]) == 1 {
+			principal = &t.tokens[i].principal
+		}
+	}
+	if principal == nil {
+		return nil, ErrInvalidToken
+	}
+
+	return &Principal{Subject: principal.Subject, Scopes: principal.Scopes}, nil
+}
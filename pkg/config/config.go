@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 
 	"github.com/goccy/go-yaml"
@@ -78,8 +79,11 @@ func validateConfig(cfg *latest.Config) error {
 	for agentName := range cfg.Agents {
 		agent := cfg.Agents[agentName]
 
-		modelNames := strings.SplitSeq(agent.Model, ",")
-		for modelName := range modelNames {
+		modelNames := slices.Concat(strings.Split(agent.Model, ","), agent.FallbackModels)
+		if agent.Compaction != nil && agent.Compaction.Model != "" {
+			modelNames = append(modelNames, agent.Compaction.Model)
+		}
+		for _, modelName := range modelNames {
 			if _, exists := cfg.Models[modelName]; exists {
 				continue
 			}
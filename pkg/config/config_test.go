@@ -42,6 +42,48 @@ func TestAutoRegisterAlloy(t *testing.T) {
 	assert.Equal(t, "claude-sonnet-4-0", cfg.Models["anthropic/claude-sonnet-4-0"].Model)
 }
 
+func TestAutoRegisterFallbackModels(t *testing.T) {
+	t.Parallel()
+
+	root := openRoot(t, "testdata")
+
+	cfg, err := LoadConfig("fallback_models.yaml", root)
+	require.NoError(t, err)
+
+	assert.Len(t, cfg.Models, 3)
+	assert.Equal(t, []string{"openai/gpt-4o", "local"}, cfg.Agents["root"].FallbackModels)
+	assert.Equal(t, 3, cfg.Agents["root"].MaxRetries)
+	assert.Equal(t, "openai", cfg.Models["openai/gpt-4o"].Provider)
+	assert.Equal(t, "dmr", cfg.Models["local"].Provider)
+}
+
+func TestCompaction(t *testing.T) {
+	t.Parallel()
+
+	root := openRoot(t, "testdata")
+
+	cfg, err := LoadConfig("compaction.yaml", root)
+	require.NoError(t, err)
+
+	assert.Equal(t, &latest.CompactionConfig{Mode: "structured", KeepTurns: 2, Model: "openai/gpt-4o-mini"}, cfg.Agents["root"].Compaction)
+	assert.Equal(t, "gpt-4o-mini", cfg.Models["openai/gpt-4o-mini"].Model)
+}
+
+func TestOutputTransforms(t *testing.T) {
+	t.Parallel()
+
+	root := openRoot(t, "testdata")
+
+	cfg, err := LoadConfig("output_transforms.yaml", root)
+	require.NoError(t, err)
+
+	assert.Equal(t, []latest.OutputTransform{
+		{Type: "select", Tools: []string{"^list_.*"}, Fields: []string{".items[].number", ".items[].title"}},
+		{Type: "toon"},
+		{Type: "truncate", MaxLines: 200},
+	}, cfg.Agents["root"].Toolsets[0].OutputTransforms)
+}
+
 func openRoot(t *testing.T, dir string) *os.Root {
 	t.Helper()
 
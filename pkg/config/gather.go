@@ -17,8 +17,8 @@ import (
 func gatherMissingEnvVars(ctx context.Context, cfg *latest.Config, env environment.Provider, runtimeConfig RuntimeConfig) (missing []string, toolErr error) {
 	requiredEnv := map[string]bool{}
 
-	// Models
-	if runtimeConfig.ModelsGateway == "" {
+	// Models, no credentials are needed when all the responses are replayed
+	if runtimeConfig.ModelsGateway == "" && runtimeConfig.CassetteMode != "strict" {
 		names := GatherEnvVarsForModels(cfg)
 		for _, e := range names {
 			requiredEnv[e] = true
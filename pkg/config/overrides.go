@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	v2 "github.com/rumpl/rb/pkg/config/v2"
@@ -84,8 +85,11 @@ func ensureModelsExist(cfg *v2.Config) error {
 	for agentName := range cfg.Agents {
 		agentConfig := cfg.Agents[agentName]
 
-		modelNames := strings.SplitSeq(agentConfig.Model, ",")
-		for modelName := range modelNames {
+		modelNames := slices.Concat(strings.Split(agentConfig.Model, ","), agentConfig.FallbackModels)
+		if agentConfig.Compaction != nil && agentConfig.Compaction.Model != "" {
+			modelNames = append(modelNames, agentConfig.Compaction.Model)
+		}
+		for _, modelName := range modelNames {
 			if _, exists := cfg.Models[modelName]; exists {
 				continue
 			}
@@ -8,4 +8,10 @@ type RuntimeConfig struct {
 	ModelsGateway      string
 	GlobalCodeMode     bool
 	WorkingDir         string
+	// Sandbox runs the commands of all the shell and script tools in a sandbox
+	Sandbox bool
+	// Cassette is the file the responses of the models are recorded to and replayed from
+	Cassette string
+	// CassetteMode is "replay" (default), "record" or "strict"
+	CassetteMode string
 }
@@ -14,21 +14,89 @@ type Config struct {
 
 // AgentConfig represents a single agent configuration
 type AgentConfig struct {
-	Model              string            `json:"model,omitempty"`
-	Description        string            `json:"description,omitempty"`
-	WelcomeMessage     string            `json:"welcome_message,omitempty"`
-	Toolsets           []Toolset         `json:"toolsets,omitempty"`
-	Instruction        string            `json:"instruction,omitempty"`
-	SubAgents          []string          `json:"sub_agents,omitempty"`
-	Handoffs           []string          `json:"handoffs,omitempty"`
-	AddDate            bool              `json:"add_date,omitempty"`
-	AddEnvironmentInfo bool              `json:"add_environment_info,omitempty"`
-	CodeModeTools      bool              `json:"code_mode_tools,omitempty"`
-	MaxIterations      int               `json:"max_iterations,omitempty"`
-	NumHistoryItems    int               `json:"num_history_items,omitempty"`
-	AddPromptFiles     []string          `json:"add_prompt_files,omitempty" yaml:"add_prompt_files,omitempty"`
-	Commands           types.Commands    `json:"commands,omitempty"`
-	StructuredOutput   *StructuredOutput `json:"structured_output,omitempty"`
+	Model                string             `json:"model,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	WelcomeMessage       string             `json:"welcome_message,omitempty"`
+	Toolsets             []Toolset          `json:"toolsets,omitempty"`
+	Instruction          string             `json:"instruction,omitempty"`
+	SubAgents            []string           `json:"sub_agents,omitempty"`
+	Handoffs             []string           `json:"handoffs,omitempty"`
+	AddDate              bool               `json:"add_date,omitempty"`
+	AddEnvironmentInfo   bool               `json:"add_environment_info,omitempty"`
+	CodeModeTools        bool               `json:"code_mode_tools,omitempty"`
+	MaxIterations        int                `json:"max_iterations,omitempty"`
+	MaxCost              float64            `json:"max_cost,omitempty"`
+	MaxTokensTotal       int                `json:"max_tokens_total,omitempty"`
+	NumHistoryItems      int                `json:"num_history_items,omitempty"`
+	AddPromptFiles       []string           `json:"add_prompt_files,omitempty" yaml:"add_prompt_files,omitempty"`
+	Commands             types.Commands     `json:"commands,omitempty"`
+	StructuredOutput     *StructuredOutput  `json:"structured_output,omitempty"`
+	Permissions          *PermissionsConfig `json:"permissions,omitempty"`
+	MaxParallelToolCalls int                `json:"max_parallel_tool_calls,omitempty"`
+	FallbackModels       []string           `json:"fallback_models,omitempty"`
+	MaxRetries           int                `json:"max_retries,omitempty"`
+	Context              *ContextConfig     `json:"context,omitempty"`
+	Compaction           *CompactionConfig  `json:"compaction,omitempty"`
+}
+
+// ContextConfig configures how the conversation is kept within the model's
+// context window, e.g.:
+//
+//	context:
+//	  limit: 32000
+//	  threshold: 0.8
+//	  strategies: [drop_tool_outputs, truncate_tool_outputs, summarize]
+//	  max_tool_output_tokens: 4000
+type ContextConfig struct {
+	// Limit overrides the size of the model's context window, in tokens.
+	Limit int `json:"limit,omitempty"`
+	// Threshold is the fraction of the context window a request can use before
+	// the strategies are applied. Defaults to 0.9.
+	Threshold float64 `json:"threshold,omitempty"`
+	// Strategies are applied in order until the request fits.
+	Strategies []string `json:"strategies,omitempty"`
+	// MaxToolOutputTokens is the size tool outputs are truncated to.
+	MaxToolOutputTokens int `json:"max_tool_output_tokens,omitempty"`
+}
+
+// CompactionConfig configures how the session is summarized when it's
+// compacted, e.g.:
+//
+//	compaction:
+//	  mode: structured
+//	  keep_turns: 2
+//	  model: cheap_model
+type CompactionConfig struct {
+	// Mode is either "summary", a prose summary of the conversation, or
+	// "structured", a summary organized in sections that also covers the tool
+	// results. Defaults to "summary".
+	Mode string `json:"mode,omitempty"`
+	// KeepTurns is the number of most recent turns kept verbatim after the summary.
+	KeepTurns int `json:"keep_turns,omitempty"`
+	// Model is the name of the model generating the summary, defaults to the agent's model.
+	Model string `json:"model,omitempty"`
+}
+
+// PermissionsConfig declares which tool calls an agent can run without asking,
+// which ones always need confirmation and which ones are never allowed.
+// Deny rules take precedence over ask rules, which take precedence over allow rules.
+type PermissionsConfig struct {
+	Allow []PermissionRule `json:"allow,omitempty"`
+	Ask   []PermissionRule `json:"ask,omitempty"`
+	Deny  []PermissionRule `json:"deny,omitempty"`
+}
+
+// PermissionRule matches tool calls by tool name and argument values.
+// Both the tool name and the argument values are globs, `\*` and `\?` match
+// the characters themselves, e.g.:
+//
+//	allow:
+//	  - tool: shell
+//	    args:
+//	      cmd: "git *"
+type PermissionRule struct {
+	Tool string            `json:"tool"`
+	Args map[string]string `json:"args,omitempty"`
 }
 
 // ModelConfig represents the configuration for a model
@@ -111,6 +179,13 @@ type Toolset struct {
 	Tools       []string `json:"tools,omitempty"`
 	Instruction string   `json:"instruction,omitempty"`
 	Toon        string   `json:"toon,omitempty"`
+	Parallel    *bool    `json:"parallel,omitempty"`
+	// MaxOutputSize is the size, in bytes, above which tool outputs are saved
+	// to a file and replaced by a preview the agent can page through.
+	MaxOutputSize int `json:"max_output_size,omitempty"`
+	// OutputTransforms rewrite the outputs of the tools, in order, before the
+	// agent sees them
+	OutputTransforms []OutputTransform `json:"output_transforms,omitempty"`
 
 	// For the `mcp` tool
 	Command string   `json:"command,omitempty"`
@@ -122,11 +197,28 @@ type Toolset struct {
 	// For `shell`, `script` or `mcp` tools
 	Env map[string]string `json:"env,omitempty"`
 
+	// For the `shell` tool - keep a shell process per session
+	Persistent bool `json:"persistent,omitempty"`
+
+	// For the `shell` or `script` tools - run commands in a sandbox
+	Sandbox *SandboxConfig `json:"sandbox,omitempty"`
+
 	// For the `todo` tool
 	Shared bool `json:"shared,omitempty"`
 
 	// For the `memory` tool
 	Path string `json:"path,omitempty"`
+	// Scope is either "shared", the default, where all the agents see the same
+	// memories, or "agent", where each agent also has its own memories
+	Scope string `json:"scope,omitempty"`
+	// Recall is the number of memories relevant to the last message of the
+	// user added to the system prompt, disabled by default. The system prompt
+	// then changes with every message, which defeats prompt caching.
+	Recall int `json:"recall,omitempty"`
+	// Embeddings adds fuzzy lexical matching on top of full-text search: the
+	// memories are also ranked by the words and character trigrams they share
+	// with the query. It matches inflections and typos, not synonyms.
+	Embeddings bool `json:"embeddings,omitempty"`
 
 	// For the `script` tool
 	Shell map[string]ScriptShellToolConfig `json:"shell,omitempty"`
@@ -153,6 +245,31 @@ func (t *Toolset) UnmarshalYAML(unmarshal func(any) error) error {
 	return t.validate()
 }
 
+// OutputTransform rewrites the outputs of some tools of a toolset
+type OutputTransform struct {
+	// Type is "toon", to encode JSON in TOON, "select", to keep some fields of
+	// JSON outputs, "truncate" or "markdown", to convert HTML to markdown
+	Type string `json:"type"`
+	// Tools are regular expressions matched against the names of the tools
+	// the transform applies to, all the tools of the toolset when empty
+	Tools []string `json:"tools,omitempty"`
+	// Fields are the jq-style paths "select" keeps, like .items[].name
+	Fields []string `json:"fields,omitempty"`
+	// MaxLines and MaxBytes are the limits of "truncate"
+	MaxLines int `json:"max_lines,omitempty"`
+	MaxBytes int `json:"max_bytes,omitempty"`
+}
+
+// SandboxConfig represents the sandbox shell and script commands run in. The
+// filesystem is read-only, except for the working directory and the writable
+// paths, and the network is disabled.
+type SandboxConfig struct {
+	// Network gives sandboxed commands access to the network
+	Network bool `json:"network,omitempty"`
+	// Writable are additional paths sandboxed commands can write to
+	Writable []string `json:"writable,omitempty"`
+}
+
 type Remote struct {
 	URL           string            `json:"url"`
 	TransportType string            `json:"transport_type,omitempty"`
@@ -2,6 +2,8 @@ package v2
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
 	"strings"
 )
 
@@ -23,12 +25,91 @@ func (t *Config) validate() error {
 				return err
 			}
 		}
+		if err := agent.Permissions.validate(); err != nil {
+			return err
+		}
+		if agent.MaxRetries < 0 {
+			return errors.New("max_retries must not be negative")
+		}
+		if err := agent.Context.validate(); err != nil {
+			return err
+		}
+		if err := agent.Compaction.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *PermissionsConfig) validate() error {
+	if p == nil {
+		return nil
+	}
+
+	for _, rules := range [][]PermissionRule{p.Allow, p.Ask, p.Deny} {
+		for _, rule := range rules {
+			if rule.Tool == "" {
+				return errors.New("permission rules require a tool name")
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *ContextConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+
+	if c.Limit < 0 {
+		return errors.New("context limit must not be negative")
+	}
+	if c.Threshold < 0 || c.Threshold > 1 {
+		return errors.New("context threshold must be between 0 and 1")
+	}
+	if c.MaxToolOutputTokens < 0 {
+		return errors.New("context max_tool_output_tokens must not be negative")
+	}
+	for _, strategy := range c.Strategies {
+		switch strategy {
+		case "drop_tool_outputs", "truncate_tool_outputs", "summarize":
+		default:
+			return fmt.Errorf("unknown context strategy %q", strategy)
+		}
+	}
+
+	return nil
+}
+
+func (c *CompactionConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+
+	switch c.Mode {
+	case "", "summary", "structured":
+	default:
+		return fmt.Errorf("unknown compaction mode %q", c.Mode)
+	}
+	if c.KeepTurns < 0 {
+		return errors.New("compaction keep_turns must not be negative")
 	}
 
 	return nil
 }
 
 func (t *Toolset) validate() error {
+	if t.MaxOutputSize < 0 {
+		return errors.New("max_output_size must not be negative")
+	}
+	for i := range t.OutputTransforms {
+		if err := t.OutputTransforms[i].validate(); err != nil {
+			return err
+		}
+	}
+
 	// Attributes used on the wrong toolset type.
 	if len(t.Shell) > 0 && t.Type != "script" {
 		return errors.New("shell can only be used with type 'script'")
@@ -36,6 +117,15 @@ func (t *Toolset) validate() error {
 	if t.Path != "" && t.Type != "memory" {
 		return errors.New("path can only be used with type 'memory'")
 	}
+	if t.Scope != "" && t.Type != "memory" {
+		return errors.New("scope can only be used with type 'memory'")
+	}
+	if t.Recall != 0 && t.Type != "memory" {
+		return errors.New("recall can only be used with type 'memory'")
+	}
+	if t.Embeddings && t.Type != "memory" {
+		return errors.New("embeddings can only be used with type 'memory'")
+	}
 	if len(t.PostEdit) > 0 && t.Type != "filesystem" {
 		return errors.New("post_edit can only be used with type 'filesystem'")
 	}
@@ -45,6 +135,12 @@ func (t *Toolset) validate() error {
 	if len(t.Env) > 0 && (t.Type != "shell" && t.Type != "script" && t.Type != "mcp") {
 		return errors.New("env can only be used with type 'shell', 'script' or 'mcp'")
 	}
+	if t.Persistent && t.Type != "shell" {
+		return errors.New("persistent can only be used with type 'shell'")
+	}
+	if t.Sandbox != nil && t.Type != "shell" && t.Type != "script" {
+		return errors.New("sandbox can only be used with type 'shell' or 'script'")
+	}
 	if t.Shared && t.Type != "todo" {
 		return errors.New("shared can only be used with type 'todo'")
 	}
@@ -69,6 +165,12 @@ func (t *Toolset) validate() error {
 		if t.Path == "" {
 			return errors.New("memory toolset requires a path to be set")
 		}
+		if t.Scope != "" && t.Scope != "shared" && t.Scope != "agent" {
+			return fmt.Errorf("invalid memory scope %q, must be 'shared' or 'agent'", t.Scope)
+		}
+		if t.Recall < 0 {
+			return errors.New("recall must not be negative")
+		}
 	case "mcp":
 		count := 0
 		if t.Command != "" {
@@ -94,3 +196,34 @@ func (t *Toolset) validate() error {
 
 	return nil
 }
+
+func (o *OutputTransform) validate() error {
+	switch o.Type {
+	case "toon", "select", "truncate", "markdown":
+	default:
+		return fmt.Errorf("unknown output transform %q", o.Type)
+	}
+
+	if len(o.Fields) > 0 && o.Type != "select" {
+		return errors.New("fields can only be used with output transform 'select'")
+	}
+	if o.Type == "select" && len(o.Fields) == 0 {
+		return errors.New("output transform 'select' requires fields")
+	}
+	if (o.MaxLines != 0 || o.MaxBytes != 0) && o.Type != "truncate" {
+		return errors.New("max_lines and max_bytes can only be used with output transform 'truncate'")
+	}
+	if o.MaxLines < 0 || o.MaxBytes < 0 {
+		return errors.New("max_lines and max_bytes must not be negative")
+	}
+	if o.Type == "truncate" && o.MaxLines == 0 && o.MaxBytes == 0 {
+		return errors.New("output transform 'truncate' requires max_lines or max_bytes")
+	}
+	for _, tool := range o.Tools {
+		if _, err := regexp.Compile(tool); err != nil {
+			return fmt.Errorf("invalid output transform tool pattern %q: %w", tool, err)
+		}
+	}
+
+	return nil
+}
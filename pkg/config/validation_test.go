@@ -35,6 +35,14 @@ func TestValidationErrors(t *testing.T) {
 			name: "memory toolset missing path",
 			path: "missing_memory_path_v2.yaml",
 		},
+		{
+			name: "invalid memory scope",
+			path: "invalid_memory_scope_v2.yaml",
+		},
+		{
+			name: "negative memory recall",
+			path: "invalid_memory_recall_v2.yaml",
+		},
 		{
 			name: "path in non memory toolset",
 			path: "invalid_path_v2.yaml",
@@ -43,6 +51,10 @@ func TestValidationErrors(t *testing.T) {
 			name: "post_edit in non filesystem toolset",
 			path: "invalid_post_edit_v2.yaml",
 		},
+		{
+			name: "truncate output transform without limits",
+			path: "invalid_output_transform_v2.yaml",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,205 @@
+// Package contextwindow keeps the requests sent to a model within its context window.
+//
+// Before each request, the Manager estimates the size of the messages and of the
+// tool definitions that will be sent. When the estimate goes over the configured
+// threshold, it applies its strategies in order until the request fits: dropping the
+// oldest tool outputs, truncating large tool outputs and, as a last resort, asking
+// the runtime to summarize the conversation.
+package contextwindow
+
+import (
+	"fmt"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+// Strategy is a way of reducing the size of a request.
+type Strategy string
+
+const (
+	// StrategyDropToolOutputs replaces the oldest tool outputs with a placeholder.
+	// The outputs of the tool calls the model is currently waiting for are kept.
+	StrategyDropToolOutputs Strategy = "drop_tool_outputs"
+	// StrategyTruncateToolOutputs keeps the beginning and the end of tool outputs
+	// that are larger than MaxToolOutputTokens.
+	StrategyTruncateToolOutputs Strategy = "truncate_tool_outputs"
+	// StrategySummarize replaces the conversation with a summary.
+	StrategySummarize Strategy = "summarize"
+)
+
+const (
+	// DefaultLimit is the context window used when it's neither configured
+	// nor known from the model's definition.
+	DefaultLimit = 128_000
+	// DefaultThreshold is the fraction of the context window a request can use.
+	DefaultThreshold = 0.9
+	// DefaultMaxToolOutputTokens is the size tool outputs are truncated to.
+	DefaultMaxToolOutputTokens = 8_000
+
+	// DroppedToolOutput replaces the tool outputs dropped from a request.
+	DroppedToolOutput = "[tool output removed to save context]"
+
+	minCalibration = 0.5
+	maxCalibration = 8.0
+)
+
+// DefaultStrategies are the strategies used when none are configured.
+var DefaultStrategies = []Strategy{StrategyDropToolOutputs, StrategyTruncateToolOutputs, StrategySummarize}
+
+// Config configures a Manager. Zero values are replaced by defaults.
+type Config struct {
+	// Limit is the size of the context window, in tokens.
+	Limit int
+	// Threshold is the fraction of Limit a request can use.
+	Threshold float64
+	// Strategies are applied in order until the request fits.
+	Strategies []Strategy
+	// MaxToolOutputTokens is the size tool outputs are truncated to.
+	MaxToolOutputTokens int
+}
+
+// Manager fits requests into a model's context window.
+type Manager struct {
+	limit               int
+	threshold           float64
+	strategies          []Strategy
+	maxToolOutputTokens int
+
+	// calibration corrects the estimates using the token counts reported by the model.
+	calibration float64
+}
+
+// New creates a Manager from the given configuration.
+func New(cfg Config) *Manager {
+	m := &Manager{
+		limit:               cfg.Limit,
+		threshold:           cfg.Threshold,
+		strategies:          cfg.Strategies,
+		maxToolOutputTokens: cfg.MaxToolOutputTokens,
+		calibration:         1,
+	}
+	if m.threshold <= 0 || m.threshold > 1 {
+		m.threshold = DefaultThreshold
+	}
+	if m.strategies == nil {
+		m.strategies = DefaultStrategies
+	}
+	if m.maxToolOutputTokens <= 0 {
+		m.maxToolOutputTokens = DefaultMaxToolOutputTokens
+	}
+	return m
+}
+
+// Limit returns the size of the context window, in tokens. modelLimit is the
+// limit known from the model's definition, or 0 if the model is unknown.
+// A configured limit takes precedence over the model's.
+func (m *Manager) Limit(modelLimit int) int {
+	switch {
+	case m.limit > 0:
+		return m.limit
+	case modelLimit > 0:
+		return modelLimit
+	default:
+		return DefaultLimit
+	}
+}
+
+// Estimate returns the calibrated estimate of the number of input tokens of a request.
+func (m *Manager) Estimate(messages []chat.Message, agentTools []tools.Tool) int {
+	return int(float64(EstimateTokens(messages, agentTools)) * m.calibration)
+}
+
+// Calibrate adjusts future estimates using the number of input tokens the model
+// reported for a request made of the given messages and tools.
+func (m *Manager) Calibrate(messages []chat.Message, agentTools []tools.Tool, inputTokens int) {
+	estimated := EstimateTokens(messages, agentTools)
+	if estimated == 0 || inputTokens <= 0 {
+		return
+	}
+	m.calibration = min(max(float64(inputTokens)/float64(estimated), minCalibration), maxCalibration)
+}
+
+// Fit applies the non-destructive strategies to messages until the request fits
+// into limit. The returned messages are a copy, the original messages are never
+// modified. needsSummary is true when the request still doesn't fit and the
+// conversation should be summarized.
+func (m *Manager) Fit(messages []chat.Message, agentTools []tools.Tool, limit int) (fitted []chat.Message, needsSummary bool) {
+	budget := int(float64(limit) * m.threshold)
+	fits := func() bool {
+		return m.Estimate(fitted, agentTools) <= budget
+	}
+
+	fitted = messages
+	if fits() {
+		return fitted, false
+	}
+	fitted = slices.Clone(messages)
+
+	for _, strategy := range m.strategies {
+		switch strategy {
+		case StrategyDropToolOutputs:
+			for _, i := range droppableToolOutputs(fitted) {
+				fitted[i].Content = DroppedToolOutput
+				fitted[i].MultiContent = nil
+				if fits() {
+					return fitted, false
+				}
+			}
+		case StrategyTruncateToolOutputs:
+			for i := range fitted {
+				if fitted[i].Role == chat.MessageRoleTool {
+					fitted[i].Content = Truncate(fitted[i].Content, m.maxToolOutputTokens*charsPerToken)
+				}
+			}
+			if fits() {
+				return fitted, false
+			}
+		case StrategySummarize:
+			return fitted, true
+		}
+	}
+
+	return fitted, false
+}
+
+// droppableToolOutputs returns the indices of the tool outputs that can be dropped,
+// oldest first. The outputs that follow the last assistant message are the results
+// the model is waiting for and are never dropped.
+func droppableToolOutputs(messages []chat.Message) []int {
+	lastAssistant := -1
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == chat.MessageRoleAssistant {
+			lastAssistant = i
+			break
+		}
+	}
+
+	var indices []int
+	for i := range lastAssistant {
+		if messages[i].Role == chat.MessageRoleTool && messages[i].Content != DroppedToolOutput {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// Truncate keeps the beginning and the end of content so that it's at most maxChars long.
+func Truncate(content string, maxChars int) string {
+	if len(content) <= maxChars {
+		return content
+	}
+
+	// Don't cut UTF-8 sequences in half
+	head := maxChars / 2
+	for head > 0 && !utf8.RuneStart(content[head]) {
+		head--
+	}
+	tail := len(content) - (maxChars - head)
+	for tail < len(content) && !utf8.RuneStart(content[tail]) {
+		tail++
+	}
+	return fmt.Sprintf("%s\n\n[... %d characters truncated ...]\n\n%s", content[:head], tail-head, content[tail:])
+}
@@ -0,0 +1,111 @@
+package contextwindow
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+func conversation(toolOutput string) []chat.Message {
+	return []chat.Message{
+		{Role: chat.MessageRoleSystem, Content: "You are a helpful agent"},
+		{Role: chat.MessageRoleUser, Content: "Read the files"},
+		{Role: chat.MessageRoleAssistant, ToolCalls: []tools.ToolCall{{ID: "1", Function: tools.FunctionCall{Name: "read_file", Arguments: `{"path":"a"}`}}}},
+		{Role: chat.MessageRoleTool, ToolCallID: "1", Content: toolOutput},
+		{Role: chat.MessageRoleAssistant, ToolCalls: []tools.ToolCall{{ID: "2", Function: tools.FunctionCall{Name: "read_file", Arguments: `{"path":"b"}`}}}},
+		{Role: chat.MessageRoleTool, ToolCallID: "2", Content: toolOutput},
+	}
+}
+
+func TestLimit(t *testing.T) {
+	assert.Equal(t, 1000, New(Config{Limit: 1000}).Limit(200_000))
+	assert.Equal(t, 200_000, New(Config{}).Limit(200_000))
+	assert.Equal(t, DefaultLimit, New(Config{}).Limit(0))
+}
+
+func TestFitUnderBudget(t *testing.T) {
+	messages := conversation("small")
+
+	fitted, needsSummary := New(Config{}).Fit(messages, nil, 1000)
+
+	assert.False(t, needsSummary)
+	assert.Equal(t, messages, fitted)
+}
+
+func TestFitDropsOldestToolOutputs(t *testing.T) {
+	messages := conversation(strings.Repeat("x", 2000))
+
+	fitted, needsSummary := New(Config{}).Fit(messages, nil, 700)
+
+	assert.False(t, needsSummary)
+	assert.Equal(t, DroppedToolOutput, fitted[3].Content)
+	// The result the model is waiting for is kept
+	assert.Equal(t, messages[5].Content, fitted[5].Content)
+	// The original messages are left untouched
+	assert.NotEqual(t, DroppedToolOutput, messages[3].Content)
+}
+
+func TestFitTruncatesToolOutputs(t *testing.T) {
+	messages := conversation(strings.Repeat("x", 2000))
+
+	m := New(Config{
+		Strategies:          []Strategy{StrategyTruncateToolOutputs},
+		MaxToolOutputTokens: 50,
+	})
+	fitted, needsSummary := m.Fit(messages, nil, 500)
+
+	assert.False(t, needsSummary)
+	assert.Contains(t, fitted[5].Content, "characters truncated")
+	assert.Less(t, len(fitted[5].Content), 300)
+}
+
+func TestFitNeedsSummary(t *testing.T) {
+	messages := conversation(strings.Repeat("x", 2000))
+
+	_, needsSummary := New(Config{}).Fit(messages, nil, 100)
+	assert.True(t, needsSummary)
+
+	_, needsSummary = New(Config{Strategies: []Strategy{StrategyDropToolOutputs}}).Fit(messages, nil, 100)
+	assert.False(t, needsSummary)
+}
+
+func TestFitCountsTools(t *testing.T) {
+	messages := conversation("small")
+	agentTools := []tools.Tool{{
+		Name:        "read_file",
+		Description: strings.Repeat("Reads a file. ", 100),
+		Parameters:  map[string]any{"type": "object"},
+	}}
+
+	_, needsSummary := New(Config{}).Fit(messages, agentTools, 300)
+	assert.True(t, needsSummary)
+}
+
+func TestCalibrate(t *testing.T) {
+	messages := conversation("some output")
+	m := New(Config{})
+
+	estimate := m.Estimate(messages, nil)
+	m.Calibrate(messages, nil, estimate*2)
+	assert.InDelta(t, estimate*2, m.Estimate(messages, nil), 1)
+
+	m.Calibrate(messages, nil, estimate*100)
+	assert.InDelta(t, estimate*maxCalibration, m.Estimate(messages, nil), 1)
+}
+
+func TestTruncateKeepsValidUTF8(t *testing.T) {
+	content := strings.Repeat("é", 100)
+
+	truncated := Truncate(content, 51)
+
+	require.NotEqual(t, content, truncated)
+	assert.True(t, strings.HasPrefix(truncated, strings.Repeat("é", 12)))
+	assert.True(t, strings.HasSuffix(truncated, strings.Repeat("é", 13)))
+	assert.True(t, utf8.ValidString(truncated))
+}
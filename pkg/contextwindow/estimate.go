@@ -0,0 +1,73 @@
+package contextwindow
+
+import (
+	"encoding/json"
+
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+const (
+	// charsPerToken is a conservative average for English text and code
+	// across the tokenizers of the providers we support.
+	charsPerToken = 4
+	// messageOverhead accounts for the role and separators of each message.
+	messageOverhead = 4
+	// imageTokens is a rough estimate of what an image attachment costs.
+	imageTokens = 1000
+)
+
+// EstimateTokens returns an estimate of the number of input tokens a request
+// made of the given messages and tool definitions will use.
+func EstimateTokens(messages []chat.Message, agentTools []tools.Tool) int {
+	total := 0
+	for i := range messages {
+		total += EstimateMessageTokens(&messages[i])
+	}
+	for i := range agentTools {
+		total += EstimateToolTokens(&agentTools[i])
+	}
+	return total
+}
+
+// EstimateMessageTokens returns an estimate of the number of tokens of a single message.
+func EstimateMessageTokens(msg *chat.Message) int {
+	chars := len(msg.Content) + len(msg.ReasoningContent) + len(msg.ToolCallID)
+	tokens := messageOverhead
+
+	for _, part := range msg.MultiContent {
+		chars += len(part.Text)
+		if part.ImageURL != nil {
+			tokens += imageTokens
+		}
+	}
+	for _, call := range msg.ToolCalls {
+		chars += len(call.ID) + len(call.Function.Name) + len(call.Function.Arguments)
+	}
+	if msg.FunctionCall != nil {
+		chars += len(msg.FunctionCall.Name) + len(msg.FunctionCall.Arguments)
+	}
+
+	return tokens + charsToTokens(chars)
+}
+
+// EstimateToolTokens returns an estimate of the number of tokens the definition of
+// a tool takes in a request.
+func EstimateToolTokens(tool *tools.Tool) int {
+	chars := len(tool.Name) + len(tool.Description)
+	if tool.Parameters != nil {
+		if buf, err := json.Marshal(tool.Parameters); err == nil {
+			chars += len(buf)
+		}
+	}
+	return messageOverhead + charsToTokens(chars)
+}
+
+// EstimateTextTokens returns an estimate of the number of tokens of a text.
+func EstimateTextTokens(text string) int {
+	return charsToTokens(len(text))
+}
+
+func charsToTokens(chars int) int {
+	return (chars + charsPerToken - 1) / charsPerToken
+}
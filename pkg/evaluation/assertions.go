@@ -0,0 +1,206 @@
+package evaluation
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/session"
+)
+
+// AssertionType is the kind of check an assertion makes
+type AssertionType string
+
+const (
+	// AssertToolCalled checks that a tool was called, optionally with arguments matching a regular expression
+	AssertToolCalled AssertionType = "tool_called"
+	// AssertToolNotCalled checks that a tool was never called with arguments matching a regular expression, if any
+	AssertToolNotCalled AssertionType = "tool_not_called"
+	// AssertContains checks that the final answer contains a text
+	AssertContains AssertionType = "contains"
+	// AssertNotContains checks that the final answer doesn't contain a text
+	AssertNotContains AssertionType = "not_contains"
+	// AssertRegex checks that the final answer matches a regular expression
+	AssertRegex AssertionType = "regex"
+	// AssertJSONSchema checks that the final answer is JSON valid against a schema
+	AssertJSONSchema AssertionType = "json_schema"
+	// AssertMaxCost checks that the run cost at most a number of dollars
+	AssertMaxCost AssertionType = "max_cost"
+	// AssertMaxIterations checks that the agents called their models at most a number of times
+	AssertMaxIterations AssertionType = "max_iterations"
+)
+
+// Assertion is a check on the outcome of an eval run
+type Assertion struct {
+	Type AssertionType `json:"type"`
+	// Tool is the name of the tool of tool_called and tool_not_called
+	Tool string `json:"tool,omitempty"`
+	// Args is a regular expression the arguments of the tool call must match
+	Args string `json:"args,omitempty"`
+	// Value is the text of contains and not_contains, or the regular expression of regex
+	Value string `json:"value,omitempty"`
+	// Schema is the JSON schema of json_schema
+	Schema *jsonschema.Schema `json:"schema,omitempty"`
+	// Max is the maximum of max_cost and max_iterations
+	Max float64 `json:"max,omitempty"`
+}
+
+// AssertionResult is the outcome of an assertion
+type AssertionResult struct {
+	Assertion Assertion `json:"assertion"`
+	Passed    bool      `json:"passed"`
+	// Message explains why the assertion failed
+	Message string `json:"message,omitempty"`
+}
+
+// String describes the assertion
+func (a *Assertion) String() string {
+	switch a.Type {
+	case AssertToolCalled, AssertToolNotCalled:
+		if a.Args != "" {
+			return fmt.Sprintf("%s %s(%s)", a.Type, a.Tool, a.Args)
+		}
+		return fmt.Sprintf("%s %s", a.Type, a.Tool)
+	case AssertContains, AssertNotContains, AssertRegex:
+		return fmt.Sprintf("%s %q", a.Type, a.Value)
+	case AssertMaxCost, AssertMaxIterations:
+		return fmt.Sprintf("%s %g", a.Type, a.Max)
+	default:
+		return string(a.Type)
+	}
+}
+
+// validate checks that the assertion can be evaluated
+func (a *Assertion) validate() error {
+	switch a.Type {
+	case AssertToolCalled, AssertToolNotCalled:
+		if a.Tool == "" {
+			return fmt.Errorf("%s assertion needs a tool", a.Type)
+		}
+		if _, err := regexp.Compile(a.Args); err != nil {
+			return fmt.Errorf("%s assertion has an invalid args pattern: %w", a.Type, err)
+		}
+	case AssertContains, AssertNotContains:
+		if a.Value == "" {
+			return fmt.Errorf("%s assertion needs a value", a.Type)
+		}
+	case AssertRegex:
+		if _, err := regexp.Compile(a.Value); err != nil {
+			return fmt.Errorf("regex assertion has an invalid pattern: %w", err)
+		}
+	case AssertJSONSchema:
+		if a.Schema == nil {
+			return fmt.Errorf("json_schema assertion needs a schema")
+		}
+		if _, err := a.Schema.Resolve(nil); err != nil {
+			return fmt.Errorf("json_schema assertion has an invalid schema: %w", err)
+		}
+	case AssertMaxCost, AssertMaxIterations:
+		if a.Max <= 0 {
+			return fmt.Errorf("%s assertion needs a positive max", a.Type)
+		}
+	default:
+		return fmt.Errorf("unknown assertion type %q", a.Type)
+	}
+	return nil
+}
+
+// check evaluates the assertion against the session of a run
+func (a *Assertion) check(sess *session.Session) AssertionResult {
+	result := AssertionResult{Assertion: *a, Passed: true}
+	fail := func(format string, args ...any) AssertionResult {
+		result.Passed = false
+		result.Message = fmt.Sprintf(format, args...)
+		return result
+	}
+
+	answer := sess.GetLastAssistantMessageContent()
+
+	switch a.Type {
+	case AssertToolCalled:
+		if !toolCalled(sess, a.Tool, a.Args) {
+			if a.Args != "" {
+				return fail("%s wasn't called with arguments matching %s", a.Tool, a.Args)
+			}
+			return fail("%s wasn't called", a.Tool)
+		}
+	case AssertToolNotCalled:
+		if toolCalled(sess, a.Tool, a.Args) {
+			return fail("%s was called", a.Tool)
+		}
+	case AssertContains:
+		if !strings.Contains(answer, a.Value) {
+			return fail("the final answer doesn't contain %q", a.Value)
+		}
+	case AssertNotContains:
+		if strings.Contains(answer, a.Value) {
+			return fail("the final answer contains %q", a.Value)
+		}
+	case AssertRegex:
+		if !regexp.MustCompile(a.Value).MatchString(answer) {
+			return fail("the final answer doesn't match %s", a.Value)
+		}
+	case AssertJSONSchema:
+		var value any
+		if err := json.Unmarshal([]byte(stripCodeFence(answer)), &value); err != nil {
+			return fail("the final answer isn't JSON: %v", err)
+		}
+		resolved, err := a.Schema.Resolve(nil)
+		if err != nil {
+			return fail("invalid schema: %v", err)
+		}
+		if err := resolved.Validate(value); err != nil {
+			return fail("the final answer doesn't match the schema: %v", err)
+		}
+	case AssertMaxCost:
+		if sess.Cost > a.Max {
+			return fail("the run cost $%.4f, more than $%.4f", sess.Cost, a.Max)
+		}
+	case AssertMaxIterations:
+		if iterations := countIterations(sess); float64(iterations) > a.Max {
+			return fail("the run took %d iterations, more than %g", iterations, a.Max)
+		}
+	default:
+		return fail("unknown assertion type %q", a.Type)
+	}
+
+	return result
+}
+
+// toolCalled returns true if a tool was called with arguments matching the pattern
+func toolCalled(sess *session.Session, name, argsPattern string) bool {
+	args := regexp.MustCompile(argsPattern)
+	for _, msg := range sess.GetAllMessages() {
+		for _, toolCall := range msg.Message.ToolCalls {
+			if toolCall.Function.Name == name && args.MatchString(toolCall.Function.Arguments) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// countIterations returns the number of model calls, one per assistant message
+func countIterations(sess *session.Session) int {
+	iterations := 0
+	for _, msg := range sess.GetAllMessages() {
+		if msg.Message.Role == chat.MessageRoleAssistant {
+			iterations++
+		}
+	}
+	return iterations
+}
+
+// stripCodeFence removes the markdown code fence models often wrap JSON in
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	_, text, _ = strings.Cut(text, "\n")
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
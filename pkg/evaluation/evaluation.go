@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/rumpl/rb/pkg/chat"
 	"github.com/rumpl/rb/pkg/runtime"
@@ -15,57 +18,220 @@ import (
 )
 
 type Score struct {
-	ToolTrajectoryScore float64
-	Rouge1Score         float64
+	ToolTrajectoryScore float64 `json:"tool_trajectory_score"`
+	Rouge1Score         float64 `json:"rouge1_score"`
 }
+
+// Result is the outcome of one run of an eval file
 type Result struct {
-	Score    Score
-	EvalFile string
+	EvalFile string `json:"eval_file"`
+	// Run is the number of the run, from 1 to the number of repeats
+	Run        int               `json:"run"`
+	Score      Score             `json:"score"`
+	Assertions []AssertionResult `json:"assertions,omitempty"`
+	Judgment   *Judgment         `json:"judgment,omitempty"`
+	Cost       float64           `json:"cost"`
+	Duration   time.Duration     `json:"duration"`
+	// Error is set when the eval couldn't be loaded or run
+	Error string `json:"error,omitempty"`
+}
+
+// Passed returns true if the run succeeded, all its assertions passed and the
+// judge gave it a passing score
+func (r *Result) Passed() bool {
+	if r.Error != "" {
+		return false
+	}
+	if r.Judgment != nil && !r.Judgment.Passed() {
+		return false
+	}
+	for _, assertion := range r.Assertions {
+		if !assertion.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// evalFile is a recorded session, the user messages are replayed and the
+// other messages are the expected answers, with assertions on the outcome
+type evalFile struct {
+	session.Session
+	Assertions []Assertion `json:"assertions,omitempty"`
+	// Rubric replaces the rubric of the judge for this eval
+	Rubric string `json:"rubric,omitempty"`
+}
+
+type options struct {
+	concurrency    int
+	repeat         int
+	runtimeOptions []runtime.Opt
+	judge          *Judge
+}
+
+type Opt func(*options)
+
+// WithConcurrency sets the number of evals run at the same time, defaults to 1
+func WithConcurrency(concurrency int) Opt {
+	return func(o *options) {
+		o.concurrency = concurrency
+	}
+}
+
+// WithRepeat runs every eval several times, to detect flaky ones
+func WithRepeat(repeat int) Opt {
+	return func(o *options) {
+		o.repeat = repeat
+	}
+}
+
+// WithRuntimeOptions sets the options of the runtimes the evals are run with
+func WithRuntimeOptions(opts ...runtime.Opt) Opt {
+	return func(o *options) {
+		o.runtimeOptions = append(o.runtimeOptions, opts...)
+	}
+}
+
+// WithJudge grades the answers of every run with a judge model
+func WithJudge(judge *Judge) Opt {
+	return func(o *options) {
+		o.judge = judge
+	}
 }
 
-func Evaluate(ctx context.Context, t *team.Team, evalsDir string) ([]Result, error) {
-	evalFiles, err := os.ReadDir(evalsDir)
+// TeamLoader loads the team the evals run with
+type TeamLoader func(ctx context.Context) (*team.Team, error)
+
+// Evaluate runs all the JSON eval files of a directory. Errors of an eval are
+// reported in its results and don't stop the other evals.
+// Every run loads its own team: runs don't share the state of the agents, of
+// their tools or of their models, like the script of a fake model.
+func Evaluate(ctx context.Context, loadTeam TeamLoader, evalsDir string, opts ...Opt) ([]Result, error) {
+	o := options{concurrency: 1, repeat: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.concurrency = max(o.concurrency, 1)
+	o.repeat = max(o.repeat, 1)
+
+	entries, err := os.ReadDir(evalsDir)
 	if err != nil {
 		return nil, err
 	}
 
-	var evals []session.Session
-	for _, evalFile := range evalFiles {
-		evalFile, err := os.ReadFile(filepath.Join(evalsDir, evalFile.Name()))
-		if err != nil {
-			return nil, err
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".json" {
+			files = append(files, entry.Name())
 		}
-		var sess session.Session
-		if err := json.Unmarshal(evalFile, &sess); err != nil {
-			return nil, err
+	}
+
+	results := make([]Result, len(files)*o.repeat)
+	sem := make(chan struct{}, o.concurrency)
+	var wg sync.WaitGroup
+	for i, name := range files {
+		for run := range o.repeat {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				results[i*o.repeat+run] = runEval(ctx, loadTeam, filepath.Join(evalsDir, name), run+1, &o)
+			}()
 		}
-		evals = append(evals, sess)
 	}
+	wg.Wait()
 
-	var results []Result
-	for i := range evals {
-		rt, err := runtime.New(t)
-		if err != nil {
-			return nil, err
+	return results, nil
+}
+
+// runEval runs an eval file once
+func runEval(ctx context.Context, loadTeam TeamLoader, path string, run int, o *options) (result Result) {
+	result = Result{EvalFile: filepath.Base(path), Run: run}
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			result.Error = fmt.Sprintf("panic: %v", r)
 		}
+		result.Duration = time.Since(start)
+	}()
 
-		actualMessages, err := runLoop(ctx, rt, &evals[i])
-		if err != nil {
-			return nil, err
+	eval, err := loadEval(path)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	t, err := loadTeam(ctx)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer func() {
+		if err := t.StopToolSets(ctx); err != nil {
+			slog.Error("Failed to stop tool sets", "eval", result.EvalFile, "error", err)
 		}
+	}()
 
-		score := evaluate(evals[i].GetAllMessages(), actualMessages)
+	rt, err := runtime.New(t, o.runtimeOptions...)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
 
-		results = append(results, Result{
-			Score:    score,
-			EvalFile: evals[i].ID,
-		})
+	sess, err := runLoop(ctx, rt, &eval.Session, result.EvalFile)
+	result.Cost = sess.Cost
+	if err != nil {
+		result.Error = err.Error()
+		return result
 	}
 
-	return results, nil
+	result.Score = evaluate(eval.GetAllMessages(), sess.GetAllMessages())
+	for i := range eval.Assertions {
+		result.Assertions = append(result.Assertions, eval.Assertions[i].check(sess))
+	}
+	if o.judge != nil {
+		result.Judgment = o.judge.grade(ctx, question(&eval.Session), eval.GetLastAssistantMessageContent(), sess.GetLastAssistantMessageContent(), eval.Rubric)
+	}
+
+	return result
 }
 
-func runLoop(ctx context.Context, rt *runtime.LocalRuntime, eval *session.Session) ([]session.Message, error) {
+func loadEval(path string) (*evalFile, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var eval evalFile
+	if err := json.Unmarshal(buf, &eval); err != nil {
+		return nil, fmt.Errorf("invalid eval file: %w", err)
+	}
+	for i := range eval.Assertions {
+		if err := eval.Assertions[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &eval, nil
+}
+
+// question returns the user messages of an eval
+func question(eval *session.Session) string {
+	var messages []string
+	for _, msg := range eval.GetAllMessages() {
+		if msg.Message.Role == chat.MessageRoleUser && !msg.Implicit {
+			messages = append(messages, msg.Message.Content)
+		}
+	}
+	return strings.Join(messages, "\n\n")
+}
+
+// runLoop replays the user messages of the eval in a new session. Tools are
+// approved since nobody is there to confirm them.
+func runLoop(ctx context.Context, rt *runtime.LocalRuntime, eval *session.Session, title string) (*session.Session, error) {
 	var userMessages []session.Message
 	allMessages := eval.GetAllMessages()
 	for i := range allMessages {
@@ -74,18 +240,19 @@ func runLoop(ctx context.Context, rt *runtime.LocalRuntime, eval *session.Sessio
 		}
 	}
 
-	sess := session.New(session.WithMaxIterations(rt.CurrentAgent().MaxIterations()))
+	sess := session.New(
+		session.WithTitle(title),
+		session.WithMaxIterations(rt.CurrentAgent().MaxIterations()),
+		session.WithToolsApproved(true),
+	)
 	for i := range userMessages {
 		sess.AddMessage(&userMessages[i])
-		_, err := rt.Run(ctx, sess)
-		if err != nil {
-			return nil, err
+		if _, err := rt.Run(ctx, sess); err != nil {
+			return sess, err
 		}
-
-		// Note: rt.Run now returns all messages, but we use sess.GetAllMessages() instead
 	}
 
-	return sess.GetAllMessages(), nil
+	return sess, nil
 }
 
 func evaluate(expectedMessages, actualMessages []session.Message) Score {
@@ -103,13 +270,14 @@ func evaluate(expectedMessages, actualMessages []session.Message) Score {
 		}
 	}
 
-	toolTrajectoryScore := toolTrajectoryScore(expectedToolMessages, actualToolMessages)
-	rouge1Score := rouge1(expectedMessages[len(expectedMessages)-1].Message.Content, actualMessages[len(actualMessages)-1].Message.Content)
-
-	return Score{
-		ToolTrajectoryScore: toolTrajectoryScore,
-		Rouge1Score:         rouge1Score,
+	score := Score{
+		ToolTrajectoryScore: toolTrajectoryScore(expectedToolMessages, actualToolMessages),
+	}
+	if len(expectedMessages) > 0 && len(actualMessages) > 0 {
+		score.Rouge1Score = rouge1(expectedMessages[len(expectedMessages)-1].Message.Content, actualMessages[len(actualMessages)-1].Message.Content)
 	}
+
+	return score
 }
 
 // https://medium.com/nlplanet/two-minutes-nlp-learn-the-rouge-metric-by-examples-f179cc285499
@@ -138,6 +306,10 @@ func rouge1(expected, actual string) float64 {
 		}
 	}
 
+	if len(expectedWords) == 0 || len(actualWords) == 0 {
+		return 0.0
+	}
+
 	precision := float64(overlap) / float64(len(actualWords))
 	recall := float64(overlap) / float64(len(expectedWords))
 
@@ -149,13 +321,17 @@ func rouge1(expected, actual string) float64 {
 }
 
 func toolTrajectoryScore(expectedToolMessages, actualToolMessages []session.Message) float64 {
+	if len(expectedToolMessages) == 0 {
+		return 0.0
+	}
+
 	score := 0.0
 
-	for i := range expectedToolMessages {
+	for i := range min(len(expectedToolMessages), len(actualToolMessages)) {
 		expected := expectedToolMessages[i]
 		actual := actualToolMessages[i]
 
-		for j := range actual.Message.ToolCalls {
+		for j := range min(len(expected.Message.ToolCalls), len(actual.Message.ToolCalls)) {
 			if actual.Message.ToolCalls[j].Function.Name == expected.Message.ToolCalls[j].Function.Name {
 				score += 1.0
 			}
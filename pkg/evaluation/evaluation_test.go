@@ -0,0 +1,237 @@
+package evaluation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/agent"
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/model/provider/base"
+	"github.com/rumpl/rb/pkg/modelsdev"
+	"github.com/rumpl/rb/pkg/runtime"
+	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/team"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+// answerProvider always answers with the same text
+type answerProvider struct {
+	answer string
+}
+
+func (p *answerProvider) ID() string { return "test/answer" }
+
+func (p *answerProvider) CreateChatCompletionStream(context.Context, []chat.Message, []tools.Tool) (chat.MessageStream, error) {
+	return &answerStream{responses: []chat.MessageStreamResponse{
+		{Choices: []chat.MessageStreamChoice{{Delta: chat.MessageDelta{Content: p.answer}}}},
+		{Choices: []chat.MessageStreamChoice{{FinishReason: chat.FinishReasonStop}}},
+	}}, nil
+}
+
+func (p *answerProvider) BaseConfig() base.Config { return base.Config{} }
+
+type answerStream struct {
+	responses []chat.MessageStreamResponse
+}
+
+func (s *answerStream) Recv() (chat.MessageStreamResponse, error) {
+	if len(s.responses) == 0 {
+		return chat.MessageStreamResponse{}, io.EOF
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+func (s *answerStream) Close() {}
+
+type noModelStore struct{}
+
+func (noModelStore) GetModel(context.Context, string) (*modelsdev.Model, error) {
+	return nil, nil
+}
+
+func writeEval(t *testing.T, dir, name string, eval any) {
+	t.Helper()
+
+	buf, err := json.Marshal(eval)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), buf, 0o644))
+}
+
+func TestEvaluate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	question := []session.Item{session.NewMessageItem(session.UserMessage("", "What is the capital of France?"))}
+	writeEval(t, dir, "pass.json", map[string]any{
+		"messages":   question,
+		"assertions": []Assertion{{Type: AssertContains, Value: "Paris"}, {Type: AssertMaxIterations, Max: 1}},
+	})
+	writeEval(t, dir, "fail.json", map[string]any{
+		"messages":   question,
+		"assertions": []Assertion{{Type: AssertToolCalled, Tool: "search"}},
+	})
+	writeEval(t, dir, "invalid.json", map[string]any{
+		"messages":   question,
+		"assertions": []Assertion{{Type: "unknown"}},
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not an eval"), 0o644))
+
+	// Every run has its own team
+	var loaded atomic.Int32
+	loadTeam := func(context.Context) (*team.Team, error) {
+		loaded.Add(1)
+		root := agent.New("root", "You answer questions", agent.WithModel(&answerProvider{answer: "The capital is Paris."}))
+		return team.New(team.WithAgents(root)), nil
+	}
+
+	results, err := Evaluate(t.Context(), loadTeam, dir,
+		WithConcurrency(3),
+		WithRepeat(2),
+		WithRuntimeOptions(runtime.WithModelStore(noModelStore{}), runtime.WithSessionCompaction(false)),
+	)
+	require.NoError(t, err)
+	require.Len(t, results, 6)
+	assert.Equal(t, int32(4), loaded.Load(), "every valid run loads a team")
+
+	byFile := map[string][]Result{}
+	for _, result := range results {
+		byFile[result.EvalFile] = append(byFile[result.EvalFile], result)
+	}
+
+	for _, result := range byFile["pass.json"] {
+		assert.True(t, result.Passed(), result.Error)
+		assert.Len(t, result.Assertions, 2)
+	}
+	assert.Equal(t, 1, byFile["pass.json"][0].Run)
+	assert.Equal(t, 2, byFile["pass.json"][1].Run)
+
+	for _, result := range byFile["fail.json"] {
+		assert.Empty(t, result.Error)
+		assert.False(t, result.Passed())
+		assert.Equal(t, "search wasn't called", result.Assertions[0].Message)
+	}
+
+	for _, result := range byFile["invalid.json"] {
+		assert.Contains(t, result.Error, `unknown assertion type "unknown"`)
+	}
+
+	summary := Summarize(results)
+	assert.Equal(t, Summary{Runs: 6, Passed: 2, Failed: 4}, summary)
+}
+
+func TestAssertions(t *testing.T) {
+	t.Parallel()
+
+	sess := session.New()
+	sess.Cost = 0.02
+	sess.AddMessage(session.UserMessage("", "List the files"))
+	sess.AddMessage(&session.Message{Message: chat.Message{
+		Role: chat.MessageRoleAssistant,
+		ToolCalls: []tools.ToolCall{{
+			Function: tools.FunctionCall{Name: "shell", Arguments: `{"cmd":"ls -la"}`},
+		}},
+	}})
+	sess.AddMessage(&session.Message{Message: chat.Message{Role: chat.MessageRoleTool, Content: "main.go"}})
+	sess.AddMessage(&session.Message{Message: chat.Message{
+		Role:    chat.MessageRoleAssistant,
+		Content: "```json\n{\"files\": [\"main.go\"]}\n```",
+	}})
+
+	tests := []struct {
+		assertion Assertion
+		passed    bool
+	}{
+		{Assertion{Type: AssertToolCalled, Tool: "shell"}, true},
+		{Assertion{Type: AssertToolCalled, Tool: "shell", Args: `"ls\b`}, true},
+		{Assertion{Type: AssertToolCalled, Tool: "shell", Args: `rm `}, false},
+		{Assertion{Type: AssertToolCalled, Tool: "read_file"}, false},
+		{Assertion{Type: AssertToolNotCalled, Tool: "shell", Args: `rm `}, true},
+		{Assertion{Type: AssertToolNotCalled, Tool: "shell"}, false},
+		{Assertion{Type: AssertContains, Value: "main.go"}, true},
+		{Assertion{Type: AssertNotContains, Value: "main.go"}, false},
+		{Assertion{Type: AssertRegex, Value: `"files":\s*\[`}, true},
+		{Assertion{Type: AssertRegex, Value: `^main`}, false},
+		{Assertion{Type: AssertJSONSchema, Schema: mustSchema(t, `{"type":"object","required":["files"]}`)}, true},
+		{Assertion{Type: AssertJSONSchema, Schema: mustSchema(t, `{"type":"object","required":["dirs"]}`)}, false},
+		{Assertion{Type: AssertMaxCost, Max: 0.05}, true},
+		{Assertion{Type: AssertMaxCost, Max: 0.01}, false},
+		{Assertion{Type: AssertMaxIterations, Max: 2}, true},
+		{Assertion{Type: AssertMaxIterations, Max: 1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.assertion.String(), func(t *testing.T) {
+			t.Parallel()
+
+			require.NoError(t, tt.assertion.validate())
+			result := tt.assertion.check(sess)
+			assert.Equal(t, tt.passed, result.Passed, result.Message)
+		})
+	}
+}
+
+func mustSchema(t *testing.T, schema string) *jsonschema.Schema {
+	t.Helper()
+
+	var s jsonschema.Schema
+	require.NoError(t, json.Unmarshal([]byte(schema), &s))
+	return &s
+}
+
+func TestAssertionValidation(t *testing.T) {
+	t.Parallel()
+
+	for _, assertion := range []Assertion{
+		{Type: "unknown"},
+		{Type: AssertToolCalled},
+		{Type: AssertToolCalled, Tool: "shell", Args: "("},
+		{Type: AssertContains},
+		{Type: AssertRegex, Value: "["},
+		{Type: AssertJSONSchema},
+		{Type: AssertMaxCost},
+	} {
+		assert.Error(t, assertion.validate(), assertion.String())
+	}
+}
+
+func TestReports(t *testing.T) {
+	t.Parallel()
+
+	results := []Result{
+		{EvalFile: "a.json", Run: 1},
+		{EvalFile: "a.json", Run: 2, Assertions: []AssertionResult{{Assertion: Assertion{Type: AssertContains, Value: "x"}, Message: "missing x"}}},
+		{EvalFile: "b.json", Run: 1, Error: "model unavailable"},
+	}
+
+	summary := Summarize(results)
+	assert.Equal(t, Summary{Runs: 3, Passed: 1, Failed: 2, Flaky: []string{"a.json"}}, summary)
+
+	var junit bytes.Buffer
+	require.NoError(t, WriteJUnitReport(&junit, results))
+	assert.Contains(t, junit.String(), `<testsuites tests="3" failures="1" errors="1"`)
+	assert.Contains(t, junit.String(), `<testcase name="a.json#2" classname="a"`)
+	assert.Contains(t, junit.String(), `<failure message="1 of 1 checks failed">contains &#34;x&#34;: missing x</failure>`)
+	assert.Contains(t, junit.String(), `<error message="model unavailable">model unavailable</error>`)
+
+	var report bytes.Buffer
+	require.NoError(t, WriteJSONReport(&report, results))
+	var decoded struct {
+		Summary Summary  `json:"summary"`
+		Results []Result `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(report.Bytes(), &decoded))
+	assert.Equal(t, summary, decoded.Summary)
+	assert.Len(t, decoded.Results, 3)
+}
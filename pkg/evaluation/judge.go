@@ -0,0 +1,222 @@
+package evaluation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/config"
+	latest "github.com/rumpl/rb/pkg/config/v2"
+	"github.com/rumpl/rb/pkg/environment"
+	"github.com/rumpl/rb/pkg/model/provider"
+	provideroptions "github.com/rumpl/rb/pkg/model/provider/options"
+	"github.com/rumpl/rb/pkg/model/provider/replay"
+)
+
+// ConfigFileName is the name of the optional configuration file of an eval directory
+const ConfigFileName = "eval.yaml"
+
+const defaultRubric = "The actual answer must be correct and complete. Use the expected answer as the reference for the facts, not for the wording."
+
+// Config is the configuration of an eval directory
+type Config struct {
+	Judge *JudgeConfig `yaml:"judge,omitempty"`
+}
+
+// JudgeConfig configures the model that grades the answers of the agent
+type JudgeConfig struct {
+	// Model is the judge model, as "provider/model"
+	Model string `yaml:"model"`
+	// Rubric describes how answers are graded, eval files can override it
+	Rubric string `yaml:"rubric,omitempty"`
+	// Threshold is the minimum score, from 0 to 1, of a passing run. Scores
+	// are only reported when it's not set.
+	Threshold float64 `yaml:"threshold,omitempty"`
+}
+
+// Judgment is the grade the judge gave to the answer of a run
+type Judgment struct {
+	// Score is between 0 and 1
+	Score     float64 `json:"score"`
+	Rationale string  `json:"rationale,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+	// Error is set when the judge couldn't grade the answer
+	Error string `json:"error,omitempty"`
+}
+
+// Passed returns true if the score reaches the threshold, always true without a threshold
+func (j *Judgment) Passed() bool {
+	if j.Threshold == 0 {
+		return true
+	}
+	return j.Error == "" && j.Score >= j.Threshold
+}
+
+// Judge grades answers with a model
+type Judge struct {
+	model     provider.Provider
+	rubric    string
+	threshold float64
+}
+
+// NewJudge creates a judge, the default rubric is used when rubric is empty
+func NewJudge(model provider.Provider, rubric string, threshold float64) *Judge {
+	if rubric == "" {
+		rubric = defaultRubric
+	}
+	return &Judge{
+		model:     model,
+		rubric:    rubric,
+		threshold: threshold,
+	}
+}
+
+// LoadConfig reads the configuration of an eval directory, if any
+func LoadConfig(evalsDir string) (*Config, error) {
+	buf, err := os.ReadFile(filepath.Join(evalsDir, ConfigFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := yaml.UnmarshalWithOptions(buf, &cfg, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ConfigFileName, err)
+	}
+	if cfg.Judge != nil {
+		if _, _, ok := strings.Cut(cfg.Judge.Model, "/"); !ok {
+			return nil, fmt.Errorf("invalid %s: the judge model must be in the provider/model format, got %q", ConfigFileName, cfg.Judge.Model)
+		}
+		if cfg.Judge.Threshold < 0 || cfg.Judge.Threshold > 1 {
+			return nil, fmt.Errorf("invalid %s: the judge threshold must be between 0 and 1", ConfigFileName)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// LoadJudge creates the judge configured in an eval directory, it returns nil
+// when there's none
+func LoadJudge(ctx context.Context, evalsDir string, runtimeConfig config.RuntimeConfig) (*Judge, error) {
+	cfg, err := LoadConfig(evalsDir)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Judge == nil {
+		return nil, nil
+	}
+
+	// Like in agent files, env files are relative to the configuration file
+	envFiles, err := environment.AbsolutePaths(evalsDir, runtimeConfig.EnvFiles)
+	if err != nil {
+		return nil, err
+	}
+	envFilesProviders, err := environment.NewEnvFilesProvider(envFiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env files: %w", err)
+	}
+	defaultEnvProvider := runtimeConfig.DefaultEnvProvider
+	if defaultEnvProvider == nil {
+		defaultEnvProvider = environment.NewDefaultProvider()
+	}
+	env := environment.NewMultiProvider(envFilesProviders, defaultEnvProvider)
+
+	providerName, modelName, _ := strings.Cut(cfg.Judge.Model, "/")
+	model, err := replay.NewModel(ctx, &latest.ModelConfig{Provider: providerName, Model: modelName}, env, runtimeConfig, provideroptions.WithGateway(runtimeConfig.ModelsGateway))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the judge model: %w", err)
+	}
+
+	return NewJudge(model, cfg.Judge.Rubric, cfg.Judge.Threshold), nil
+}
+
+const judgeInstructions = `You are an impartial judge grading the answer of an AI agent.
+Grade the actual answer against the rubric and the expected answer, from 0 (wrong or missing) to 10 (fully meets the rubric).
+Reply only with a JSON object: {"score": <number from 0 to 10>, "rationale": "<one or two sentences explaining the score>"}`
+
+// grade asks the judge model to grade an answer, rubric overrides the rubric of the judge when set
+func (j *Judge) grade(ctx context.Context, question, expected, actual, rubric string) *Judgment {
+	judgment := &Judgment{Threshold: j.threshold}
+	if rubric == "" {
+		rubric = j.rubric
+	}
+	if expected == "" {
+		expected = "(none, grade with the rubric only)"
+	}
+
+	prompt := fmt.Sprintf("## Rubric\n\n%s\n\n## Question\n\n%s\n\n## Expected answer\n\n%s\n\n## Actual answer\n\n%s", rubric, question, expected, actual)
+	reply, err := complete(ctx, j.model, []chat.Message{
+		{Role: chat.MessageRoleSystem, Content: judgeInstructions},
+		{Role: chat.MessageRoleUser, Content: prompt},
+	})
+	if err != nil {
+		judgment.Error = err.Error()
+		return judgment
+	}
+
+	score, rationale, err := parseJudgment(reply)
+	if err != nil {
+		judgment.Error = err.Error()
+		return judgment
+	}
+	judgment.Score = score / 10
+	judgment.Rationale = rationale
+
+	return judgment
+}
+
+// complete returns the whole reply of a model
+func complete(ctx context.Context, model provider.Provider, messages []chat.Message) (string, error) {
+	stream, err := model.CreateChatCompletionStream(ctx, messages, nil)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var reply strings.Builder
+	for {
+		response, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		for _, choice := range response.Choices {
+			reply.WriteString(choice.Delta.Content)
+		}
+	}
+
+	return reply.String(), nil
+}
+
+// parseJudgment extracts the score and the rationale from the reply of the judge
+func parseJudgment(reply string) (float64, string, error) {
+	text := stripCodeFence(reply)
+	if start, end := strings.Index(text, "{"), strings.LastIndex(text, "}"); start >= 0 && end > start {
+		text = text[start : end+1]
+	}
+
+	var judgment struct {
+		Score     *float64 `json:"score"`
+		Rationale string   `json:"rationale"`
+	}
+	if err := json.Unmarshal([]byte(text), &judgment); err != nil || judgment.Score == nil {
+		return 0, "", fmt.Errorf("the judge didn't reply with a score: %q", reply)
+	}
+	if *judgment.Score < 0 || *judgment.Score > 10 {
+		return 0, "", fmt.Errorf("the judge replied with a score out of range: %g", *judgment.Score)
+	}
+
+	return *judgment.Score, judgment.Rationale, nil
+}
@@ -0,0 +1,128 @@
+package evaluation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/agent"
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/runtime"
+	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/team"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+// recordingProvider answers like answerProvider and records the prompts it got
+type recordingProvider struct {
+	answerProvider
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (p *recordingProvider) CreateChatCompletionStream(ctx context.Context, messages []chat.Message, tools []tools.Tool) (chat.MessageStream, error) {
+	p.mu.Lock()
+	p.prompts = append(p.prompts, messages[len(messages)-1].Content)
+	p.mu.Unlock()
+	return p.answerProvider.CreateChatCompletionStream(ctx, messages, tools)
+}
+
+func TestEvaluateWithJudge(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	messages := []session.Item{
+		session.NewMessageItem(session.UserMessage("", "What is the capital of France?")),
+		session.NewMessageItem(&session.Message{Message: chat.Message{Role: chat.MessageRoleAssistant, Content: "Paris"}}),
+	}
+	writeEval(t, dir, "default.json", map[string]any{"messages": messages})
+	writeEval(t, dir, "rubric.json", map[string]any{"messages": messages, "rubric": "Only the city name counts."})
+
+	root := agent.New("root", "You answer questions", agent.WithModel(&answerProvider{answer: "The capital is Paris."}))
+	judgeModel := &recordingProvider{answerProvider: answerProvider{answer: "```json\n{\"score\": 8, \"rationale\": \"Correct but verbose\"}\n```"}}
+
+	loadTeam := func(context.Context) (*team.Team, error) { return team.New(team.WithAgents(root)), nil }
+	results, err := Evaluate(t.Context(), loadTeam, dir,
+		WithJudge(NewJudge(judgeModel, "", 0.9)),
+		WithRuntimeOptions(runtime.WithModelStore(noModelStore{}), runtime.WithSessionCompaction(false)),
+	)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	for _, result := range results {
+		require.NotNil(t, result.Judgment)
+		assert.InDelta(t, 0.8, result.Judgment.Score, 1e-9)
+		assert.Equal(t, "Correct but verbose", result.Judgment.Rationale)
+		assert.False(t, result.Passed())
+	}
+
+	require.Len(t, judgeModel.prompts, 2)
+	rubrics := map[string]int{}
+	for _, prompt := range judgeModel.prompts {
+		assert.Contains(t, prompt, "## Question\n\nWhat is the capital of France?")
+		assert.Contains(t, prompt, "## Expected answer\n\nParis")
+		assert.Contains(t, prompt, "## Actual answer\n\nThe capital is Paris.")
+
+		rubric, _, _ := strings.Cut(strings.TrimPrefix(prompt, "## Rubric\n\n"), "\n\n")
+		rubrics[rubric]++
+	}
+	assert.Equal(t, map[string]int{defaultRubric: 1, "Only the city name counts.": 1}, rubrics)
+}
+
+func TestParseJudgment(t *testing.T) {
+	t.Parallel()
+
+	score, rationale, err := parseJudgment(`Here is my grade: {"score": 7.5, "rationale": "Mostly right"}`)
+	require.NoError(t, err)
+	assert.InDelta(t, 7.5, score, 1e-9)
+	assert.Equal(t, "Mostly right", rationale)
+
+	_, _, err = parseJudgment(`{"rationale": "no score"}`)
+	require.Error(t, err)
+
+	_, _, err = parseJudgment(`{"score": 11}`)
+	require.Error(t, err)
+
+	_, _, err = parseJudgment(`great answer`)
+	require.Error(t, err)
+}
+
+func TestJudgmentPassed(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, (&Judgment{Score: 0.1}).Passed())
+	assert.True(t, (&Judgment{Error: "unavailable"}).Passed())
+	assert.True(t, (&Judgment{Score: 0.7, Threshold: 0.7}).Passed())
+	assert.False(t, (&Judgment{Score: 0.6, Threshold: 0.7}).Passed())
+	assert.False(t, (&Judgment{Error: "unavailable", Threshold: 0.7}).Passed())
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig(t.TempDir())
+	require.NoError(t, err)
+	assert.Nil(t, cfg.Judge)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ConfigFileName), []byte("judge:\n  model: openai/gpt-4o\n  rubric: Be strict\n  threshold: 0.7\n"), 0o644))
+	cfg, err = LoadConfig(dir)
+	require.NoError(t, err)
+	assert.Equal(t, &JudgeConfig{Model: "openai/gpt-4o", Rubric: "Be strict", Threshold: 0.7}, cfg.Judge)
+
+	for _, invalid := range []string{
+		"judge:\n  model: gpt-4o\n",
+		"judge:\n  model: openai/gpt-4o\n  threshold: 7\n",
+		"judge:\n  model: openai/gpt-4o\n  unknown: true\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(invalid), 0o644))
+		_, err := LoadConfig(dir)
+		assert.Error(t, err, invalid)
+	}
+}
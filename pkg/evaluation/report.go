@@ -0,0 +1,166 @@
+package evaluation
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Summary aggregates the results of the runs of every eval file
+type Summary struct {
+	Runs   int `json:"runs"`
+	Passed int `json:"passed"`
+	Failed int `json:"failed"`
+	// Flaky are the eval files that passed some of their runs, but not all
+	Flaky []string `json:"flaky,omitempty"`
+}
+
+// Summarize counts the passed and failed runs and finds the flaky evals
+func Summarize(results []Result) Summary {
+	var summary Summary
+
+	passedByFile := map[string]int{}
+	runsByFile := map[string]int{}
+	var files []string
+	for i := range results {
+		file := results[i].EvalFile
+		if _, seen := runsByFile[file]; !seen {
+			files = append(files, file)
+		}
+		runsByFile[file]++
+
+		summary.Runs++
+		if results[i].Passed() {
+			summary.Passed++
+			passedByFile[file]++
+		} else {
+			summary.Failed++
+		}
+	}
+
+	for _, file := range files {
+		if passed := passedByFile[file]; passed > 0 && passed < runsByFile[file] {
+			summary.Flaky = append(summary.Flaky, file)
+		}
+	}
+
+	return summary
+}
+
+// WriteJSONReport writes the summary and the results as JSON
+func WriteJSONReport(w io.Writer, results []Result) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Summary Summary  `json:"summary"`
+		Results []Result `json:"results"`
+	}{
+		Summary: Summarize(results),
+		Results: results,
+	})
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name       `xml:"testsuites"`
+	Tests    int            `xml:"tests,attr"`
+	Failures int            `xml:"failures,attr"`
+	Errors   int            `xml:"errors,attr"`
+	Time     float64        `xml:"time,attr"`
+	Suite    junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      float64         `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnitReport writes the results as JUnit XML, one test case per run
+func WriteJUnitReport(w io.Writer, results []Result) error {
+	suite := junitTestSuite{Name: "rb eval"}
+
+	var total time.Duration
+	for i := range results {
+		result := &results[i]
+		total += result.Duration
+
+		testCase := junitTestCase{
+			Name:      fmt.Sprintf("%s#%d", result.EvalFile, result.Run),
+			ClassName: strings.TrimSuffix(result.EvalFile, ".json"),
+			Time:      result.Duration.Seconds(),
+			SystemOut: fmt.Sprintf("tool trajectory score: %f\nrouge-1 score: %f\ncost: $%.4f\n", result.Score.ToolTrajectoryScore, result.Score.Rouge1Score, result.Cost),
+		}
+
+		checks := len(result.Assertions)
+		var failures []string
+		for _, assertion := range result.Assertions {
+			if !assertion.Passed {
+				failures = append(failures, assertion.Assertion.String()+": "+assertion.Message)
+			}
+		}
+		if judgment := result.Judgment; judgment != nil {
+			checks++
+			testCase.SystemOut += fmt.Sprintf("judge score: %f\njudge rationale: %s\n", judgment.Score, judgment.Rationale)
+			switch {
+			case judgment.Passed():
+			case judgment.Error != "":
+				failures = append(failures, "judge: "+judgment.Error)
+			default:
+				failures = append(failures, fmt.Sprintf("judge: score %f below %f: %s", judgment.Score, judgment.Threshold, judgment.Rationale))
+			}
+		}
+
+		switch {
+		case result.Error != "":
+			suite.Errors++
+			testCase.Error = &junitMessage{Message: result.Error, Text: result.Error}
+		case len(failures) > 0:
+			suite.Failures++
+			testCase.Failure = &junitMessage{
+				Message: fmt.Sprintf("%d of %d checks failed", len(failures), checks),
+				Text:    strings.Join(failures, "\n"),
+			}
+		}
+
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Tests = len(results)
+	suite.Time = total.Seconds()
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Time:     suite.Time,
+		Suite:    suite,
+	}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -5,16 +5,44 @@ import (
 	"errors"
 )
 
-var ErrEmptyID = errors.New("memory ID cannot be empty")
+var (
+	ErrEmptyID  = errors.New("memory ID cannot be empty")
+	ErrNotFound = errors.New("memory not found")
+)
 
 type UserMemory struct {
-	ID        string `description:"The ID of the memory"`
-	CreatedAt string `description:"The creation timestamp of the memory"`
-	Memory    string `description:"The content of the memory"`
+	ID        string   `description:"The ID of the memory"`
+	CreatedAt string   `description:"The creation timestamp of the memory"`
+	UpdatedAt string   `json:",omitempty" description:"The last update timestamp of the memory"`
+	Memory    string   `description:"The content of the memory"`
+	Category  string   `json:",omitempty" description:"The category of the memory"`
+	Tags      []string `json:",omitempty" description:"The tags of the memory"`
+	// Agent is the agent the memory belongs to, memories without an agent are shared by all the agents
+	Agent string `json:",omitempty" description:"The agent the memory belongs to"`
+}
+
+// Query selects and ranks memories
+type Query struct {
+	// Text is the text the memories are ranked against, the latest memories come first when it's empty
+	Text string
+	// Category only keeps the memories of this category
+	Category string
+	// Tags only keeps the memories that have all these tags
+	Tags []string
+	// Agent keeps the memories of this agent on top of the shared memories
+	Agent string
+	// Limit is the maximum number of memories returned, 0 means no limit
+	Limit int
 }
 
 type Database interface {
 	AddMemory(ctx context.Context, memory UserMemory) error
 	GetMemories(ctx context.Context) ([]UserMemory, error)
+	// SearchMemories returns the memories matching the query, the most relevant first
+	SearchMemories(ctx context.Context, query Query) ([]UserMemory, error)
+	// UpdateMemory updates the content, category and tags of a memory of the
+	// same agent or of a shared memory, empty fields are left unchanged
+	UpdateMemory(ctx context.Context, memory UserMemory) error
+	// DeleteMemory deletes a memory of the same agent or a shared memory
 	DeleteMemory(ctx context.Context, memory UserMemory) error
 }
@@ -1,19 +1,47 @@
 package sqlite
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
 
 	_ "modernc.org/sqlite"
 
 	"github.com/rumpl/rb/pkg/memory/database"
+	"github.com/rumpl/rb/pkg/memory/embedding"
 )
 
+// minSimilarity is the similarity under which memories aren't considered related to a query
+const minSimilarity = 0.2
+
+// rankConstant dampens the weight of the first ranks when the full-text and
+// the embedding rankings are merged, see reciprocal rank fusion
+const rankConstant = 60
+
+const memoryColumns = "m.id, m.created_at, m.updated_at, m.memory, m.category, m.tags, m.agent"
+
 type MemoryDatabase struct {
-	db *sql.DB
+	db       *sql.DB
+	embedder embedding.Embedder
+}
+
+type Opt func(*MemoryDatabase)
+
+// WithEmbedder also ranks the memories by the similarity of their vectors, on
+// top of full-text search
+func WithEmbedder(embedder embedding.Embedder) Opt {
+	return func(m *MemoryDatabase) {
+		m.embedder = embedder
+	}
 }
 
-func NewMemoryDatabase(path string) (database.Database, error) {
+func NewMemoryDatabase(path string, opts ...Opt) (database.Database, error) {
 	// Add query parameters for better concurrency handling
 	// _busy_timeout: Wait up to 5 seconds if database is locked
 	// _journal_mode=WAL: Enable Write-Ahead Logging for better concurrent access
@@ -28,25 +56,108 @@ func NewMemoryDatabase(path string) (database.Database, error) {
 	db.SetMaxIdleConns(1)
 	db.SetConnMaxLifetime(0)
 
-	_, err = db.ExecContext(context.Background(), "CREATE TABLE IF NOT EXISTS memories (id TEXT PRIMARY KEY, created_at TEXT, memory TEXT)")
-	if err != nil {
+	if err := migrate(context.Background(), db); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	return &MemoryDatabase{db: db}, nil
+	m := &MemoryDatabase{db: db}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// migrate creates the memories table, adds the columns older databases don't
+// have and indexes the memories for full-text search
+func migrate(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS memories (id TEXT PRIMARY KEY, created_at TEXT, memory TEXT)"); err != nil {
+		return err
+	}
+
+	existing := map[string]bool{}
+	rows, err := tx.QueryContext(ctx, "SELECT name FROM pragma_table_info('memories')")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, column := range []struct{ name, definition string }{
+		{"updated_at", "TEXT NOT NULL DEFAULT ''"},
+		{"category", "TEXT NOT NULL DEFAULT ''"},
+		{"tags", "TEXT NOT NULL DEFAULT '[]'"},
+		{"agent", "TEXT NOT NULL DEFAULT ''"},
+		{"embedding", "BLOB"},
+	} {
+		if existing[column.name] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE memories ADD COLUMN %s %s", column.name, column.definition)); err != nil {
+			return fmt.Errorf("failed to add column %s to memories: %w", column.name, err)
+		}
+	}
+
+	var indexed int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'memories_fts'").Scan(&indexed); err != nil {
+		return err
+	}
+	if indexed == 0 {
+		for _, statement := range []string{
+			"CREATE VIRTUAL TABLE memories_fts USING fts5(id UNINDEXED, memory, category, tags)",
+			"INSERT INTO memories_fts (id, memory, category, tags) SELECT id, memory, category, tags FROM memories",
+			`CREATE TRIGGER memories_fts_insert AFTER INSERT ON memories BEGIN
+				INSERT INTO memories_fts (id, memory, category, tags) VALUES (new.id, new.memory, new.category, new.tags);
+			END`,
+			`CREATE TRIGGER memories_fts_delete AFTER DELETE ON memories BEGIN
+				DELETE FROM memories_fts WHERE id = old.id;
+			END`,
+			`CREATE TRIGGER memories_fts_update AFTER UPDATE OF memory, category, tags ON memories BEGIN
+				UPDATE memories_fts SET memory = new.memory, category = new.category, tags = new.tags WHERE id = old.id;
+			END`,
+		} {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("failed to index memories: %w", err)
+			}
+		}
+	}
+
+	return tx.Commit()
 }
 
 func (m *MemoryDatabase) AddMemory(ctx context.Context, memory database.UserMemory) error {
 	if memory.ID == "" {
 		return database.ErrEmptyID
 	}
-	_, err := m.db.ExecContext(ctx, "INSERT INTO memories (id, created_at, memory) VALUES (?, ?, ?)",
-		memory.ID, memory.CreatedAt, memory.Memory)
+
+	tags, err := encodeTags(memory.Tags)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.ExecContext(ctx, "INSERT INTO memories (id, created_at, updated_at, memory, category, tags, agent, embedding) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		memory.ID, memory.CreatedAt, memory.UpdatedAt, memory.Memory, memory.Category, tags, memory.Agent, m.embed(memory.Memory))
 	return err
 }
 
 func (m *MemoryDatabase) GetMemories(ctx context.Context) ([]database.UserMemory, error) {
-	rows, err := m.db.QueryContext(ctx, "SELECT id, created_at, memory FROM memories")
+	rows, err := m.db.QueryContext(ctx, "SELECT "+memoryColumns+" FROM memories m ORDER BY m.rowid")
 	if err != nil {
 		return nil, err
 	}
@@ -54,18 +165,282 @@ func (m *MemoryDatabase) GetMemories(ctx context.Context) ([]database.UserMemory
 
 	var memories []database.UserMemory
 	for rows.Next() {
-		var memory database.UserMemory
-		err := rows.Scan(&memory.ID, &memory.CreatedAt, &memory.Memory)
+		memory, err := scanMemory(rows)
 		if err != nil {
 			return nil, err
 		}
 		memories = append(memories, memory)
 	}
 
+	return memories, rows.Err()
+}
+
+func (m *MemoryDatabase) SearchMemories(ctx context.Context, query database.Query) ([]database.UserMemory, error) {
+	filter, args := filterMemories(query)
+
+	match := matchExpression(query.Text)
+	if match == "" {
+		return m.queryMemories(ctx, "SELECT "+memoryColumns+" FROM memories m WHERE "+filter+" ORDER BY m.created_at DESC, m.rowid DESC LIMIT ?", append(args, sqlLimit(query.Limit))...)
+	}
+
+	limit := query.Limit
+	if m.embedder != nil {
+		// The rankings are merged before they are cut
+		limit = 0
+	}
+	matches, err := m.queryMemories(ctx, "SELECT "+memoryColumns+" FROM memories_fts f JOIN memories m ON m.id = f.id WHERE memories_fts MATCH ? AND "+filter+" ORDER BY bm25(memories_fts) LIMIT ?", append(append([]any{match}, args...), sqlLimit(limit))...)
+	if err != nil || m.embedder == nil {
+		return matches, err
+	}
+
+	similar, err := m.similarMemories(ctx, query.Text, filter, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return fuseRankings(query.Limit, matches, similar), nil
+}
+
+// similarMemories returns the memories whose embedding is similar to the one of the text, the most similar first
+func (m *MemoryDatabase) similarMemories(ctx context.Context, text, filter string, args []any) ([]database.UserMemory, error) {
+	target := m.embedder.Embed(text)
+
+	rows, err := m.db.QueryContext(ctx, "SELECT "+memoryColumns+", m.embedding FROM memories m WHERE "+filter, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type scored struct {
+		memory     database.UserMemory
+		similarity float32
+	}
+	var candidates []scored
+	for rows.Next() {
+		var blob []byte
+		memory, err := scanMemory(rows, &blob)
+		if err != nil {
+			return nil, err
+		}
+
+		// Memories added before embeddings were enabled don't have one
+		vector := decodeEmbedding(blob)
+		if len(vector) != len(target) {
+			vector = m.embedder.Embed(memory.Memory)
+		}
+		if similarity := embedding.Cosine(target, vector); similarity >= minSimilarity {
+			candidates = append(candidates, scored{memory: memory, similarity: similarity})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(candidates, func(a, b scored) int {
+		return cmp.Compare(b.similarity, a.similarity)
+	})
+
+	memories := make([]database.UserMemory, len(candidates))
+	for i, candidate := range candidates {
+		memories[i] = candidate.memory
+	}
 	return memories, nil
 }
 
+func (m *MemoryDatabase) UpdateMemory(ctx context.Context, memory database.UserMemory) error {
+	if memory.ID == "" {
+		return database.ErrEmptyID
+	}
+
+	var tags any
+	if memory.Tags != nil {
+		encoded, err := encodeTags(memory.Tags)
+		if err != nil {
+			return err
+		}
+		tags = encoded
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `UPDATE memories SET
+		memory = COALESCE(NULLIF(?, ''), memory),
+		category = COALESCE(NULLIF(?, ''), category),
+		tags = COALESCE(?, tags),
+		updated_at = ?
+		WHERE id = ? AND agent IN ('', ?)`,
+		memory.Memory, memory.Category, tags, memory.UpdatedAt, memory.ID, memory.Agent)
+	if err != nil {
+		return err
+	}
+	if updated, err := res.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		return database.ErrNotFound
+	}
+
+	if m.embedder != nil && memory.Memory != "" {
+		if _, err := tx.ExecContext(ctx, "UPDATE memories SET embedding = ? WHERE id = ?", m.embed(memory.Memory), memory.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (m *MemoryDatabase) DeleteMemory(ctx context.Context, memory database.UserMemory) error {
-	_, err := m.db.ExecContext(ctx, "DELETE FROM memories WHERE id = ?", memory.ID)
-	return err
+	res, err := m.db.ExecContext(ctx, "DELETE FROM memories WHERE id = ? AND agent IN ('', ?)", memory.ID, memory.Agent)
+	if err != nil {
+		return err
+	}
+	if deleted, err := res.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
+
+func (m *MemoryDatabase) queryMemories(ctx context.Context, query string, args ...any) ([]database.UserMemory, error) {
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memories []database.UserMemory
+	for rows.Next() {
+		memory, err := scanMemory(rows)
+		if err != nil {
+			return nil, err
+		}
+		memories = append(memories, memory)
+	}
+
+	return memories, rows.Err()
+}
+
+// embed returns the encoded embedding of a text, nil without an embedder
+func (m *MemoryDatabase) embed(text string) []byte {
+	if m.embedder == nil {
+		return nil
+	}
+
+	vector := m.embedder.Embed(text)
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return buf
+}
+
+func decodeEmbedding(buf []byte) []float32 {
+	vector := make([]float32, len(buf)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return vector
+}
+
+func scanMemory(rows *sql.Rows, extra ...any) (database.UserMemory, error) {
+	var (
+		memory database.UserMemory
+		tags   string
+	)
+	dest := append([]any{&memory.ID, &memory.CreatedAt, &memory.UpdatedAt, &memory.Memory, &memory.Category, &tags, &memory.Agent}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return database.UserMemory{}, err
+	}
+	if err := json.Unmarshal([]byte(tags), &memory.Tags); err != nil {
+		return database.UserMemory{}, fmt.Errorf("invalid tags for memory %s: %w", memory.ID, err)
+	}
+	if len(memory.Tags) == 0 {
+		memory.Tags = nil
+	}
+
+	return memory, nil
+}
+
+// filterMemories returns the SQL condition and its arguments that select the memories of a query
+func filterMemories(query database.Query) (string, []any) {
+	conditions := []string{"m.agent IN ('', ?)"}
+	args := []any{query.Agent}
+
+	if query.Category != "" {
+		conditions = append(conditions, "m.category = ? COLLATE NOCASE")
+		args = append(args, query.Category)
+	}
+	for _, tag := range normalizeTags(query.Tags) {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM json_each(m.tags) WHERE json_each.value = ?)")
+		args = append(args, tag)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// matchExpression turns a text into a full-text query that matches any of its
+// words, or their prefix. Single letters are too common to rank anything.
+func matchExpression(text string) string {
+	var terms []string
+	for _, word := range embedding.Words(text) {
+		if len([]rune(word)) < 2 {
+			continue
+		}
+		terms = append(terms, `"`+word+`"*`)
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// fuseRankings merges rankings with reciprocal rank fusion
+func fuseRankings(limit int, rankings ...[]database.UserMemory) []database.UserMemory {
+	scores := map[string]float64{}
+	var memories []database.UserMemory
+	for _, ranking := range rankings {
+		for rank, memory := range ranking {
+			if _, ok := scores[memory.ID]; !ok {
+				memories = append(memories, memory)
+			}
+			scores[memory.ID] += 1 / float64(rankConstant+rank+1)
+		}
+	}
+
+	slices.SortStableFunc(memories, func(a, b database.UserMemory) int {
+		return cmp.Compare(scores[b.ID], scores[a.ID])
+	})
+	if limit > 0 && len(memories) > limit {
+		memories = memories[:limit]
+	}
+	return memories
+}
+
+func encodeTags(tags []string) (string, error) {
+	buf, err := json.Marshal(normalizeTags(tags))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode tags: %w", err)
+	}
+	return string(buf), nil
+}
+
+// normalizeTags lowercases and deduplicates tags
+func normalizeTags(tags []string) []string {
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// sqlLimit converts a limit to SQL, where a negative limit means no limit
+func sqlLimit(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+	return limit
 }
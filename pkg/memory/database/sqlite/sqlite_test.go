@@ -2,6 +2,7 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
 	"os"
 	"testing"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/rumpl/rb/pkg/memory/database"
+	"github.com/rumpl/rb/pkg/memory/embedding"
 )
 
 func setupTestDB(t *testing.T) database.Database {
@@ -138,7 +140,7 @@ func TestDeleteMemory(t *testing.T) {
 		ID: "non-existent-id",
 	}
 	err = db.DeleteMemory(t.Context(), nonExistentMemory)
-	require.NoError(t, err, "Deleting non-existent memory should not return an error")
+	require.ErrorIs(t, err, database.ErrNotFound, "Deleting non-existent memory should return not found")
 }
 
 func TestDatabaseOperationsWithCanceledContext(t *testing.T) {
@@ -195,3 +197,171 @@ func TestDatabaseWithMultipleInstances(t *testing.T) {
 	assert.Equal(t, "shared-id", memories[0].ID)
 	assert.Equal(t, "Shared memory", memories[0].Memory)
 }
+
+func addMemories(t *testing.T, db database.Database, memories ...database.UserMemory) {
+	t.Helper()
+
+	for i, memory := range memories {
+		memory.CreatedAt = time.Date(2025, 1, 1, 0, 0, i, 0, time.UTC).Format(time.RFC3339)
+		require.NoError(t, db.AddMemory(t.Context(), memory))
+	}
+}
+
+func ids(memories []database.UserMemory) []string {
+	var ids []string
+	for _, memory := range memories {
+		ids = append(ids, memory.ID)
+	}
+	return ids
+}
+
+func TestSearchMemories(t *testing.T) {
+	db := setupTestDB(t)
+
+	addMemories(t, db,
+		database.UserMemory{ID: "color", Memory: "The user's favorite color is green", Category: "preferences", Tags: []string{"Colors", "style"}},
+		database.UserMemory{ID: "city", Memory: "The user lives in Paris", Category: "facts"},
+		database.UserMemory{ID: "editor", Memory: "The user prefers vim over emacs, in a dark color scheme", Category: "Preferences", Tags: []string{"tools"}},
+		database.UserMemory{ID: "private", Memory: "The reviewer agent prefers short reviews", Agent: "reviewer"},
+	)
+
+	memories, err := db.SearchMemories(t.Context(), database.Query{Text: "What is my favorite color?"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"color", "editor"}, ids(memories))
+	assert.Equal(t, []string{"colors", "style"}, memories[0].Tags)
+
+	memories, err = db.SearchMemories(t.Context(), database.Query{Text: "where do they live"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"city"}, ids(memories), "words match by prefix")
+
+	memories, err = db.SearchMemories(t.Context(), database.Query{Text: "color", Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, memories, 1)
+
+	memories, err = db.SearchMemories(t.Context(), database.Query{Category: "preferences"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"editor", "color"}, ids(memories), "the latest memories come first without a text")
+
+	memories, err = db.SearchMemories(t.Context(), database.Query{Text: "color", Tags: []string{"colors", "STYLE"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"color"}, ids(memories))
+
+	memories, err = db.SearchMemories(t.Context(), database.Query{Text: "prefers"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"editor"}, ids(memories), "the memories of other agents are hidden")
+
+	memories, err = db.SearchMemories(t.Context(), database.Query{Text: "prefers", Agent: "reviewer"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"editor", "private"}, ids(memories))
+
+	memories, err = db.SearchMemories(t.Context(), database.Query{Text: "\"(*"})
+	require.NoError(t, err)
+	assert.Len(t, memories, 3, "a text without words returns the latest memories")
+}
+
+func TestUpdateMemory(t *testing.T) {
+	db := setupTestDB(t)
+
+	addMemories(t, db,
+		database.UserMemory{ID: "color", Memory: "The user's favorite color is green", Category: "preferences", Tags: []string{"colors"}},
+		database.UserMemory{ID: "private", Memory: "Short reviews", Agent: "reviewer"},
+	)
+
+	err := db.UpdateMemory(t.Context(), database.UserMemory{ID: "color", Memory: "The user's favorite color is purple", UpdatedAt: "2025-02-01T00:00:00Z"})
+	require.NoError(t, err)
+
+	memories, err := db.SearchMemories(t.Context(), database.Query{Text: "purple"})
+	require.NoError(t, err)
+	require.Len(t, memories, 1)
+	assert.Equal(t, "The user's favorite color is purple", memories[0].Memory)
+	assert.Equal(t, "preferences", memories[0].Category, "empty fields are left unchanged")
+	assert.Equal(t, []string{"colors"}, memories[0].Tags)
+	assert.Equal(t, "2025-02-01T00:00:00Z", memories[0].UpdatedAt)
+
+	memories, err = db.SearchMemories(t.Context(), database.Query{Text: "green"})
+	require.NoError(t, err)
+	assert.Empty(t, memories, "the full-text index is updated")
+
+	require.NoError(t, db.UpdateMemory(t.Context(), database.UserMemory{ID: "color", Tags: []string{}}))
+	memories, err = db.GetMemories(t.Context())
+	require.NoError(t, err)
+	assert.Nil(t, memories[0].Tags)
+
+	err = db.UpdateMemory(t.Context(), database.UserMemory{ID: "private", Memory: "Long reviews"})
+	require.ErrorIs(t, err, database.ErrNotFound, "memories of other agents can't be updated")
+	err = db.UpdateMemory(t.Context(), database.UserMemory{ID: "missing", Memory: "Hello"})
+	require.ErrorIs(t, err, database.ErrNotFound)
+	err = db.UpdateMemory(t.Context(), database.UserMemory{Memory: "Hello"})
+	require.ErrorIs(t, err, database.ErrEmptyID)
+
+	// Shared memories can be changed by every agent
+	require.NoError(t, db.UpdateMemory(t.Context(), database.UserMemory{ID: "color", Category: "taste", Agent: "reviewer"}))
+	memories, err = db.SearchMemories(t.Context(), database.Query{Category: "taste"})
+	require.NoError(t, err)
+	require.Len(t, memories, 1)
+	assert.Empty(t, memories[0].Agent, "updated shared memories stay shared")
+
+	err = db.DeleteMemory(t.Context(), database.UserMemory{ID: "private"})
+	require.ErrorIs(t, err, database.ErrNotFound, "memories of other agents can't be deleted")
+	require.NoError(t, db.DeleteMemory(t.Context(), database.UserMemory{ID: "color", Agent: "reviewer"}))
+	memories, err = db.GetMemories(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"private"}, ids(memories))
+	err = db.DeleteMemory(t.Context(), database.UserMemory{ID: "color"})
+	require.ErrorIs(t, err, database.ErrNotFound)
+
+	memories, err = db.SearchMemories(t.Context(), database.Query{Text: "purple"})
+	require.NoError(t, err)
+	assert.Empty(t, memories)
+}
+
+func TestMigrateOldDatabase(t *testing.T) {
+	path := t.TempDir() + "/old.db"
+
+	old, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	_, err = old.ExecContext(t.Context(), "CREATE TABLE memories (id TEXT PRIMARY KEY, created_at TEXT, memory TEXT)")
+	require.NoError(t, err)
+	_, err = old.ExecContext(t.Context(), "INSERT INTO memories (id, created_at, memory) VALUES ('1', '2024-01-01T00:00:00Z', 'The user likes tea')")
+	require.NoError(t, err)
+	require.NoError(t, old.Close())
+
+	db, err := NewMemoryDatabase(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.(*MemoryDatabase).db.Close() })
+
+	memories, err := db.SearchMemories(t.Context(), database.Query{Text: "tea"})
+	require.NoError(t, err)
+	require.Len(t, memories, 1)
+	assert.Equal(t, database.UserMemory{ID: "1", CreatedAt: "2024-01-01T00:00:00Z", Memory: "The user likes tea"}, memories[0])
+
+	// Opening the database again doesn't migrate it twice
+	again, err := NewMemoryDatabase(path)
+	require.NoError(t, err)
+	again.(*MemoryDatabase).db.Close()
+}
+
+func TestSearchMemoriesWithEmbeddings(t *testing.T) {
+	db, err := NewMemoryDatabase(t.TempDir()+"/test.db", WithEmbedder(embedding.NewHashing()))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.(*MemoryDatabase).db.Close() })
+
+	addMemories(t, db,
+		database.UserMemory{ID: "color", Memory: "The user's favourite colour is green"},
+		database.UserMemory{ID: "city", Memory: "The user lives in Paris"},
+	)
+
+	memories, err := db.SearchMemories(t.Context(), database.Query{Text: "favorite color"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"color"}, ids(memories), "close spellings match")
+
+	withoutEmbeddings := &MemoryDatabase{db: db.(*MemoryDatabase).db}
+	memories, err = withoutEmbeddings.SearchMemories(t.Context(), database.Query{Text: "favorite color"})
+	require.NoError(t, err)
+	assert.Empty(t, memories)
+
+	require.NoError(t, db.UpdateMemory(t.Context(), database.UserMemory{ID: "city", Memory: "The user moved to Lyon"}))
+	memories, err = db.SearchMemories(t.Context(), database.Query{Text: "moving to lyon"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"city"}, ids(memories))
+}
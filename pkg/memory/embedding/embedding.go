@@ -0,0 +1,91 @@
+// Package embedding computes vector representations of texts, so that
+// memories can be ranked by fuzzy lexical similarity instead of only by the
+// exact words they share.
+package embedding
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+const defaultDimensions = 512
+
+// Embedder turns a text into a normalized vector
+type Embedder interface {
+	Embed(text string) []float32
+}
+
+// Hashing is a local embedder that hashes the words and the character
+// trigrams of a text into a fixed number of dimensions. It doesn't know about
+// synonyms like an embedding model would, but it matches inflections and
+// typos, runs offline and is cheap enough to embed every memory.
+type Hashing struct {
+	dimensions int
+}
+
+// NewHashing creates a hashing embedder
+func NewHashing() *Hashing {
+	return &Hashing{dimensions: defaultDimensions}
+}
+
+// Embed implements [Embedder].
+func (h *Hashing) Embed(text string) []float32 {
+	vector := make([]float32, h.dimensions)
+	for _, word := range Words(text) {
+		addFeature(vector, word, 1)
+
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			addFeature(vector, string(runes[i:i+3]), 0.5)
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector
+}
+
+// addFeature adds a feature to the dimension it hashes to, the sign comes from
+// the hash too so that collisions cancel out instead of adding up
+func addFeature(vector []float32, feature string, weight float32) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(feature))
+	sum := h.Sum32()
+
+	if sum&(1<<31) != 0 {
+		weight = -weight
+	}
+	vector[sum%uint32(len(vector))] += weight
+}
+
+// Cosine returns the cosine similarity of two normalized vectors, 0 if they
+// don't have the same dimensions
+func Cosine(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot float32
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return dot
+}
+
+// Words splits a text into lowercase words
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log/slog"
 
+	"github.com/rumpl/rb/pkg/model/provider/fake"
 	"github.com/rumpl/rb/pkg/model/provider/options"
 )
 
@@ -11,6 +12,18 @@ import (
 // as the base provider, applying the provided options. If cloning fails, the
 // original base provider is returned.
 func CloneWithOptions(ctx context.Context, base Provider, opts ...options.Opt) Provider {
+	// Providers that wrap another one clone it themselves
+	if cloner, ok := base.(interface {
+		CloneWithOptions(ctx context.Context, opts ...options.Opt) Provider
+	}); ok {
+		return cloner.CloneWithOptions(ctx, opts...)
+	}
+
+	// Clones of fake models don't play the script, they are used for titles and summaries
+	if fakeClient, ok := base.(*fake.Client); ok {
+		return fakeClient.Clone(opts...)
+	}
+
 	config := base.BaseConfig()
 
 	// Preserve existing options, then apply overrides. Later opts take precedence.
@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go/v3"
+	"google.golang.org/genai"
+)
+
+// transientStreamErrors are the error types that providers send in the middle
+// of a stream when they are overloaded or rate limiting. Other errors, like
+// api_error, may be caused by the request itself and are not retried.
+var transientStreamErrors = []string{
+	"overloaded_error",
+	"rate_limit_error",
+}
+
+// IsRetryable returns true if err is a transient provider error that is worth
+// retrying: rate limits (429), server errors (5xx) and dropped connections.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if code := statusCode(err); code != 0 {
+		return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	msg := err.Error()
+	for _, s := range transientStreamErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return strings.Contains(msg, "connection reset by peer")
+}
+
+// statusCode returns the HTTP status code of a provider API error, or 0 if
+// err doesn't carry one.
+func statusCode(err error) int {
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) {
+		return openaiErr.StatusCode
+	}
+
+	var anthropicErr *anthropic.Error
+	if errors.As(err, &anthropicErr) {
+		return anthropicErr.StatusCode
+	}
+
+	var geminiErr genai.APIError
+	if errors.As(err, &geminiErr) {
+		return geminiErr.Code
+	}
+	var geminiErrPtr *genai.APIError
+	if errors.As(err, &geminiErrPtr) {
+		return geminiErrPtr.Code
+	}
+
+	return 0
+}
@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go/v3"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genai"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "canceled", err: fmt.Errorf("stream: %w", context.Canceled), want: false},
+		{name: "generic error", err: errors.New("invalid request"), want: false},
+		{name: "openai rate limit", err: &openai.Error{StatusCode: 429}, want: true},
+		{name: "openai bad request", err: &openai.Error{StatusCode: 400}, want: false},
+		{name: "anthropic server error", err: fmt.Errorf("creating stream: %w", &anthropic.Error{StatusCode: 529}), want: true},
+		{name: "anthropic unauthorized", err: &anthropic.Error{StatusCode: 401}, want: false},
+		{name: "gemini unavailable", err: genai.APIError{Code: 503}, want: true},
+		{name: "connection reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), want: true},
+		{name: "overloaded while streaming", err: errors.New(`received error while streaming: {"type":"error","error":{"type":"overloaded_error"}}`), want: true},
+		{name: "api error while streaming", err: errors.New(`received error while streaming: {"type":"error","error":{"type":"api_error"}}`), want: false},
+		{name: "openai request timeout", err: &openai.Error{StatusCode: 408}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsRetryable(tt.err))
+		})
+	}
+}
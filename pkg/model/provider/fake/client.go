@@ -0,0 +1,285 @@
+// Package fake implements a model that plays a script instead of calling a
+// model, to test agent configurations. The model of a fake model config is
+// the path to the script, relative to the agent file:
+//
+//	models:
+//	  scripted:
+//	    provider: fake
+//	    model: testdata/root.yaml
+//
+// The script lists the turns of the model, each request of the agent gets the
+// next turn:
+//
+//	turns:
+//	  - reasoning: The user wants a file listing
+//	    tool_calls:
+//	      - name: shell
+//	        arguments: {cmd: ls}
+//	  - content: There are 3 files.
+//	    usage: {input_tokens: 120, output_tokens: 8}
+//	  - error: rate limited
+//
+// Every agent that uses a fake model plays the script from its first turn.
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/rumpl/rb/pkg/chat"
+	latest "github.com/rumpl/rb/pkg/config/v2"
+	"github.com/rumpl/rb/pkg/model/provider/base"
+	"github.com/rumpl/rb/pkg/model/provider/options"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+const defaultReply = "Scripted reply"
+
+// Script is the list of responses a fake model streams
+type Script struct {
+	Turns []Turn `yaml:"turns"`
+	// Reply answers the requests made to generate session titles and
+	// summaries, they don't play the turns
+	Reply string `yaml:"reply,omitempty"`
+}
+
+// Turn is one response of the model
+type Turn struct {
+	Reasoning string     `yaml:"reasoning,omitempty"`
+	Content   string     `yaml:"content,omitempty"`
+	ToolCalls []ToolCall `yaml:"tool_calls,omitempty"`
+	Usage     *Usage     `yaml:"usage,omitempty"`
+	// Error fails the request, after the rest of the turn was streamed
+	Error string `yaml:"error,omitempty"`
+}
+
+// ToolCall is a tool call of a turn, Arguments is either a JSON string or an object
+type ToolCall struct {
+	ID        string `yaml:"id,omitempty"`
+	Name      string `yaml:"name"`
+	Arguments any    `yaml:"arguments,omitempty"`
+}
+
+// Usage is the token usage reported at the end of a turn
+type Usage struct {
+	InputTokens       int `yaml:"input_tokens,omitempty"`
+	OutputTokens      int `yaml:"output_tokens,omitempty"`
+	CachedInputTokens int `yaml:"cached_input_tokens,omitempty"`
+	ReasoningTokens   int `yaml:"reasoning_tokens,omitempty"`
+}
+
+// Client plays a script
+// It implements the provider.Provider interface
+type Client struct {
+	base.Config
+	script *Script
+	// replyOnly is set on clones, they answer every request with the reply of the script
+	replyOnly bool
+
+	mu   sync.Mutex
+	next int
+}
+
+// toolCallIDs numbers the tool calls without an ID, so that they are unique in a session
+var toolCallIDs atomic.Int64
+
+// NewClient creates a fake client from the script the model config points to
+func NewClient(_ context.Context, cfg *latest.ModelConfig, opts ...options.Opt) (*Client, error) {
+	if cfg == nil {
+		return nil, errors.New("model configuration is required")
+	}
+	if cfg.Provider != "fake" {
+		return nil, errors.New("model type must be 'fake'")
+	}
+
+	var globalOptions options.ModelOptions
+	for _, opt := range opts {
+		opt(&globalOptions)
+	}
+
+	script, err := LoadScript(cfg.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("Fake client created successfully", "script", cfg.Model, "turns", len(script.Turns))
+
+	return &Client{
+		Config: base.Config{
+			ModelConfig:  *cfg,
+			ModelOptions: globalOptions,
+		},
+		script: script,
+	}, nil
+}
+
+// LoadScript reads and validates a script file
+func LoadScript(path string) (*Script, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fake model script: %w", err)
+	}
+
+	var script Script
+	if err := yaml.UnmarshalWithOptions(buf, &script, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf("invalid fake model script %s: %w", path, err)
+	}
+
+	for i, turn := range script.Turns {
+		for _, toolCall := range turn.ToolCalls {
+			if toolCall.Name == "" {
+				return nil, fmt.Errorf("invalid fake model script %s: turn %d has a tool call without a name", path, i+1)
+			}
+			if _, err := toolCall.arguments(); err != nil {
+				return nil, fmt.Errorf("invalid fake model script %s: turn %d: %w", path, i+1, err)
+			}
+		}
+	}
+
+	return &script, nil
+}
+
+// Clone returns a client for the same script that answers every request with
+// the reply of the script, for session titles and summaries
+func (c *Client) Clone(opts ...options.Opt) *Client {
+	modelOptions := c.ModelOptions
+	for _, opt := range opts {
+		opt(&modelOptions)
+	}
+
+	return &Client{
+		Config: base.Config{
+			ModelConfig:  c.ModelConfig,
+			ModelOptions: modelOptions,
+			Env:          c.Env,
+		},
+		script:    c.script,
+		replyOnly: true,
+	}
+}
+
+// CreateChatCompletionStream streams the next turn of the script
+func (c *Client) CreateChatCompletionStream(context.Context, []chat.Message, []tools.Tool) (chat.MessageStream, error) {
+	if c.replyOnly {
+		reply := c.script.Reply
+		if reply == "" {
+			reply = defaultReply
+		}
+		return newStream(Turn{Content: reply})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.next >= len(c.script.Turns) {
+		return nil, fmt.Errorf("the fake model script %s has no turn left after %d turns", c.ModelConfig.Model, len(c.script.Turns))
+	}
+	turn := c.script.Turns[c.next]
+	c.next++
+
+	slog.Debug("Playing fake model turn", "script", c.ModelConfig.Model, "turn", c.next)
+	return newStream(turn)
+}
+
+func (t *ToolCall) arguments() (string, error) {
+	switch arguments := t.Arguments.(type) {
+	case nil:
+		return "{}", nil
+	case string:
+		return arguments, nil
+	default:
+		buf, err := json.Marshal(arguments)
+		if err != nil {
+			return "", fmt.Errorf("invalid arguments for tool %s: %w", t.Name, err)
+		}
+		return string(buf), nil
+	}
+}
+
+// stream streams the chunks of a turn
+type stream struct {
+	responses []chat.MessageStreamResponse
+	err       error
+}
+
+func newStream(turn Turn) (*stream, error) {
+	var responses []chat.MessageStreamResponse
+	delta := func(delta chat.MessageDelta) {
+		responses = append(responses, chat.MessageStreamResponse{
+			Object:  "chat.completion.chunk",
+			Choices: []chat.MessageStreamChoice{{Delta: delta}},
+		})
+	}
+
+	if turn.Reasoning != "" {
+		delta(chat.MessageDelta{Role: string(chat.MessageRoleAssistant), ReasoningContent: turn.Reasoning})
+	}
+	if turn.Content != "" {
+		delta(chat.MessageDelta{Role: string(chat.MessageRoleAssistant), Content: turn.Content})
+	}
+	for _, toolCall := range turn.ToolCalls {
+		arguments, err := toolCall.arguments()
+		if err != nil {
+			return nil, err
+		}
+		id := toolCall.ID
+		if id == "" {
+			id = fmt.Sprintf("call_fake_%d", toolCallIDs.Add(1))
+		}
+		delta(chat.MessageDelta{ToolCalls: []tools.ToolCall{{
+			ID:   id,
+			Type: "function",
+			Function: tools.FunctionCall{
+				Name:      toolCall.Name,
+				Arguments: arguments,
+			},
+		}}})
+	}
+
+	if turn.Error != "" {
+		return &stream{responses: responses, err: errors.New(turn.Error)}, nil
+	}
+
+	finishReason := chat.FinishReasonStop
+	if len(turn.ToolCalls) > 0 {
+		finishReason = chat.FinishReasonToolCalls
+	}
+	last := chat.MessageStreamResponse{
+		Object:  "chat.completion.chunk",
+		Choices: []chat.MessageStreamChoice{{FinishReason: finishReason}},
+	}
+	if turn.Usage != nil {
+		last.Usage = &chat.Usage{
+			InputTokens:       turn.Usage.InputTokens,
+			OutputTokens:      turn.Usage.OutputTokens,
+			CachedInputTokens: turn.Usage.CachedInputTokens,
+			ReasoningTokens:   turn.Usage.ReasoningTokens,
+		}
+	}
+	responses = append(responses, last)
+
+	return &stream{responses: responses}, nil
+}
+
+func (s *stream) Recv() (chat.MessageStreamResponse, error) {
+	if len(s.responses) == 0 {
+		if s.err != nil {
+			return chat.MessageStreamResponse{}, s.err
+		}
+		return chat.MessageStreamResponse{}, io.EOF
+	}
+	response := s.responses[0]
+	s.responses = s.responses[1:]
+	return response, nil
+}
+
+func (s *stream) Close() {}
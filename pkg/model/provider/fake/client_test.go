@@ -0,0 +1,143 @@
+package fake
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/chat"
+	latest "github.com/rumpl/rb/pkg/config/v2"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+func writeScript(t *testing.T, script string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "script.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(script), 0o644))
+	return path
+}
+
+func newClient(t *testing.T, script string) *Client {
+	t.Helper()
+
+	client, err := NewClient(t.Context(), &latest.ModelConfig{Provider: "fake", Model: writeScript(t, script)})
+	require.NoError(t, err)
+	return client
+}
+
+// playTurn collects the response of the client to a request
+func playTurn(t *testing.T, client *Client) (chat.MessageDelta, []chat.MessageStreamResponse, error) {
+	t.Helper()
+
+	stream, err := client.CreateChatCompletionStream(t.Context(), []chat.Message{{Role: chat.MessageRoleUser, Content: "hi"}}, nil)
+	if err != nil {
+		return chat.MessageDelta{}, nil, err
+	}
+	defer stream.Close()
+
+	var (
+		merged    chat.MessageDelta
+		responses []chat.MessageStreamResponse
+	)
+	for {
+		response, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return merged, responses, nil
+		}
+		if err != nil {
+			return merged, responses, err
+		}
+		responses = append(responses, response)
+		for _, choice := range response.Choices {
+			merged.Content += choice.Delta.Content
+			merged.ReasoningContent += choice.Delta.ReasoningContent
+			merged.ToolCalls = append(merged.ToolCalls, choice.Delta.ToolCalls...)
+		}
+	}
+}
+
+func TestPlayScript(t *testing.T) {
+	t.Parallel()
+
+	client := newClient(t, `
+turns:
+  - reasoning: Let me look
+    tool_calls:
+      - id: call_1
+        name: shell
+        arguments: {cmd: ls}
+      - name: read_file
+        arguments: '{"path": "main.go"}'
+  - content: Done
+    usage: {input_tokens: 12, output_tokens: 3}
+  - content: Partial
+    error: rate limited
+`)
+	assert.Equal(t, "fake/"+client.ModelConfig.Model, client.ID())
+
+	delta, responses, err := playTurn(t, client)
+	require.NoError(t, err)
+	assert.Equal(t, "Let me look", delta.ReasoningContent)
+	require.Len(t, delta.ToolCalls, 2)
+	assert.Equal(t, tools.ToolCall{ID: "call_1", Type: "function", Function: tools.FunctionCall{Name: "shell", Arguments: `{"cmd":"ls"}`}}, delta.ToolCalls[0])
+	assert.Equal(t, `{"path": "main.go"}`, delta.ToolCalls[1].Function.Arguments)
+	assert.NotEmpty(t, delta.ToolCalls[1].ID)
+	assert.Equal(t, chat.FinishReasonToolCalls, responses[len(responses)-1].Choices[0].FinishReason)
+
+	delta, responses, err = playTurn(t, client)
+	require.NoError(t, err)
+	assert.Equal(t, "Done", delta.Content)
+	last := responses[len(responses)-1]
+	assert.Equal(t, chat.FinishReasonStop, last.Choices[0].FinishReason)
+	assert.Equal(t, &chat.Usage{InputTokens: 12, OutputTokens: 3}, last.Usage)
+
+	delta, _, err = playTurn(t, client)
+	require.EqualError(t, err, "rate limited")
+	assert.Equal(t, "Partial", delta.Content)
+
+	_, _, err = playTurn(t, client)
+	require.ErrorContains(t, err, "no turn left after 3 turns")
+}
+
+func TestCloneRepliesWithoutPlayingTurns(t *testing.T) {
+	t.Parallel()
+
+	client := newClient(t, "turns:\n  - content: First\nreply: A title\n")
+
+	delta, _, err := playTurn(t, client.Clone())
+	require.NoError(t, err)
+	assert.Equal(t, "A title", delta.Content)
+
+	delta, _, err = playTurn(t, client)
+	require.NoError(t, err)
+	assert.Equal(t, "First", delta.Content)
+
+	delta, _, err = playTurn(t, newClient(t, "turns: []\n").Clone())
+	require.NoError(t, err)
+	assert.Equal(t, defaultReply, delta.Content)
+}
+
+func TestInvalidScripts(t *testing.T) {
+	t.Parallel()
+
+	for _, script := range []string{
+		"turns:\n  - tool_calls:\n      - arguments: {}\n",
+		"turns:\n  - text: hello\n",
+		"turns: {",
+	} {
+		_, err := LoadScript(writeScript(t, script))
+		assert.Error(t, err, script)
+	}
+
+	_, err := LoadScript(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+
+	_, err = NewClient(t.Context(), &latest.ModelConfig{Provider: "openai", Model: "gpt-4o"})
+	require.Error(t, err)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/rumpl/rb/pkg/model/provider/anthropic"
 	"github.com/rumpl/rb/pkg/model/provider/base"
 	"github.com/rumpl/rb/pkg/model/provider/dmr"
+	"github.com/rumpl/rb/pkg/model/provider/fake"
 	"github.com/rumpl/rb/pkg/model/provider/gemini"
 	"github.com/rumpl/rb/pkg/model/provider/openai"
 	"github.com/rumpl/rb/pkg/model/provider/options"
@@ -93,6 +94,9 @@ func New(ctx context.Context, cfg *latest.ModelConfig, env environment.Provider,
 	case "dmr":
 		return dmr.NewClient(ctx, enhancedCfg, opts...)
 
+	case "fake":
+		return fake.NewClient(ctx, enhancedCfg, opts...)
+
 	default:
 		slog.Error("Unknown provider type", "type", providerType)
 		return nil, fmt.Errorf("unknown provider type: %s", providerType)
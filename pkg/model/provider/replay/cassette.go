@@ -0,0 +1,191 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rumpl/rb/pkg/chat"
+)
+
+// Mode is how a cassette is used
+type Mode string
+
+const (
+	// ModeReplay replays the recorded responses, requests that weren't
+	// recorded are sent to the model and their responses are recorded
+	ModeReplay Mode = "replay"
+	// ModeRecord sends all the requests to the model and records the
+	// responses, replacing the content of the cassette
+	ModeRecord Mode = "record"
+	// ModeStrict only replays the recorded responses, requests that weren't
+	// recorded fail. No model is needed.
+	ModeStrict Mode = "strict"
+)
+
+// ErrUnmatchedRequest is returned in strict mode for requests that weren't recorded
+var ErrUnmatchedRequest = errors.New("no recorded response for this request")
+
+// ParseMode returns the mode with the given name, the default mode is replay
+func ParseMode(name string) (Mode, error) {
+	switch Mode(name) {
+	case "", ModeReplay:
+		return ModeReplay, nil
+	case ModeRecord, ModeStrict:
+		return Mode(name), nil
+	default:
+		return "", fmt.Errorf("unknown cassette mode %q, expected one of replay, record or strict", name)
+	}
+}
+
+// Interaction is a recorded request and the stream of its response
+type Interaction struct {
+	// Hash identifies the normalized request
+	Hash     string                       `json:"hash"`
+	Model    string                       `json:"model"`
+	Request  Request                      `json:"request"`
+	Response []chat.MessageStreamResponse `json:"response"`
+	// Error is the error that ended the stream, if any
+	Error string `json:"error,omitempty"`
+}
+
+// Request is the part of a request that's recorded, to help read cassettes
+type Request struct {
+	Messages []chat.Message `json:"messages"`
+	Tools    []string       `json:"tools,omitempty"`
+}
+
+// Cassette is a file of recorded model interactions
+type Cassette struct {
+	path string
+	mode Mode
+
+	mu           sync.Mutex
+	interactions []Interaction
+	// replayed counts how many times each hash was replayed, so that
+	// identical requests get their responses in the recorded order
+	replayed map[string]int
+}
+
+type cassetteFile struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+var (
+	openCassettesMu sync.Mutex
+	openCassettes   = map[string]*Cassette{}
+)
+
+// Open opens a cassette file. All the models of a process share the same
+// cassette for a given path, so that their interactions are saved together.
+func Open(path string, mode Mode) (*Cassette, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	openCassettesMu.Lock()
+	defer openCassettesMu.Unlock()
+
+	if cassette, ok := openCassettes[absPath]; ok {
+		if cassette.mode != mode {
+			return nil, fmt.Errorf("cassette %s is already open in %s mode", path, cassette.mode)
+		}
+		return cassette, nil
+	}
+
+	cassette := &Cassette{
+		path:     absPath,
+		mode:     mode,
+		replayed: map[string]int{},
+	}
+
+	// Recording starts from scratch
+	if mode != ModeRecord {
+		buf, err := os.ReadFile(absPath)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && mode == ModeReplay:
+		case err != nil:
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		default:
+			var file cassetteFile
+			if err := json.Unmarshal(buf, &file); err != nil {
+				return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+			}
+			cassette.interactions = file.Interactions
+		}
+	}
+
+	openCassettes[absPath] = cassette
+	return cassette, nil
+}
+
+// Close forgets the cassette, the next Open of its path reads the file again
+func (c *Cassette) Close() {
+	openCassettesMu.Lock()
+	defer openCassettesMu.Unlock()
+
+	if openCassettes[c.path] == c {
+		delete(openCassettes, c.path)
+	}
+}
+
+// Mode returns the mode the cassette was opened in
+func (c *Cassette) Mode() Mode {
+	return c.mode
+}
+
+// Interactions returns the recorded interactions
+func (c *Cassette) Interactions() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Interaction(nil), c.interactions...)
+}
+
+// find returns the next recorded interaction for a request. Once all the
+// interactions of a request were replayed, the last one is replayed again.
+func (c *Cassette) find(hash string) (*Interaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matches []*Interaction
+	for i := range c.interactions {
+		if c.interactions[i].Hash == hash {
+			matches = append(matches, &c.interactions[i])
+		}
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	n := c.replayed[hash]
+	c.replayed[hash]++
+	return matches[min(n, len(matches)-1)], true
+}
+
+// record adds an interaction and saves the cassette
+func (c *Cassette) record(interaction Interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.interactions = append(c.interactions, interaction)
+
+	buf, err := json.MarshalIndent(cassetteFile{Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that the cassette is never half written
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
@@ -0,0 +1,248 @@
+// Package replay records the responses of models to a cassette file and
+// replays them, so that agents can be run and tested without calling models.
+package replay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/config"
+	latest "github.com/rumpl/rb/pkg/config/v2"
+	"github.com/rumpl/rb/pkg/environment"
+	"github.com/rumpl/rb/pkg/model/provider"
+	"github.com/rumpl/rb/pkg/model/provider/base"
+	"github.com/rumpl/rb/pkg/model/provider/options"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+// Provider records the responses of a model to a cassette, or replays them
+type Provider struct {
+	config   base.Config
+	model    provider.Provider
+	cassette *Cassette
+}
+
+// New wraps a model, the model can be nil in strict mode
+func New(config base.Config, model provider.Provider, cassette *Cassette) *Provider {
+	return &Provider{
+		config:   config,
+		model:    model,
+		cassette: cassette,
+	}
+}
+
+// NewModel creates the provider of a model. When the runtime config has a
+// cassette, its responses are recorded or replayed. In strict mode the model
+// isn't created, so no credentials are needed.
+func NewModel(ctx context.Context, cfg *latest.ModelConfig, env environment.Provider, runtimeConfig config.RuntimeConfig, opts ...options.Opt) (provider.Provider, error) {
+	if runtimeConfig.Cassette == "" {
+		return provider.New(ctx, cfg, env, opts...)
+	}
+
+	mode, err := ParseMode(runtimeConfig.CassetteMode)
+	if err != nil {
+		return nil, err
+	}
+	cassette, err := Open(runtimeConfig.Cassette, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == ModeStrict {
+		return New(base.Config{ModelConfig: *cfg, Env: env}, nil, cassette), nil
+	}
+
+	model, err := provider.New(ctx, cfg, env, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return New(model.BaseConfig(), model, cassette), nil
+}
+
+// ID returns the ID of the model
+func (p *Provider) ID() string {
+	return p.config.ID()
+}
+
+// BaseConfig returns the config of the model
+func (p *Provider) BaseConfig() base.Config {
+	return p.config
+}
+
+// CloneWithOptions clones the model, the clone uses the same cassette
+func (p *Provider) CloneWithOptions(ctx context.Context, opts ...options.Opt) provider.Provider {
+	if p.model == nil {
+		return p
+	}
+	clone := provider.CloneWithOptions(ctx, p.model, opts...)
+	return New(clone.BaseConfig(), clone, p.cassette)
+}
+
+// CreateChatCompletionStream replays the recorded response of the request, or
+// streams and records the response of the model
+func (p *Provider) CreateChatCompletionStream(ctx context.Context, messages []chat.Message, availableTools []tools.Tool) (chat.MessageStream, error) {
+	hash := Hash(p.ID(), messages, availableTools)
+
+	if p.cassette.Mode() != ModeRecord {
+		if interaction, ok := p.cassette.find(hash); ok {
+			slog.Debug("Replaying recorded response", "model", p.ID(), "hash", hash)
+			return &replayStream{interaction: interaction}, nil
+		}
+	}
+
+	if p.cassette.Mode() == ModeStrict || p.model == nil {
+		return nil, fmt.Errorf("%w: model %s, request hash %s", ErrUnmatchedRequest, p.ID(), hash)
+	}
+
+	stream, err := p.model.CreateChatCompletionStream(ctx, messages, availableTools)
+	if err != nil {
+		return nil, err
+	}
+
+	toolNames := make([]string, len(availableTools))
+	for i, tool := range availableTools {
+		toolNames[i] = tool.Name
+	}
+
+	slog.Debug("Recording response", "model", p.ID(), "hash", hash)
+	return &recordingStream{
+		stream:   stream,
+		cassette: p.cassette,
+		interaction: Interaction{
+			Hash:    hash,
+			Model:   p.ID(),
+			Request: Request{Messages: messages, Tools: toolNames},
+		},
+	}, nil
+}
+
+// replayStream streams a recorded response
+type replayStream struct {
+	interaction *Interaction
+	next        int
+}
+
+func (s *replayStream) Recv() (chat.MessageStreamResponse, error) {
+	if s.next < len(s.interaction.Response) {
+		response := s.interaction.Response[s.next]
+		s.next++
+		return response, nil
+	}
+	if s.interaction.Error != "" {
+		return chat.MessageStreamResponse{}, errors.New(s.interaction.Error)
+	}
+	return chat.MessageStreamResponse{}, io.EOF
+}
+
+func (s *replayStream) Close() {}
+
+// recordingStream records the response of a model as it's streamed. The
+// interaction is saved once the stream ends, streams closed early aren't saved.
+type recordingStream struct {
+	stream      chat.MessageStream
+	cassette    *Cassette
+	interaction Interaction
+	done        bool
+}
+
+func (s *recordingStream) Recv() (chat.MessageStreamResponse, error) {
+	response, err := s.stream.Recv()
+	switch {
+	case err == nil:
+		s.interaction.Response = append(s.interaction.Response, response)
+	case !s.done:
+		s.done = true
+		if !errors.Is(err, io.EOF) {
+			s.interaction.Error = err.Error()
+		}
+		if recordErr := s.cassette.record(s.interaction); recordErr != nil {
+			slog.Error("Failed to save cassette", "error", recordErr)
+		}
+	}
+	return response, err
+}
+
+func (s *recordingStream) Close() {
+	s.stream.Close()
+}
+
+var datePattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+
+// Hash identifies a request. The request is normalized so that recordings
+// can be replayed on other days and machines: the working directory, the
+// home directory and the dates are replaced by placeholders, whitespace is
+// collapsed and the IDs of tool calls and the timestamps are ignored.
+func Hash(model string, messages []chat.Message, availableTools []tools.Tool) string {
+	var replacements []string
+	if wd, err := os.Getwd(); err == nil && wd != "/" {
+		replacements = append(replacements, wd, "<wd>")
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "/" {
+		replacements = append(replacements, home, "<home>")
+	}
+	replacer := strings.NewReplacer(replacements...)
+	normalize := func(text string) string {
+		text = datePattern.ReplaceAllString(replacer.Replace(text), "<date>")
+		return strings.Join(strings.Fields(text), " ")
+	}
+
+	type normalizedToolCall struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	}
+	type normalizedMessage struct {
+		Role      chat.MessageRole     `json:"role"`
+		Content   string               `json:"content"`
+		Parts     []string             `json:"parts,omitempty"`
+		ToolCalls []normalizedToolCall `json:"tool_calls,omitempty"`
+	}
+
+	normalized := struct {
+		Model    string              `json:"model"`
+		Messages []normalizedMessage `json:"messages"`
+		Tools    []string            `json:"tools"`
+	}{
+		Model: model,
+	}
+
+	for i := range messages {
+		msg := normalizedMessage{
+			Role:    messages[i].Role,
+			Content: normalize(messages[i].Content),
+		}
+		for _, part := range messages[i].MultiContent {
+			if part.ImageURL != nil {
+				msg.Parts = append(msg.Parts, part.ImageURL.URL)
+			} else {
+				msg.Parts = append(msg.Parts, normalize(part.Text))
+			}
+		}
+		for _, toolCall := range messages[i].ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, normalizedToolCall{
+				Name:      toolCall.Function.Name,
+				Arguments: normalize(toolCall.Function.Arguments),
+			})
+		}
+		normalized.Messages = append(normalized.Messages, msg)
+	}
+
+	for _, tool := range availableTools {
+		normalized.Tools = append(normalized.Tools, tool.Name)
+	}
+	slices.Sort(normalized.Tools)
+
+	buf, _ := json.Marshal(normalized)
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
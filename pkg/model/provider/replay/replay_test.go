@@ -0,0 +1,212 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/config"
+	latest "github.com/rumpl/rb/pkg/config/v2"
+	"github.com/rumpl/rb/pkg/environment"
+	"github.com/rumpl/rb/pkg/model/provider"
+	"github.com/rumpl/rb/pkg/model/provider/base"
+	"github.com/rumpl/rb/pkg/model/provider/options"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+// countingProvider answers every request with the same text and counts the requests
+type countingProvider struct {
+	answer string
+	err    error
+	calls  atomic.Int32
+}
+
+func (p *countingProvider) ID() string { return "test/model" }
+
+func (p *countingProvider) BaseConfig() base.Config {
+	return base.Config{ModelConfig: latest.ModelConfig{Provider: "test", Model: "model"}}
+}
+
+func (p *countingProvider) CreateChatCompletionStream(context.Context, []chat.Message, []tools.Tool) (chat.MessageStream, error) {
+	p.calls.Add(1)
+	return &testStream{
+		responses: []chat.MessageStreamResponse{
+			{Choices: []chat.MessageStreamChoice{{Delta: chat.MessageDelta{Content: p.answer}}}},
+			{Choices: []chat.MessageStreamChoice{{FinishReason: chat.FinishReasonStop}}, Usage: &chat.Usage{InputTokens: 10, OutputTokens: 2}},
+		},
+		err: p.err,
+	}, nil
+}
+
+type testStream struct {
+	responses []chat.MessageStreamResponse
+	err       error
+}
+
+func (s *testStream) Recv() (chat.MessageStreamResponse, error) {
+	if len(s.responses) == 0 {
+		if s.err != nil {
+			return chat.MessageStreamResponse{}, s.err
+		}
+		return chat.MessageStreamResponse{}, io.EOF
+	}
+	response := s.responses[0]
+	s.responses = s.responses[1:]
+	return response, nil
+}
+
+func (s *testStream) Close() {}
+
+func readAll(t *testing.T, stream chat.MessageStream) (string, error) {
+	t.Helper()
+
+	var content string
+	for {
+		response, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return content, nil
+		}
+		if err != nil {
+			return content, err
+		}
+		for _, choice := range response.Choices {
+			content += choice.Delta.Content
+		}
+	}
+}
+
+func ask(t *testing.T, p provider.Provider, question string) (string, error) {
+	t.Helper()
+
+	stream, err := p.CreateChatCompletionStream(t.Context(), []chat.Message{{Role: chat.MessageRoleUser, Content: question}}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+	return readAll(t, stream)
+}
+
+func openCassette(t *testing.T, path string, mode Mode) *Cassette {
+	t.Helper()
+
+	cassette, err := Open(path, mode)
+	require.NoError(t, err)
+	t.Cleanup(cassette.Close)
+	return cassette
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	model := &countingProvider{answer: "Paris"}
+	cassette := openCassette(t, path, ModeRecord)
+	answer, err := ask(t, New(model.BaseConfig(), model, cassette), "Capital of France?")
+	require.NoError(t, err)
+	assert.Equal(t, "Paris", answer)
+	cassette.Close()
+
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+
+	strict := New(model.BaseConfig(), nil, openCassette(t, path, ModeStrict))
+	answer, err = ask(t, strict, "Capital  of France?\n")
+	require.NoError(t, err)
+	assert.Equal(t, "Paris", answer)
+
+	_, err = ask(t, strict, "Capital of Italy?")
+	require.ErrorIs(t, err, ErrUnmatchedRequest)
+
+	assert.Equal(t, int32(1), model.calls.Load())
+}
+
+func TestReplayModeRecordsUnmatchedRequests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	model := &countingProvider{answer: "Rome"}
+	p := New(model.BaseConfig(), model, openCassette(t, path, ModeReplay))
+
+	for range 3 {
+		answer, err := ask(t, p, "Capital of Italy?")
+		require.NoError(t, err)
+		assert.Equal(t, "Rome", answer)
+	}
+
+	assert.Equal(t, int32(1), model.calls.Load())
+	assert.Len(t, p.cassette.Interactions(), 1)
+}
+
+func TestReplayRecordedErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	model := &countingProvider{answer: "Par", err: errors.New("connection reset")}
+	cassette := openCassette(t, path, ModeRecord)
+	_, err := ask(t, New(model.BaseConfig(), model, cassette), "Capital of France?")
+	require.EqualError(t, err, "connection reset")
+	cassette.Close()
+
+	answer, err := ask(t, New(model.BaseConfig(), nil, openCassette(t, path, ModeStrict)), "Capital of France?")
+	require.EqualError(t, err, "connection reset")
+	assert.Equal(t, "Par", answer)
+}
+
+func TestOpenErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := Open(filepath.Join(dir, "missing.json"), ModeStrict)
+	require.Error(t, err)
+
+	invalid := filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(invalid, []byte("{"), 0o644))
+	_, err = Open(invalid, ModeReplay)
+	require.Error(t, err)
+
+	path := filepath.Join(dir, "cassette.json")
+	openCassette(t, path, ModeRecord)
+	_, err = Open(path, ModeStrict)
+	require.Error(t, err)
+
+	_, err = ParseMode("once")
+	require.Error(t, err)
+}
+
+func TestNewModelStrictNeedsNoCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"interactions": []}`), 0o644))
+
+	runtimeConfig := config.RuntimeConfig{Cassette: path, CassetteMode: "strict"}
+	model, err := NewModel(t.Context(), &latest.ModelConfig{Provider: "openai", Model: "gpt-4o"}, environment.NewMultiProvider(), runtimeConfig)
+	require.NoError(t, err)
+	t.Cleanup(model.(*Provider).cassette.Close)
+	assert.Equal(t, "openai/gpt-4o", model.ID())
+
+	clone := provider.CloneWithOptions(t.Context(), model, options.WithStructuredOutput(nil))
+	assert.Same(t, model, clone)
+}
+
+func TestHashNormalization(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	request := func(content, toolCallID string) []chat.Message {
+		return []chat.Message{
+			{Role: chat.MessageRoleSystem, Content: content},
+			{Role: chat.MessageRoleAssistant, ToolCalls: []tools.ToolCall{{ID: toolCallID, Function: tools.FunctionCall{Name: "shell", Arguments: `{"cmd":"ls"}`}}}},
+			{Role: chat.MessageRoleTool, ToolCallID: toolCallID, Content: "main.go"},
+		}
+	}
+	availableTools := []tools.Tool{{Name: "shell"}, {Name: "read_file"}}
+	reordered := []tools.Tool{{Name: "read_file"}, {Name: "shell"}}
+
+	hash := Hash("test/model", request("Today is 2025-01-02.\nWorking directory: "+wd, "call_1"), availableTools)
+	assert.Equal(t, hash, Hash("test/model", request("Today is 2026-10-17.  Working directory: <wd>", "call_2"), reordered))
+	assert.NotEqual(t, hash, Hash("other/model", request("Today is 2025-01-02.\nWorking directory: "+wd, "call_1"), availableTools))
+	assert.NotEqual(t, hash, Hash("test/model", request("Tomorrow is 2025-01-02.\nWorking directory: "+wd, "call_1"), availableTools))
+	assert.NotEqual(t, hash, Hash("test/model", request("Today is 2025-01-02.\nWorking directory: "+wd, "call_1"), availableTools[:1]))
+}
@@ -0,0 +1,225 @@
+package permissions
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+// Decision is the outcome of evaluating a tool call against permission rules
+type Decision string
+
+const (
+	// DecisionNone means that no rule matched the tool call
+	DecisionNone Decision = ""
+	// DecisionAllow means that the tool call can run without confirmation
+	DecisionAllow Decision = "allow"
+	// DecisionAsk means that the user must confirm the tool call
+	DecisionAsk Decision = "ask"
+	// DecisionDeny means that the tool call must not run
+	DecisionDeny Decision = "deny"
+)
+
+// Rule matches tool calls by tool name and, optionally, by argument values.
+//
+// Both Tool and the values of Args are globs where `*` matches any sequence
+// of characters (including `/`) and `?` matches a single character. A
+// backslash before `*`, `?` or another backslash matches that character.
+// Every argument listed in Args must be present in the tool call and match.
+// When an argument is a list, every element of the list must match.
+type Rule struct {
+	Tool string            `json:"tool"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// String returns a human readable representation of the rule, e.g. `shell(cmd=git *)`
+func (r Rule) String() string {
+	if len(r.Args) == 0 {
+		return r.Tool
+	}
+
+	keys := make([]string, 0, len(r.Args))
+	for k := range r.Args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, len(keys))
+	for _, k := range keys {
+		args = append(args, k+"="+r.Args[k])
+	}
+
+	return r.Tool + "(" + strings.Join(args, ", ") + ")"
+}
+
+// CallRule returns a rule matching the calls of the same tool with the same
+// values for the arguments of toolCall. It returns false when an argument is
+// a list, an object or null, rules can't match those exactly.
+func CallRule(toolCall tools.ToolCall) (Rule, bool) {
+	var args map[string]any
+	if strings.TrimSpace(toolCall.Function.Arguments) != "" {
+		if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+			return Rule{}, false
+		}
+	}
+
+	rule := Rule{Tool: escapeGlob(toolCall.Function.Name)}
+	for name, value := range args {
+		var pattern string
+		switch v := value.(type) {
+		case nil, []any, map[string]any:
+			return Rule{}, false
+		case string:
+			pattern = escapeGlob(v)
+		default:
+			pattern = escapeGlob(fmt.Sprint(v))
+		}
+		if rule.Args == nil {
+			rule.Args = map[string]string{}
+		}
+		rule.Args[name] = pattern
+	}
+
+	return rule, true
+}
+
+// Matches returns true if the rule applies to the given tool call
+func (r Rule) Matches(toolCall tools.ToolCall) bool {
+	if !globMatch(r.Tool, toolCall.Function.Name) {
+		return false
+	}
+	if len(r.Args) == 0 {
+		return true
+	}
+
+	var args map[string]any
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+		return false
+	}
+
+	for name, pattern := range r.Args {
+		value, ok := args[name]
+		if !ok || !argMatches(pattern, value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func argMatches(pattern string, value any) bool {
+	switch v := value.(type) {
+	case string:
+		return globMatch(pattern, v)
+	case []any:
+		if len(v) == 0 {
+			return false
+		}
+		for _, item := range v {
+			if !argMatches(pattern, item) {
+				return false
+			}
+		}
+		return true
+	case nil, map[string]any:
+		return false
+	default:
+		return globMatch(pattern, fmt.Sprint(v))
+	}
+}
+
+// Checker evaluates tool calls against allow, ask and deny rules.
+// A nil Checker has no rules.
+type Checker struct {
+	allow []Rule
+	ask   []Rule
+	deny  []Rule
+}
+
+// NewChecker creates a Checker from lists of allow, ask and deny rules
+func NewChecker(allow, ask, deny []Rule) *Checker {
+	return &Checker{
+		allow: allow,
+		ask:   ask,
+		deny:  deny,
+	}
+}
+
+// Check returns the decision for a tool call and the rule that produced it.
+// Deny rules take precedence over ask rules, which take precedence over allow rules.
+func (c *Checker) Check(toolCall tools.ToolCall) (Decision, *Rule) {
+	if c == nil {
+		return DecisionNone, nil
+	}
+
+	if rule := firstMatch(c.deny, toolCall); rule != nil {
+		return DecisionDeny, rule
+	}
+	if rule := firstMatch(c.ask, toolCall); rule != nil {
+		return DecisionAsk, rule
+	}
+	if rule := firstMatch(c.allow, toolCall); rule != nil {
+		return DecisionAllow, rule
+	}
+
+	return DecisionNone, nil
+}
+
+// AnyMatch returns true if any of the rules matches the tool call
+func AnyMatch(rules []Rule, toolCall tools.ToolCall) bool {
+	return firstMatch(rules, toolCall) != nil
+}
+
+func firstMatch(rules []Rule, toolCall tools.ToolCall) *Rule {
+	for i := range rules {
+		if rules[i].Matches(toolCall) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+func globMatch(pattern, value string) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	var expr strings.Builder
+	expr.WriteString("(?s)^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			if r != '*' && r != '?' && r != '\\' {
+				// Only wildcards and backslashes are escaped, other backslashes are kept
+				expr.WriteString(regexp.QuoteMeta(`\`))
+			}
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*':
+			expr.WriteString(".*")
+		case r == '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if escaped {
+		expr.WriteString(regexp.QuoteMeta(`\`))
+	}
+	expr.WriteString("$")
+
+	matched, err := regexp.MatchString(expr.String(), value)
+	return err == nil && matched
+}
+
+// escapeGlob escapes the wildcards of s so that it only matches itself
+func escapeGlob(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`).Replace(s)
+}
@@ -0,0 +1,174 @@
+package permissions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+func call(name, args string) tools.ToolCall {
+	return tools.ToolCall{
+		ID:       "call_1",
+		Type:     "function",
+		Function: tools.FunctionCall{Name: name, Arguments: args},
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     Rule
+		toolCall tools.ToolCall
+		want     bool
+	}{
+		{
+			name:     "exact tool name",
+			rule:     Rule{Tool: "read_file"},
+			toolCall: call("read_file", `{"path":"main.go"}`),
+			want:     true,
+		},
+		{
+			name:     "tool name glob",
+			rule:     Rule{Tool: "read_*"},
+			toolCall: call("read_multiple_files", `{}`),
+			want:     true,
+		},
+		{
+			name:     "different tool",
+			rule:     Rule{Tool: "read_file"},
+			toolCall: call("write_file", `{}`),
+			want:     false,
+		},
+		{
+			name:     "command prefix",
+			rule:     Rule{Tool: "shell", Args: map[string]string{"cmd": "git *"}},
+			toolCall: call("shell", `{"cmd":"git commit -m 'fix a/b'"}`),
+			want:     true,
+		},
+		{
+			name:     "command prefix mismatch",
+			rule:     Rule{Tool: "shell", Args: map[string]string{"cmd": "git *"}},
+			toolCall: call("shell", `{"cmd":"rm -rf /"}`),
+			want:     false,
+		},
+		{
+			name:     "multiline command",
+			rule:     Rule{Tool: "shell", Args: map[string]string{"cmd": "echo *"}},
+			toolCall: call("shell", `{"cmd":"echo a\nrm -rf /"}`),
+			want:     true,
+		},
+		{
+			name:     "missing argument",
+			rule:     Rule{Tool: "shell", Args: map[string]string{"cmd": "*"}},
+			toolCall: call("shell", `{}`),
+			want:     false,
+		},
+		{
+			name:     "invalid arguments",
+			rule:     Rule{Tool: "shell", Args: map[string]string{"cmd": "*"}},
+			toolCall: call("shell", `not json`),
+			want:     false,
+		},
+		{
+			name:     "all list elements match",
+			rule:     Rule{Tool: "read_multiple_files", Args: map[string]string{"paths": "src/*"}},
+			toolCall: call("read_multiple_files", `{"paths":["src/a.go","src/b/c.go"]}`),
+			want:     true,
+		},
+		{
+			name:     "one list element does not match",
+			rule:     Rule{Tool: "read_multiple_files", Args: map[string]string{"paths": "src/*"}},
+			toolCall: call("read_multiple_files", `{"paths":["src/a.go","/etc/passwd"]}`),
+			want:     false,
+		},
+		{
+			name:     "non string argument",
+			rule:     Rule{Tool: "fetch", Args: map[string]string{"timeout": "3?"}},
+			toolCall: call("fetch", `{"timeout":30}`),
+			want:     true,
+		},
+		{
+			name:     "regexp characters are literal",
+			rule:     Rule{Tool: "shell", Args: map[string]string{"cmd": "ls (a)"}},
+			toolCall: call("shell", `{"cmd":"ls (a)"}`),
+			want:     true,
+		},
+		{
+			name:     "escaped wildcard",
+			rule:     Rule{Tool: "shell", Args: map[string]string{"cmd": `rm \*`}},
+			toolCall: call("shell", `{"cmd":"rm /"}`),
+			want:     false,
+		},
+		{
+			name:     "escaped wildcard matches itself",
+			rule:     Rule{Tool: "shell", Args: map[string]string{"cmd": `rm \*.log`}},
+			toolCall: call("shell", `{"cmd":"rm *.log"}`),
+			want:     true,
+		},
+		{
+			name:     "other backslashes are literal",
+			rule:     Rule{Tool: "read_file", Args: map[string]string{"path": `C:\Users\notes.txt`}},
+			toolCall: call("read_file", `{"path":"C:\\Users\\notes.txt"}`),
+			want:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rule.Matches(tt.toolCall))
+		})
+	}
+}
+
+func TestCheckerPrecedence(t *testing.T) {
+	checker := NewChecker(
+		[]Rule{{Tool: "shell"}},
+		[]Rule{{Tool: "shell", Args: map[string]string{"cmd": "git push*"}}},
+		[]Rule{{Tool: "shell", Args: map[string]string{"cmd": "rm *"}}},
+	)
+
+	decision, rule := checker.Check(call("shell", `{"cmd":"ls"}`))
+	assert.Equal(t, DecisionAllow, decision)
+	assert.Equal(t, "shell", rule.String())
+
+	decision, rule = checker.Check(call("shell", `{"cmd":"git push origin main"}`))
+	assert.Equal(t, DecisionAsk, decision)
+	assert.Equal(t, "shell(cmd=git push*)", rule.String())
+
+	decision, rule = checker.Check(call("shell", `{"cmd":"rm -rf /"}`))
+	assert.Equal(t, DecisionDeny, decision)
+	assert.Equal(t, "shell(cmd=rm *)", rule.String())
+
+	decision, rule = checker.Check(call("read_file", `{}`))
+	assert.Equal(t, DecisionNone, decision)
+	assert.Nil(t, rule)
+}
+
+func TestNilChecker(t *testing.T) {
+	var checker *Checker
+
+	decision, rule := checker.Check(call("shell", `{}`))
+	assert.Equal(t, DecisionNone, decision)
+	assert.Nil(t, rule)
+}
+
+func TestCallRule(t *testing.T) {
+	rule, ok := CallRule(call("shell", `{"cmd":"rm *.log","timeout":30}`))
+	assert.True(t, ok)
+	assert.Equal(t, Rule{Tool: "shell", Args: map[string]string{"cmd": `rm \*.log`, "timeout": "30"}}, rule)
+	assert.True(t, rule.Matches(call("shell", `{"cmd":"rm *.log","timeout":30}`)))
+	assert.False(t, rule.Matches(call("shell", `{"cmd":"rm a.log","timeout":30}`)))
+	assert.False(t, rule.Matches(call("shell", `{"cmd":"rm *.log","timeout":60}`)))
+	assert.False(t, rule.Matches(call("shell", `{"cmd":"rm *.log"}`)))
+
+	rule, ok = CallRule(call("list_allowed_directories", ""))
+	assert.True(t, ok)
+	assert.Equal(t, Rule{Tool: "list_allowed_directories"}, rule)
+
+	_, ok = CallRule(call("read_multiple_files", `{"paths":["a.go","b.go"]}`))
+	assert.False(t, ok)
+	_, ok = CallRule(call("shell", `not json`))
+	assert.False(t, ok)
+}
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/rumpl/rb/pkg/api"
@@ -23,11 +24,26 @@ type Client struct {
 	baseURL    *url.URL
 	httpClient *http.Client
 	registry   map[string]func() Event
+	token      string
 }
 
 // ClientOption is a function for configuring the Client
 type ClientOption func(*Client)
 
+// WithToken authenticates the requests with a bearer token
+func WithToken(token string) ClientOption {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// setAuthorization adds the bearer token, if any, to a request
+func (c *Client) setAuthorization(req *http.Request) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
 // NewClient creates a new HTTP client for the rb server
 func NewClient(baseURL string, opts ...ClientOption) (*Client, error) {
 	parsedURL, err := url.Parse(baseURL)
@@ -54,6 +70,7 @@ func NewClient(baseURL string, opts ...ClientOption) (*Client, error) {
 			"session_compaction":     func() Event { return &SessionCompactionEvent{} },
 			"partial_tool_call":      func() Event { return &PartialToolCallEvent{} },
 			"max_iterations_reached": func() Event { return &MaxIterationsReachedEvent{} },
+			"budget_exceeded":        func() Event { return &BudgetExceededEvent{} },
 			"error":                  func() Event { return &ErrorEvent{} },
 			"elicitation_request":    func() Event { return &ElicitationRequestEvent{} },
 			"authorization_event":    func() Event { return &AuthorizationEvent{} },
@@ -88,6 +105,7 @@ func (c *Client) doRequest(ctx context.Context, method, endpoint string, body, r
 	}
 
 	u := *c.baseURL
+	endpoint, u.RawQuery, _ = strings.Cut(endpoint, "?")
 	u.Path = path.Join(u.Path, endpoint)
 
 	req, err := http.NewRequestWithContext(ctx, method, u.String(), reqBody)
@@ -98,6 +116,7 @@ func (c *Client) doRequest(ctx context.Context, method, endpoint string, body, r
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	c.setAuthorization(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -118,6 +137,12 @@ func (c *Client) doRequest(ctx context.Context, method, endpoint string, body, r
 		return fmt.Errorf("HTTP error %d: %s", resp.StatusCode, string(respBody))
 	}
 
+	// Responses that aren't JSON, like exports, are returned as is
+	if raw, ok := result.(*[]byte); ok {
+		*raw = respBody
+		return nil
+	}
+
 	if result != nil {
 		if err := json.Unmarshal(respBody, result); err != nil {
 			return fmt.Errorf("unmarshaling response: %w", err)
@@ -219,6 +244,13 @@ func (c *Client) GetSessions(ctx context.Context) ([]api.SessionsResponse, error
 	return sessions, err
 }
 
+// SearchSessions searches the titles, messages and tool call arguments of the sessions
+func (c *Client) SearchSessions(ctx context.Context, query string) ([]api.SessionSearchResponse, error) {
+	var results []api.SessionSearchResponse
+	err := c.doRequest(ctx, http.MethodGet, "/api/sessions/search?q="+url.QueryEscape(query), nil, &results)
+	return results, err
+}
+
 // GetSession retrieves a session by ID
 func (c *Client) GetSession(ctx context.Context, id string) (*api.SessionResponse, error) {
 	var sess api.SessionResponse
@@ -233,6 +265,28 @@ func (c *Client) CreateSession(ctx context.Context, sessTemplate *session.Sessio
 	return &sess, err
 }
 
+// ForkSession creates a new session from the items of a session up to and including messageIndex
+func (c *Client) ForkSession(ctx context.Context, id string, messageIndex int) (*session.Session, error) {
+	var sess session.Session
+	req := api.ForkSessionRequest{MessageIndex: &messageIndex}
+	err := c.doRequest(ctx, http.MethodPost, "/api/sessions/"+id+"/fork", req, &sess)
+	return &sess, err
+}
+
+// ExportSession exports a session in the given format, "json", "markdown" or "html"
+func (c *Client) ExportSession(ctx context.Context, id, format string) ([]byte, error) {
+	var data []byte
+	err := c.doRequest(ctx, http.MethodGet, "/api/sessions/"+id+"/export?format="+url.QueryEscape(format), nil, &data)
+	return data, err
+}
+
+// ImportSession imports a session exported as JSON
+func (c *Client) ImportSession(ctx context.Context, sess *session.Session) (*api.SessionsResponse, error) {
+	var resp api.SessionsResponse
+	err := c.doRequest(ctx, http.MethodPost, "/api/sessions/import", sess, &resp)
+	return &resp, err
+}
+
 // ResumeSession resumes a session by ID
 func (c *Client) ResumeSession(ctx context.Context, id, confirmation string) error {
 	req := api.ResumeSessionRequest{Confirmation: confirmation}
@@ -283,6 +337,7 @@ func (c *Client) runAgentWithAgentName(ctx context.Context, sessionID, agent, ag
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "text/event-stream")
 	req.Header.Set("Cache-Control", "no-cache")
+	c.setAuthorization(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
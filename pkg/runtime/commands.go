@@ -2,12 +2,18 @@ package runtime
 
 import (
 	"context"
+	"fmt"
 	"strings"
+
+	"github.com/rumpl/rb/pkg/tools"
 )
 
-func ResolveCommand(ctx context.Context, rt Runtime, userInput string) string {
+// ResolveCommand converts a /command of the agent to its prompt and a /prompt
+// of its MCP servers to the text of the prompt, the words after the name of a
+// prompt are its arguments
+func ResolveCommand(ctx context.Context, rt Runtime, userInput string) (string, error) {
 	if !strings.HasPrefix(userInput, "/") {
-		return userInput
+		return userInput, nil
 	}
 
 	cmd, rest, _ := strings.Cut(userInput, " ")
@@ -17,7 +23,58 @@ func ResolveCommand(ctx context.Context, rt Runtime, userInput string) string {
 		if rest != "" {
 			userInput += " " + rest
 		}
+		return userInput, nil
+	}
+
+	for _, p := range rt.CurrentAgentPrompts(ctx) {
+		if p.Name != cmd[1:] {
+			continue
+		}
+
+		arguments, err := PromptArguments(p, rest)
+		if err != nil {
+			return "", err
+		}
+		return rt.GetPrompt(ctx, p.Name, arguments)
+	}
+
+	return userInput, nil
+}
+
+// PromptArguments assigns the words of input to the arguments of a prompt, in
+// order, the last argument gets the rest of the input
+func PromptArguments(prompt tools.Prompt, input string) (map[string]string, error) {
+	arguments := map[string]string{}
+	rest := strings.TrimSpace(input)
+	for i, arg := range prompt.Arguments {
+		if rest == "" {
+			if arg.Required {
+				return nil, fmt.Errorf("missing argument %q, usage: %s", arg.Name, PromptUsage(prompt))
+			}
+			continue
+		}
+
+		if i == len(prompt.Arguments)-1 {
+			arguments[arg.Name] = rest
+			break
+		}
+		var value string
+		value, rest, _ = strings.Cut(rest, " ")
+		arguments[arg.Name] = value
+		rest = strings.TrimSpace(rest)
 	}
+	return arguments, nil
+}
 
-	return userInput
+// PromptUsage describes how to use a prompt as a /command
+func PromptUsage(prompt tools.Prompt) string {
+	usage := "/" + prompt.Name
+	for _, arg := range prompt.Arguments {
+		if arg.Required {
+			usage += " <" + arg.Name + ">"
+		} else {
+			usage += " [" + arg.Name + "]"
+		}
+	}
+	return usage
 }
@@ -1,6 +1,8 @@
 package runtime
 
 import (
+	"fmt"
+
 	"github.com/rumpl/rb/pkg/tools"
 )
 
@@ -325,6 +327,45 @@ func (e *MaxIterationsReachedEvent) GetAgentName() string {
 	return e.AgentName
 }
 
+// Budgets of a session
+const (
+	BudgetCost   = "cost"
+	BudgetTokens = "tokens"
+)
+
+// BudgetExceededEvent is sent when a session used its cost or token budget.
+// The runtime waits to be resumed: approving extends the budget, rejecting stops.
+type BudgetExceededEvent struct {
+	Type string `json:"type"`
+	// Budget is either BudgetCost, in dollars, or BudgetTokens
+	Budget string  `json:"budget"`
+	Limit  float64 `json:"limit"`
+	Used   float64 `json:"used"`
+	AgentContext
+}
+
+// FormatBudget formats an amount of a budget, in dollars or in tokens
+func FormatBudget(budget string, amount float64) string {
+	if budget == BudgetCost {
+		return fmt.Sprintf("$%.4f", amount)
+	}
+	return fmt.Sprintf("%d tokens", int(amount))
+}
+
+func BudgetExceeded(budget string, limit, used float64, agentName string) Event {
+	return &BudgetExceededEvent{
+		Type:         "budget_exceeded",
+		Budget:       budget,
+		Limit:        limit,
+		Used:         used,
+		AgentContext: AgentContext{AgentName: agentName},
+	}
+}
+
+func (e *BudgetExceededEvent) GetAgentName() string {
+	return e.AgentName
+}
+
 // MCP initialization lifecycle events
 type MCPInitStartedEvent struct {
 	Type string `json:"type"`
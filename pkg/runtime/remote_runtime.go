@@ -2,6 +2,7 @@ package runtime
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 
@@ -10,6 +11,7 @@ import (
 	latest "github.com/rumpl/rb/pkg/config/v2"
 	"github.com/rumpl/rb/pkg/session"
 	"github.com/rumpl/rb/pkg/team"
+	"github.com/rumpl/rb/pkg/tools"
 )
 
 // RemoteRuntime implements the Interface using a remote client
@@ -224,5 +226,31 @@ func (r *RemoteRuntime) ResumeElicitation(ctx context.Context, action string, co
 	return nil
 }
 
+// errNotSupportedRemotely is returned for the resources and prompts of the MCP
+// servers, they aren't exposed by the remote API
+var errNotSupportedRemotely = errors.New("not supported by the remote runtime")
+
+// CurrentAgentResources returns no resources, the remote API doesn't expose them
+func (r *RemoteRuntime) CurrentAgentResources(context.Context) []tools.Resource {
+	return nil
+}
+
+func (r *RemoteRuntime) ReadResource(context.Context, string) (string, error) {
+	return "", errNotSupportedRemotely
+}
+
+func (r *RemoteRuntime) SubscribeResource(context.Context, string, func(string)) error {
+	return errNotSupportedRemotely
+}
+
+// CurrentAgentPrompts returns no prompts, the remote API doesn't expose them
+func (r *RemoteRuntime) CurrentAgentPrompts(context.Context) []tools.Prompt {
+	return nil
+}
+
+func (r *RemoteRuntime) GetPrompt(context.Context, string, map[string]string) (string, error) {
+	return "", errNotSupportedRemotely
+}
+
 // Verify that RemoteRuntime implements the Interface
 var _ Runtime = (*RemoteRuntime)(nil)
@@ -0,0 +1,49 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/tools"
+	"github.com/rumpl/rb/pkg/tools/builtin"
+)
+
+func (r *LocalRuntime) CurrentAgentResources(ctx context.Context) []tools.Resource {
+	return r.CurrentAgent().Resources(ctx)
+}
+
+func (r *LocalRuntime) ReadResource(ctx context.Context, uri string) (string, error) {
+	return r.CurrentAgent().ReadResource(ctx, uri)
+}
+
+func (r *LocalRuntime) SubscribeResource(ctx context.Context, uri string, handler func(uri string)) error {
+	return r.CurrentAgent().SubscribeResource(ctx, uri, handler)
+}
+
+func (r *LocalRuntime) CurrentAgentPrompts(ctx context.Context) []tools.Prompt {
+	return r.CurrentAgent().Prompts(ctx)
+}
+
+func (r *LocalRuntime) GetPrompt(ctx context.Context, name string, arguments map[string]string) (string, error) {
+	return r.CurrentAgent().GetPrompt(ctx, name, arguments)
+}
+
+// handleReadResource returns the content of a resource of the current agent
+func (r *LocalRuntime) handleReadResource(ctx context.Context, _ *session.Session, toolCall tools.ToolCall, _ chan Event) (*tools.ToolCallResult, error) {
+	var params builtin.ReadResourceArgs
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &params); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	content, err := r.CurrentAgent().ReadResource(ctx, params.URI)
+	if err != nil {
+		return nil, err
+	}
+	if content == "" {
+		content = "The resource is empty."
+	}
+
+	return &tools.ToolCallResult{Output: content}, nil
+}
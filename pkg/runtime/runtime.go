@@ -1,12 +1,14 @@
 package runtime
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -17,10 +19,13 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/rumpl/rb/pkg/agent"
+	"github.com/rumpl/rb/pkg/artifacts"
 	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/contextwindow"
 	"github.com/rumpl/rb/pkg/model/provider"
 	"github.com/rumpl/rb/pkg/model/provider/options"
 	"github.com/rumpl/rb/pkg/modelsdev"
+	"github.com/rumpl/rb/pkg/permissions"
 	"github.com/rumpl/rb/pkg/session"
 	"github.com/rumpl/rb/pkg/team"
 	"github.com/rumpl/rb/pkg/tools"
@@ -42,7 +47,18 @@ type ElicitationResult struct {
 const (
 	ResumeTypeApprove        ResumeType = "approve"
 	ResumeTypeApproveSession ResumeType = "approve-session"
-	ResumeTypeReject         ResumeType = "reject"
+	// ResumeTypeApproveCall approves the calls to the tool with the same
+	// arguments for the rest of the session. Ask rules still apply.
+	ResumeTypeApproveCall ResumeType = "approve-call"
+	// ResumeTypeApproveTool approves every call to the tool, whatever its arguments,
+	// for the rest of the session. Ask rules still apply.
+	ResumeTypeApproveTool ResumeType = "approve-tool"
+	ResumeTypeReject      ResumeType = "reject"
+)
+
+const (
+	defaultRetryBackoff = time.Second
+	maxRetryBackoff     = 30 * time.Second
 )
 
 // ToolHandler is a function type for handling tool calls
@@ -69,6 +85,16 @@ type Runtime interface {
 	Summarize(ctx context.Context, sess *session.Session, events chan Event)
 	// ResumeElicitation sends an elicitation response back to a waiting elicitation request
 	ResumeElicitation(_ context.Context, action string, content map[string]any) error
+	// CurrentAgentResources returns the resources of the toolsets of the active agent
+	CurrentAgentResources(ctx context.Context) []tools.Resource
+	// ReadResource returns the content of a resource of the active agent
+	ReadResource(ctx context.Context, uri string) (string, error)
+	// SubscribeResource calls handler when a resource of the active agent is updated
+	SubscribeResource(ctx context.Context, uri string, handler func(uri string)) error
+	// CurrentAgentPrompts returns the prompts of the toolsets of the active agent
+	CurrentAgentPrompts(ctx context.Context) []tools.Prompt
+	// GetPrompt returns the text of a prompt of the active agent
+	GetPrompt(ctx context.Context, name string, arguments map[string]string) (string, error)
 }
 
 // LocalRuntime manages the execution of agents
@@ -86,6 +112,8 @@ type LocalRuntime struct {
 	elicitationEventsChannel    chan Event             // Current events channel for sending elicitation requests
 	elicitationEventsChannelMux sync.RWMutex           // Protects elicitationEventsChannel
 	titleGenerationWg           sync.WaitGroup         // Wait group for title generation
+	retryBackoff                time.Duration          // Delay before the first retry of a transient model error
+	toolOutputs                 *artifacts.Store       // Where large tool outputs are saved, nil to keep them in the session
 }
 
 type streamResult struct {
@@ -94,6 +122,7 @@ type streamResult struct {
 	ReasoningContent  string
 	ThinkingSignature string // Used with Anthropic's extended thinking feature
 	Stopped           bool
+	InputTokens       int // Input tokens reported by the model, 0 if unknown
 }
 
 type Opt func(*LocalRuntime)
@@ -129,6 +158,22 @@ func WithSessionCompaction(sessionCompaction bool) Opt {
 	}
 }
 
+// WithRetryBackoff sets the delay before the first retry of a transient model error.
+// The delay doubles after each retry.
+func WithRetryBackoff(backoff time.Duration) Opt {
+	return func(r *LocalRuntime) {
+		r.retryBackoff = backoff
+	}
+}
+
+// WithToolOutputStore sets where large tool outputs are saved. When nil,
+// tool outputs are always kept in full in the session.
+func WithToolOutputStore(store *artifacts.Store) Opt {
+	return func(r *LocalRuntime) {
+		r.toolOutputs = store
+	}
+}
+
 func WithModelStore(store modelStore) Opt {
 	return func(r *LocalRuntime) {
 		r.modelsStore = store
@@ -151,6 +196,7 @@ func New(agents *team.Team, opts ...Opt) (*LocalRuntime, error) {
 		modelsStore:          modelsStore,
 		sessionCompaction:    true,
 		managedOAuth:         true,
+		retryBackoff:         defaultRetryBackoff,
 	}
 
 	for _, opt := range opts {
@@ -191,6 +237,8 @@ func (r *LocalRuntime) registerDefaultTools() {
 	slog.Debug("Registering default tools")
 	r.toolMap[builtin.ToolNameTransferTask] = r.handleTaskTransfer
 	r.toolMap[builtin.ToolNameHandoff] = r.handleHandoff
+	r.toolMap[builtin.ToolNameReadToolOutput] = r.handleReadToolOutput
+	r.toolMap[builtin.ToolNameReadResource] = r.handleReadResource
 	slog.Debug("Registered default tools", "count", len(r.toolMap))
 }
 
@@ -241,6 +289,11 @@ func (r *LocalRuntime) RunStream(ctx context.Context, sess *session.Session) <-c
 
 		r.registerDefaultTools()
 
+		// One context window manager per agent, each calibrated on its own requests
+		windows := make(map[string]*contextwindow.Manager)
+		// The resources of each agent are listed once per run
+		resources := make(map[string][]tools.Resource)
+
 		iteration := 0
 		// Use a runtime copy of maxIterations so we don't modify the session's persistent config
 		runtimeMaxIterations := sess.MaxIterations
@@ -263,6 +316,21 @@ func (r *LocalRuntime) RunStream(ctx context.Context, sess *session.Session) <-c
 				events <- Error(fmt.Sprintf("failed to get tools: %v", err))
 				return
 			}
+			if hasSpilledToolOutputs(sess) {
+				// Let the agent page through the tool outputs that were too large for the session
+				readToolOutput, _ := builtin.NewReadToolOutputTool().Tools(ctx)
+				agentTools = append(agentTools, readToolOutput...)
+			}
+			agentResources, ok := resources[a.Name()]
+			if !ok {
+				agentResources = a.Resources(ctx)
+				resources[a.Name()] = agentResources
+			}
+			if len(agentResources) > 0 {
+				// Let the agent read the resources of its MCP servers
+				readResource, _ := builtin.NewReadResourceTool(agentResources).Tools(ctx)
+				agentTools = append(agentTools, readResource...)
+			}
 
 			// Check iteration limit
 			if runtimeMaxIterations > 0 && iteration >= runtimeMaxIterations {
@@ -292,6 +360,39 @@ func (r *LocalRuntime) RunStream(ctx context.Context, sess *session.Session) <-c
 					return
 				}
 			}
+
+			// Check budgets
+			if budget, limit, used, exceeded := budgetExceeded(sess); exceeded {
+				slog.Debug("Session budget exceeded", "agent", a.Name(), "budget", budget, "used", used, "limit", limit)
+				events <- BudgetExceeded(budget, limit, used, a.Name())
+
+				// Wait for user decision
+				select {
+				case resumeType := <-r.resumeChan:
+					if resumeType == ResumeTypeApprove {
+						slog.Debug("User chose to extend the budget", "agent", a.Name(), "budget", budget)
+						// Give the session another budget, the extension is saved with the session
+						// so that the next message doesn't exceed it right away
+						if budget == BudgetCost {
+							sess.MaxCost = sess.Cost + cmp.Or(a.MaxCost(), sess.MaxCost)
+						} else {
+							sess.MaxTokensTotal = sess.TotalTokens + cmp.Or(a.MaxTokensTotal(), sess.MaxTokensTotal)
+						}
+					} else {
+						slog.Debug("User chose to stop after the budget was exceeded", "agent", a.Name(), "budget", budget)
+						assistantMessage := chat.Message{
+							Role:      chat.MessageRoleAssistant,
+							Content:   fmt.Sprintf("I have used the %s budget of the session (%s). Stopping as requested by user.", budget, FormatBudget(budget, limit)),
+							CreatedAt: time.Now().Format(time.RFC3339),
+						}
+						sess.AddMessage(session.NewAgentMessage(a, &assistantMessage))
+						return
+					}
+				case <-ctx.Done():
+					slog.Debug("Context cancelled while waiting for budget decision", "agent", a.Name())
+					return
+				}
+			}
 			iteration++
 			// Exit immediately if the stream context has been cancelled (e.g., Ctrl+C)
 			if err := ctx.Err(); err != nil {
@@ -303,33 +404,27 @@ func (r *LocalRuntime) RunStream(ctx context.Context, sess *session.Session) <-c
 			messages = sess.GetMessages(ctx, a)
 			slog.Debug("Retrieved messages for processing", "agent", a.Name(), "message_count", len(messages))
 
+			// Make sure the request fits in the model's context window
+			window, ok := windows[a.Name()]
+			if !ok {
+				window = contextwindow.New(a.ContextWindow())
+				windows[a.Name()] = window
+			}
+			contextLimit := window.Limit(r.modelContextLimit(ctx, a))
+			var needsSummary bool
+			messages, needsSummary = window.Fit(messages, agentTools, contextLimit)
+			if needsSummary && r.sessionCompaction {
+				// The request doesn't fit even before the model answered, e.g. a resumed session
+				r.compact(ctx, sess, contextLimit, events)
+				messages, _ = window.Fit(sess.GetMessages(ctx, a), agentTools, contextLimit)
+			}
+
 			streamCtx, streamSpan := r.startSpan(ctx, "runtime.stream", trace.WithAttributes(
 				attribute.String("agent", a.Name()),
 				attribute.String("session.id", sess.ID),
 			))
 
-			model := a.Model()
-			modelID := model.ID()
-			slog.Debug("Using agent", "agent", a.Name(), "model", modelID)
-			slog.Debug("Getting model definition", "model_id", modelID)
-			m, err := r.modelsStore.GetModel(ctx, modelID)
-			if err != nil {
-				slog.Debug("Failed to get model definition", "error", err)
-			}
-
-			slog.Debug("Creating chat completion stream", "agent", a.Name())
-			stream, err := model.CreateChatCompletionStream(streamCtx, messages, agentTools)
-			if err != nil {
-				streamSpan.RecordError(err)
-				streamSpan.SetStatus(codes.Error, "creating chat completion")
-				slog.Error("Failed to create chat completion stream", "agent", a.Name(), "error", err)
-				events <- Error(fmt.Sprintf("creating chat completion: %v", err))
-				streamSpan.End()
-				return
-			}
-
-			slog.Debug("Processing stream", "agent", a.Name())
-			res, err := r.handleStream(stream, a, agentTools, sess, m, events)
+			res, modelID, _, err := r.runModel(streamCtx, a, sess, messages, agentTools, events)
 			if err != nil {
 				// Treat context cancellation as a graceful stop
 				if errors.Is(err, context.Canceled) {
@@ -350,6 +445,7 @@ func (r *LocalRuntime) RunStream(ctx context.Context, sess *session.Session) <-c
 				attribute.Bool("stopped", res.Stopped),
 			)
 			streamSpan.End()
+			window.Calibrate(messages, agentTools, res.InputTokens)
 			slog.Debug("Stream processed", "agent", a.Name(), "tool_calls", len(res.Calls), "content_length", len(res.Content), "stopped", res.Stopped)
 
 			// Add assistant message to conversation history, but skip empty assistant messages
@@ -364,42 +460,28 @@ func (r *LocalRuntime) RunStream(ctx context.Context, sess *session.Session) <-c
 					CreatedAt:         time.Now().Format(time.RFC3339),
 				}
 
-				sess.AddMessage(session.NewAgentMessage(a, &assistantMessage))
+				agentMessage := session.NewAgentMessage(a, &assistantMessage)
+				agentMessage.Model = modelID
+				sess.AddMessage(agentMessage)
 				slog.Debug("Added assistant message to session", "agent", a.Name(), "total_messages", len(sess.GetAllMessages()))
 			} else {
 				slog.Debug("Skipping empty assistant message (no content and no tool calls)", "agent", a.Name())
 			}
 
-			contextLimit := 0
-			if m != nil {
-				contextLimit = m.Limit.Context
-			}
 			events <- TokenUsage(sess.InputTokens, sess.OutputTokens, sess.InputTokens+sess.OutputTokens, contextLimit, sess.Cost)
 
-			if m != nil && r.sessionCompaction {
-				if sess.InputTokens+sess.OutputTokens > int(float64(contextLimit)*0.9) {
-					// Avoid inserting a summary between assistant tool_use and tool_result messages.
-					// Defer compaction until after tool calls are processed in this iteration.
-					if len(res.Calls) == 0 {
-						events <- SessionCompaction(sess.ID, "start", r.currentAgent)
-						r.Summarize(ctx, sess, events)
-						events <- TokenUsage(sess.InputTokens, sess.OutputTokens, sess.InputTokens+sess.OutputTokens, contextLimit, sess.Cost)
-						events <- SessionCompaction(sess.ID, "completed", r.currentAgent)
-					}
-				}
+			// Avoid inserting a summary between assistant tool_use and tool_result messages.
+			// Defer compaction until after tool calls are processed in this iteration.
+			if len(res.Calls) == 0 {
+				r.compactIfNeeded(ctx, sess, a, window, agentTools, contextLimit, events)
 			}
 
 			r.processToolCalls(ctx, sess, res.Calls, agentTools, events)
 
 			// If tool_use occurred, perform compaction after tool results are appended
 			// to avoid splitting assistant tool_use and user tool_result adjacency.
-			if m != nil && r.sessionCompaction && len(res.Calls) > 0 {
-				if sess.InputTokens+sess.OutputTokens > int(float64(contextLimit)*0.9) {
-					events <- SessionCompaction(sess.ID, "start", r.currentAgent)
-					r.Summarize(ctx, sess, events)
-					events <- TokenUsage(sess.InputTokens, sess.OutputTokens, sess.InputTokens+sess.OutputTokens, contextLimit, sess.Cost)
-					events <- SessionCompaction(sess.ID, "completed", r.currentAgent)
-				}
+			if len(res.Calls) > 0 {
+				r.compactIfNeeded(ctx, sess, a, window, agentTools, contextLimit, events)
 			}
 
 			if res.Stopped {
@@ -469,6 +551,10 @@ func (r *LocalRuntime) Resume(_ context.Context, confirmationType ResumeType) {
 	switch confirmationType {
 	case ResumeTypeApprove:
 		cType = ResumeTypeApprove
+	case ResumeTypeApproveCall:
+		cType = ResumeTypeApproveCall
+	case ResumeTypeApproveTool:
+		cType = ResumeTypeApproveTool
 	case ResumeTypeReject:
 		cType = ResumeTypeReject
 	}
@@ -516,6 +602,106 @@ func (r *LocalRuntime) Run(ctx context.Context, sess *session.Session) ([]sessio
 	return sess.GetAllMessages(), nil
 }
 
+// modelContextLimit returns the size of the context window of the agent's model
+// according to models.dev, or 0 if the model is unknown.
+func (r *LocalRuntime) modelContextLimit(ctx context.Context, a *agent.Agent) int {
+	m, err := r.modelsStore.GetModel(ctx, a.Model().ID())
+	if err != nil || m == nil {
+		return 0
+	}
+	return m.Limit.Context
+}
+
+// compactIfNeeded summarizes the session when the next request wouldn't fit in
+// the context window, even after dropping and truncating tool outputs.
+func (r *LocalRuntime) compactIfNeeded(ctx context.Context, sess *session.Session, a *agent.Agent, window *contextwindow.Manager, agentTools []tools.Tool, contextLimit int, events chan Event) {
+	if !r.sessionCompaction {
+		return
+	}
+	if _, needsSummary := window.Fit(sess.GetMessages(ctx, a), agentTools, contextLimit); !needsSummary {
+		return
+	}
+
+	r.compact(ctx, sess, contextLimit, events)
+}
+
+// compact summarizes the session because the next request doesn't fit in the context window
+func (r *LocalRuntime) compact(ctx context.Context, sess *session.Session, contextLimit int, events chan Event) {
+	slog.Debug("Next request doesn't fit in the context window, compacting session", "agent", r.currentAgent, "session_id", sess.ID, "limit", contextLimit)
+	events <- SessionCompaction(sess.ID, "start", r.currentAgent)
+
+	// The kept turns alone may not fit, keep fewer of them rather than having nothing to summarize
+	keepTurns := r.CurrentAgent().Compaction().KeepTurns
+	for keepTurns > 0 {
+		if start, end := compactionRange(sess.Messages, keepTurns); start < end {
+			break
+		}
+		keepTurns--
+	}
+	r.summarize(ctx, sess, keepTurns, events)
+	events <- TokenUsage(sess.InputTokens, sess.OutputTokens, sess.InputTokens+sess.OutputTokens, contextLimit, sess.Cost)
+	events <- SessionCompaction(sess.ID, "completed", r.currentAgent)
+}
+
+// runModel streams a completion from the agent's model. Transient errors are retried
+// with exponential backoff, then the agent's fallback models are tried in order.
+// It returns the ID and the definition of the model that produced the result.
+func (r *LocalRuntime) runModel(ctx context.Context, a *agent.Agent, sess *session.Session, messages []chat.Message, agentTools []tools.Tool, events chan Event) (streamResult, string, *modelsdev.Model, error) {
+	models := append([]provider.Provider{a.Model()}, a.FallbackModels()...)
+
+	var lastErr error
+	for i, model := range models {
+		modelID := model.ID()
+		if i > 0 {
+			slog.Warn("Switching to fallback model", "agent", a.Name(), "from", models[i-1].ID(), "to", modelID, "error", lastErr)
+			events <- Warning(fmt.Sprintf("Model %s failed (%v), switching to fallback model %s", models[i-1].ID(), lastErr, modelID), a.Name())
+		}
+
+		slog.Debug("Getting model definition", "model_id", modelID)
+		m, err := r.modelsStore.GetModel(ctx, modelID)
+		if err != nil {
+			slog.Debug("Failed to get model definition", "error", err)
+		}
+
+		backoff := r.retryBackoff
+		for attempt := 0; ; attempt++ {
+			slog.Debug("Creating chat completion stream", "agent", a.Name(), "model", modelID, "attempt", attempt)
+			var res streamResult
+			stream, err := model.CreateChatCompletionStream(ctx, messages, agentTools)
+			if err != nil {
+				err = fmt.Errorf("creating chat completion: %w", err)
+			} else {
+				slog.Debug("Processing stream", "agent", a.Name())
+				res, err = r.handleStream(stream, a, agentTools, sess, m, events)
+			}
+			if err == nil {
+				return res, modelID, m, nil
+			}
+
+			// Once output has been streamed to the user, neither retrying nor
+			// falling back would give a consistent conversation.
+			if errors.Is(err, context.Canceled) || res.Content != "" || res.ReasoningContent != "" || len(res.Calls) > 0 {
+				return res, modelID, m, err
+			}
+
+			lastErr = err
+			if attempt >= a.MaxRetries() || !provider.IsRetryable(err) {
+				break
+			}
+
+			slog.Warn("Transient model error, retrying", "agent", a.Name(), "model", modelID, "attempt", attempt+1, "backoff", backoff, "error", err)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return streamResult{}, modelID, m, ctx.Err()
+			}
+			backoff = min(backoff*2, maxRetryBackoff)
+		}
+	}
+
+	return streamResult{}, "", nil, lastErr
+}
+
 func (r *LocalRuntime) handleStream(stream chat.MessageStream, a *agent.Agent, agentTools []tools.Tool, sess *session.Session, m *modelsdev.Model, events chan Event) (streamResult, error) {
 	defer stream.Close()
 
@@ -523,6 +709,7 @@ func (r *LocalRuntime) handleStream(stream chat.MessageStream, a *agent.Agent, a
 	var fullReasoningContent strings.Builder
 	var thinkingSignature string
 	var toolCalls []tools.ToolCall
+	var inputTokens int
 	// Track which tool call indices we've already emitted partial events for
 	emittedPartialEvents := make(map[string]bool)
 
@@ -532,7 +719,13 @@ func (r *LocalRuntime) handleStream(stream chat.MessageStream, a *agent.Agent, a
 			break
 		}
 		if err != nil {
-			return streamResult{Stopped: true}, fmt.Errorf("error receiving from stream: %w", err)
+			// Return what was received so far so that callers know whether it's safe to retry
+			return streamResult{
+				Calls:            toolCalls,
+				Content:          fullContent.String(),
+				ReasoningContent: fullReasoningContent.String(),
+				Stopped:          true,
+			}, fmt.Errorf("error receiving from stream: %w", err)
 		}
 
 		if response.Usage != nil {
@@ -543,7 +736,9 @@ func (r *LocalRuntime) handleStream(stream chat.MessageStream, a *agent.Agent, a
 					float64(response.Usage.CachedOutputTokens)*m.Cost.CacheWrite) / 1e6
 			}
 
+			sess.TotalTokens += response.Usage.InputTokens + response.Usage.CachedInputTokens + response.Usage.OutputTokens + response.Usage.CachedOutputTokens + response.Usage.ReasoningTokens
 			sess.InputTokens = response.Usage.InputTokens + response.Usage.CachedInputTokens
+			inputTokens = sess.InputTokens
 			sess.OutputTokens = response.Usage.OutputTokens + response.Usage.CachedOutputTokens + response.Usage.ReasoningTokens
 		}
 
@@ -558,6 +753,7 @@ func (r *LocalRuntime) handleStream(stream chat.MessageStream, a *agent.Agent, a
 				ReasoningContent:  fullReasoningContent.String(),
 				ThinkingSignature: thinkingSignature,
 				Stopped:           true,
+				InputTokens:       inputTokens,
 			}, nil
 		}
 
@@ -647,6 +843,7 @@ func (r *LocalRuntime) handleStream(stream chat.MessageStream, a *agent.Agent, a
 		ReasoningContent:  fullReasoningContent.String(),
 		ThinkingSignature: thinkingSignature,
 		Stopped:           stoppedDueToNoOutput,
+		InputTokens:       inputTokens,
 	}, nil
 }
 
@@ -655,7 +852,14 @@ func (r *LocalRuntime) processToolCalls(ctx context.Context, sess *session.Sessi
 	a := r.CurrentAgent()
 	slog.Debug("Processing tool calls", "agent", a.Name(), "call_count", len(calls))
 
-	for i, toolCall := range calls {
+	for i := 0; i < len(calls); i++ {
+		if batch := r.parallelToolCalls(a, sess, calls[i:], agentTools); len(batch) > 1 {
+			r.runToolsInParallel(ctx, sess, batch, events, a)
+			i += len(batch) - 1
+			continue
+		}
+
+		toolCall := calls[i]
 		// Start a span for each tool call
 		callCtx, callSpan := r.startSpan(ctx, "runtime.tool.call", trace.WithAttributes(
 			attribute.String("tool.name", toolCall.Function.Name),
@@ -668,16 +872,19 @@ func (r *LocalRuntime) processToolCalls(ctx context.Context, sess *session.Sessi
 		slog.Debug("Processing tool call", "agent", a.Name(), "tool", toolCall.Function.Name, "session_id", sess.ID)
 		handler, exists := r.toolMap[toolCall.Function.Name]
 		if exists {
-			tool := tools.Tool{
-				Annotations: tools.ToolAnnotations{
-					// TODO: We need to handle the transfer task tool better
-					Title: "Transfer Task",
-				},
+			// Use the definition the agent was given, for its title
+			tool := tools.Tool{Name: toolCall.Function.Name}
+			if idx := slices.IndexFunc(agentTools, func(t tools.Tool) bool { return t.Name == toolCall.Function.Name }); idx != -1 {
+				tool = agentTools[idx]
 			}
 			slog.Debug("Using runtime tool handler", "tool", toolCall.Function.Name, "session_id", sess.ID)
-			if sess.ToolsApproved || toolCall.Function.Name == builtin.ToolNameTransferTask {
+			safe := toolCall.Function.Name == builtin.ToolNameTransferTask || toolCall.Function.Name == builtin.ToolNameReadToolOutput || toolCall.Function.Name == builtin.ToolNameReadResource
+			switch r.toolPermission(a, sess, toolCall, safe) {
+			case permissions.DecisionDeny:
+				r.addToolDeniedResponse(sess, toolCall, tool, events)
+			case permissions.DecisionAllow:
 				r.runAgentTool(callCtx, handler, sess, toolCall, tool, events, a)
-			} else {
+			default:
 				slog.Debug("Tools not approved, waiting for resume", "tool", toolCall.Function.Name, "session_id", sess.ID)
 
 				events <- ToolCallConfirmation(toolCall, tool, a.Name())
@@ -692,6 +899,14 @@ func (r *LocalRuntime) processToolCalls(ctx context.Context, sess *session.Sessi
 						slog.Debug("Resume signal received, approving session", "tool", toolCall.Function.Name, "session_id", sess.ID)
 						sess.ToolsApproved = true
 						r.runAgentTool(callCtx, handler, sess, toolCall, tool, events, a)
+					case ResumeTypeApproveCall:
+						slog.Debug("Resume signal received, approving tool call for session", "tool", toolCall.Function.Name, "session_id", sess.ID)
+						approveCall(sess, toolCall)
+						r.runAgentTool(callCtx, handler, sess, toolCall, tool, events, a)
+					case ResumeTypeApproveTool:
+						slog.Debug("Resume signal received, approving tool for session", "tool", toolCall.Function.Name, "session_id", sess.ID)
+						sess.ApproveRule(permissions.Rule{Tool: toolCall.Function.Name})
+						r.runAgentTool(callCtx, handler, sess, toolCall, tool, events, a)
 					case ResumeTypeReject:
 						slog.Debug("Resume signal received, rejecting tool handler", "tool", toolCall.Function.Name, "session_id", sess.ID)
 						r.addToolRejectedResponse(sess, toolCall, tool, events)
@@ -719,10 +934,14 @@ func (r *LocalRuntime) processToolCalls(ctx context.Context, sess *session.Sessi
 			}
 			slog.Debug("Using agent tool handler", "tool", toolCall.Function.Name)
 
-			if sess.ToolsApproved || tool.Annotations.ReadOnlyHint {
+			switch r.toolPermission(a, sess, toolCall, tool.Annotations.ReadOnlyHint) {
+			case permissions.DecisionDeny:
+				r.addToolDeniedResponse(sess, toolCall, tool, events)
+				break toolLoop
+			case permissions.DecisionAllow:
 				slog.Debug("Tools approved, running tool", "tool", toolCall.Function.Name, "session_id", sess.ID)
 				r.runTool(callCtx, tool, toolCall, events, sess, a)
-			} else {
+			default:
 				slog.Debug("Tools not approved, waiting for resume", "tool", toolCall.Function.Name, "session_id", sess.ID)
 				events <- ToolCallConfirmation(toolCall, tool, a.Name())
 				select {
@@ -735,6 +954,14 @@ func (r *LocalRuntime) processToolCalls(ctx context.Context, sess *session.Sessi
 						slog.Debug("Resume signal received, approving session", "tool", toolCall.Function.Name, "session_id", sess.ID)
 						sess.ToolsApproved = true
 						r.runTool(callCtx, tool, toolCall, events, sess, a)
+					case ResumeTypeApproveCall:
+						slog.Debug("Resume signal received, approving tool call for session", "tool", toolCall.Function.Name, "session_id", sess.ID)
+						approveCall(sess, toolCall)
+						r.runTool(callCtx, tool, toolCall, events, sess, a)
+					case ResumeTypeApproveTool:
+						slog.Debug("Resume signal received, approving tool for session", "tool", toolCall.Function.Name, "session_id", sess.ID)
+						sess.ApproveRule(permissions.Rule{Tool: toolCall.Function.Name})
+						r.runTool(callCtx, tool, toolCall, events, sess, a)
 					case ResumeTypeReject:
 						slog.Debug("Resume signal received, rejecting tool handler", "tool", toolCall.Function.Name, "session_id", sess.ID)
 						r.addToolRejectedResponse(sess, toolCall, tool, events)
@@ -760,10 +987,119 @@ func (r *LocalRuntime) processToolCalls(ctx context.Context, sess *session.Sessi
 	}
 }
 
+// toolPermission decides whether a tool call can run, must be confirmed by the user or is denied.
+// Deny and ask rules from the agent configuration always apply, even when the session's tools are
+// approved. Otherwise, rules approved during the session and allow rules are checked in that order.
+// When no rule matches, the call runs if the session's tools are approved or if the tool is safe to
+// run without confirmation.
+func (r *LocalRuntime) toolPermission(a *agent.Agent, sess *session.Session, toolCall tools.ToolCall, safe bool) permissions.Decision {
+	decision, rule := a.Permissions().Check(toolCall)
+	switch decision {
+	case permissions.DecisionDeny:
+		slog.Debug("Tool call denied by permission rule", "tool", toolCall.Function.Name, "rule", rule.String(), "session_id", sess.ID)
+		return permissions.DecisionDeny
+	case permissions.DecisionAsk:
+		return permissions.DecisionAsk
+	}
+
+	if decision == permissions.DecisionAllow || permissions.AnyMatch(sess.ApprovedRules, toolCall) {
+		return permissions.DecisionAllow
+	}
+
+	if sess.ToolsApproved || safe {
+		return permissions.DecisionAllow
+	}
+	return permissions.DecisionAsk
+}
+
+// approveCall approves the calls with the same arguments as toolCall for the
+// rest of the session. When the arguments can't be matched exactly, only this
+// call is approved.
+func approveCall(sess *session.Session, toolCall tools.ToolCall) {
+	rule, ok := permissions.CallRule(toolCall)
+	if !ok {
+		slog.Debug("The arguments of the tool call can't be matched exactly, approving it once", "tool", toolCall.Function.Name, "session_id", sess.ID)
+		return
+	}
+	sess.ApproveRule(rule)
+}
+
+// parallelToolCall is a tool call that can run concurrently with other tool calls
+type parallelToolCall struct {
+	toolCall tools.ToolCall
+	tool     tools.Tool
+}
+
+// parallelToolCalls returns the leading tool calls that are parallel-safe and
+// allowed to run without asking the user. Runtime tools, like transfer_task,
+// change the current agent and always run on their own.
+func (r *LocalRuntime) parallelToolCalls(a *agent.Agent, sess *session.Session, calls []tools.ToolCall, agentTools []tools.Tool) []parallelToolCall {
+	if a.MaxParallelToolCalls() <= 1 {
+		return nil
+	}
+
+	var batch []parallelToolCall
+	for _, toolCall := range calls {
+		if _, ok := r.toolMap[toolCall.Function.Name]; ok {
+			break
+		}
+
+		idx := slices.IndexFunc(agentTools, func(t tools.Tool) bool { return t.Name == toolCall.Function.Name })
+		if idx == -1 {
+			break
+		}
+
+		tool := agentTools[idx]
+		if !tool.IsParallelSafe() || r.toolPermission(a, sess, toolCall, tool.Annotations.ReadOnlyHint) != permissions.DecisionAllow {
+			break
+		}
+
+		batch = append(batch, parallelToolCall{toolCall: toolCall, tool: tool})
+	}
+
+	return batch
+}
+
+// runToolsInParallel runs tool calls concurrently, at most MaxParallelToolCalls at a time.
+// Tool responses are added to the session in the order of the calls.
+func (r *LocalRuntime) runToolsInParallel(ctx context.Context, sess *session.Session, batch []parallelToolCall, events chan Event, a *agent.Agent) {
+	slog.Debug("Running tool calls in parallel", "agent", a.Name(), "call_count", len(batch), "max_parallel", a.MaxParallelToolCalls())
+
+	for _, call := range batch {
+		events <- ToolCall(call.toolCall, call.tool, a.Name())
+	}
+
+	outputs := make([]string, len(batch))
+	sem := make(chan struct{}, a.MaxParallelToolCalls())
+
+	var wg sync.WaitGroup
+	for i, call := range batch {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			outputs[i] = r.executeTool(ctx, call.tool, call.toolCall, events, sess, a)
+		})
+	}
+	wg.Wait()
+
+	for i, call := range batch {
+		r.addToolResponseMessage(sess, call.toolCall, outputs[i], a)
+	}
+}
+
 // runTool executes agent tools from toolsets (MCP, filesystem, etc.).
 // Tool execution may require OAuth authorization, so the handler call is wrapped
 // with ExecuteWithOAuth to automatically handle authorization flows and retries.
 func (r *LocalRuntime) runTool(ctx context.Context, tool tools.Tool, toolCall tools.ToolCall, events chan Event, sess *session.Session, a *agent.Agent) {
+	events <- ToolCall(toolCall, tool, a.Name())
+
+	output := r.executeTool(ctx, tool, toolCall, events, sess, a)
+	r.addToolResponseMessage(sess, toolCall, output, a)
+}
+
+// executeTool calls the tool's handler, emits the tool response event and returns the tool output.
+func (r *LocalRuntime) executeTool(ctx context.Context, tool tools.Tool, toolCall tools.ToolCall, events chan Event, sess *session.Session, a *agent.Agent) string {
 	// Start a child span for the actual tool handler execution
 	ctx, span := r.startSpan(ctx, "runtime.tool.handler", trace.WithAttributes(
 		attribute.String("tool.name", toolCall.Function.Name),
@@ -773,12 +1109,11 @@ func (r *LocalRuntime) runTool(ctx context.Context, tool tools.Tool, toolCall to
 	))
 	defer span.End()
 
-	events <- ToolCall(toolCall, tool, a.Name())
-
 	var res *tools.ToolCallResult
 	var err error
 
-	res, err = tool.Handler(ctx, toolCall)
+	handlerCtx := tools.WithToolCallID(tools.WithAgentName(tools.WithSessionID(ctx, sess.ID), a.Name()), toolCall.ID)
+	res, err = tool.Handler(handlerCtx, toolCall)
 
 	if err != nil {
 		if errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled) {
@@ -801,8 +1136,13 @@ func (r *LocalRuntime) runTool(ctx context.Context, tool tools.Tool, toolCall to
 
 	events <- ToolCallResponse(toolCall, tool, res.Output, a.Name())
 
+	return r.spillToolOutput(sess, tool, res.Output)
+}
+
+// addToolResponseMessage adds the output of a tool call to the session
+func (r *LocalRuntime) addToolResponseMessage(sess *session.Session, toolCall tools.ToolCall, output string, a *agent.Agent) {
 	// Ensure tool response content is not empty for API compatibility
-	content := res.Output
+	content := output
 	if strings.TrimSpace(content) == "" {
 		content = "(no output)"
 	}
@@ -850,25 +1190,30 @@ func (r *LocalRuntime) runAgentTool(ctx context.Context, handler ToolHandler, se
 
 	events <- ToolCallResponse(toolCall, tool, output, a.Name())
 
-	// Ensure tool response content is not empty for API compatibility
-	content := output
-	if strings.TrimSpace(content) == "" {
-		content = "(no output)"
-	}
+	r.addToolResponseMessage(sess, toolCall, output, a)
+}
+
+func (r *LocalRuntime) addToolRejectedResponse(sess *session.Session, toolCall tools.ToolCall, tool tools.Tool, events chan Event) {
+	a := r.CurrentAgent()
+
+	result := "The user rejected the tool call."
+
+	events <- ToolCallResponse(toolCall, tool, result, a.Name())
 
 	toolResponseMsg := chat.Message{
 		Role:       chat.MessageRoleTool,
-		Content:    content,
+		Content:    result,
 		ToolCallID: toolCall.ID,
 		CreatedAt:  time.Now().Format(time.RFC3339),
 	}
 	sess.AddMessage(session.NewAgentMessage(a, &toolResponseMsg))
 }
 
-func (r *LocalRuntime) addToolRejectedResponse(sess *session.Session, toolCall tools.ToolCall, tool tools.Tool, events chan Event) {
+func (r *LocalRuntime) addToolDeniedResponse(sess *session.Session, toolCall tools.ToolCall, tool tools.Tool, events chan Event) {
 	a := r.CurrentAgent()
 
-	result := "The user rejected the tool call."
+	_, rule := a.Permissions().Check(toolCall)
+	result := fmt.Sprintf("The tool call was denied by the permission rule `%s`. Do not retry this call, try a different approach or ask the user for help.", rule)
 
 	events <- ToolCallResponse(toolCall, tool, result, a.Name())
 
@@ -964,14 +1309,26 @@ func (r *LocalRuntime) handleTaskTransfer(ctx context.Context, sess *session.Ses
 		return nil, err
 	}
 
+	maxCost, costExhausted := subSessionBudget(child.MaxCost(), sess.MaxCost, sess.Cost)
+	maxTokensTotal, tokensExhausted := subSessionBudget(child.MaxTokensTotal(), sess.MaxTokensTotal, sess.TotalTokens)
+	if costExhausted || tokensExhausted {
+		// The session asks the user to extend its budget before its next iteration
+		return &tools.ToolCallResult{Output: "The budget of the session is used up, the task wasn't transferred."}, nil
+	}
+
 	s := session.New(
 		session.WithSystemMessage(memberAgentTask),
 		session.WithImplicitUserMessage("", "Follow the default instructions"),
 		session.WithMaxIterations(child.MaxIterations()),
+		session.WithMaxCost(maxCost),
+		session.WithMaxTokensTotal(maxTokensTotal),
 		session.WithTitle("Transferred task"),
 		session.WithToolsApproved(sess.ToolsApproved),
+		session.WithApprovedRules(slices.Clone(sess.ApprovedRules)),
 		session.WithSendUserMessage(false),
 	)
+	// The sub-session is saved with the session, so are its tool outputs
+	s.ToolOutputsID = sess.ToolOutputsKey()
 
 	for event := range r.RunStream(ctx, s) {
 		evts <- event
@@ -983,7 +1340,9 @@ func (r *LocalRuntime) handleTaskTransfer(ctx context.Context, sess *session.Ses
 	}
 
 	sess.ToolsApproved = s.ToolsApproved
+	sess.ApprovedRules = s.ApprovedRules
 	sess.Cost += s.Cost
+	sess.TotalTokens += s.TotalTokens
 
 	sess.AddSubSession(s)
 
@@ -995,6 +1354,35 @@ func (r *LocalRuntime) handleTaskTransfer(ctx context.Context, sess *session.Ses
 	}, nil
 }
 
+// budgetExceeded returns which budget of the session was used, its limit and how much was used
+func budgetExceeded(sess *session.Session) (budget string, limit, used float64, exceeded bool) {
+	if sess.MaxCost > 0 && sess.Cost >= sess.MaxCost {
+		return BudgetCost, sess.MaxCost, sess.Cost, true
+	}
+	if sess.MaxTokensTotal > 0 && sess.TotalTokens >= sess.MaxTokensTotal {
+		return BudgetTokens, float64(sess.MaxTokensTotal), float64(sess.TotalTokens), true
+	}
+	return "", 0, 0, false
+}
+
+// subSessionBudget returns the budget of a sub-session: the budget of its
+// agent, capped by what's left of the budget of the parent session. The
+// parent's budget is only checked between its own iterations, a sub-session
+// without a budget could otherwise spend without limit.
+func subSessionBudget[T int | float64](agentBudget, parentBudget, parentUsed T) (budget T, exhausted bool) {
+	if parentBudget <= 0 {
+		return agentBudget, false
+	}
+	remaining := parentBudget - parentUsed
+	if remaining <= 0 {
+		return 0, true
+	}
+	if agentBudget <= 0 {
+		return remaining, false
+	}
+	return min(agentBudget, remaining), false
+}
+
 // generateSessionTitle generates a title for the session based on the conversation history
 func (r *LocalRuntime) generateSessionTitle(ctx context.Context, sess *session.Session, events chan Event) {
 	slog.Debug("Generating title for session", "session_id", sess.ID)
@@ -1051,8 +1439,19 @@ func (r *LocalRuntime) generateSessionTitle(ctx context.Context, sess *session.S
 	events <- SessionTitle(sess.ID, title, r.currentAgent)
 }
 
-// Summarize generates a summary for the session based on the conversation history
+// summaryToolOutputSize is the number of characters of each tool result kept in
+// the conversation history of a structured summary
+const summaryToolOutputSize = 2000
+
+// Summarize generates a summary for the session based on the conversation history.
+// The summary replaces the history that precedes it, except for the turns the
+// agent's compaction config keeps verbatim.
 func (r *LocalRuntime) Summarize(ctx context.Context, sess *session.Session, events chan Event) {
+	r.summarize(ctx, sess, r.CurrentAgent().Compaction().KeepTurns, events)
+}
+
+// summarize summarizes the session, keeping the last keepTurns turns verbatim
+func (r *LocalRuntime) summarize(ctx context.Context, sess *session.Session, keepTurns int, events chan Event) {
 	slog.Debug("Generating summary for session", "session_id", sess.ID)
 
 	events <- SessionCompaction(sess.ID, "started", r.currentAgent)
@@ -1060,32 +1459,50 @@ func (r *LocalRuntime) Summarize(ctx context.Context, sess *session.Session, eve
 		events <- SessionCompaction(sess.ID, "completed", r.currentAgent)
 	}()
 
-	// Create conversation history for summarization
-	var conversationHistory strings.Builder
-	messages := sess.GetAllMessages()
-
 	// Check if session is empty
-	if len(messages) == 0 {
+	if len(sess.GetAllMessages()) == 0 {
 		events <- &WarningEvent{Message: "Session is empty. Start a conversation before compacting."}
 		return
 	}
-	for i := range messages {
-		role := "Unknown"
-		switch messages[i].Message.Role {
-		case "user":
-			role = "User"
-		case "assistant":
-			role = "Assistant"
-		case "system":
-			continue // Skip system messages for summarization
-		}
-		conversationHistory.WriteString(fmt.Sprintf("\n%s: %s", role, messages[i].Message.Content))
+
+	a := r.CurrentAgent()
+	compaction := a.Compaction()
+	start, end := compactionRange(sess.Messages, keepTurns)
+	if start == end {
+		events <- &WarningEvent{Message: "Nothing to compact since the last summary."}
+		return
 	}
 
 	// Create a new session for summary generation
-	systemPrompt := "You are a helpful AI assistant that creates comprehensive summaries of conversations. You will be given a conversation history and asked to create a concise yet thorough summary that captures the key points, decisions made, and outcomes."
-	userPrompt := fmt.Sprintf("Based on the following conversation between a user and an AI assistant, create a comprehensive summary that captures:\n- The main topics discussed\n- Key information exchanged\n- Decisions made or conclusions reached\n- Important outcomes or results\n\nProvide a well-structured summary (2-4 paragraphs) that someone could read to understand what happened in this conversation. Return ONLY the summary text, nothing else.\n\nConversation history:%s\n\nGenerate a summary for this conversation:", conversationHistory.String())
-	newModel := provider.CloneWithOptions(ctx, r.CurrentAgent().Model(), options.WithStructuredOutput(nil))
+	var systemPrompt, userPrompt string
+	if compaction.Structured {
+		systemPrompt = "You are a helpful AI assistant that compacts the history of a conversation between a user and an AI agent so that the agent can keep working on the task with only the summary. You keep every fact the agent will need: goals, decisions, file paths, commands and what the tools found."
+		userPrompt = fmt.Sprintf("Based on the following conversation between a user and an AI agent, including the tools the agent called and their (possibly truncated) results, create a summary with exactly these sections:\n\n## Goals\nWhat the user wants to achieve, including constraints and preferences.\n\n## Decisions\nDecisions made and conclusions reached, with their reasons.\n\n## Files touched\nThe files that were read, created or modified, with a short note about each.\n\n## Open todos\nWhat remains to be done.\n\n## Key tool findings\nThe facts learned from tool results that are still relevant: errors, command outputs, file contents, search results.\n\nIf a previous summary is given, merge it into the new summary. Write \"None\" in empty sections. Return ONLY the summary, nothing else.\n\nConversation history:%s\n\nGenerate the summary for this conversation:", structuredHistory(sess.Messages[:end], start))
+	} else {
+		var conversationHistory strings.Builder
+		if start > 0 {
+			conversationHistory.WriteString("\n\nPrevious summary:\n" + sess.Messages[start-1].Summary + "\n")
+		}
+		for _, msg := range (&session.Session{Messages: sess.Messages[start:end]}).GetAllMessages() {
+			role := "Unknown"
+			switch msg.Message.Role {
+			case "user":
+				role = "User"
+			case "assistant":
+				role = "Assistant"
+			}
+			conversationHistory.WriteString(fmt.Sprintf("\n%s: %s", role, msg.Message.Content))
+		}
+
+		systemPrompt = "You are a helpful AI assistant that creates comprehensive summaries of conversations. You will be given a conversation history and asked to create a concise yet thorough summary that captures the key points, decisions made, and outcomes."
+		userPrompt = fmt.Sprintf("Based on the following conversation between a user and an AI assistant, create a comprehensive summary that captures:\n- The main topics discussed\n- Key information exchanged\n- Decisions made or conclusions reached\n- Important outcomes or results\n\nProvide a well-structured summary (2-4 paragraphs) that someone could read to understand what happened in this conversation. If a previous summary is given, merge it into the new summary. Return ONLY the summary text, nothing else.\n\nConversation history:%s\n\nGenerate a summary for this conversation:", conversationHistory.String())
+	}
+
+	model := compaction.Model
+	if model == nil {
+		model = a.Model()
+	}
+	newModel := provider.CloneWithOptions(ctx, model, options.WithStructuredOutput(nil))
 	newTeam := team.New(
 		team.WithID("summary-generator"),
 		team.WithAgents(agent.New("root", systemPrompt, agent.WithModel(newModel))),
@@ -1095,7 +1512,7 @@ func (r *LocalRuntime) Summarize(ctx context.Context, sess *session.Session, eve
 	summarySession.AddMessage(session.UserMessage("", userPrompt))
 	summarySession.Title = "Generating summary..."
 
-	summaryRuntime, err := New(newTeam, WithSessionCompaction(false))
+	summaryRuntime, err := New(newTeam, WithSessionCompaction(false), WithModelStore(r.modelsStore))
 	if err != nil {
 		slog.Error("Failed to create summary generator runtime", "error", err)
 		return
@@ -1112,12 +1529,70 @@ func (r *LocalRuntime) Summarize(ctx context.Context, sess *session.Session, eve
 	if summary == "" {
 		return
 	}
-	// Add the summary to the session as a summary item
-	sess.Messages = append(sess.Messages, session.Item{Summary: summary})
-	slog.Debug("Generated session summary", "session_id", sess.ID, "summary_length", len(summary))
+	// Add the summary to the session as a summary item, before the turns that are kept
+	sess.Messages = slices.Insert(sess.Messages, end, session.Item{Summary: summary})
+	slog.Debug("Generated session summary", "session_id", sess.ID, "summary_length", len(summary), "kept_items", len(sess.Messages)-end-1)
 	events <- SessionSummary(sess.ID, summary, r.currentAgent)
 }
 
+// compactionRange returns the range of items to summarize: the items after the
+// last summary, up to the last keepTurns turns. A turn starts with a user message.
+// The range is empty when there aren't more turns than keepTurns.
+func compactionRange(items []session.Item, keepTurns int) (start, end int) {
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].Summary != "" {
+			start = i + 1
+			break
+		}
+	}
+
+	if keepTurns <= 0 {
+		return start, len(items)
+	}
+
+	turns := 0
+	for i := len(items) - 1; i >= start; i-- {
+		if items[i].IsMessage() && items[i].Message.Message.Role == chat.MessageRoleUser {
+			turns++
+			if turns == keepTurns {
+				return start, i
+			}
+		}
+	}
+
+	return start, start
+}
+
+// structuredHistory renders the conversation history for a structured summary:
+// the previous summary, if any, followed by the messages, tool calls and tool
+// results of the items from start.
+func structuredHistory(items []session.Item, start int) string {
+	var history strings.Builder
+	if start > 0 {
+		history.WriteString("\n\nPrevious summary:\n" + items[start-1].Summary + "\n")
+	}
+
+	toolNames := map[string]string{}
+	for _, msg := range (&session.Session{Messages: items[start:]}).GetAllMessages() {
+		switch msg.Message.Role {
+		case chat.MessageRoleUser:
+			history.WriteString("\nUser: " + msg.Message.Content)
+		case chat.MessageRoleAssistant:
+			if msg.Message.Content != "" {
+				history.WriteString("\nAssistant: " + msg.Message.Content)
+			}
+			for _, call := range msg.Message.ToolCalls {
+				toolNames[call.ID] = call.Function.Name
+				history.WriteString(fmt.Sprintf("\nAssistant called %s(%s)", call.Function.Name, call.Function.Arguments))
+			}
+		case chat.MessageRoleTool:
+			history.WriteString(fmt.Sprintf("\nResult of %s: %s", toolNames[msg.Message.ToolCallID], contextwindow.Truncate(msg.Message.Content, summaryToolOutputSize)))
+		}
+	}
+
+	return history.String()
+}
+
 // setElicitationEventsChannel sets the current events channel for elicitation requests
 func (r *LocalRuntime) setElicitationEventsChannel(events chan Event) {
 	r.elicitationEventsChannelMux.Lock()
@@ -6,18 +6,28 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/rumpl/rb/pkg/agent"
+	"github.com/rumpl/rb/pkg/artifacts"
 	"github.com/rumpl/rb/pkg/chat"
+	latest "github.com/rumpl/rb/pkg/config/v2"
+	"github.com/rumpl/rb/pkg/contextwindow"
 	"github.com/rumpl/rb/pkg/model/provider/base"
 	"github.com/rumpl/rb/pkg/modelsdev"
+	"github.com/rumpl/rb/pkg/permissions"
 	"github.com/rumpl/rb/pkg/session"
 	"github.com/rumpl/rb/pkg/team"
 	"github.com/rumpl/rb/pkg/tools"
+	"github.com/rumpl/rb/pkg/tools/builtin"
 )
 
 type stubToolSet struct {
@@ -52,10 +62,14 @@ type mockStream struct {
 	responses []chat.MessageStreamResponse
 	idx       int
 	closed    bool
+	err       error // returned instead of io.EOF once all responses are consumed
 }
 
 func (m *mockStream) Recv() (chat.MessageStreamResponse, error) {
 	if m.idx >= len(m.responses) {
+		if m.err != nil {
+			return chat.MessageStreamResponse{}, m.err
+		}
 		return chat.MessageStreamResponse{}, io.EOF
 	}
 	resp := m.responses[m.idx]
@@ -214,7 +228,7 @@ func TestSimple(t *testing.T) {
 		UserMessage("Hi"),
 		StreamStarted(sess.ID, "root"),
 		AgentChoice("root", "Hello"),
-		TokenUsage(3, 2, 5, 0, 0),
+		TokenUsage(3, 2, 5, contextwindow.DefaultLimit, 0),
 		StreamStopped(sess.ID, "root"),
 	}
 
@@ -243,7 +257,7 @@ func TestMultipleContentChunks(t *testing.T) {
 		AgentChoice("root", "how "),
 		AgentChoice("root", "are "),
 		AgentChoice("root", "you?"),
-		TokenUsage(8, 12, 20, 0, 0),
+		TokenUsage(8, 12, 20, contextwindow.DefaultLimit, 0),
 		StreamStopped(sess.ID, "root"),
 	}
 
@@ -268,7 +282,7 @@ func TestWithReasoning(t *testing.T) {
 		AgentChoiceReasoning("root", "Let me think about this..."),
 		AgentChoiceReasoning("root", " I should respond politely."),
 		AgentChoice("root", "Hello, how can I help you?"),
-		TokenUsage(10, 15, 25, 0, 0),
+		TokenUsage(10, 15, 25, contextwindow.DefaultLimit, 0),
 		StreamStopped(sess.ID, "root"),
 	}
 
@@ -295,7 +309,7 @@ func TestMixedContentAndReasoning(t *testing.T) {
 		AgentChoice("root", "Hello!"),
 		AgentChoiceReasoning("root", " I should be friendly"),
 		AgentChoice("root", " How can I help you today?"),
-		TokenUsage(15, 20, 35, 0, 0),
+		TokenUsage(15, 20, 35, contextwindow.DefaultLimit, 0),
 		StreamStopped(sess.ID, "root"),
 	}
 
@@ -668,6 +682,106 @@ func TestSummarize_EmptySession(t *testing.T) {
 	require.Contains(t, warningMsg, "empty", "warning message should mention empty session")
 }
 
+func TestSummarize_StructuredKeepsLastTurns(t *testing.T) {
+	prov := &mockProvider{id: "test/mock-model", stream: &mockStream{}}
+	summaryModel := &recordingProvider{mockProvider: mockProvider{id: "test/cheap-model", stream: newStreamBuilder().AddContent("## Goals\nFix the build").AddStopWithUsage(3, 2).Build()}}
+	root := agent.New("root", "You are a test agent",
+		agent.WithModel(prov),
+		agent.WithCompaction(agent.Compaction{Structured: true, KeepTurns: 1, Model: summaryModel}),
+	)
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Why doesn't it build?"))
+	sess.AddMessage(session.NewAgentMessage(root, &chat.Message{
+		Role:      chat.MessageRoleAssistant,
+		ToolCalls: []tools.ToolCall{{ID: "call_1", Function: tools.FunctionCall{Name: "shell", Arguments: `{"cmd":"go build"}`}}},
+	}))
+	sess.AddMessage(session.NewAgentMessage(root, &chat.Message{Role: chat.MessageRoleTool, ToolCallID: "call_1", Content: "main.go:3: undefined: foo"}))
+	sess.AddMessage(session.NewAgentMessage(root, &chat.Message{Role: chat.MessageRoleAssistant, Content: "foo is undefined"}))
+	sess.AddMessage(session.UserMessage("", "Fix it"))
+	sess.AddMessage(session.NewAgentMessage(root, &chat.Message{Role: chat.MessageRoleAssistant, Content: "Done"}))
+
+	events := make(chan Event, 10)
+	rt.Summarize(t.Context(), sess, events)
+	close(events)
+
+	require.Len(t, summaryModel.requests, 1)
+	prompt := summaryModel.requests[0][len(summaryModel.requests[0])-1].Content
+	require.Contains(t, prompt, "## Key tool findings")
+	require.Contains(t, prompt, `Assistant called shell({"cmd":"go build"})`)
+	require.Contains(t, prompt, "Result of shell: main.go:3: undefined: foo")
+	require.NotContains(t, prompt, "Fix it")
+
+	// The summary replaces the first turn, the last one is kept verbatim
+	require.Len(t, sess.Messages, 7)
+	require.Equal(t, "## Goals\nFix the build", sess.Messages[4].Summary)
+
+	var conversation []string
+	for _, msg := range sess.GetMessages(t.Context(), root) {
+		if msg.Role != chat.MessageRoleSystem {
+			conversation = append(conversation, msg.Content)
+		}
+	}
+	require.Equal(t, []string{"Fix it", "Done"}, conversation)
+}
+
+func TestSummarize_KeepsSessionWithFewerTurnsThanKept(t *testing.T) {
+	summaryModel := &recordingProvider{mockProvider: mockProvider{id: "test/cheap-model", stream: newStreamBuilder().AddContent("summary").AddStopWithUsage(3, 2).Build()}}
+	root := agent.New("root", "You are a test agent",
+		agent.WithModel(&mockProvider{id: "test/mock-model", stream: &mockStream{}}),
+		agent.WithCompaction(agent.Compaction{KeepTurns: 2, Model: summaryModel}),
+	)
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Why doesn't it build?"))
+	sess.AddMessage(session.NewAgentMessage(root, &chat.Message{Role: chat.MessageRoleAssistant, Content: "foo is undefined"}))
+	sess.AddMessage(session.UserMessage("", "Fix it"))
+	sess.AddMessage(session.NewAgentMessage(root, &chat.Message{Role: chat.MessageRoleAssistant, Content: "Done"}))
+
+	events := make(chan Event, 10)
+	rt.Summarize(t.Context(), sess, events)
+	close(events)
+
+	require.Empty(t, summaryModel.requests)
+	require.Len(t, sess.Messages, 4)
+	require.True(t, hasEventType(t, drainEvents(events), &WarningEvent{}))
+}
+
+func TestCompactionRange(t *testing.T) {
+	user := session.Item{Message: session.UserMessage("", "question")}
+	assistant := session.Item{Message: &session.Message{Message: chat.Message{Role: chat.MessageRoleAssistant, Content: "answer"}}}
+	summary := session.Item{Summary: "summary"}
+
+	tests := []struct {
+		name      string
+		items     []session.Item
+		keepTurns int
+		start     int
+		end       int
+	}{
+		{name: "everything", items: []session.Item{user, assistant, user, assistant}, start: 0, end: 4},
+		{name: "keep last turn", items: []session.Item{user, assistant, user, assistant}, keepTurns: 1, start: 0, end: 2},
+		{name: "as many turns as kept", items: []session.Item{user, assistant, user, assistant}, keepTurns: 2, start: 0, end: 0},
+		{name: "not enough turns", items: []session.Item{user, assistant}, keepTurns: 2, start: 0, end: 0},
+		{name: "not enough turns after summary", items: []session.Item{user, summary, user, assistant}, keepTurns: 2, start: 2, end: 2},
+		{name: "after summary", items: []session.Item{user, summary, user, assistant, user, assistant}, keepTurns: 1, start: 2, end: 4},
+		{name: "nothing new", items: []session.Item{user, assistant, summary}, keepTurns: 1, start: 3, end: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := compactionRange(tt.items, tt.keepTurns)
+			require.Equal(t, tt.start, start)
+			require.Equal(t, tt.end, end)
+		})
+	}
+}
+
 func TestProcessToolCalls_UnknownTool_NoToolResultMessage(t *testing.T) {
 	// Build a runtime with a simple agent but no tools registered matching the call
 	root := agent.New("root", "You are a test agent")
@@ -707,3 +821,864 @@ func TestProcessToolCalls_UnknownTool_NoToolResultMessage(t *testing.T) {
 	}
 	require.False(t, sawToolMsg, "no tool result should be added for unknown tool; this reproduces invalid sequencing state")
 }
+
+func TestProcessToolCalls_PermissionRules(t *testing.T) {
+	var ran []string
+	newTool := func(name string) tools.Tool {
+		return tools.Tool{
+			Name:       name,
+			Parameters: map[string]any{},
+			Handler: func(ctx context.Context, call tools.ToolCall) (*tools.ToolCallResult, error) {
+				ran = append(ran, call.Function.Arguments)
+				return &tools.ToolCallResult{Output: "ok"}, nil
+			},
+		}
+	}
+	shellTool := newTool("shell")
+
+	root := agent.New("root", "You are a test agent",
+		agent.WithTools(shellTool),
+		agent.WithPermissions(permissions.NewChecker(
+			[]permissions.Rule{{Tool: "shell", Args: map[string]string{"cmd": "git *"}}},
+			nil,
+			[]permissions.Rule{{Tool: "shell", Args: map[string]string{"cmd": "rm *"}}},
+		)),
+	)
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+
+	// Even with all tools approved, deny rules must apply
+	sess := session.New(session.WithUserMessage("", "Start"), session.WithToolsApproved(true))
+
+	calls := []tools.ToolCall{
+		{ID: "call_1", Type: "function", Function: tools.FunctionCall{Name: "shell", Arguments: `{"cmd":"git status"}`}},
+		{ID: "call_2", Type: "function", Function: tools.FunctionCall{Name: "shell", Arguments: `{"cmd":"rm -rf /"}`}},
+	}
+
+	events := make(chan Event, 10)
+	rt.processToolCalls(t.Context(), sess, calls, []tools.Tool{shellTool}, events)
+	close(events)
+	for range events {
+	}
+
+	require.Equal(t, []string{`{"cmd":"git status"}`}, ran)
+
+	var denied string
+	for _, it := range sess.Messages {
+		if it.IsMessage() && it.Message.Message.ToolCallID == "call_2" {
+			denied = it.Message.Message.Content
+		}
+	}
+	require.Contains(t, denied, "shell(cmd=rm *)")
+}
+
+func TestToolPermission(t *testing.T) {
+	root := agent.New("root", "You are a test agent",
+		agent.WithPermissions(permissions.NewChecker(
+			nil,
+			[]permissions.Rule{{Tool: "shell", Args: map[string]string{"cmd": "git push*"}}},
+			nil,
+		)),
+	)
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+
+	push := tools.ToolCall{Function: tools.FunctionCall{Name: "shell", Arguments: `{"cmd":"git push"}`}}
+	readFile := tools.ToolCall{Function: tools.FunctionCall{Name: "read_file", Arguments: `{}`}}
+
+	sess := session.New()
+	require.Equal(t, permissions.DecisionAsk, rt.toolPermission(root, sess, push, true), "ask rules override read-only hints")
+	require.Equal(t, permissions.DecisionAllow, rt.toolPermission(root, sess, readFile, true))
+	require.Equal(t, permissions.DecisionAsk, rt.toolPermission(root, sess, readFile, false))
+
+	status := tools.ToolCall{Function: tools.FunctionCall{Name: "shell", Arguments: `{"cmd":"git status"}`}}
+	require.Equal(t, permissions.DecisionAsk, rt.toolPermission(root, sess, status, false))
+
+	sess.ApproveRule(permissions.Rule{Tool: "shell"})
+	require.Equal(t, permissions.DecisionAllow, rt.toolPermission(root, sess, status, false))
+	require.Equal(t, permissions.DecisionAsk, rt.toolPermission(root, sess, push, false), "ask rules apply to approved tools")
+	require.Equal(t, permissions.DecisionAsk, rt.toolPermission(root, sess, readFile, false), "approving a tool must not approve other tools")
+
+	sess.ToolsApproved = true
+	require.Equal(t, permissions.DecisionAllow, rt.toolPermission(root, sess, readFile, false))
+	require.Equal(t, permissions.DecisionAsk, rt.toolPermission(root, sess, push, false), "ask rules apply when all the tools are approved")
+}
+
+func TestProcessToolCalls_ApproveCall(t *testing.T) {
+	var ran []string
+	shellTool := tools.Tool{
+		Name:       "shell",
+		Parameters: map[string]any{},
+		Handler: func(ctx context.Context, call tools.ToolCall) (*tools.ToolCallResult, error) {
+			ran = append(ran, call.Function.Arguments)
+			return &tools.ToolCallResult{Output: "ok"}, nil
+		},
+	}
+	root := agent.New("root", "You are a test agent", agent.WithTools(shellTool))
+
+	rt, err := New(team.New(team.WithAgents(root)), WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Start"))
+	calls := []tools.ToolCall{{ID: "call_1", Type: "function", Function: tools.FunctionCall{Name: "shell", Arguments: `{"cmd":"ls *"}`}}}
+
+	events := make(chan Event)
+	go func() {
+		for event := range events {
+			if _, ok := event.(*ToolCallConfirmationEvent); ok {
+				rt.resumeChan <- ResumeTypeApproveCall
+			}
+		}
+	}()
+	rt.processToolCalls(t.Context(), sess, calls, []tools.Tool{shellTool}, events)
+	close(events)
+
+	require.Equal(t, []string{`{"cmd":"ls *"}`}, ran)
+	require.Equal(t, []permissions.Rule{{Tool: "shell", Args: map[string]string{"cmd": `ls \*`}}}, sess.ApprovedRules)
+	require.Equal(t, permissions.DecisionAllow, rt.toolPermission(root, sess, calls[0], false))
+	rm := tools.ToolCall{Function: tools.FunctionCall{Name: "shell", Arguments: `{"cmd":"ls /; rm -rf /"}`}}
+	require.Equal(t, permissions.DecisionAsk, rt.toolPermission(root, sess, rm, false), "only the approved arguments are allowed")
+}
+
+func TestProcessToolCalls_Parallel(t *testing.T) {
+	const callCount = 4
+
+	// Every handler waits for all the others to start, which only succeeds if they run concurrently
+	var started sync.WaitGroup
+	started.Add(callCount)
+
+	slowTool := tools.Tool{
+		Name:        "slow_read",
+		Parameters:  map[string]any{},
+		Annotations: tools.ToolAnnotations{ReadOnlyHint: true},
+		Handler: func(ctx context.Context, call tools.ToolCall) (*tools.ToolCallResult, error) {
+			started.Done()
+			started.Wait()
+			return &tools.ToolCallResult{Output: "result " + call.ID}, nil
+		},
+	}
+
+	root := agent.New("root", "You are a test agent",
+		agent.WithTools(slowTool),
+		agent.WithMaxParallelToolCalls(callCount),
+	)
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+	rt.registerDefaultTools()
+
+	sess := session.New(session.WithUserMessage("", "Start"))
+
+	var calls []tools.ToolCall
+	for i := range callCount {
+		calls = append(calls, tools.ToolCall{
+			ID:       fmt.Sprintf("call_%d", i),
+			Type:     "function",
+			Function: tools.FunctionCall{Name: "slow_read", Arguments: "{}"},
+		})
+	}
+
+	events := make(chan Event, 2*callCount)
+	done := make(chan struct{})
+	go func() {
+		rt.processToolCalls(t.Context(), sess, calls, []tools.Tool{slowTool}, events)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tool calls did not run in parallel")
+	}
+
+	var toolCallIDs []string
+	for _, it := range sess.Messages {
+		if it.IsMessage() && it.Message.Message.Role == chat.MessageRoleTool {
+			toolCallIDs = append(toolCallIDs, it.Message.Message.ToolCallID)
+			require.Equal(t, "result "+it.Message.Message.ToolCallID, it.Message.Message.Content)
+		}
+	}
+	require.Equal(t, []string{"call_0", "call_1", "call_2", "call_3"}, toolCallIDs, "tool responses must keep the order of the calls")
+}
+
+func TestParallelToolCalls_Batching(t *testing.T) {
+	readTool := tools.Tool{Name: "read", Annotations: tools.ToolAnnotations{ReadOnlyHint: true}}
+	writeTool := tools.Tool{Name: "write"}
+	notParallel := false
+	sequentialReadTool := tools.Tool{Name: "sequential_read", Annotations: tools.ToolAnnotations{ReadOnlyHint: true}, Parallel: &notParallel}
+	agentTools := []tools.Tool{readTool, writeTool, sequentialReadTool}
+
+	newCall := func(name string) tools.ToolCall {
+		return tools.ToolCall{Function: tools.FunctionCall{Name: name, Arguments: "{}"}}
+	}
+
+	root := agent.New("root", "You are a test agent", agent.WithMaxParallelToolCalls(2))
+	tm := team.New(team.WithAgents(root))
+	rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+	rt.registerDefaultTools()
+
+	sess := session.New()
+
+	batch := rt.parallelToolCalls(root, sess, []tools.ToolCall{newCall("read"), newCall("read"), newCall("write"), newCall("read")}, agentTools)
+	require.Len(t, batch, 2, "batches stop at tools that need confirmation")
+
+	batch = rt.parallelToolCalls(root, sess, []tools.ToolCall{newCall("read"), newCall("sequential_read")}, agentTools)
+	require.Len(t, batch, 1, "batches stop at tools that are not parallel-safe")
+
+	batch = rt.parallelToolCalls(root, sess, []tools.ToolCall{newCall("read"), newCall("transfer_task")}, agentTools)
+	require.Len(t, batch, 1, "batches stop at runtime tools")
+
+	byDefault := agent.New("root", "You are a test agent")
+	batch = rt.parallelToolCalls(byDefault, sess, []tools.ToolCall{newCall("read"), newCall("read")}, agentTools)
+	require.Len(t, batch, 2, "tool calls run in parallel by default")
+
+	notParallelModel := &configProvider{base.Config{ModelConfig: latest.ModelConfig{ParallelToolCalls: &notParallel}}}
+	sequential := agent.New("root", "You are a test agent", agent.WithModel(notParallelModel), agent.WithMaxParallelToolCalls(2))
+	batch = rt.parallelToolCalls(sequential, sess, []tools.ToolCall{newCall("read"), newCall("read")}, agentTools)
+	require.Empty(t, batch, "parallel_tool_calls: false runs the tool calls sequentially")
+}
+
+// configProvider is a provider that only has a configuration
+type configProvider struct {
+	config base.Config
+}
+
+func (p *configProvider) ID() string { return p.config.ID() }
+
+func (p *configProvider) CreateChatCompletionStream(context.Context, []chat.Message, []tools.Tool) (chat.MessageStream, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *configProvider) BaseConfig() base.Config { return p.config }
+
+type flakyProvider struct {
+	id     string
+	err    error
+	fails  int
+	calls  int
+	stream *mockStream
+}
+
+func (p *flakyProvider) ID() string { return p.id }
+
+func (p *flakyProvider) CreateChatCompletionStream(context.Context, []chat.Message, []tools.Tool) (chat.MessageStream, error) {
+	p.calls++
+	if p.calls <= p.fails {
+		return nil, p.err
+	}
+	return p.stream, nil
+}
+
+func (p *flakyProvider) BaseConfig() base.Config { return base.Config{} }
+
+func (p *flakyProvider) MaxTokens() int { return 0 }
+
+func drainEvents(ch <-chan Event) []Event {
+	var evs []Event
+	for ev := range ch {
+		evs = append(evs, ev)
+	}
+	return evs
+}
+
+func TestRunStream_RetriesTransientErrors(t *testing.T) {
+	prov := &flakyProvider{
+		id:     "test/flaky-model",
+		err:    fmt.Errorf("read: %w", syscall.ECONNRESET),
+		fails:  2,
+		stream: newStreamBuilder().AddContent("Hello").AddStopWithUsage(3, 2).Build(),
+	}
+	root := agent.New("root", "You are a test agent", agent.WithModel(prov), agent.WithMaxRetries(2))
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}), WithRetryBackoff(time.Millisecond))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Hi"))
+	sess.Title = "Unit Test"
+
+	events := drainEvents(rt.RunStream(t.Context(), sess))
+
+	require.Equal(t, 3, prov.calls)
+	require.False(t, hasEventType(t, events, &ErrorEvent{}))
+	require.Contains(t, events, AgentChoice("root", "Hello"))
+}
+
+func TestRunStream_NoRetryWithoutMaxRetries(t *testing.T) {
+	prov := &flakyProvider{
+		id:    "test/flaky-model",
+		err:   fmt.Errorf("read: %w", syscall.ECONNRESET),
+		fails: 1,
+	}
+	root := agent.New("root", "You are a test agent", agent.WithModel(prov))
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}), WithRetryBackoff(time.Millisecond))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Hi"))
+	sess.Title = "Unit Test"
+
+	events := drainEvents(rt.RunStream(t.Context(), sess))
+
+	require.Equal(t, 1, prov.calls)
+	require.True(t, hasEventType(t, events, &ErrorEvent{}))
+}
+
+func TestRunStream_FallbackModel(t *testing.T) {
+	primary := &mockProviderWithError{id: "test/primary-model"}
+	fallback := &mockProvider{id: "test/fallback-model", stream: newStreamBuilder().AddContent("Hello").AddStopWithUsage(3, 2).Build()}
+	root := agent.New("root", "You are a test agent", agent.WithModel(primary), agent.WithFallbackModels(fallback))
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Hi"))
+	sess.Title = "Unit Test"
+
+	events := drainEvents(rt.RunStream(t.Context(), sess))
+
+	require.False(t, hasEventType(t, events, &ErrorEvent{}))
+	require.True(t, hasWarningEvent(events))
+	require.Contains(t, events, AgentChoice("root", "Hello"))
+
+	messages := sess.GetAllMessages()
+	last := messages[len(messages)-1]
+	require.Equal(t, chat.MessageRoleAssistant, last.Message.Role)
+	require.Equal(t, "test/fallback-model", last.Model)
+}
+
+func TestRunStream_NoFallbackAfterPartialOutput(t *testing.T) {
+	stream := newStreamBuilder().AddContent("Hel").Build()
+	stream.err = fmt.Errorf("read: %w", syscall.ECONNRESET)
+	primary := &mockProvider{id: "test/primary-model", stream: stream}
+	fallback := &flakyProvider{id: "test/fallback-model"}
+	root := agent.New("root", "You are a test agent", agent.WithModel(primary), agent.WithFallbackModels(fallback), agent.WithMaxRetries(3))
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}), WithRetryBackoff(time.Millisecond))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Hi"))
+	sess.Title = "Unit Test"
+
+	events := drainEvents(rt.RunStream(t.Context(), sess))
+
+	require.True(t, hasEventType(t, events, &ErrorEvent{}))
+	require.Equal(t, 0, fallback.calls)
+}
+
+// recordingProvider records the messages of each request
+type recordingProvider struct {
+	mockProvider
+	requests [][]chat.Message
+}
+
+func (p *recordingProvider) CreateChatCompletionStream(ctx context.Context, messages []chat.Message, agentTools []tools.Tool) (chat.MessageStream, error) {
+	p.requests = append(p.requests, messages)
+	return p.mockProvider.CreateChatCompletionStream(ctx, messages, agentTools)
+}
+
+func TestRunStream_DropsOldToolOutputsToFitContext(t *testing.T) {
+	prov := &recordingProvider{mockProvider: mockProvider{id: "test/mock-model", stream: newStreamBuilder().AddContent("Done").AddStopWithUsage(3, 2).Build()}}
+	root := agent.New("root", "You are a test agent",
+		agent.WithModel(prov),
+		agent.WithContextWindow(contextwindow.Config{Limit: 1000}),
+	)
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+
+	largeOutput := strings.Repeat("x", 4000)
+	sess := session.New(session.WithUserMessage("", "Read the file"))
+	sess.Title = "Unit Test"
+	sess.AddMessage(session.NewAgentMessage(root, &chat.Message{
+		Role:      chat.MessageRoleAssistant,
+		ToolCalls: []tools.ToolCall{{ID: "call_1", Function: tools.FunctionCall{Name: "read_file"}}},
+	}))
+	sess.AddMessage(session.NewAgentMessage(root, &chat.Message{Role: chat.MessageRoleTool, ToolCallID: "call_1", Content: largeOutput}))
+	sess.AddMessage(session.NewAgentMessage(root, &chat.Message{Role: chat.MessageRoleAssistant, Content: "The file is full of x"}))
+	sess.AddMessage(session.UserMessage("", "Thanks"))
+
+	drainEvents(rt.RunStream(t.Context(), sess))
+
+	require.Len(t, prov.requests, 1)
+	var toolOutputs []string
+	for _, msg := range prov.requests[0] {
+		if msg.Role == chat.MessageRoleTool {
+			toolOutputs = append(toolOutputs, msg.Content)
+		}
+	}
+	require.Equal(t, []string{contextwindow.DroppedToolOutput}, toolOutputs)
+
+	// The session itself keeps the full output
+	require.Equal(t, largeOutput, sess.GetAllMessages()[2].Message.Content)
+}
+
+func TestRunStream_SummarizesWhenFirstRequestDoesNotFit(t *testing.T) {
+	summaryStream := newStreamBuilder().AddContent("The file is full of x").AddStopWithUsage(3, 2).Build()
+	mainStream := newStreamBuilder().AddContent("Done").AddStopWithUsage(3, 2).Build()
+	prov := &queueProvider{id: "test/mock-model", streams: []chat.MessageStream{summaryStream, mainStream}}
+	root := agent.New("root", "You are a test agent",
+		agent.WithModel(prov),
+		agent.WithContextWindow(contextwindow.Config{Limit: 1000, Strategies: []contextwindow.Strategy{contextwindow.StrategySummarize}}),
+		agent.WithCompaction(agent.Compaction{KeepTurns: 1}),
+	)
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(true), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Read the file"))
+	sess.Title = "Unit Test"
+	sess.AddMessage(session.NewAgentMessage(root, &chat.Message{Role: chat.MessageRoleAssistant, Content: strings.Repeat("x", 4000)}))
+	sess.AddMessage(session.UserMessage("", "Thanks"))
+
+	drainEvents(rt.RunStream(t.Context(), sess))
+
+	require.Len(t, sess.Messages, 5)
+	require.Equal(t, "The file is full of x", sess.Messages[2].Summary)
+	require.Equal(t, "Done", sess.GetLastAssistantMessageContent())
+}
+
+func TestRunStream_KeepsFewerTurnsWhenTheKeptTurnsDoNotFit(t *testing.T) {
+	summaryStream := newStreamBuilder().AddContent("The file is full of x").AddStopWithUsage(3, 2).Build()
+	mainStream := newStreamBuilder().AddContent("Done").AddStopWithUsage(3, 2).Build()
+	prov := &queueProvider{id: "test/mock-model", streams: []chat.MessageStream{summaryStream, mainStream}}
+	root := agent.New("root", "You are a test agent",
+		agent.WithModel(prov),
+		agent.WithContextWindow(contextwindow.Config{Limit: 1000, Strategies: []contextwindow.Strategy{contextwindow.StrategySummarize}}),
+		agent.WithCompaction(agent.Compaction{KeepTurns: 3}),
+	)
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(true), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Read the file"))
+	sess.Title = "Unit Test"
+	sess.AddMessage(session.NewAgentMessage(root, &chat.Message{Role: chat.MessageRoleAssistant, Content: strings.Repeat("x", 4000)}))
+	sess.AddMessage(session.UserMessage("", "Thanks"))
+
+	events := drainEvents(rt.RunStream(t.Context(), sess))
+
+	// Only the last turn is kept
+	require.Len(t, sess.Messages, 5)
+	require.Equal(t, "The file is full of x", sess.Messages[2].Summary)
+	require.Equal(t, "Done", sess.GetLastAssistantMessageContent())
+	require.False(t, hasEventType(t, events, &WarningEvent{}))
+}
+
+func TestSummarize_FromTheLastSummary(t *testing.T) {
+	summaryModel := &recordingProvider{mockProvider: mockProvider{id: "test/cheap-model", stream: newStreamBuilder().AddContent("new summary").AddStopWithUsage(3, 2).Build()}}
+	root := agent.New("root", "You are a test agent",
+		agent.WithModel(&mockProvider{id: "test/mock-model", stream: &mockStream{}}),
+		agent.WithCompaction(agent.Compaction{Model: summaryModel}),
+	)
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Why doesn't it build?"))
+	sess.AddMessage(session.NewAgentMessage(root, &chat.Message{Role: chat.MessageRoleAssistant, Content: "foo is undefined"}))
+	sess.Messages = append(sess.Messages, session.Item{Summary: "old summary"})
+	sess.AddMessage(session.UserMessage("", "Fix it"))
+	sess.AddMessage(session.NewAgentMessage(root, &chat.Message{Role: chat.MessageRoleAssistant, Content: "Done"}))
+
+	events := make(chan Event, 10)
+	rt.Summarize(t.Context(), sess, events)
+	close(events)
+
+	require.Len(t, summaryModel.requests, 1)
+	prompt := summaryModel.requests[0][len(summaryModel.requests[0])-1].Content
+	require.Contains(t, prompt, "Previous summary:\nold summary")
+	require.Contains(t, prompt, "User: Fix it")
+	require.NotContains(t, prompt, "Why doesn't it build?")
+	require.Equal(t, "new summary", sess.Messages[len(sess.Messages)-1].Summary)
+}
+
+func TestRunStream_SpillsLargeToolOutputs(t *testing.T) {
+	largeOutput := strings.Repeat("0123456789", 100)
+	searchTool := tools.Tool{
+		Name:          "search",
+		Parameters:    map[string]any{},
+		Annotations:   tools.ToolAnnotations{ReadOnlyHint: true},
+		MaxOutputSize: 400,
+		Handler: func(context.Context, tools.ToolCall) (*tools.ToolCallResult, error) {
+			return &tools.ToolCallResult{Output: largeOutput}, nil
+		},
+	}
+
+	toolStream := newStreamBuilder().
+		AddToolCallName("call_1", "search").
+		AddToolCallArguments("call_1", "{}").
+		AddStopWithUsage(1, 1).
+		Build()
+	prov := &mockProvider{id: "test/mock-model", stream: toolStream}
+	root := agent.New("root", "You are a test agent", agent.WithModel(prov), agent.WithTools(searchTool))
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}), WithToolOutputStore(artifacts.NewStore(t.TempDir())))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Search"))
+	sess.Title = "Unit Test"
+
+	events := drainEvents(rt.RunStream(t.Context(), sess))
+	// The user still sees the whole output
+	for _, ev := range events {
+		if e, ok := ev.(*ToolCallResponseEvent); ok {
+			require.Equal(t, largeOutput, e.Response)
+		}
+	}
+
+	var toolMessage string
+	for _, msg := range sess.GetAllMessages() {
+		if msg.Message.Role == chat.MessageRoleTool {
+			toolMessage = msg.Message.Content
+		}
+	}
+	require.Less(t, len(toolMessage), len(largeOutput))
+	require.Contains(t, toolMessage, "read_tool_output")
+	require.True(t, hasSpilledToolOutputs(sess), "the agent needs read_tool_output from now on")
+
+	handle := regexp.MustCompile(`handle "([^"]+)"`).FindStringSubmatch(toolMessage)
+	require.Len(t, handle, 2)
+
+	res, err := rt.handleReadToolOutput(t.Context(), sess, tools.ToolCall{Function: tools.FunctionCall{Arguments: fmt.Sprintf(`{"handle":%q,"offset":990}`, handle[1])}}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "0123456789", res.Output)
+
+	res, err = rt.handleReadToolOutput(t.Context(), sess, tools.ToolCall{Function: tools.FunctionCall{Arguments: fmt.Sprintf(`{"handle":%q,"length":10}`, handle[1])}}, nil)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(res.Output, "0123456789\n\n[Showing bytes 0-10 of 1000. Use offset=10 to read more.]"))
+}
+
+func TestRunStream_BudgetExceeded(t *testing.T) {
+	for _, tt := range []struct {
+		name           string
+		answer         ResumeType
+		expected       string
+		maxTokensTotal int
+	}{
+		{name: "extend", answer: ResumeTypeApprove, expected: "Done", maxTokensTotal: 110},
+		{name: "stop", answer: ResumeTypeReject, expected: "I have used the tokens budget of the session (50 tokens). Stopping as requested by user.", maxTokensTotal: 50},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			toolStream := newStreamBuilder().
+				AddToolCallName("call_1", "test_tool").
+				AddToolCallArguments("call_1", "{}").
+				AddStopWithUsage(40, 20).
+				Build()
+			toolStream.responses[len(toolStream.responses)-1].Choices[0].FinishReason = chat.FinishReasonToolCalls
+			doneStream := newStreamBuilder().AddContent("Done").AddStopWithUsage(10, 5).Build()
+			prov := &queueProvider{id: "test/mock-model", streams: []chat.MessageStream{toolStream, doneStream}}
+
+			testTool := tools.Tool{
+				Name:        "test_tool",
+				Parameters:  map[string]any{},
+				Annotations: tools.ToolAnnotations{ReadOnlyHint: true},
+				Handler: func(context.Context, tools.ToolCall) (*tools.ToolCallResult, error) {
+					return &tools.ToolCallResult{Output: "ok"}, nil
+				},
+			}
+			root := agent.New("root", "You are a test agent", agent.WithModel(prov), agent.WithTools(testTool))
+			tm := team.New(team.WithAgents(root))
+
+			rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+			require.NoError(t, err)
+
+			sess := session.New(session.WithUserMessage("", "Start"), session.WithMaxTokensTotal(50))
+			sess.Title = "Unit Test"
+
+			var exceeded []*BudgetExceededEvent
+			for ev := range rt.RunStream(t.Context(), sess) {
+				if e, ok := ev.(*BudgetExceededEvent); ok {
+					exceeded = append(exceeded, e)
+					rt.resumeChan <- tt.answer
+				}
+			}
+
+			require.Len(t, exceeded, 1)
+			require.Equal(t, BudgetTokens, exceeded[0].Budget)
+			require.InDelta(t, 50, exceeded[0].Limit, 0)
+			require.InDelta(t, 60, exceeded[0].Used, 0)
+
+			messages := sess.GetAllMessages()
+			require.Equal(t, tt.expected, messages[len(messages)-1].Message.Content)
+			// The extension is kept for the next messages
+			require.Equal(t, tt.maxTokensTotal, sess.MaxTokensTotal)
+		})
+	}
+}
+
+func TestRunStream_BudgetExceededStopsOnCancel(t *testing.T) {
+	stream := newStreamBuilder().
+		AddToolCallName("call_1", "test_tool").
+		AddToolCallArguments("call_1", "{}").
+		AddStopWithUsage(40, 20).
+		Build()
+	stream.responses[len(stream.responses)-1].Choices[0].FinishReason = chat.FinishReasonToolCalls
+	testTool := tools.Tool{
+		Name:        "test_tool",
+		Parameters:  map[string]any{},
+		Annotations: tools.ToolAnnotations{ReadOnlyHint: true},
+		Handler: func(context.Context, tools.ToolCall) (*tools.ToolCallResult, error) {
+			return &tools.ToolCallResult{Output: "ok"}, nil
+		},
+	}
+	root := agent.New("root", "You are a test agent", agent.WithModel(&mockProvider{id: "test/mock-model", stream: stream}), agent.WithTools(testTool))
+	tm := team.New(team.WithAgents(root))
+
+	rt, err := New(tm, WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Start"), session.WithMaxTokensTotal(50))
+	sess.Title = "Unit Test"
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	var exceeded bool
+	for ev := range rt.RunStream(ctx, sess) {
+		if _, ok := ev.(*BudgetExceededEvent); ok {
+			exceeded = true
+			cancel()
+		}
+	}
+
+	require.True(t, exceeded)
+	require.Equal(t, 60, sess.TotalTokens)
+}
+
+type resourceToolSet struct {
+	stubToolSet
+	prompts []tools.Prompt
+	got     map[string]string
+	lists   int
+}
+
+func (s *resourceToolSet) Resources(context.Context) ([]tools.Resource, error) {
+	s.lists++
+	return []tools.Resource{{URI: "docs://readme", Name: "readme"}}, nil
+}
+
+func (s *resourceToolSet) ReadResource(_ context.Context, uri string) (string, error) {
+	return "content of " + uri, nil
+}
+
+func (s *resourceToolSet) SubscribeResource(context.Context, string) error { return nil }
+func (s *resourceToolSet) SetResourceUpdatedHandler(func(string))          {}
+
+func (s *resourceToolSet) Prompts(context.Context) ([]tools.Prompt, error) {
+	return s.prompts, nil
+}
+
+func (s *resourceToolSet) GetPrompt(_ context.Context, name string, arguments map[string]string) (string, error) {
+	s.got = arguments
+	return "prompt " + name, nil
+}
+
+func newResourceRuntime(t *testing.T, ts *resourceToolSet) *LocalRuntime {
+	t.Helper()
+
+	root := agent.New("root", "You are a test agent",
+		agent.WithModel(&mockProvider{id: "test/mock-model"}),
+		agent.WithToolSets(ts),
+		agent.WithCommands(map[string]string{"fix": "Fix the bug"}),
+	)
+	// Start the toolsets, their resources and prompts are only listed once started
+	_, err := root.Tools(t.Context())
+	require.NoError(t, err)
+
+	rt, err := New(team.New(team.WithAgents(root)), WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+	return rt
+}
+
+func TestResolveCommand(t *testing.T) {
+	t.Parallel()
+
+	ts := &resourceToolSet{prompts: []tools.Prompt{{
+		Name:      "review",
+		Arguments: []tools.PromptArgument{{Name: "file", Required: true}, {Name: "focus"}},
+	}}}
+	rt := newResourceRuntime(t, ts)
+
+	resolved, err := ResolveCommand(t.Context(), rt, "/fix quickly")
+	require.NoError(t, err)
+	require.Equal(t, "Fix the bug quickly", resolved)
+
+	resolved, err = ResolveCommand(t.Context(), rt, "/review main.go the error handling")
+	require.NoError(t, err)
+	require.Equal(t, "prompt review", resolved)
+	require.Equal(t, map[string]string{"file": "main.go", "focus": "the error handling"}, ts.got)
+
+	_, err = ResolveCommand(t.Context(), rt, "/review")
+	require.ErrorContains(t, err, "usage: /review <file> [focus]")
+
+	resolved, err = ResolveCommand(t.Context(), rt, "/unknown")
+	require.NoError(t, err)
+	require.Equal(t, "/unknown", resolved)
+}
+
+func TestHandleReadResource(t *testing.T) {
+	t.Parallel()
+
+	ts := &resourceToolSet{}
+	rt := newResourceRuntime(t, ts)
+	sess := session.New()
+
+	res, err := rt.handleReadResource(t.Context(), sess, tools.ToolCall{Function: tools.FunctionCall{Arguments: `{"uri":"docs://readme"}`}}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "content of docs://readme", res.Output)
+	require.Equal(t, 1, ts.lists)
+
+	require.Equal(t, []tools.Resource{{URI: "docs://readme", Name: "readme"}}, rt.CurrentAgentResources(t.Context()))
+	require.Equal(t, 2, ts.lists)
+
+	res, err = rt.handleReadResource(t.Context(), sess, tools.ToolCall{Function: tools.FunctionCall{Arguments: `{"uri":"docs://readme"}`}}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "content of docs://readme", res.Output)
+
+	_, err = rt.handleReadResource(t.Context(), sess, tools.ToolCall{Function: tools.FunctionCall{Arguments: `{"uri":"docs://missing"}`}}, nil)
+	require.ErrorContains(t, err, "resource docs://missing not found")
+	require.Equal(t, 2, ts.lists)
+}
+
+func TestProcessToolCalls_RuntimeToolTitles(t *testing.T) {
+	t.Parallel()
+
+	rt := newResourceRuntime(t, &resourceToolSet{})
+	rt.registerDefaultTools()
+	sess := session.New(session.WithToolsApproved(true))
+
+	readResource, err := builtin.NewReadResourceTool([]tools.Resource{{URI: "docs://readme", Name: "readme"}}).Tools(t.Context())
+	require.NoError(t, err)
+
+	calls := []tools.ToolCall{{
+		ID:       "call_1",
+		Type:     "function",
+		Function: tools.FunctionCall{Name: builtin.ToolNameReadResource, Arguments: `{"uri":"docs://readme"}`},
+	}}
+	events := make(chan Event, 10)
+	rt.processToolCalls(t.Context(), sess, calls, readResource, events)
+	close(events)
+
+	var titles []string
+	for event := range events {
+		switch e := event.(type) {
+		case *ToolCallEvent:
+			titles = append(titles, e.ToolDefinition.DisplayName())
+		case *ToolCallResponseEvent:
+			titles = append(titles, e.ToolDefinition.DisplayName())
+		}
+	}
+	require.Equal(t, []string{"Read Resource", "Read Resource"}, titles)
+}
+
+func TestNewKeepsToolOutputsInTheSession(t *testing.T) {
+	t.Parallel()
+
+	rt, err := New(team.New(team.WithAgents(agent.New("root", "You are a test agent"))), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+
+	// Without a store, large outputs would be left behind once the session is gone
+	largeOutput := strings.Repeat("x", defaultMaxToolOutputSize+1)
+	require.Equal(t, largeOutput, rt.spillToolOutput(session.New(), tools.Tool{Name: "search"}, largeOutput))
+}
+
+func TestHandleTaskTransfer(t *testing.T) {
+	t.Parallel()
+
+	stream := newStreamBuilder().AddContent("done").AddStopWithUsage(1, 1).Build()
+	worker := agent.New("worker", "You are a worker", agent.WithModel(&mockProvider{id: "test/mock-model", stream: stream}))
+	root := agent.New("root", "You are a test agent", agent.WithSubAgents(worker))
+
+	rt, err := New(team.New(team.WithAgents(root, worker)), WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Delegate"))
+	events := make(chan Event)
+	go func() {
+		for range events {
+		}
+	}()
+	defer close(events)
+
+	res, err := rt.handleTaskTransfer(t.Context(), sess, tools.ToolCall{Function: tools.FunctionCall{Arguments: `{"agent":"worker","task":"work"}`}}, events)
+	require.NoError(t, err)
+	require.Equal(t, "done", res.Output)
+
+	subSession := sess.Messages[len(sess.Messages)-1].SubSession
+	require.NotNil(t, subSession)
+	// The tool outputs of the sub-session are deleted with the session
+	require.Equal(t, sess.ID, subSession.ToolOutputsKey())
+}
+
+func TestHandleReadToolOutput_MultiByteCharacters(t *testing.T) {
+	t.Parallel()
+
+	store := artifacts.NewStore(t.TempDir())
+	rt, err := New(team.New(team.WithAgents(agent.New("root", "You are a test agent"))), WithModelStore(mockModelStore{}), WithToolOutputStore(store))
+	require.NoError(t, err)
+
+	sess := session.New()
+	handle, err := store.Save(sess.ToolOutputsKey(), "héé")
+	require.NoError(t, err)
+
+	// The offset is in the middle of the first é, the range starts with it
+	res, err := rt.handleReadToolOutput(t.Context(), sess, tools.ToolCall{Function: tools.FunctionCall{Arguments: fmt.Sprintf(`{"handle":%q,"offset":2,"length":1}`, handle)}}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "é\n\n[Showing bytes 1-3 of 5. Use offset=3 to read more.]", res.Output)
+}
+
+func TestSubSessionBudget(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name                            string
+		agentBudget, parentBudget, used float64
+		expected                        float64
+		exhausted                       bool
+	}{
+		{name: "no budgets", expected: 0},
+		{name: "agent budget", agentBudget: 2, expected: 2},
+		{name: "remaining budget of the parent", parentBudget: 5, used: 4, expected: 1},
+		{name: "lower of the two", agentBudget: 2, parentBudget: 5, used: 1, expected: 2},
+		{name: "parent budget used up", agentBudget: 2, parentBudget: 5, used: 5, exhausted: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			budget, exhausted := subSessionBudget(tt.agentBudget, tt.parentBudget, tt.used)
+			require.InDelta(t, tt.expected, budget, 0)
+			require.Equal(t, tt.exhausted, exhausted)
+		})
+	}
+}
+
+func TestHandleTaskTransfer_BudgetUsedUp(t *testing.T) {
+	t.Parallel()
+
+	worker := agent.New("worker", "You are a worker", agent.WithModel(&mockProvider{id: "test/mock-model"}))
+	root := agent.New("root", "You are a test agent", agent.WithSubAgents(worker))
+
+	rt, err := New(team.New(team.WithAgents(root, worker)), WithSessionCompaction(false), WithModelStore(mockModelStore{}))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Delegate"), session.WithMaxTokensTotal(50))
+	sess.TotalTokens = 60
+
+	res, err := rt.handleTaskTransfer(t.Context(), sess, tools.ToolCall{Function: tools.FunctionCall{Arguments: `{"agent":"worker","task":"work"}`}}, nil)
+	require.NoError(t, err)
+	require.Contains(t, res.Output, "budget of the session is used up")
+	require.Len(t, sess.Messages, 1, "no sub-session is started")
+}
@@ -0,0 +1,122 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rumpl/rb/pkg/artifacts"
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/tools"
+	"github.com/rumpl/rb/pkg/tools/builtin"
+)
+
+const (
+	// defaultMaxToolOutputSize is the size, in bytes, above which tool outputs
+	// are saved to the artifact store instead of the session.
+	defaultMaxToolOutputSize = 32 * 1024
+	// toolOutputPreviewSize is the size of the beginning and of the end of a
+	// saved tool output that are kept in the session.
+	toolOutputPreviewSize = 2 * 1024
+	// defaultReadToolOutputLength is how much of a saved tool output
+	// read_tool_output returns when no length is given.
+	defaultReadToolOutputLength = 16 * 1024
+
+	spilledToolOutputHint = "use the " + builtin.ToolNameReadToolOutput + " tool to read it"
+)
+
+// spillToolOutput saves outputs larger than the tool's maximum output size to the
+// artifact store and returns a preview of the output with the handle to read the rest.
+func (r *LocalRuntime) spillToolOutput(sess *session.Session, tool tools.Tool, output string) string {
+	maxSize := tool.MaxOutputSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxToolOutputSize
+	}
+	if r.toolOutputs == nil || len(output) <= maxSize {
+		return output
+	}
+
+	handle, err := r.toolOutputs.Save(sess.ToolOutputsKey(), output)
+	if err != nil {
+		slog.Warn("Failed to save large tool output, keeping it in the session", "tool", tool.Name, "session_id", sess.ID, "error", err)
+		return output
+	}
+	slog.Debug("Saved large tool output", "tool", tool.Name, "session_id", sess.ID, "handle", handle, "size", len(output))
+
+	previewSize := min(toolOutputPreviewSize, maxSize/4)
+	head := runeBoundary(output, previewSize)
+	tail := runeBoundary(output, len(output)-previewSize)
+
+	return fmt.Sprintf("%s\n\n[... %d of %d bytes omitted. The full output was saved with handle %q, %s ...]\n\n%s",
+		output[:head], tail-head, len(output), handle, spilledToolOutputHint, output[tail:])
+}
+
+// hasSpilledToolOutputs returns true if some tool outputs of the session were
+// saved to the artifact store, in which case the agent needs read_tool_output.
+func hasSpilledToolOutputs(sess *session.Session) bool {
+	for _, item := range sess.Messages {
+		if item.IsMessage() && item.Message.Message.Role == chat.MessageRoleTool && strings.Contains(item.Message.Message.Content, spilledToolOutputHint) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleReadToolOutput returns a part of a tool output saved by spillToolOutput
+func (r *LocalRuntime) handleReadToolOutput(_ context.Context, sess *session.Session, toolCall tools.ToolCall, _ chan Event) (*tools.ToolCallResult, error) {
+	var params builtin.ReadToolOutputArgs
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &params); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if r.toolOutputs == nil {
+		return &tools.ToolCallResult{Output: "Tool outputs are not saved in this session."}, nil
+	}
+
+	output, err := r.toolOutputs.Read(sess.ToolOutputsKey(), params.Handle)
+	if errors.Is(err, artifacts.ErrNotFound) {
+		return &tools.ToolCallResult{Output: fmt.Sprintf("No tool output found with handle %q.", params.Handle)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Offset < 0 || params.Offset >= len(output) {
+		return &tools.ToolCallResult{Output: fmt.Sprintf("Offset %d is out of range, the tool output is %d bytes long.", params.Offset, len(output))}, nil
+	}
+
+	length := params.Length
+	if length <= 0 {
+		length = defaultReadToolOutputLength
+	}
+	length = min(length, defaultMaxToolOutputSize)
+
+	// Offsets are in bytes, a range never splits a UTF-8 sequence
+	start := runeBoundary(output, params.Offset)
+	end := runeBoundary(output, min(start+length, len(output)))
+	if end <= start {
+		// Always return at least one character
+		_, size := utf8.DecodeRuneInString(output[start:])
+		end = start + size
+	}
+
+	chunk := output[start:end]
+	if end < len(output) {
+		chunk += fmt.Sprintf("\n\n[Showing bytes %d-%d of %d. Use offset=%d to read more.]", start, end, len(output), end)
+	}
+
+	return &tools.ToolCallResult{Output: chunk}, nil
+}
+
+// runeBoundary moves i back to the start of the UTF-8 sequence it's in
+func runeBoundary(s string, i int) int {
+	for i > 0 && i < len(s) && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
@@ -0,0 +1,128 @@
+// Package sandbox runs commands with a read-only view of the filesystem, except
+// for a few writable paths, without network access and with only the basic
+// variables of rb's environment.
+//
+// Sandboxed commands are started through the rb binary itself: Wrap rewrites a
+// command so that rb is re-executed with ExecArg in new namespaces, and Main sets
+// up the mounts and drops its capabilities before replacing itself with the
+// original command.
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// ExecArg is the first argument rb is re-executed with to run a sandboxed command
+const ExecArg = "__sandbox_exec"
+
+// Config describes what sandboxed commands have access to
+type Config struct {
+	// Writable are the paths that are mounted read-write, usually the working
+	// directory. The rest of the filesystem is read-only, except for /tmp which
+	// is replaced by an empty directory.
+	Writable []string `json:"writable,omitempty"`
+	// Network gives access to the host's network.
+	Network bool `json:"network,omitempty"`
+}
+
+// environ are the variables of rb's environment that sandboxed commands inherit,
+// the other variables may hold credentials
+var environ = []string{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "COLORTERM", "LANG", "LANGUAGE", "TZ"}
+
+// Environ returns the variables of rb's environment that sandboxed commands
+// can see: the basics of a shell session and the locale settings.
+func Environ() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if slices.Contains(environ, name) || strings.HasPrefix(name, "LC_") {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// Wrap changes cmd so that it runs in the sandbox. It must be called once the
+// command is fully configured, just before it's started. A command without
+// an environment gets Environ instead of the whole environment of rb.
+func Wrap(cmd *exec.Cmd, cfg Config) error {
+	if err := supported(); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("finding the rb executable: %w", err)
+	}
+
+	writable := make([]string, len(cfg.Writable))
+	for i, path := range cfg.Writable {
+		if writable[i], err = filepath.Abs(path); err != nil {
+			return err
+		}
+	}
+	cfg.Writable = writable
+	config, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	args := cmd.Args
+	if len(args) == 0 {
+		args = []string{cmd.Path}
+	}
+	cmd.Args = append([]string{exe, ExecArg, string(config), cmd.Path}, args[1:]...)
+	cmd.Path = exe
+	if cmd.Env == nil {
+		cmd.Env = Environ()
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	setNamespaces(cmd.SysProcAttr, cfg)
+
+	return nil
+}
+
+// Main sets up the sandbox and runs the command given in args, as prepared by Wrap.
+// It only returns if the command couldn't be started.
+func Main(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: " + ExecArg + " <config> <command> [args...]")
+	}
+
+	var cfg Config
+	if err := json.Unmarshal([]byte(args[0]), &cfg); err != nil {
+		return fmt.Errorf("invalid sandbox config: %w", err)
+	}
+
+	// The working directory needs to be looked up again once the mounts have changed
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := setup(cfg); err != nil {
+		return fmt.Errorf("setting up the sandbox: %w", err)
+	}
+	if err := os.Chdir(wd); err != nil {
+		return err
+	}
+
+	// The command is executed from the thread that dropped its privileges
+	runtime.LockOSThread()
+	if err := dropPrivileges(); err != nil {
+		return fmt.Errorf("dropping privileges: %w", err)
+	}
+
+	return syscall.Exec(args[1], args[1:], os.Environ())
+}
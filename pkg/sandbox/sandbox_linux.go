@@ -0,0 +1,104 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func supported() error {
+	return nil
+}
+
+// setNamespaces runs the command in new user and mount namespaces, and in a new
+// network namespace, with only a loopback interface, unless network is allowed.
+func setNamespaces(attr *syscall.SysProcAttr, cfg Config) {
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS
+	if !cfg.Network {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+
+	// Keep the same user and group so that written files have the right owner
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+}
+
+// setup makes the whole filesystem read-only, except for the writable paths,
+// and mounts an empty /tmp. It runs in the new mount namespace.
+func setup(cfg Config) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("making mounts private: %w", err)
+	}
+
+	// Copy the writable paths before making everything read-only
+	var trees []int
+	defer func() {
+		for _, fd := range trees {
+			_ = unix.Close(fd)
+		}
+	}()
+	for _, path := range cfg.Writable {
+		fd, err := unix.OpenTree(unix.AT_FDCWD, path, unix.OPEN_TREE_CLONE|unix.OPEN_TREE_CLOEXEC|unix.AT_RECURSIVE)
+		if err != nil {
+			return fmt.Errorf("copying mount of %s: %w", path, err)
+		}
+		trees = append(trees, fd)
+	}
+
+	if err := unix.MountSetattr(unix.AT_FDCWD, "/", unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY}); err != nil {
+		return fmt.Errorf("making the filesystem read-only: %w", err)
+	}
+
+	if err := unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("mounting /tmp: %w", err)
+	}
+
+	for i, path := range cfg.Writable {
+		// Writable paths in /tmp need to be created in the new tmpfs
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			return err
+		}
+		if err := unix.MoveMount(trees[i], "", unix.AT_FDCWD, path, unix.MOVE_MOUNT_F_EMPTY_PATH); err != nil {
+			return fmt.Errorf("mounting %s read-write: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// dropPrivileges clears all the capabilities the command would otherwise have in
+// the new user namespace, so that it can't undo the mounts, and prevents it from
+// gaining new ones, e.g. with setuid binaries. Capabilities belong to threads:
+// the caller must be locked to its thread and exec the command from it.
+func dropPrivileges() error {
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("setting no_new_privs: %w", err)
+	}
+
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("clearing the ambient capabilities: %w", err)
+	}
+
+	// The kernel rejects the capabilities it doesn't know about with EINVAL
+	for capability := 0; ; capability++ {
+		err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(capability), 0, 0, 0)
+		if errors.Is(err, unix.EINVAL) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("dropping capability %d from the bounding set: %w", capability, err)
+		}
+	}
+
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capset(&header, &data[0]); err != nil {
+		return fmt.Errorf("clearing the capabilities: %w", err)
+	}
+
+	return nil
+}
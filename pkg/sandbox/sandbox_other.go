@@ -0,0 +1,22 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"syscall"
+)
+
+func supported() error {
+	return errors.New("sandboxing commands is only supported on Linux")
+}
+
+func setNamespaces(*syscall.SysProcAttr, Config) {}
+
+func setup(Config) error {
+	return supported()
+}
+
+func dropPrivileges() error {
+	return supported()
+}
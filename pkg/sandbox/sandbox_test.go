@@ -0,0 +1,153 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain lets the test binary set up the sandbox, as rb does, when it's re-executed by Wrap
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == ExecArg {
+		err := Main(os.Args[2:])
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(126)
+	}
+	os.Exit(m.Run())
+}
+
+func runSandboxed(t *testing.T, cfg Config, dir, script string) (string, error) {
+	t.Helper()
+
+	cmd := exec.Command("/bin/sh", "-c", script)
+	cmd.Dir = dir
+	require.NoError(t, Wrap(cmd, cfg))
+
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+func skipIfUnsupported(t *testing.T) {
+	t.Helper()
+
+	if runtime.GOOS != "linux" {
+		t.Skip("sandboxing is only supported on Linux")
+	}
+	if output, err := runSandboxed(t, Config{}, "/", "true"); err != nil {
+		t.Skipf("user namespaces are not available: %s", output)
+	}
+}
+
+func TestWrap_FilesystemIsReadOnlyExceptWritablePaths(t *testing.T) {
+	skipIfUnsupported(t)
+
+	workingDir := t.TempDir()
+	// Paths in /tmp are hidden by the sandbox's own /tmp, use a directory outside of it
+	other, err := os.Getwd()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.Remove(filepath.Join(other, "file.txt")) })
+
+	output, err := runSandboxed(t, Config{Writable: []string{workingDir}}, workingDir, "pwd && echo hello > file.txt && cat file.txt")
+	require.NoError(t, err, output)
+	assert.Equal(t, workingDir+"\nhello\n", output)
+	assert.FileExists(t, filepath.Join(workingDir, "file.txt"))
+
+	output, err = runSandboxed(t, Config{Writable: []string{workingDir}}, workingDir, "echo hello > "+filepath.Join(other, "file.txt"))
+	require.Error(t, err)
+	assert.Contains(t, output, "Read-only file system")
+	assert.NoFileExists(t, filepath.Join(other, "file.txt"))
+
+	output, err = runSandboxed(t, Config{Writable: []string{workingDir}}, workingDir, "echo hello > /tmp/scratch && cat /tmp/scratch")
+	require.NoError(t, err, output)
+	assert.Equal(t, "hello\n", output)
+}
+
+func TestWrap_CannotRemountReadWrite(t *testing.T) {
+	skipIfUnsupported(t)
+	if _, err := exec.LookPath("mount"); err != nil {
+		t.Skip("mount is not installed")
+	}
+
+	workingDir := t.TempDir()
+	other, err := os.Getwd()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.Remove(filepath.Join(other, "file.txt")) })
+
+	output, err := runSandboxed(t, Config{Writable: []string{workingDir}}, workingDir, "grep -E '^(CapEff|CapPrm|CapBnd|CapAmb|NoNewPrivs)' /proc/self/status")
+	require.NoError(t, err, output)
+	assert.Regexp(t, `CapPrm:\s+0+\n`, output)
+	assert.Regexp(t, `CapEff:\s+0+\n`, output)
+	assert.Regexp(t, `CapBnd:\s+0+\n`, output)
+	assert.Regexp(t, `CapAmb:\s+0+\n`, output)
+	assert.Regexp(t, `NoNewPrivs:\s+1\n`, output)
+
+	output, err = runSandboxed(t, Config{Writable: []string{workingDir}}, workingDir, "mount -o remount,bind,rw /")
+	require.Error(t, err, output)
+
+	output, err = runSandboxed(t, Config{Writable: []string{workingDir}}, workingDir, "mount -o remount,bind,rw / ; mount -o remount,bind,rw "+other+" ; echo hello > "+filepath.Join(other, "file.txt"))
+	require.Error(t, err, output)
+	assert.NoFileExists(t, filepath.Join(other, "file.txt"))
+}
+
+func TestWrap_Network(t *testing.T) {
+	skipIfUnsupported(t)
+
+	output, err := runSandboxed(t, Config{}, "/", "cat /proc/net/dev")
+	require.NoError(t, err, output)
+	assert.Contains(t, output, "lo:")
+	assert.Equal(t, 1, countInterfaces(output))
+
+	output, err = runSandboxed(t, Config{Network: true}, "/", "cat /proc/net/dev")
+	require.NoError(t, err, output)
+	hostOutput, err := os.ReadFile("/proc/net/dev")
+	require.NoError(t, err)
+	assert.Equal(t, countInterfaces(string(hostOutput)), countInterfaces(output))
+}
+
+func TestWrap_ExitCode(t *testing.T) {
+	skipIfUnsupported(t)
+
+	_, err := runSandboxed(t, Config{}, "/", "exit 3")
+
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 3, exitErr.ExitCode())
+}
+
+func TestEnviron(t *testing.T) {
+	t.Setenv("RB_TEST_SECRET", "secret")
+	t.Setenv("LC_ALL", "C")
+	t.Setenv("PATH", "/usr/bin:/bin")
+
+	env := Environ()
+	assert.Contains(t, env, "LC_ALL=C")
+	assert.Contains(t, env, "PATH=/usr/bin:/bin")
+	assert.NotContains(t, env, "RB_TEST_SECRET=secret")
+}
+
+func TestWrap_Environment(t *testing.T) {
+	skipIfUnsupported(t)
+	t.Setenv("RB_TEST_SECRET", "secret")
+
+	output, err := runSandboxed(t, Config{}, "/", "echo \"secret=$RB_TEST_SECRET\"")
+	require.NoError(t, err, output)
+	assert.Equal(t, "secret=\n", output)
+}
+
+// countInterfaces counts the network interfaces listed in /proc/net/dev
+func countInterfaces(procNetDev string) int {
+	count := 0
+	for _, line := range strings.Split(procNetDev, "\n") {
+		if strings.Contains(line, ":") {
+			count++
+		}
+	}
+	return count
+}
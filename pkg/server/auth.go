@@ -0,0 +1,81 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/rumpl/rb/pkg/auth"
+	"github.com/rumpl/rb/pkg/session"
+)
+
+// authorize authenticates the caller and checks that it was granted one of the
+// scopes. It lets everything through when the server has no authenticator.
+func (s *Server) authorize(scopes ...auth.Scope) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if s.authenticator == nil {
+				return next(c)
+			}
+
+			token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !ok || token == "" {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing bearer token")
+			}
+
+			ctx := c.Request().Context()
+			principal, err := s.authenticator.Authenticate(ctx, token)
+			if err != nil {
+				if !errors.Is(err, auth.ErrInvalidToken) {
+					slog.Error("Failed to authenticate request", "error", err)
+					return echo.NewHTTPError(http.StatusInternalServerError, "failed to authenticate")
+				}
+				slog.Debug("Rejected API token", "error", err)
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
+			}
+
+			allowed := false
+			for _, scope := range scopes {
+				allowed = allowed || principal.HasScope(scope)
+			}
+			if !allowed {
+				return echo.NewHTTPError(http.StatusForbidden, "insufficient scope")
+			}
+
+			c.SetRequest(c.Request().WithContext(auth.WithPrincipal(ctx, principal)))
+			return next(c)
+		}
+	}
+}
+
+// canAccess returns true if the caller owns the session, or if authentication is disabled
+func canAccess(c echo.Context, sess *session.Session) bool {
+	principal := auth.PrincipalFromContext(c.Request().Context())
+	return principal == nil || sess.Owner == principal.Subject
+}
+
+// getOwnedSession returns a session of the caller. Sessions of other callers
+// are reported as not found so that their IDs can't be probed.
+func (s *Server) getOwnedSession(c echo.Context, id string) (*session.Session, error) {
+	sess, err := s.sessionStore.GetSession(c.Request().Context(), id)
+	if err != nil || !canAccess(c, sess) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "session not found")
+	}
+	return sess, nil
+}
+
+// ownedSessions filters out the sessions of other callers
+func ownedSessions(c echo.Context, sessions []*session.Session) []*session.Session {
+	owned := make([]*session.Session, 0, len(sessions))
+	for _, sess := range sessions {
+		if canAccess(c, sess) {
+			owned = append(owned, sess)
+		}
+	}
+	return owned
+}
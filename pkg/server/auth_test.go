@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/api"
+	"github.com/rumpl/rb/pkg/auth"
+	"github.com/rumpl/rb/pkg/session"
+)
+
+type fakeAuthenticator map[string]*auth.Principal
+
+func (a fakeAuthenticator) Authenticate(_ context.Context, token string) (*auth.Principal, error) {
+	if principal, ok := a[token]; ok {
+		return principal, nil
+	}
+	return nil, auth.ErrInvalidToken
+}
+
+func TestServer_Authentication(t *testing.T) {
+	t.Parallel()
+
+	store, err := session.NewSQLiteSessionStore(filepath.Join(t.TempDir(), "sessions.db"))
+	require.NoError(t, err)
+
+	authenticator := fakeAuthenticator{
+		"alice":  {Subject: "alice", Scopes: []auth.Scope{auth.ScopeReadSessions, auth.ScopeRunAgents}},
+		"bob":    {Subject: "bob", Scopes: []auth.Scope{auth.ScopeReadSessions, auth.ScopeRunAgents}},
+		"reader": {Subject: "alice", Scopes: []auth.Scope{auth.ScopeReadSessions}},
+	}
+
+	ctx := t.Context()
+	lnPath := startServerWithStore(t, ctx, prepareAgentsDir(t, "pirate.yaml"), store, WithAuthenticator(authenticator))
+
+	status, _ := authDo(t, ctx, http.MethodGet, lnPath, "/api/ping", "", nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = authDo(t, ctx, http.MethodGet, lnPath, "/api/sessions", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = authDo(t, ctx, http.MethodGet, lnPath, "/api/sessions", "unknown", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// Scopes
+	status, _ = authDo(t, ctx, http.MethodGet, lnPath, "/api/agents", "alice", nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = authDo(t, ctx, http.MethodPut, lnPath, "/api/agents/pirate/yaml", "alice", "version: 2")
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = authDo(t, ctx, http.MethodPost, lnPath, "/api/sessions", "reader", session.Session{})
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// Sessions are owned by their creator
+	status, buf := authDo(t, ctx, http.MethodPost, lnPath, "/api/sessions", "alice", session.Session{})
+	require.Equal(t, http.StatusOK, status)
+	var aliceSession session.Session
+	unmarshal(t, buf, &aliceSession)
+	assert.Equal(t, "alice", aliceSession.Owner)
+
+	status, buf = authDo(t, ctx, http.MethodPost, lnPath, "/api/sessions", "bob", session.Session{})
+	require.Equal(t, http.StatusOK, status)
+	var bobSession session.Session
+	unmarshal(t, buf, &bobSession)
+
+	status, buf = authDo(t, ctx, http.MethodGet, lnPath, "/api/sessions", "reader", nil)
+	require.Equal(t, http.StatusOK, status)
+	var sessions []api.SessionsResponse
+	unmarshal(t, buf, &sessions)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, aliceSession.ID, sessions[0].ID)
+
+	aliceReport := session.New(session.WithTitle("Quarterly report"), session.WithOwner("alice"))
+	require.NoError(t, store.AddSession(ctx, aliceReport))
+	require.NoError(t, store.AddSession(ctx, session.New(session.WithTitle("Quarterly report"), session.WithOwner("bob"))))
+	status, buf = authDo(t, ctx, http.MethodGet, lnPath, "/api/sessions/search?q=report", "reader", nil)
+	require.Equal(t, http.StatusOK, status)
+	var results []api.SessionSearchResponse
+	unmarshal(t, buf, &results)
+	require.Len(t, results, 1)
+	assert.Equal(t, aliceReport.ID, results[0].ID)
+
+	status, _ = authDo(t, ctx, http.MethodGet, lnPath, "/api/sessions/"+aliceSession.ID, "alice", nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = authDo(t, ctx, http.MethodGet, lnPath, "/api/sessions/"+bobSession.ID, "alice", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = authDo(t, ctx, http.MethodDelete, lnPath, "/api/sessions/"+bobSession.ID, "alice", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = authDo(t, ctx, http.MethodPost, lnPath, "/api/sessions/"+bobSession.ID+"/agent/pirate", "alice", []api.Message{{Content: "hi"}})
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func authDo(t *testing.T, ctx context.Context, method, socketPath, path, token string, payload any) (int, []byte) {
+	t.Helper()
+
+	var body io.Reader = http.NoBody
+	if payload != nil {
+		buf, err := json.Marshal(payload)
+		require.NoError(t, err)
+		body = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "http://_"+path, body)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", strings.TrimPrefix(socketPath, "unix://"))
+			},
+		},
+	}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	buf, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp.StatusCode, buf
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -24,6 +25,7 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 
 	"github.com/rumpl/rb/pkg/api"
+	"github.com/rumpl/rb/pkg/auth"
 	"github.com/rumpl/rb/pkg/config"
 	latest "github.com/rumpl/rb/pkg/config/v2"
 	"github.com/rumpl/rb/pkg/content"
@@ -34,6 +36,7 @@ import (
 	"github.com/rumpl/rb/pkg/remote"
 	"github.com/rumpl/rb/pkg/runtime"
 	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/session/export"
 	"github.com/rumpl/rb/pkg/team"
 	"github.com/rumpl/rb/pkg/teamloader"
 )
@@ -49,6 +52,7 @@ type Server struct {
 	teamsMu        sync.RWMutex
 	agentsDir      string
 	rootFS         *os.Root
+	authenticator  auth.Authenticator
 }
 
 type Opt func(*Server) error
@@ -66,6 +70,15 @@ func WithAgentsDir(dir string) Opt {
 	}
 }
 
+// WithAuthenticator requires API callers to authenticate with a bearer token.
+// Callers only see the sessions they created and the routes their scopes allow.
+func WithAuthenticator(authenticator auth.Authenticator) Opt {
+	return func(s *Server) error {
+		s.authenticator = authenticator
+		return nil
+	}
+}
+
 func New(sessionStore session.Store, runConfig config.RuntimeConfig, teams map[string]*team.Team, opts ...Opt) (*Server, error) {
 	e := echo.New()
 	e.Use(middleware.CORS())
@@ -92,52 +105,65 @@ func New(sessionStore session.Store, runConfig config.RuntimeConfig, teams map[s
 
 	group := e.Group("/api")
 
+	readAgents := s.authorize(auth.ScopeRunAgents, auth.ScopeEditAgents)
+	editAgents := s.authorize(auth.ScopeEditAgents)
+	readSessions := s.authorize(auth.ScopeReadSessions)
+	runAgents := s.authorize(auth.ScopeRunAgents)
+
 	// Health check endpoint
 	group.GET("/ping", s.ping)
 	// List all available agents
-	group.GET("/agents", s.getAgents)
+	group.GET("/agents", s.getAgents, readAgents)
 	// Get an agent by id
-	group.GET("/agents/:id", s.getAgentConfig)
+	group.GET("/agents/:id", s.getAgentConfig, readAgents)
 	// Get an agent's raw YAML configuration by id
-	group.GET("/agents/:id/yaml", s.getAgentConfigYAML)
+	group.GET("/agents/:id/yaml", s.getAgentConfigYAML, readAgents)
 	// Edit an agent's raw YAML configuration by id
-	group.PUT("/agents/:id/yaml", s.editAgentConfigYAML)
+	group.PUT("/agents/:id/yaml", s.editAgentConfigYAML, editAgents)
 	// Edit an agent configuration by id
-	group.PUT("/agents/config", s.editAgentConfig)
+	group.PUT("/agents/config", s.editAgentConfig, editAgents)
 	// Create a new agent
-	group.POST("/agents", s.createAgent)
+	group.POST("/agents", s.createAgent, editAgents)
 	// Create a new agent manually with YAML configuration
-	group.POST("/agents/config", s.createAgentConfig)
+	group.POST("/agents/config", s.createAgentConfig, editAgents)
 	// Import an agent from a file path
-	group.POST("/agents/import", s.importAgent)
+	group.POST("/agents/import", s.importAgent, editAgents)
 	// Export multiple agents as a zip file
-	group.POST("/agents/export", s.exportAgents)
+	group.POST("/agents/export", s.exportAgents, editAgents)
 	// Pull an agent from a remote registry
-	group.POST("/agents/pull", s.pullAgent)
+	group.POST("/agents/pull", s.pullAgent, editAgents)
 	// Push an agent to a remote registry
-	group.POST("/agents/push", s.pushAgent)
+	group.POST("/agents/push", s.pushAgent, editAgents)
 	// Delete an agent by file path
-	group.DELETE("/agents", s.deleteAgent)
+	group.DELETE("/agents", s.deleteAgent, editAgents)
 	// List all sessions
-	group.GET("/sessions", s.getSessions)
+	group.GET("/sessions", s.getSessions, readSessions)
+	// Search sessions
+	group.GET("/sessions/search", s.searchSessions, readSessions)
 	// Get sessions by agent filename
-	group.GET("/sessions/agent/:id", s.getSessionsByAgent)
+	group.GET("/sessions/agent/:id", s.getSessionsByAgent, readSessions)
 	// Get a session by id
-	group.GET("/sessions/:id", s.getSession)
+	group.GET("/sessions/:id", s.getSession, readSessions)
 	// Resume a session by id
-	group.POST("/sessions/:id/resume", s.resumeSession)
+	group.POST("/sessions/:id/resume", s.resumeSession, runAgents)
+	// Fork a session into a new session
+	group.POST("/sessions/:id/fork", s.forkSession, runAgents)
+	// Export a session as JSON, Markdown or HTML
+	group.GET("/sessions/:id/export", s.exportSession, readSessions)
+	// Import a session exported as JSON
+	group.POST("/sessions/import", s.importSession, runAgents)
 	// Create a new session and run an agent loop
-	group.POST("/sessions", s.createSession)
+	group.POST("/sessions", s.createSession, runAgents)
 	// Delete a session
-	group.DELETE("/sessions/:id", s.deleteSession)
+	group.DELETE("/sessions/:id", s.deleteSession, runAgents)
 
 	// Run an agent loop
-	group.POST("/sessions/:id/agent/:agent", s.runAgent)
-	group.POST("/sessions/:id/agent/:agent/:agent_name", s.runAgent)
+	group.POST("/sessions/:id/agent/:agent", s.runAgent, runAgents)
+	group.POST("/sessions/:id/agent/:agent/:agent_name", s.runAgent, runAgents)
 
-	group.POST("/sessions/:id/elicitation", s.elicitation)
+	group.POST("/sessions/:id/elicitation", s.elicitation, runAgents)
 
-	group.GET("/desktop/token", s.getDesktopToken)
+	group.GET("/desktop/token", s.getDesktopToken, editAgents)
 
 	return s, nil
 }
@@ -941,19 +967,11 @@ func (s *Server) getSessions(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get sessions")
 	}
+	sessions = ownedSessions(c, sessions)
 
 	responses := make([]api.SessionsResponse, len(sessions))
 	for i, sess := range sessions {
-		responses[i] = api.SessionsResponse{
-			ID:                         sess.ID,
-			Title:                      sess.Title,
-			CreatedAt:                  sess.CreatedAt.Format(time.RFC3339),
-			NumMessages:                len(sess.GetAllMessages()),
-			InputTokens:                sess.InputTokens,
-			OutputTokens:               sess.OutputTokens,
-			GetMostRecentAgentFilename: sess.GetMostRecentAgentFilename(),
-			WorkingDir:                 sess.WorkingDir,
-		}
+		responses[i] = sessionsResponse(sess)
 	}
 	return c.JSON(http.StatusOK, responses)
 }
@@ -968,23 +986,69 @@ func (s *Server) getSessionsByAgent(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get sessions for agent")
 	}
+	sessions = ownedSessions(c, sessions)
 
 	responses := make([]api.SessionsResponse, len(sessions))
 	for i, sess := range sessions {
-		responses[i] = api.SessionsResponse{
-			ID:                         sess.ID,
-			Title:                      sess.Title,
-			CreatedAt:                  sess.CreatedAt.Format(time.RFC3339),
-			NumMessages:                len(sess.GetAllMessages()),
-			InputTokens:                sess.InputTokens,
-			OutputTokens:               sess.OutputTokens,
-			GetMostRecentAgentFilename: sess.GetMostRecentAgentFilename(),
-			WorkingDir:                 sess.WorkingDir,
+		responses[i] = sessionsResponse(sess)
+	}
+	return c.JSON(http.StatusOK, responses)
+}
+
+func (s *Server) searchSessions(c echo.Context) error {
+	query := c.QueryParam("q")
+	if strings.TrimSpace(query) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "q parameter is required")
+	}
+
+	filters := session.SearchFilters{}
+	if principal := auth.PrincipalFromContext(c.Request().Context()); principal != nil {
+		filters.Owner = principal.Subject
+	}
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be a positive integer")
+		}
+		filters.Limit = limit
+	}
+	if sinceStr := c.QueryParam("since"); sinceStr != "" {
+		since, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "since must be an RFC 3339 date")
+		}
+		filters.Since = since
+	}
+
+	results, err := s.sessionStore.SearchSessions(c.Request().Context(), query, filters)
+	if err != nil {
+		slog.Error("Failed to search sessions", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to search sessions")
+	}
+
+	responses := make([]api.SessionSearchResponse, len(results))
+	for i, result := range results {
+		responses[i] = api.SessionSearchResponse{
+			SessionsResponse: sessionsResponse(result.Session),
+			Snippet:          result.Snippet,
 		}
 	}
 	return c.JSON(http.StatusOK, responses)
 }
 
+func sessionsResponse(sess *session.Session) api.SessionsResponse {
+	return api.SessionsResponse{
+		ID:                         sess.ID,
+		Title:                      sess.Title,
+		CreatedAt:                  sess.CreatedAt.Format(time.RFC3339),
+		NumMessages:                len(sess.GetAllMessages()),
+		InputTokens:                sess.InputTokens,
+		OutputTokens:               sess.OutputTokens,
+		GetMostRecentAgentFilename: sess.GetMostRecentAgentFilename(),
+		WorkingDir:                 sess.WorkingDir,
+	}
+}
+
 func (s *Server) createSession(c echo.Context) error {
 	var sessionTemplate session.Session
 	if err := c.Bind(&sessionTemplate); err != nil {
@@ -994,6 +1058,8 @@ func (s *Server) createSession(c echo.Context) error {
 	var opts []session.Opt
 	opts = append(opts,
 		session.WithMaxIterations(sessionTemplate.MaxIterations),
+		session.WithMaxCost(sessionTemplate.MaxCost),
+		session.WithMaxTokensTotal(sessionTemplate.MaxTokensTotal),
 		session.WithToolsApproved(sessionTemplate.ToolsApproved),
 	)
 
@@ -1015,6 +1081,10 @@ func (s *Server) createSession(c echo.Context) error {
 		opts = append(opts, session.WithWorkingDir(absWd))
 	}
 
+	if principal := auth.PrincipalFromContext(c.Request().Context()); principal != nil {
+		opts = append(opts, session.WithOwner(principal.Subject))
+	}
+
 	sess := session.New(opts...)
 
 	if err := s.sessionStore.AddSession(c.Request().Context(), sess); err != nil {
@@ -1026,9 +1096,9 @@ func (s *Server) createSession(c echo.Context) error {
 }
 
 func (s *Server) getSession(c echo.Context) error {
-	sess, err := s.sessionStore.GetSession(c.Request().Context(), c.Param("id"))
+	sess, err := s.getOwnedSession(c, c.Param("id"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "session not found")
+		return err
 	}
 
 	params := api.PaginationParams{
@@ -1072,6 +1142,10 @@ func (s *Server) resumeSession(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
 	}
 
+	if _, err := s.getOwnedSession(c, sessionID); err != nil {
+		return err
+	}
+
 	rt, exists := s.runtimes[sessionID]
 	if !exists {
 		return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("runtime not found: %s", sessionID))
@@ -1082,8 +1156,87 @@ func (s *Server) resumeSession(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"message": "session resumed"})
 }
 
+func (s *Server) forkSession(c echo.Context) error {
+	var req api.ForkSessionRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	sessionID := c.Param("id")
+	sess, err := s.getOwnedSession(c, sessionID)
+	if err != nil {
+		return err
+	}
+
+	messageIndex := len(sess.Messages) - 1
+	if req.MessageIndex != nil {
+		messageIndex = *req.MessageIndex
+	}
+
+	forked, err := s.sessionStore.ForkSession(c.Request().Context(), sessionID, messageIndex)
+	if err != nil {
+		if errors.Is(err, session.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "session not found")
+		}
+		slog.Error("Failed to fork session", "session_id", sessionID, "error", err)
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("failed to fork session: %v", err))
+	}
+
+	return c.JSON(http.StatusOK, forked)
+}
+
+func (s *Server) exportSession(c echo.Context) error {
+	format := export.FormatJSON
+	if name := c.QueryParam("format"); name != "" {
+		var err error
+		format, err = export.ParseFormat(name)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	sess, err := s.getOwnedSession(c, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := export.Write(&buf, sess, format); err != nil {
+		slog.Error("Failed to export session", "session_id", sess.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to export session")
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", sess.ID+format.Extension()))
+	return c.Blob(http.StatusOK, format.ContentType(), buf.Bytes())
+}
+
+func (s *Server) importSession(c echo.Context) error {
+	sess, err := export.Read(c.Request().Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Imported sessions belong to the caller, whoever exported them
+	if principal := auth.PrincipalFromContext(c.Request().Context()); principal != nil {
+		sess.Owner = principal.Subject
+	}
+
+	if err := export.Import(c.Request().Context(), s.sessionStore, sess); err != nil {
+		if errors.Is(err, export.ErrSessionExists) {
+			return echo.NewHTTPError(http.StatusConflict, "a session with this ID already exists")
+		}
+		slog.Error("Failed to import session", "session_id", sess.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to import session")
+	}
+
+	return c.JSON(http.StatusOK, sessionsResponse(sess))
+}
+
 func (s *Server) deleteSession(c echo.Context) error {
 	sessionID := c.Param("id")
+	if _, err := s.getOwnedSession(c, sessionID); err != nil {
+		return err
+	}
 
 	// Cancel the runtime context if it's still running
 	s.cancelsMu.Lock()
@@ -1120,9 +1273,9 @@ func (s *Server) runAgent(c echo.Context) error {
 	slog.Debug("Running agent", "agent_filename", agentFilename, "session_id", sessionID, "current_agent", currentAgent)
 
 	// Build a per-session team so Filesystem tool can be bound to session working dir
-	sess, err := s.sessionStore.GetSession(c.Request().Context(), sessionID)
+	sess, err := s.getOwnedSession(c, sessionID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, "session not found")
+		return err
 	}
 
 	p := addYamlExt(agentFilename)
@@ -1146,6 +1299,13 @@ func (s *Server) runAgent(c echo.Context) error {
 	if len(sess.Messages) == 0 && sess.MaxIterations == 0 && agent.MaxIterations() > 0 {
 		sess.MaxIterations = agent.MaxIterations()
 	}
+	// Same for the budgets
+	if len(sess.Messages) == 0 && sess.MaxCost == 0 {
+		sess.MaxCost = agent.MaxCost()
+	}
+	if len(sess.Messages) == 0 && sess.MaxTokensTotal == 0 {
+		sess.MaxTokensTotal = agent.MaxTokensTotal()
+	}
 
 	rt, exists := s.runtimes[sess.ID]
 	if !exists {
@@ -1153,6 +1313,7 @@ func (s *Server) runAgent(c echo.Context) error {
 			runtime.WithCurrentAgent(currentAgent),
 			runtime.WithManagedOAuth(false),
 			runtime.WithRootSessionID(sess.ID),
+			runtime.WithToolOutputStore(session.ToolOutputStore(s.sessionStore)),
 		}
 		rt, err = runtime.New(t, opts...)
 		if err != nil {
@@ -1275,6 +1436,10 @@ func (s *Server) elicitation(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
 	}
 
+	if _, err := s.getOwnedSession(c, sessionID); err != nil {
+		return err
+	}
+
 	rt, exists := s.runtimes[sessionID]
 	if !exists {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("runtime not found: %s", sessionID)})
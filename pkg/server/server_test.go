@@ -218,6 +218,94 @@ func TestServer_ListSessions(t *testing.T) {
 	assert.Empty(t, sessions)
 }
 
+func TestServer_ForkSession(t *testing.T) {
+	t.Parallel()
+
+	store, err := session.NewSQLiteSessionStore(filepath.Join(t.TempDir(), "sessions.db"))
+	require.NoError(t, err)
+
+	sess := session.New(
+		session.WithTitle("Original"),
+		session.WithUserMessage("", "first"),
+		session.WithUserMessage("", "second"),
+		session.WithUserMessage("", "third"),
+	)
+	require.NoError(t, store.AddSession(t.Context(), sess))
+
+	ctx := t.Context()
+	lnPath := startServerWithStore(t, ctx, prepareAgentsDir(t, "pirate.yaml"), store)
+
+	buf := httpPOST(t, ctx, lnPath, "/api/sessions/"+sess.ID+"/fork", api.ForkSessionRequest{MessageIndex: new(int)})
+	var forked session.Session
+	unmarshal(t, buf, &forked)
+	assert.NotEqual(t, sess.ID, forked.ID)
+	assert.Equal(t, "Original (fork)", forked.Title)
+	require.Len(t, forked.Messages, 1)
+	assert.Equal(t, "first", forked.Messages[0].Message.Message.Content)
+
+	buf = httpPOST(t, ctx, lnPath, "/api/sessions/"+sess.ID+"/fork", api.ForkSessionRequest{})
+	unmarshal(t, buf, &forked)
+	assert.Len(t, forked.Messages, 3)
+
+	sessions, err := store.GetSessions(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, sessions, 3)
+}
+
+func TestServer_SearchSessions(t *testing.T) {
+	t.Parallel()
+
+	store, err := session.NewSQLiteSessionStore(filepath.Join(t.TempDir(), "sessions.db"))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithTitle("Release"), session.WithUserMessage("", "Tag version 1.2"))
+	require.NoError(t, store.AddSession(t.Context(), sess))
+	require.NoError(t, store.AddSession(t.Context(), session.New(session.WithTitle("Other"), session.WithUserMessage("", "Hello"))))
+
+	ctx := t.Context()
+	lnPath := startServerWithStore(t, ctx, prepareAgentsDir(t, "pirate.yaml"), store)
+
+	buf := httpGET(t, ctx, lnPath, "/api/sessions/search?q=versi")
+	var results []api.SessionSearchResponse
+	unmarshal(t, buf, &results)
+	require.Len(t, results, 1)
+	assert.Equal(t, sess.ID, results[0].ID)
+	assert.Equal(t, "Release", results[0].Title)
+	assert.Contains(t, results[0].Snippet, "[version]")
+}
+
+func TestServer_ExportImportSession(t *testing.T) {
+	t.Parallel()
+
+	store, err := session.NewSQLiteSessionStore(filepath.Join(t.TempDir(), "sessions.db"))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithTitle("Release"), session.WithUserMessage("", "Tag version 1.2"))
+	sess.Messages = append(sess.Messages, session.Item{Summary: "Tagged"})
+	require.NoError(t, store.AddSession(t.Context(), sess))
+
+	ctx := t.Context()
+	lnPath := startServerWithStore(t, ctx, prepareAgentsDir(t, "pirate.yaml"), store)
+
+	markdown := httpGET(t, ctx, lnPath, "/api/sessions/"+sess.ID+"/export?format=markdown")
+	assert.Contains(t, string(markdown), "# Release")
+	assert.Contains(t, string(markdown), "## Summary\n\nTagged")
+
+	exported := httpGET(t, ctx, lnPath, "/api/sessions/"+sess.ID+"/export")
+	require.NoError(t, store.DeleteSession(t.Context(), sess.ID))
+
+	buf := httpPOST(t, ctx, lnPath, "/api/sessions/import", exported)
+	var imported api.SessionsResponse
+	unmarshal(t, buf, &imported)
+	assert.Equal(t, sess.ID, imported.ID)
+
+	stored, err := store.GetSession(t.Context(), sess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Release", stored.Title)
+	require.Len(t, stored.Messages, 2)
+	assert.Equal(t, "Tagged", stored.Messages[1].Summary)
+}
+
 func TestServer_ReloadTeams(t *testing.T) {
 	t.Setenv("OPENAI_API_KEY", "dummy")
 	t.Setenv("ANTHROPIC_API_KEY", "dummy")
@@ -614,9 +702,15 @@ func startServer(t *testing.T, ctx context.Context, agentsDir string) string {
 	t.Helper()
 
 	var store mockStore
+	return startServerWithStore(t, ctx, agentsDir, store)
+}
+
+func startServerWithStore(t *testing.T, ctx context.Context, agentsDir string, store session.Store, opts ...Opt) string {
+	t.Helper()
+
 	var runConfig config.RuntimeConfig
 
-	srv, err := New(store, runConfig, nil, WithAgentsDir(agentsDir))
+	srv, err := New(store, runConfig, nil, append([]Opt{WithAgentsDir(agentsDir)}, opts...)...)
 	require.NoError(t, err)
 
 	socketPath := "unix://" + filepath.Join(t.TempDir(), "sock")
@@ -639,6 +733,11 @@ func httpGET(t *testing.T, ctx context.Context, socketPath, path string) []byte
 	return httpDo(t, ctx, http.MethodGet, socketPath, path, nil)
 }
 
+func httpPOST(t *testing.T, ctx context.Context, socketPath, path string, payload any) []byte {
+	t.Helper()
+	return httpDo(t, ctx, http.MethodPost, socketPath, path, payload)
+}
+
 func httpPUT(t *testing.T, ctx context.Context, socketPath, path string, payload any) {
 	t.Helper()
 	httpDo(t, ctx, http.MethodPut, socketPath, path, payload)
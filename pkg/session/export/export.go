@@ -0,0 +1,299 @@
+// Package export converts sessions to and from portable formats.
+//
+// JSON exports hold the whole session, including sub-sessions, summaries, tool
+// calls and usage, and can be imported back or used as eval files. Markdown
+// and HTML exports are meant to be read.
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/session"
+)
+
+// ErrSessionExists is returned when importing a session whose ID is already in the store
+var ErrSessionExists = errors.New("a session with this ID already exists")
+
+// Format is the format of an exported session
+type Format string
+
+const (
+	FormatJSON     Format = "json"
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+)
+
+// Formats are all the supported formats
+var Formats = []Format{FormatJSON, FormatMarkdown, FormatHTML}
+
+// ParseFormat returns the format with the given name, "md" is accepted for markdown
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "json":
+		return FormatJSON, nil
+	case "markdown", "md":
+		return FormatMarkdown, nil
+	case "html":
+		return FormatHTML, nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected one of json, markdown or html", name)
+	}
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// Extension returns the file extension of the format, with the leading dot
+func (f Format) Extension() string {
+	switch f {
+	case FormatMarkdown:
+		return ".md"
+	case FormatHTML:
+		return ".html"
+	default:
+		return ".json"
+	}
+}
+
+// Write exports the session in the given format
+func Write(w io.Writer, sess *session.Session, format Format) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(sess)
+	case FormatMarkdown:
+		_, err := io.WriteString(w, Markdown(sess))
+		return err
+	case FormatHTML:
+		return writeHTML(w, sess)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// Read imports a session exported as JSON. Sessions without an ID, like
+// hand-written eval files, are given a new one.
+func Read(r io.Reader) (*session.Session, error) {
+	var sess session.Session
+	if err := json.NewDecoder(r).Decode(&sess); err != nil {
+		return nil, fmt.Errorf("invalid session JSON: %w", err)
+	}
+	if len(sess.Messages) == 0 {
+		return nil, errors.New("the session has no messages")
+	}
+
+	if sess.ID == "" {
+		sess.ID = uuid.New().String()
+	}
+	if sess.CreatedAt.IsZero() {
+		sess.CreatedAt = time.Now()
+	}
+
+	return &sess, nil
+}
+
+// Import adds an imported session to the store, it never overwrites an existing session
+func Import(ctx context.Context, store session.Store, sess *session.Session) error {
+	_, err := store.GetSession(ctx, sess.ID)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %s", ErrSessionExists, sess.ID)
+	case !errors.Is(err, session.ErrNotFound):
+		return err
+	}
+
+	return store.AddSession(ctx, sess)
+}
+
+// Markdown renders the session as a markdown document
+func Markdown(sess *session.Session) string {
+	var builder strings.Builder
+
+	title := sess.Title
+	if title == "" {
+		title = "Untitled session"
+	}
+	fmt.Fprintf(&builder, "# %s\n\n", title)
+	fmt.Fprintf(&builder, "- **Session:** %s\n", sess.ID)
+	fmt.Fprintf(&builder, "- **Created:** %s\n", sess.CreatedAt.Format(time.RFC3339))
+	if sess.WorkingDir != "" {
+		fmt.Fprintf(&builder, "- **Working directory:** %s\n", sess.WorkingDir)
+	}
+	fmt.Fprintf(&builder, "- **Tokens:** %d input, %d output\n", sess.InputTokens, sess.OutputTokens)
+	fmt.Fprintf(&builder, "- **Cost:** $%.4f\n", sess.Cost)
+
+	writeItems(&builder, sess.Messages, 2)
+
+	return builder.String()
+}
+
+// writeItems renders the items of a session with headings of the given level,
+// the items of sub-sessions are rendered one level deeper
+func writeItems(builder *strings.Builder, items []session.Item, level int) {
+	heading := strings.Repeat("#", min(level, 6))
+
+	for _, item := range items {
+		switch {
+		case item.Summary != "":
+			fmt.Fprintf(builder, "\n%s Summary\n\n%s\n", heading, item.Summary)
+		case item.IsSubSession():
+			title := item.SubSession.Title
+			if title == "" {
+				title = "Task"
+			}
+			fmt.Fprintf(builder, "\n%s Sub-session: %s\n", heading, title)
+			writeItems(builder, item.SubSession.Messages, level+1)
+		case item.IsMessage() && !item.Message.Implicit:
+			writeMessage(builder, item.Message, heading)
+		}
+	}
+}
+
+func writeMessage(builder *strings.Builder, msg *session.Message, heading string) {
+	switch msg.Message.Role {
+	case chat.MessageRoleUser:
+		fmt.Fprintf(builder, "\n%s User\n\n%s\n", heading, messageText(&msg.Message))
+
+	case chat.MessageRoleAssistant:
+		fmt.Fprintf(builder, "\n%s Assistant", heading)
+		if msg.AgentName != "" {
+			fmt.Fprintf(builder, " (%s)", msg.AgentName)
+		}
+		builder.WriteString("\n")
+
+		if msg.Message.ReasoningContent != "" {
+			fmt.Fprintf(builder, "\n**Reasoning**\n\n%s\n", quote(msg.Message.ReasoningContent))
+		}
+		if text := messageText(&msg.Message); text != "" {
+			fmt.Fprintf(builder, "\n%s\n", text)
+		}
+		for _, toolCall := range msg.Message.ToolCalls {
+			fmt.Fprintf(builder, "\n**Tool call:** `%s`", toolCall.Function.Name)
+			if toolCall.ID != "" {
+				fmt.Fprintf(builder, " (ID: %s)", toolCall.ID)
+			}
+			builder.WriteString("\n\n")
+			writeCodeBlock(builder, toolCall.Function.Arguments)
+		}
+		if msg.Model != "" {
+			fmt.Fprintf(builder, "\n*Model: %s*\n", msg.Model)
+		}
+
+	case chat.MessageRoleTool:
+		fmt.Fprintf(builder, "\n%s Tool Result", heading)
+		if msg.Message.ToolCallID != "" {
+			fmt.Fprintf(builder, " (ID: %s)", msg.Message.ToolCallID)
+		}
+		builder.WriteString("\n\n")
+		writeCodeBlock(builder, msg.Message.Content)
+	}
+}
+
+// messageText returns the content of a message, or the text of its parts
+func messageText(msg *chat.Message) string {
+	if msg.Content != "" || len(msg.MultiContent) == 0 {
+		return msg.Content
+	}
+
+	var parts []string
+	for _, part := range msg.MultiContent {
+		switch part.Type {
+		case chat.MessagePartTypeText:
+			parts = append(parts, part.Text)
+		default:
+			parts = append(parts, "*["+string(part.Type)+"]*")
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func quote(text string) string {
+	return "> " + strings.ReplaceAll(text, "\n", "\n> ")
+}
+
+// writeCodeBlock writes content in a fenced code block, JSON is indented.
+// The fence is longer than any run of backticks in the content.
+func writeCodeBlock(builder *strings.Builder, content string) {
+	language := ""
+	var indented bytes.Buffer
+	if json.Valid([]byte(content)) && json.Indent(&indented, []byte(content), "", "  ") == nil {
+		language = "json"
+		content = indented.String()
+	}
+
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(builder, "%s%s\n%s\n%s\n", fence, language, strings.TrimRight(content, "\n"), fence)
+}
+
+var htmlPage = template.Must(template.New("session").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 52rem; margin: 2rem auto; padding: 0 1rem; font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; line-height: 1.5; color: #1f2328; }
+h1 { border-bottom: 1px solid #d0d7de; padding-bottom: .3rem; }
+h2, h3, h4, h5, h6 { margin-top: 2rem; }
+pre { background: #f6f8fa; padding: .75rem; overflow-x: auto; border-radius: 6px; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: .9em; }
+blockquote { margin: 0; padding: 0 1rem; color: #59636e; border-left: .25rem solid #d0d7de; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #d0d7de; padding: .25rem .5rem; }
+</style>
+</head>
+<body>
+{{.Body}}
+</body>
+</html>
+`))
+
+// writeHTML renders the markdown export as a standalone HTML page. Raw HTML in
+// the messages is escaped.
+func writeHTML(w io.Writer, sess *session.Session) error {
+	var body bytes.Buffer
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+	if err := md.Convert([]byte(Markdown(sess)), &body); err != nil {
+		return err
+	}
+
+	title := sess.Title
+	if title == "" {
+		title = "Untitled session"
+	}
+
+	return htmlPage.Execute(w, struct {
+		Title string
+		Body  template.HTML
+	}{
+		Title: title,
+		// goldmark doesn't render raw HTML unless the unsafe option is set
+		Body: template.HTML(body.String()), //nolint:gosec // see above
+	})
+}
@@ -0,0 +1,134 @@
+package export
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+func testSession() *session.Session {
+	sub := session.New(session.WithTitle("Research"))
+	sub.AddMessage(session.UserMessage("", "Look it up"))
+	sub.AddMessage(&session.Message{
+		AgentName: "researcher",
+		Message:   chat.Message{Role: chat.MessageRoleAssistant, Content: "Found it"},
+	})
+
+	sess := session.New(session.WithTitle("Fix the build"))
+	sess.InputTokens = 120
+	sess.OutputTokens = 45
+	sess.Cost = 0.0123
+	sess.AddMessage(session.UserMessage("", "Why is the build <b>broken</b>?"))
+	sess.AddMessage(&session.Message{
+		AgentName: "root",
+		Model:     "openai/gpt-4o",
+		Message: chat.Message{
+			Role:    chat.MessageRoleAssistant,
+			Content: "Let me check.",
+			ToolCalls: []tools.ToolCall{{
+				ID:       "call_1",
+				Type:     "function",
+				Function: tools.FunctionCall{Name: "shell", Arguments: `{"cmd":"go build ./..."}`},
+			}},
+		},
+	})
+	sess.AddMessage(&session.Message{
+		Message: chat.Message{Role: chat.MessageRoleTool, ToolCallID: "call_1", Content: "main.go:3: ```oops```"},
+	})
+	sess.Messages = append(sess.Messages, session.NewSubSessionItem(sub), session.Item{Summary: "The build is broken by main.go"})
+
+	return sess
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	sess := testSession()
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, sess, FormatJSON))
+
+	imported, err := Read(&buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, sess.ID, imported.ID)
+	assert.Equal(t, sess.Title, imported.Title)
+	assert.Equal(t, sess.Cost, imported.Cost)
+	assert.Equal(t, sess.InputTokens, imported.InputTokens)
+	assert.Equal(t, sess.OutputTokens, imported.OutputTokens)
+	require.Len(t, imported.Messages, len(sess.Messages))
+	assert.Equal(t, "shell", imported.Messages[1].Message.Message.ToolCalls[0].Function.Name)
+	assert.Equal(t, "Research", imported.Messages[3].SubSession.Title)
+	assert.Len(t, imported.Messages[3].SubSession.Messages, 2)
+	assert.Equal(t, "The build is broken by main.go", imported.Messages[4].Summary)
+}
+
+func TestReadAssignsIDAndDate(t *testing.T) {
+	sess, err := Read(strings.NewReader(`{"messages":[{"message":{"message":{"role":"user","content":"hi"}}}]}`))
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, sess.ID)
+	assert.False(t, sess.CreatedAt.IsZero())
+}
+
+func TestReadRejectsInvalidSessions(t *testing.T) {
+	_, err := Read(strings.NewReader(`not json`))
+	require.Error(t, err)
+
+	_, err = Read(strings.NewReader(`{"id":"empty"}`))
+	require.Error(t, err)
+}
+
+func TestImportDoesNotOverwrite(t *testing.T) {
+	store, err := session.NewSQLiteSessionStore(filepath.Join(t.TempDir(), "session.db"))
+	require.NoError(t, err)
+
+	sess := testSession()
+	require.NoError(t, Import(t.Context(), store, sess))
+
+	err = Import(t.Context(), store, sess)
+	require.ErrorIs(t, err, ErrSessionExists)
+
+	stored, err := store.GetSession(t.Context(), sess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Research", stored.Messages[3].SubSession.Title)
+}
+
+func TestMarkdown(t *testing.T) {
+	md := Markdown(testSession())
+
+	assert.Contains(t, md, "# Fix the build\n")
+	assert.Contains(t, md, "- **Cost:** $0.0123")
+	assert.Contains(t, md, "## Assistant (root)")
+	assert.Contains(t, md, "**Tool call:** `shell` (ID: call_1)")
+	assert.Contains(t, md, "\"cmd\": \"go build ./...\"")
+	assert.Contains(t, md, "## Tool Result (ID: call_1)\n\n````\nmain.go:3: ```oops```\n````")
+	assert.Contains(t, md, "## Sub-session: Research")
+	assert.Contains(t, md, "### Assistant (researcher)")
+	assert.Contains(t, md, "## Summary\n\nThe build is broken by main.go")
+}
+
+func TestHTMLEscapesContent(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, testSession(), FormatHTML))
+
+	html := buf.String()
+	assert.Contains(t, html, "<title>Fix the build</title>")
+	assert.Contains(t, html, "<h2>Sub-session: Research</h2>")
+	assert.NotContains(t, html, "<b>broken</b>")
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("md")
+	require.NoError(t, err)
+	assert.Equal(t, FormatMarkdown, format)
+
+	_, err = ParseFormat("pdf")
+	require.Error(t, err)
+}
@@ -178,6 +178,129 @@ func getAllMigrations() []Migration {
 			UpSQL:       `ALTER TABLE sessions ADD COLUMN working_dir TEXT DEFAULT ''`,
 			DownSQL:     `ALTER TABLE sessions DROP COLUMN working_dir`,
 		},
+		{
+			ID:          9,
+			Name:        "009_add_approved_rules_column",
+			Description: "Add approved_rules column to sessions table",
+			UpSQL:       `ALTER TABLE sessions ADD COLUMN approved_rules TEXT DEFAULT ''`,
+			DownSQL:     `ALTER TABLE sessions DROP COLUMN approved_rules`,
+		},
+		{
+			ID:          10,
+			Name:        "010_add_owner_column",
+			Description: "Add owner column to sessions table",
+			UpSQL:       `ALTER TABLE sessions ADD COLUMN owner TEXT DEFAULT ''`,
+			DownSQL:     `ALTER TABLE sessions DROP COLUMN owner`,
+		},
+		{
+			ID:          11,
+			Name:        "011_add_sessions_fts",
+			Description: "Add a full-text index over the titles, messages and tool call arguments of the sessions",
+			UpSQL: `
+				CREATE VIRTUAL TABLE sessions_fts USING fts5(session_id UNINDEXED, title, content, tokenize = 'unicode61 remove_diacritics 2');
+
+				CREATE TRIGGER sessions_fts_insert AFTER INSERT ON sessions BEGIN
+					INSERT INTO sessions_fts (session_id, title, content) VALUES (NEW.id, NEW.title, (` + sessionTextSQL("NEW.messages") + `));
+				END;
+
+				CREATE TRIGGER sessions_fts_update AFTER UPDATE OF title, messages ON sessions BEGIN
+					DELETE FROM sessions_fts WHERE session_id = OLD.id;
+					INSERT INTO sessions_fts (session_id, title, content) VALUES (NEW.id, NEW.title, (` + sessionTextSQL("NEW.messages") + `));
+				END;
+
+				CREATE TRIGGER sessions_fts_delete AFTER DELETE ON sessions BEGIN
+					DELETE FROM sessions_fts WHERE session_id = OLD.id;
+				END;
+
+				INSERT INTO sessions_fts (session_id, title, content) SELECT id, title, (` + sessionTextSQL("messages") + `) FROM sessions;
+			`,
+			DownSQL: `
+				DROP TRIGGER sessions_fts_insert;
+				DROP TRIGGER sessions_fts_update;
+				DROP TRIGGER sessions_fts_delete;
+				DROP TABLE sessions_fts;
+			`,
+		},
+		{
+			ID:          12,
+			Name:        "012_add_budget_columns",
+			Description: "Add max_cost, max_tokens_total and total_tokens columns to sessions table",
+			UpSQL: `
+				ALTER TABLE sessions ADD COLUMN max_cost REAL DEFAULT 0;
+				ALTER TABLE sessions ADD COLUMN max_tokens_total INTEGER DEFAULT 0;
+				ALTER TABLE sessions ADD COLUMN total_tokens INTEGER DEFAULT 0;
+			`,
+			DownSQL: `
+				ALTER TABLE sessions DROP COLUMN max_cost;
+				ALTER TABLE sessions DROP COLUMN max_tokens_total;
+				ALTER TABLE sessions DROP COLUMN total_tokens;
+			`,
+		},
+		{
+			ID:          13,
+			Name:        "013_add_tool_outputs_id_column",
+			Description: "Add tool_outputs_id column to sessions table",
+			UpSQL:       `ALTER TABLE sessions ADD COLUMN tool_outputs_id TEXT DEFAULT ''`,
+			DownSQL:     `ALTER TABLE sessions DROP COLUMN tool_outputs_id`,
+		},
+		{
+			ID:          14,
+			Name:        "014_key_sessions_fts_by_rowid",
+			Description: "Key the full-text index by the rowid of the sessions, the session_id column of the index can't be looked up",
+			UpSQL: `
+				DROP TRIGGER sessions_fts_insert;
+				DROP TRIGGER sessions_fts_update;
+				DROP TRIGGER sessions_fts_delete;
+				DROP TABLE sessions_fts;
+
+				CREATE VIRTUAL TABLE sessions_fts USING fts5(title, content, tokenize = 'unicode61 remove_diacritics 2');
+
+				CREATE TRIGGER sessions_fts_insert AFTER INSERT ON sessions BEGIN
+					INSERT INTO sessions_fts (rowid, title, content) VALUES (NEW.rowid, NEW.title, (` + sessionTextSQL("NEW.messages") + `));
+				END;
+
+				CREATE TRIGGER sessions_fts_update AFTER UPDATE OF title, messages ON sessions BEGIN
+					DELETE FROM sessions_fts WHERE rowid = OLD.rowid;
+					INSERT INTO sessions_fts (rowid, title, content) VALUES (NEW.rowid, NEW.title, (` + sessionTextSQL("NEW.messages") + `));
+				END;
+
+				CREATE TRIGGER sessions_fts_delete AFTER DELETE ON sessions BEGIN
+					DELETE FROM sessions_fts WHERE rowid = OLD.rowid;
+				END;
+
+				INSERT INTO sessions_fts (rowid, title, content) SELECT rowid, title, (` + sessionTextSQL("messages") + `) FROM sessions;
+			`,
+			DownSQL: `
+				DROP TRIGGER sessions_fts_insert;
+				DROP TRIGGER sessions_fts_update;
+				DROP TRIGGER sessions_fts_delete;
+				DROP TABLE sessions_fts;
+
+				CREATE VIRTUAL TABLE sessions_fts USING fts5(session_id UNINDEXED, title, content, tokenize = 'unicode61 remove_diacritics 2');
+
+				CREATE TRIGGER sessions_fts_insert AFTER INSERT ON sessions BEGIN
+					INSERT INTO sessions_fts (session_id, title, content) VALUES (NEW.id, NEW.title, (` + sessionTextSQL("NEW.messages") + `));
+				END;
+
+				CREATE TRIGGER sessions_fts_update AFTER UPDATE OF title, messages ON sessions BEGIN
+					DELETE FROM sessions_fts WHERE session_id = OLD.id;
+					INSERT INTO sessions_fts (session_id, title, content) VALUES (NEW.id, NEW.title, (` + sessionTextSQL("NEW.messages") + `));
+				END;
+
+				CREATE TRIGGER sessions_fts_delete AFTER DELETE ON sessions BEGIN
+					DELETE FROM sessions_fts WHERE session_id = OLD.id;
+				END;
+
+				INSERT INTO sessions_fts (session_id, title, content) SELECT id, title, (` + sessionTextSQL("messages") + `) FROM sessions;
+			`,
+		},
 		// Add more migrations here as needed
 	}
 }
+
+// sessionTextSQL returns the SQL expression extracting the searchable text of
+// the JSON encoded items of a session: the content of the messages, including
+// tool results, the arguments of the tool calls and the summaries.
+func sessionTextSQL(messages string) string {
+	return "SELECT group_concat(value, char(10)) FROM json_tree(CASE WHEN json_valid(" + messages + ") THEN " + messages + " ELSE '[]' END) WHERE key IN ('content', 'arguments', 'summary') AND type = 'text'"
+}
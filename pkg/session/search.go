@@ -0,0 +1,105 @@
+package session
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// defaultSearchLimit is the number of results returned when SearchFilters.Limit isn't set
+const defaultSearchLimit = 50
+
+// SearchFilters narrows down the results of a session search
+type SearchFilters struct {
+	// Owner only keeps the sessions of this owner, when set
+	Owner string
+	// Since only keeps the sessions created after this time, when set
+	Since time.Time
+	// Limit is the maximum number of results, defaults to 50
+	Limit int
+}
+
+// SearchResult is a session matching a search
+type SearchResult struct {
+	Session *Session
+	// Snippet is an excerpt of the session around the matched terms, the terms
+	// are enclosed in square brackets
+	Snippet string
+}
+
+// SearchSessions searches the titles, the messages and the tool call arguments
+// of the sessions. Every word of the query must match, the last one as a prefix.
+// The best matches come first.
+func (s *SQLiteSessionStore) SearchSessions(ctx context.Context, query string, filters SearchFilters) ([]SearchResult, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	where := ""
+	args := []any{match}
+	if filters.Owner != "" {
+		where += " AND owner = ?"
+		args = append(args, filters.Owner)
+	}
+	if !filters.Since.IsZero() {
+		// The creation times are stored with the offset of the local time zone
+		where += " AND datetime(created_at) >= datetime(?)"
+		args = append(args, filters.Since.UTC().Format(time.RFC3339))
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+sessionColumns+`, fts_snippet FROM sessions
+		JOIN (
+			SELECT rowid AS fts_rowid, snippet(sessions_fts, -1, '[', ']', '…', 16) AS fts_snippet, rank AS fts_rank
+			FROM sessions_fts WHERE sessions_fts MATCH ?
+		) ON sessions.rowid = fts_rowid
+		WHERE 1 = 1`+where+`
+		ORDER BY fts_rank
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var snippet string
+		session, err := scanSession(snippetScanner{rows: rows, snippet: &snippet})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, SearchResult{Session: session, Snippet: snippet})
+	}
+
+	return results, rows.Err()
+}
+
+// snippetScanner scans the snippet that follows the session columns of a search result
+type snippetScanner struct {
+	rows    rowScanner
+	snippet *string
+}
+
+func (s snippetScanner) Scan(dest ...any) error {
+	return s.rows.Scan(append(dest, s.snippet)...)
+}
+
+// ftsQuery turns the words of a user query into an FTS5 query matching all
+// the words, the last one as a prefix so that results show up while typing.
+// Words are quoted so that FTS5 operators and punctuation are searched as is.
+func ftsQuery(query string) string {
+	words := strings.Fields(query)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	if len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,129 @@
+package session
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/agent"
+	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+func TestSearchSessions(t *testing.T) {
+	store, err := NewSQLiteSessionStore(filepath.Join(t.TempDir(), "test_store.db"))
+	require.NoError(t, err)
+	defer store.(*SQLiteSessionStore).Close()
+
+	testAgent := agent.New("root", "test instruction")
+	sessions := []*Session{
+		{
+			ID:    "build",
+			Title: "Fixing the build",
+			Messages: []Item{
+				NewMessageItem(UserMessage("", "Why does the build fail?")),
+				NewMessageItem(NewAgentMessage(testAgent, &chat.Message{
+					Role:      chat.MessageRoleAssistant,
+					ToolCalls: []tools.ToolCall{{ID: "call_1", Function: tools.FunctionCall{Name: "shell", Arguments: `{"cmd":"go vet ./pkg/frobnicator"}`}}},
+				})),
+				NewMessageItem(NewAgentMessage(testAgent, &chat.Message{Role: chat.MessageRoleTool, ToolCallID: "call_1", Content: "undefined: quuxify"})),
+			},
+			Owner:     "alice",
+			CreatedAt: time.Now().Add(-48 * time.Hour),
+		},
+		{
+			ID:    "docs",
+			Title: "Writing docs",
+			Messages: []Item{
+				NewMessageItem(UserMessage("", "Document the café module")),
+				NewSubSessionItem(New(WithUserMessage("", "Explain the build"))),
+			},
+			Owner:     "bob",
+			CreatedAt: time.Now(),
+		},
+	}
+	for _, sess := range sessions {
+		require.NoError(t, store.AddSession(t.Context(), sess))
+	}
+
+	search := func(query string, filters SearchFilters) []string {
+		t.Helper()
+		results, err := store.SearchSessions(t.Context(), query, filters)
+		require.NoError(t, err)
+		var ids []string
+		for _, result := range results {
+			ids = append(ids, result.Session.ID)
+		}
+		return ids
+	}
+
+	// Titles, messages, sub-sessions, tool call arguments and tool results are indexed
+	assert.ElementsMatch(t, []string{"build", "docs"}, search("build", SearchFilters{}))
+	assert.Equal(t, []string{"build"}, search("frobnicator", SearchFilters{}))
+	assert.Equal(t, []string{"build"}, search("quuxify", SearchFilters{}))
+	assert.Equal(t, []string{"docs"}, search("writing", SearchFilters{}))
+	assert.Equal(t, []string{"docs"}, search("cafe", SearchFilters{}))
+	// The last word is a prefix, all the words must match
+	assert.Equal(t, []string{"build"}, search("build frob", SearchFilters{}))
+	assert.Empty(t, search("build missing", SearchFilters{}))
+	// FTS5 syntax is searched as is
+	assert.Empty(t, search(`"unbalanced AND (`, SearchFilters{}))
+	assert.Empty(t, search("   ", SearchFilters{}))
+
+	assert.Equal(t, []string{"build"}, search("build", SearchFilters{Owner: "alice"}))
+	assert.Equal(t, []string{"docs"}, search("build", SearchFilters{Since: time.Now().Add(-time.Hour)}))
+	assert.Len(t, search("build", SearchFilters{Limit: 1}), 1)
+
+	results, err := store.SearchSessions(t.Context(), "quux", SearchFilters{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Contains(t, results[0].Snippet, "[quuxify]")
+	assert.Equal(t, "alice", results[0].Session.Owner)
+
+	// The index follows updates and deletions
+	sessions[1].Title = "Writing release notes"
+	require.NoError(t, store.UpdateSession(t.Context(), sessions[1]))
+	assert.Empty(t, search("docs", SearchFilters{}))
+	assert.Equal(t, []string{"docs"}, search("release", SearchFilters{}))
+
+	var indexed int
+	require.NoError(t, store.(*SQLiteSessionStore).db.QueryRowContext(t.Context(), "SELECT count(*) FROM sessions_fts").Scan(&indexed))
+	assert.Equal(t, 2, indexed)
+
+	require.NoError(t, store.DeleteSession(t.Context(), "build"))
+	assert.Empty(t, search("quuxify", SearchFilters{}))
+}
+
+func TestSearchSessionsSinceTimeZones(t *testing.T) {
+	store, err := NewSQLiteSessionStore(filepath.Join(t.TempDir(), "test_store.db"))
+	require.NoError(t, err)
+	defer store.(*SQLiteSessionStore).Close()
+
+	paris := time.FixedZone("Paris", 2*60*60)
+	newYork := time.FixedZone("New York", -5*60*60)
+
+	// Created at 08:00 UTC
+	createdAt := time.Date(2025, 6, 1, 10, 0, 0, 0, paris)
+	require.NoError(t, store.AddSession(t.Context(), &Session{
+		ID:        "paris",
+		Title:     "Planning the release",
+		Messages:  []Item{NewMessageItem(UserMessage("", "Plan the release"))},
+		CreatedAt: createdAt,
+	}))
+
+	search := func(since time.Time) int {
+		t.Helper()
+		results, err := store.SearchSessions(t.Context(), "release", SearchFilters{Since: since})
+		require.NoError(t, err)
+		return len(results)
+	}
+
+	// 09:00 in New York is 14:00 UTC, after the session was created
+	assert.Zero(t, search(time.Date(2025, 6, 1, 9, 0, 0, 0, newYork)))
+	// 02:00 in New York is 07:00 UTC, before the session was created
+	assert.Equal(t, 1, search(time.Date(2025, 6, 1, 2, 0, 0, 0, newYork)))
+	assert.Equal(t, 1, search(createdAt.In(newYork)))
+}
@@ -2,8 +2,11 @@ package session
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -11,6 +14,7 @@ import (
 
 	"github.com/rumpl/rb/pkg/agent"
 	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/permissions"
 )
 
 // TODO: instead of trimming, we should compact the history when it nears the
@@ -56,9 +60,20 @@ type Session struct {
 	// ToolsApproved is a flag to indicate if the tools have been approved
 	ToolsApproved bool `json:"tools_approved"`
 
+	// ApprovedRules holds the permission rules the user approved for the rest of the session
+	ApprovedRules []permissions.Rule `json:"approved_rules,omitempty"`
+
 	// WorkingDir is the base directory used for filesystem-aware tools
 	WorkingDir string `json:"working_dir,omitempty"`
 
+	// Owner is the subject of the API caller that created the session, if any
+	Owner string `json:"owner,omitempty"`
+
+	// ToolOutputsID is the key of the large tool outputs of the session in the
+	// artifact store, forks share the tool outputs of the session they come from.
+	// When empty, the key is the ID of the session.
+	ToolOutputsID string `json:"tool_outputs_id,omitempty"`
+
 	// SendUserMessage is a flag to indicate if the user message should be sent
 	SendUserMessage bool
 
@@ -66,9 +81,18 @@ type Session struct {
 	// If 0, there is no limit
 	MaxIterations int `json:"max_iterations"`
 
+	// MaxCost is the budget of the session in dollars, if 0, there is no limit
+	MaxCost float64 `json:"max_cost,omitempty"`
+
+	// MaxTokensTotal is the budget of the session in tokens, if 0, there is no limit
+	MaxTokensTotal int `json:"max_tokens_total,omitempty"`
+
 	InputTokens  int     `json:"input_tokens"`
 	OutputTokens int     `json:"output_tokens"`
 	Cost         float64 `json:"cost"`
+
+	// TotalTokens is the number of tokens used by all the requests of the session, including sub-sessions
+	TotalTokens int `json:"total_tokens"`
 }
 
 // Message is a message from an agent
@@ -76,6 +100,9 @@ type Message struct {
 	AgentFilename string       `json:"agentFilename"`
 	AgentName     string       `json:"agentName"` // TODO: rename to agent_name
 	Message       chat.Message `json:"message"`
+	// Model is the ID of the model that generated an assistant message.
+	// It can differ from the agent's model when a fallback model was used.
+	Model string `json:"model,omitempty"`
 	// Implicit is an optional field to indicate if the message shouldn't be shown to the user. It's needed for special  situations
 	// like when an agent transfers a task to another agent - new session is created with a default user message, but this shouldn't be shown to the user.
 	// Such messages should be marked as true
@@ -165,6 +192,34 @@ func (s *Session) AddSubSession(subSession *Session) {
 	s.Messages = append(s.Messages, NewSubSessionItem(subSession))
 }
 
+// UserMessageIndex returns the position in Messages of the n-th message the user sent.
+// Implicit messages are not counted.
+func (s *Session) UserMessageIndex(n int) (int, bool) {
+	for i, item := range s.Messages {
+		if !item.IsMessage() || item.Message.Implicit || item.Message.Message.Role != chat.MessageRoleUser {
+			continue
+		}
+		if n == 0 {
+			return i, true
+		}
+		n--
+	}
+	return 0, false
+}
+
+// Truncate removes the item at index and all the items that follow it
+func (s *Session) Truncate(index int) {
+	if index < 0 || index >= len(s.Messages) {
+		return
+	}
+	s.Messages = s.Messages[:index]
+}
+
+// ApproveRule records a permission rule the user approved for the rest of the session
+func (s *Session) ApproveRule(rule permissions.Rule) {
+	s.ApprovedRules = append(s.ApprovedRules, rule)
+}
+
 // AllowedDirectories returns the directories that should be considered safe for tools
 func (s *Session) AllowedDirectories() []string {
 	if s.WorkingDir == "" {
@@ -188,6 +243,16 @@ func (s *Session) GetAllMessages() []Message {
 	return messages
 }
 
+// lastUserMessageContent returns the content of the last message of the user in this session
+func (s *Session) lastUserMessageContent() string {
+	for i := len(s.Messages) - 1; i >= 0; i-- {
+		if item := s.Messages[i]; item.IsMessage() && item.Message.Message.Role == chat.MessageRoleUser {
+			return item.Message.Message.Content
+		}
+	}
+	return ""
+}
+
 func (s *Session) GetLastAssistantMessageContent() string {
 	messages := s.GetAllMessages()
 	for i := len(messages) - 1; i >= 0; i-- {
@@ -198,6 +263,58 @@ func (s *Session) GetLastAssistantMessageContent() string {
 	return ""
 }
 
+// Fork creates a new session that contains a copy of the items of this session
+// up to and including the item at messageIndex. Sub-sessions, token counters,
+// cost, approvals and the working directory are copied too.
+func (s *Session) Fork(messageIndex int) (*Session, error) {
+	if messageIndex < 0 || messageIndex >= len(s.Messages) {
+		return nil, fmt.Errorf("message index %d out of range [0, %d)", messageIndex, len(s.Messages))
+	}
+
+	// Round-trip through JSON to deep copy the messages and sub-sessions
+	itemsJSON, err := json.Marshal(s.Messages[:messageIndex+1])
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	if err := json.Unmarshal(itemsJSON, &items); err != nil {
+		return nil, err
+	}
+
+	title := s.Title
+	if title != "" {
+		title += " (fork)"
+	}
+
+	forked := New(
+		WithTitle(title),
+		WithMaxIterations(s.MaxIterations),
+		WithMaxCost(s.MaxCost),
+		WithMaxTokensTotal(s.MaxTokensTotal),
+		WithWorkingDir(s.WorkingDir),
+		WithToolsApproved(s.ToolsApproved),
+		WithApprovedRules(slices.Clone(s.ApprovedRules)),
+		WithSendUserMessage(s.SendUserMessage),
+		WithOwner(s.Owner),
+	)
+	forked.Messages = items
+	forked.ToolOutputsID = s.ToolOutputsKey()
+	forked.InputTokens = s.InputTokens
+	forked.OutputTokens = s.OutputTokens
+	forked.Cost = s.Cost
+	forked.TotalTokens = s.TotalTokens
+
+	return forked, nil
+}
+
+// ToolOutputsKey returns the key of the large tool outputs of the session in the artifact store
+func (s *Session) ToolOutputsKey() string {
+	if s.ToolOutputsID != "" {
+		return s.ToolOutputsID
+	}
+	return s.ID
+}
+
 type Opt func(s *Session)
 
 func WithUserMessage(agentFilename, content string) Opt {
@@ -224,6 +341,18 @@ func WithMaxIterations(maxIterations int) Opt {
 	}
 }
 
+func WithMaxCost(maxCost float64) Opt {
+	return func(s *Session) {
+		s.MaxCost = maxCost
+	}
+}
+
+func WithMaxTokensTotal(maxTokensTotal int) Opt {
+	return func(s *Session) {
+		s.MaxTokensTotal = maxTokensTotal
+	}
+}
+
 func WithWorkingDir(workingDir string) Opt {
 	return func(s *Session) {
 		s.WorkingDir = workingDir
@@ -242,6 +371,18 @@ func WithToolsApproved(toolsApproved bool) Opt {
 	}
 }
 
+func WithApprovedRules(rules []permissions.Rule) Opt {
+	return func(s *Session) {
+		s.ApprovedRules = rules
+	}
+}
+
+func WithOwner(owner string) Opt {
+	return func(s *Session) {
+		s.Owner = owner
+	}
+}
+
 func WithSendUserMessage(sendUserMessage bool) Opt {
 	return func(s *Session) {
 		s.SendUserMessage = sendUserMessage
@@ -412,6 +553,13 @@ func (s *Session) GetMessages(ctx context.Context, a *agent.Agent) []chat.Messag
 		}
 	}
 
+	for _, content := range a.ToolSetsContext(ctx, s.lastUserMessageContent()) {
+		messages = append(messages, chat.Message{
+			Role:    chat.MessageRoleSystem,
+			Content: content,
+		})
+	}
+
 	lastSummaryIndex := -1
 	for i := len(s.Messages) - 1; i >= 0; i-- {
 		if s.Messages[i].Summary != "" {
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -156,6 +157,38 @@ func TestGetMessagesWithToolCalls(t *testing.T) {
 	}
 }
 
+// recallToolSet recalls what it was asked about, for the agent that asked
+type recallToolSet struct {
+	tools.ElicitationTool
+}
+
+func (recallToolSet) Tools(context.Context) ([]tools.Tool, error) { return nil, nil }
+func (recallToolSet) Instructions() string                        { return "" }
+func (recallToolSet) Start(context.Context) error                 { return nil }
+func (recallToolSet) Stop(context.Context) error                  { return nil }
+
+func (recallToolSet) ProvideContext(ctx context.Context, query string) (string, error) {
+	return tools.AgentName(ctx) + " remembers: " + query, nil
+}
+
+func TestGetMessagesWithToolSetsContext(t *testing.T) {
+	testAgent := agent.New("test", "test instruction", agent.WithToolSets(&recallToolSet{}))
+
+	s := New(WithUserMessage("", "first question"))
+	s.AddMessage(NewAgentMessage(testAgent, &chat.Message{Role: chat.MessageRoleAssistant, Content: "answer"}))
+	s.AddMessage(UserMessage("", "second question"))
+
+	messages := s.GetMessages(t.Context(), testAgent)
+
+	var system []string
+	for _, msg := range messages {
+		if msg.Role == chat.MessageRoleSystem {
+			system = append(system, msg.Content)
+		}
+	}
+	assert.Contains(t, system, "test remembers: second question")
+}
+
 func TestGetMessagesWithSummary(t *testing.T) {
 	testAgent := agent.New("test", "test instruction")
 
@@ -202,3 +235,30 @@ func TestGetMessagesWithSummary(t *testing.T) {
 	assert.True(t, summaryFound, "should include summary as system message")
 	assert.Equal(t, 2, userAssistantMessages, "should only include messages after summary")
 }
+
+func TestUserMessageIndexAndTruncate(t *testing.T) {
+	testAgent := agent.New("root", "test instruction")
+	s := New(
+		WithSystemMessage("system"),
+		WithImplicitUserMessage("", "implicit"),
+		WithUserMessage("", "first"),
+	)
+	s.AddMessage(NewAgentMessage(testAgent, &chat.Message{Role: chat.MessageRoleAssistant, Content: "answer"}))
+	s.AddMessage(UserMessage("", "second"))
+	s.AddMessage(NewAgentMessage(testAgent, &chat.Message{Role: chat.MessageRoleAssistant, Content: "another answer"}))
+
+	index, ok := s.UserMessageIndex(0)
+	assert.True(t, ok)
+	assert.Equal(t, 2, index)
+
+	index, ok = s.UserMessageIndex(1)
+	assert.True(t, ok)
+	assert.Equal(t, 4, index)
+
+	_, ok = s.UserMessageIndex(2)
+	assert.False(t, ok)
+
+	s.Truncate(index)
+	assert.Len(t, s.Messages, 4)
+	assert.Equal(t, "answer", s.Messages[3].Message.Message.Content)
+}
@@ -5,10 +5,14 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"strconv"
 	"time"
 
 	_ "modernc.org/sqlite"
+
+	"github.com/rumpl/rb/pkg/artifacts"
+	"github.com/rumpl/rb/pkg/permissions"
 )
 
 var (
@@ -33,15 +37,38 @@ type Store interface {
 	GetSessionsByAgent(ctx context.Context, agentFilename string) ([]*Session, error)
 	DeleteSession(ctx context.Context, id string) error
 	UpdateSession(ctx context.Context, session *Session) error
+	ForkSession(ctx context.Context, id string, messageIndex int) (*Session, error)
+	SearchSessions(ctx context.Context, query string, filters SearchFilters) ([]SearchResult, error)
+}
+
+// Save updates the session in the store, or adds it if it isn't stored yet
+func Save(ctx context.Context, store Store, sess *Session) error {
+	err := store.UpdateSession(ctx, sess)
+	if errors.Is(err, ErrNotFound) {
+		return store.AddSession(ctx, sess)
+	}
+	return err
 }
 
 // SQLiteSessionStore implements Store using SQLite
 type SQLiteSessionStore struct {
-	db *sql.DB
+	db          *sql.DB
+	toolOutputs *artifacts.Store
+}
+
+// StoreOpt configures a SQLiteSessionStore
+type StoreOpt func(*SQLiteSessionStore)
+
+// WithToolOutputStore deletes the large tool outputs saved in the artifact
+// store along with the sessions they belong to
+func WithToolOutputStore(toolOutputs *artifacts.Store) StoreOpt {
+	return func(s *SQLiteSessionStore) {
+		s.toolOutputs = toolOutputs
+	}
 }
 
 // NewSQLiteSessionStore creates a new SQLite session store
-func NewSQLiteSessionStore(path string) (Store, error) {
+func NewSQLiteSessionStore(path string, opts ...StoreOpt) (Store, error) {
 	// Add query parameters for better concurrency handling
 	// _busy_timeout: Wait up to 5 seconds if database is locked
 	// _journal_mode=WAL: Enable Write-Ahead Logging for better concurrent access
@@ -74,7 +101,11 @@ func NewSQLiteSessionStore(path string) (Store, error) {
 		return nil, err
 	}
 
-	return &SQLiteSessionStore{db: db}, nil
+	store := &SQLiteSessionStore{db: db}
+	for _, opt := range opts {
+		opt(store)
+	}
+	return store, nil
 }
 
 // AddSession adds a new session to the store
@@ -88,9 +119,14 @@ func (s *SQLiteSessionStore) AddSession(ctx context.Context, session *Session) e
 		return err
 	}
 
+	approvedRulesJSON, err := marshalApprovedRules(session.ApprovedRules)
+	if err != nil {
+		return err
+	}
+
 	_, err = s.db.ExecContext(ctx,
-		"INSERT INTO sessions (id, messages, tools_approved, input_tokens, output_tokens, title, send_user_message, max_iterations, working_dir, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		session.ID, string(itemsJSON), session.ToolsApproved, session.InputTokens, session.OutputTokens, session.Title, session.SendUserMessage, session.MaxIterations, session.WorkingDir, session.CreatedAt.Format(time.RFC3339))
+		"INSERT INTO sessions (id, messages, tools_approved, input_tokens, output_tokens, title, cost, send_user_message, max_iterations, working_dir, created_at, approved_rules, owner, max_cost, max_tokens_total, total_tokens, tool_outputs_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		session.ID, string(itemsJSON), session.ToolsApproved, session.InputTokens, session.OutputTokens, session.Title, session.Cost, session.SendUserMessage, session.MaxIterations, session.WorkingDir, session.CreatedAt.Format(time.RFC3339), approvedRulesJSON, session.Owner, session.MaxCost, session.MaxTokensTotal, session.TotalTokens, session.ToolOutputsID)
 	return err
 }
 
@@ -101,13 +137,9 @@ func (s *SQLiteSessionStore) GetSession(ctx context.Context, id string) (*Sessio
 	}
 
 	row := s.db.QueryRowContext(ctx,
-		"SELECT id, messages, tools_approved, input_tokens, output_tokens, title, cost, send_user_message, max_iterations, working_dir, created_at FROM sessions WHERE id = ?", id)
-
-	var messagesJSON, toolsApprovedStr, inputTokensStr, outputTokensStr, titleStr, costStr, sendUserMessageStr, maxIterationsStr, createdAtStr string
-	var sessionID string
-	var workingDir sql.NullString
+		"SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id)
 
-	err := row.Scan(&sessionID, &messagesJSON, &toolsApprovedStr, &inputTokensStr, &outputTokensStr, &titleStr, &costStr, &sendUserMessageStr, &maxIterationsStr, &workingDir, &createdAtStr)
+	session, err := scanSession(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -115,6 +147,51 @@ func (s *SQLiteSessionStore) GetSession(ctx context.Context, id string) (*Sessio
 		return nil, err
 	}
 
+	return session, nil
+}
+
+// GetSessions retrieves all sessions
+func (s *SQLiteSessionStore) GetSessions(ctx context.Context) ([]*Session, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+sessionColumns+" FROM sessions ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// sessionColumns lists the columns read by scanSession, in order
+const sessionColumns = "id, messages, tools_approved, input_tokens, output_tokens, title, cost, send_user_message, max_iterations, working_dir, created_at, approved_rules, owner, max_cost, max_tokens_total, total_tokens, tool_outputs_id"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSession reads a session from a row selected with sessionColumns
+func scanSession(row rowScanner) (*Session, error) {
+	var messagesJSON, toolsApprovedStr, inputTokensStr, outputTokensStr, titleStr, costStr, sendUserMessageStr, maxIterationsStr, createdAtStr string
+	var sessionID string
+	var workingDir, approvedRulesJSON, owner, toolOutputsID sql.NullString
+	var maxCost sql.NullFloat64
+	var maxTokensTotal, totalTokens sql.NullInt64
+
+	err := row.Scan(&sessionID, &messagesJSON, &toolsApprovedStr, &inputTokensStr, &outputTokensStr, &titleStr, &costStr, &sendUserMessageStr, &maxIterationsStr, &workingDir, &createdAtStr, &approvedRulesJSON, &owner, &maxCost, &maxTokensTotal, &totalTokens, &toolOutputsID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Ok listen up, we used to only store messages in the database, but now we
 	// store messages and sub-sessions. So we need to handle both cases.
 	// We do this in a kind of hacky way, but it works. "AgentFilename" is always present
@@ -167,113 +244,34 @@ func (s *SQLiteSessionStore) GetSession(ctx context.Context, id string) (*Sessio
 		return nil, err
 	}
 
+	var approvedRules []permissions.Rule
+	if approvedRulesJSON.String != "" {
+		if err := json.Unmarshal([]byte(approvedRulesJSON.String), &approvedRules); err != nil {
+			return nil, err
+		}
+	}
+
 	return &Session{
 		ID:              sessionID,
 		Title:           titleStr,
 		Messages:        items,
 		ToolsApproved:   toolsApproved,
+		ApprovedRules:   approvedRules,
 		InputTokens:     inputTokens,
 		OutputTokens:    outputTokens,
 		Cost:            cost,
 		SendUserMessage: sendUserMessage,
 		MaxIterations:   maxIterations,
+		MaxCost:         maxCost.Float64,
+		MaxTokensTotal:  int(maxTokensTotal.Int64),
+		TotalTokens:     int(totalTokens.Int64),
 		CreatedAt:       createdAt,
 		WorkingDir:      workingDir.String,
+		Owner:           owner.String,
+		ToolOutputsID:   toolOutputsID.String,
 	}, nil
 }
 
-// GetSessions retrieves all sessions
-func (s *SQLiteSessionStore) GetSessions(ctx context.Context) ([]*Session, error) {
-	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, messages, tools_approved, input_tokens, output_tokens, title, cost, send_user_message, max_iterations, working_dir, created_at FROM sessions ORDER BY created_at DESC")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var sessions []*Session
-	for rows.Next() {
-		var messagesJSON, toolsApprovedStr, inputTokensStr, outputTokensStr, titleStr, costStr, sendUserMessageStr, maxIterationsStr, createdAtStr string
-		var sessionID string
-		var workingDir sql.NullString
-
-		err := rows.Scan(&sessionID, &messagesJSON, &toolsApprovedStr, &inputTokensStr, &outputTokensStr, &titleStr, &costStr, &sendUserMessageStr, &maxIterationsStr, &workingDir, &createdAtStr)
-		if err != nil {
-			return nil, err
-		}
-
-		// Ok listen up, we used to only store messages in the database, but now we
-		// store messages and sub-sessions. So we need to handle both cases.
-		// We do this in a kind of hacky way, but it works. "AgentFilename" is always present
-		// in a message in the old format, so we check for it to determine the format.
-		var items []Item
-		var messages []Message
-		if err := json.Unmarshal([]byte(messagesJSON), &messages); err != nil {
-			return nil, err
-		}
-		if len(messages) > 0 && messages[0].AgentFilename == "" {
-			if err := json.Unmarshal([]byte(messagesJSON), &items); err != nil {
-				return nil, err
-			}
-		} else {
-			items = convertMessagesToItems(messages)
-		}
-
-		toolsApproved, err := strconv.ParseBool(toolsApprovedStr)
-		if err != nil {
-			return nil, err
-		}
-
-		inputTokens, err := strconv.Atoi(inputTokensStr)
-		if err != nil {
-			return nil, err
-		}
-
-		outputTokens, err := strconv.Atoi(outputTokensStr)
-		if err != nil {
-			return nil, err
-		}
-
-		cost, err := strconv.ParseFloat(costStr, 64)
-		if err != nil {
-			return nil, err
-		}
-
-		sendUserMessage, err := strconv.ParseBool(sendUserMessageStr)
-		if err != nil {
-			return nil, err
-		}
-
-		maxIterations, err := strconv.Atoi(maxIterationsStr)
-		if err != nil {
-			return nil, err
-		}
-
-		createdAt, err := time.Parse(time.RFC3339, createdAtStr)
-		if err != nil {
-			return nil, err
-		}
-
-		session := &Session{
-			ID:              sessionID,
-			Title:           titleStr,
-			Messages:        items,
-			ToolsApproved:   toolsApproved,
-			InputTokens:     inputTokens,
-			OutputTokens:    outputTokens,
-			Cost:            cost,
-			SendUserMessage: sendUserMessage,
-			MaxIterations:   maxIterations,
-			CreatedAt:       createdAt,
-			WorkingDir:      workingDir.String,
-		}
-
-		sessions = append(sessions, session)
-	}
-
-	return sessions, nil
-}
-
 // GetSessionsByAgent retrieves all sessions for a specific agent
 func (s *SQLiteSessionStore) GetSessionsByAgent(ctx context.Context, agentFilename string) ([]*Session, error) {
 	allSessions, err := s.GetSessions(ctx)
@@ -306,6 +304,15 @@ func (s *SQLiteSessionStore) DeleteSession(ctx context.Context, id string) error
 		return ErrEmptyID
 	}
 
+	var toolOutputsKey string
+	err := s.db.QueryRowContext(ctx, "SELECT COALESCE(NULLIF(tool_outputs_id, ''), id) FROM sessions WHERE id = ?", id).Scan(&toolOutputsKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
 	result, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE id = ?", id)
 	if err != nil {
 		return err
@@ -320,9 +327,48 @@ func (s *SQLiteSessionStore) DeleteSession(ctx context.Context, id string) error
 		return ErrNotFound
 	}
 
+	s.deleteToolOutputs(ctx, toolOutputsKey)
+
 	return nil
 }
 
+// ToolOutputs returns the artifact store of the large tool outputs of the
+// sessions, nil when they aren't deleted with the sessions
+func (s *SQLiteSessionStore) ToolOutputs() *artifacts.Store {
+	return s.toolOutputs
+}
+
+// ToolOutputStore returns where the runtime saves the large tool outputs of
+// the sessions of the store, nil when the store doesn't delete them. Saving
+// them elsewhere would leave them behind once their sessions are deleted.
+func ToolOutputStore(store Store) *artifacts.Store {
+	if s, ok := store.(interface{ ToolOutputs() *artifacts.Store }); ok {
+		return s.ToolOutputs()
+	}
+	return nil
+}
+
+// deleteToolOutputs deletes the tool outputs saved with the given key, unless
+// a fork of the deleted session still uses them
+func (s *SQLiteSessionStore) deleteToolOutputs(ctx context.Context, key string) {
+	if s.toolOutputs == nil {
+		return
+	}
+
+	var users int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sessions WHERE id = ? OR tool_outputs_id = ?", key, key).Scan(&users); err != nil {
+		slog.Warn("Failed to check if the tool outputs are still used", "key", key, "error", err)
+		return
+	}
+	if users > 0 {
+		return
+	}
+
+	if err := s.toolOutputs.Delete(key); err != nil {
+		slog.Warn("Failed to delete the tool outputs of the session", "key", key, "error", err)
+	}
+}
+
 // UpdateSession updates an existing session
 func (s *SQLiteSessionStore) UpdateSession(ctx context.Context, session *Session) error {
 	if session.ID == "" {
@@ -334,9 +380,14 @@ func (s *SQLiteSessionStore) UpdateSession(ctx context.Context, session *Session
 		return err
 	}
 
+	approvedRulesJSON, err := marshalApprovedRules(session.ApprovedRules)
+	if err != nil {
+		return err
+	}
+
 	result, err := s.db.ExecContext(ctx,
-		"UPDATE sessions SET messages = ?, title = ?, tools_approved = ?, input_tokens = ?, output_tokens = ?, cost = ?, send_user_message = ?, max_iterations = ?, working_dir = ? WHERE id = ?",
-		string(itemsJSON), session.Title, session.ToolsApproved, session.InputTokens, session.OutputTokens, session.Cost, session.SendUserMessage, session.MaxIterations, session.WorkingDir, session.ID)
+		"UPDATE sessions SET messages = ?, title = ?, tools_approved = ?, input_tokens = ?, output_tokens = ?, cost = ?, send_user_message = ?, max_iterations = ?, working_dir = ?, approved_rules = ?, max_cost = ?, max_tokens_total = ?, total_tokens = ? WHERE id = ?",
+		string(itemsJSON), session.Title, session.ToolsApproved, session.InputTokens, session.OutputTokens, session.Cost, session.SendUserMessage, session.MaxIterations, session.WorkingDir, approvedRulesJSON, session.MaxCost, session.MaxTokensTotal, session.TotalTokens, session.ID)
 	if err != nil {
 		return err
 	}
@@ -353,6 +404,39 @@ func (s *SQLiteSessionStore) UpdateSession(ctx context.Context, session *Session
 	return nil
 }
 
+// ForkSession creates and stores a new session from the items of an existing
+// session up to and including the item at messageIndex
+func (s *SQLiteSessionStore) ForkSession(ctx context.Context, id string, messageIndex int) (*Session, error) {
+	session, err := s.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	forked, err := session.Fork(messageIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.AddSession(ctx, forked); err != nil {
+		return nil, err
+	}
+
+	return forked, nil
+}
+
+func marshalApprovedRules(rules []permissions.Rule) (string, error) {
+	if len(rules) == 0 {
+		return "", nil
+	}
+
+	rulesJSON, err := json.Marshal(rules)
+	if err != nil {
+		return "", err
+	}
+
+	return string(rulesJSON), nil
+}
+
 // Close closes the database connection
 func (s *SQLiteSessionStore) Close() error {
 	return s.db.Close()
@@ -9,7 +9,9 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/rumpl/rb/pkg/agent"
+	"github.com/rumpl/rb/pkg/artifacts"
 	"github.com/rumpl/rb/pkg/chat"
+	"github.com/rumpl/rb/pkg/permissions"
 )
 
 func TestStoreAgentName(t *testing.T) {
@@ -210,3 +212,160 @@ func TestStoreAgentNameJSON(t *testing.T) {
 	assert.Equal(t, "my-agent", retrievedSession.Messages[1].Message.AgentName)      // First agent
 	assert.Equal(t, "another-agent", retrievedSession.Messages[2].Message.AgentName) // Second agent
 }
+
+func TestStoreApprovedRules(t *testing.T) {
+	tempDB := filepath.Join(t.TempDir(), "test_store.db")
+
+	store, err := NewSQLiteSessionStore(tempDB)
+	require.NoError(t, err)
+	defer store.(*SQLiteSessionStore).Close()
+
+	session := &Session{
+		ID:        "rules-session",
+		Messages:  []Item{NewMessageItem(UserMessage("", "Hello"))},
+		CreatedAt: time.Now(),
+	}
+
+	err = store.AddSession(t.Context(), session)
+	require.NoError(t, err)
+
+	retrievedSession, err := store.GetSession(t.Context(), "rules-session")
+	require.NoError(t, err)
+	assert.Empty(t, retrievedSession.ApprovedRules)
+
+	session.ApproveRule(permissions.Rule{Tool: "shell", Args: map[string]string{"cmd": "git *"}})
+	err = store.UpdateSession(t.Context(), session)
+	require.NoError(t, err)
+
+	retrievedSession, err = store.GetSession(t.Context(), "rules-session")
+	require.NoError(t, err)
+	assert.Equal(t, []permissions.Rule{{Tool: "shell", Args: map[string]string{"cmd": "git *"}}}, retrievedSession.ApprovedRules)
+}
+
+func TestStoreBudgets(t *testing.T) {
+	tempDB := filepath.Join(t.TempDir(), "test_store.db")
+
+	store, err := NewSQLiteSessionStore(tempDB)
+	require.NoError(t, err)
+	defer store.(*SQLiteSessionStore).Close()
+
+	session := New(WithUserMessage("", "Hello"), WithMaxCost(1.5), WithMaxTokensTotal(10000))
+	require.NoError(t, store.AddSession(t.Context(), session))
+
+	session.TotalTokens = 1234
+	require.NoError(t, store.UpdateSession(t.Context(), session))
+
+	retrieved, err := store.GetSession(t.Context(), session.ID)
+	require.NoError(t, err)
+	assert.InDelta(t, 1.5, retrieved.MaxCost, 0.0001)
+	assert.Equal(t, 10000, retrieved.MaxTokensTotal)
+	assert.Equal(t, 1234, retrieved.TotalTokens)
+}
+
+func TestForkSession(t *testing.T) {
+	tempDB := filepath.Join(t.TempDir(), "test_store.db")
+
+	store, err := NewSQLiteSessionStore(tempDB)
+	require.NoError(t, err)
+	defer store.(*SQLiteSessionStore).Close()
+
+	testAgent := agent.New("root", "test instruction")
+	subSession := New(WithUserMessage("", "Sub task"))
+	session := &Session{
+		ID:    "original-session",
+		Title: "Original",
+		Messages: []Item{
+			NewMessageItem(UserMessage("", "Hello")),
+			NewSubSessionItem(subSession),
+			NewMessageItem(NewAgentMessage(testAgent, &chat.Message{Role: chat.MessageRoleAssistant, Content: "First answer"})),
+			NewMessageItem(UserMessage("", "Try again")),
+		},
+		InputTokens:  10,
+		OutputTokens: 20,
+		Cost:         0.5,
+		WorkingDir:   "/tmp/project",
+		Owner:        "alice",
+		CreatedAt:    time.Now(),
+	}
+	require.NoError(t, store.AddSession(t.Context(), session))
+
+	forked, err := store.ForkSession(t.Context(), "original-session", 2)
+	require.NoError(t, err)
+	assert.NotEqual(t, session.ID, forked.ID)
+	assert.Equal(t, "Original (fork)", forked.Title)
+
+	retrieved, err := store.GetSession(t.Context(), forked.ID)
+	require.NoError(t, err)
+	require.Len(t, retrieved.Messages, 3)
+	assert.Equal(t, "First answer", retrieved.Messages[2].Message.Message.Content)
+	require.True(t, retrieved.Messages[1].IsSubSession())
+	assert.Equal(t, "Sub task", retrieved.Messages[1].SubSession.Messages[0].Message.Message.Content)
+	assert.Equal(t, 10, retrieved.InputTokens)
+	assert.Equal(t, 20, retrieved.OutputTokens)
+	assert.InDelta(t, 0.5, retrieved.Cost, 0.0001)
+	assert.Equal(t, "/tmp/project", retrieved.WorkingDir)
+	assert.Equal(t, "alice", retrieved.Owner)
+
+	// The original session is left untouched
+	original, err := store.GetSession(t.Context(), "original-session")
+	require.NoError(t, err)
+	assert.Len(t, original.Messages, 4)
+
+	_, err = store.ForkSession(t.Context(), "original-session", 4)
+	require.Error(t, err)
+
+	_, err = store.ForkSession(t.Context(), "missing", 0)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestDeleteSessionToolOutputs(t *testing.T) {
+	tempDB := filepath.Join(t.TempDir(), "test_store.db")
+	toolOutputs := artifacts.NewStore(t.TempDir())
+
+	store, err := NewSQLiteSessionStore(tempDB, WithToolOutputStore(toolOutputs))
+	require.NoError(t, err)
+	defer store.(*SQLiteSessionStore).Close()
+	assert.Same(t, toolOutputs, ToolOutputStore(store))
+	assert.Nil(t, ToolOutputStore(nil))
+
+	session := New(WithUserMessage("", "Hello"))
+	require.NoError(t, store.AddSession(t.Context(), session))
+	handle, err := toolOutputs.Save(session.ToolOutputsKey(), "large output")
+	require.NoError(t, err)
+
+	// Forks read the tool outputs of the session they come from
+	forked, err := store.ForkSession(t.Context(), session.ID, 0)
+	require.NoError(t, err)
+	retrieved, err := store.GetSession(t.Context(), forked.ID)
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, retrieved.ToolOutputsKey())
+
+	// The tool outputs are kept as long as a session uses them
+	require.NoError(t, store.DeleteSession(t.Context(), session.ID))
+	content, err := toolOutputs.Read(retrieved.ToolOutputsKey(), handle)
+	require.NoError(t, err)
+	assert.Equal(t, "large output", content)
+
+	require.NoError(t, store.DeleteSession(t.Context(), forked.ID))
+	_, err = toolOutputs.Read(retrieved.ToolOutputsKey(), handle)
+	require.ErrorIs(t, err, artifacts.ErrNotFound)
+
+	require.ErrorIs(t, store.DeleteSession(t.Context(), forked.ID), ErrNotFound)
+}
+
+func TestSave(t *testing.T) {
+	store, err := NewSQLiteSessionStore(filepath.Join(t.TempDir(), "test_store.db"))
+	require.NoError(t, err)
+	defer store.(*SQLiteSessionStore).Close()
+
+	session := New(WithUserMessage("", "Hello"))
+	require.NoError(t, Save(t.Context(), store, session))
+
+	session.Title = "Greetings"
+	require.NoError(t, Save(t.Context(), store, session))
+
+	sessions, err := store.GetSessions(t.Context())
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "Greetings", sessions[0].Title)
+}
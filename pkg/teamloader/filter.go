@@ -24,6 +24,10 @@ type filterTools struct {
 	toolNames []string
 }
 
+func (f *filterTools) Unwrap() tools.ToolSet {
+	return f.ToolSet
+}
+
 func (f *filterTools) Tools(ctx context.Context) ([]tools.Tool, error) {
 	allTools, err := f.ToolSet.Tools(ctx)
 	if err != nil {
@@ -22,6 +22,10 @@ type replaceInstruction struct {
 	instruction string
 }
 
+func (a replaceInstruction) Unwrap() tools.ToolSet {
+	return a.ToolSet
+}
+
 func (a replaceInstruction) Instructions() string {
 	return strings.Replace(a.instruction, "{ORIGINAL_INSTRUCTIONS}", a.ToolSet.Instructions(), 1)
 }
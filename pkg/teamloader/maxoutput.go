@@ -0,0 +1,41 @@
+package teamloader
+
+import (
+	"context"
+
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+type maxOutputSizeTools struct {
+	tools.ToolSet
+	maxOutputSize int
+}
+
+func (f *maxOutputSizeTools) Unwrap() tools.ToolSet {
+	return f.ToolSet
+}
+
+func (f *maxOutputSizeTools) Tools(ctx context.Context) ([]tools.Tool, error) {
+	allTools, err := f.ToolSet.Tools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range allTools {
+		allTools[i].MaxOutputSize = f.maxOutputSize
+	}
+
+	return allTools, nil
+}
+
+// WithMaxOutputSize caps the size of the outputs of the tools of a toolset
+func WithMaxOutputSize(inner tools.ToolSet, maxOutputSize int) tools.ToolSet {
+	if maxOutputSize <= 0 {
+		return inner
+	}
+
+	return &maxOutputSizeTools{
+		ToolSet:       inner,
+		maxOutputSize: maxOutputSize,
+	}
+}
@@ -0,0 +1,28 @@
+package teamloader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+func TestWithMaxOutputSize(t *testing.T) {
+	t.Parallel()
+
+	inner := &mockToolSet{
+		toolsFunc: func(ctx context.Context) ([]tools.Tool, error) {
+			return []tools.Tool{{Name: "search"}, {Name: "shell"}}, nil
+		},
+	}
+
+	assert.Same(t, inner, WithMaxOutputSize(inner, 0))
+
+	allTools, err := WithMaxOutputSize(inner, 1024).Tools(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 1024, allTools[0].MaxOutputSize)
+	assert.Equal(t, 1024, allTools[1].MaxOutputSize)
+}
@@ -0,0 +1,41 @@
+package teamloader
+
+import (
+	"context"
+
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+type parallelTools struct {
+	tools.ToolSet
+	parallel bool
+}
+
+func (f *parallelTools) Unwrap() tools.ToolSet {
+	return f.ToolSet
+}
+
+func (f *parallelTools) Tools(ctx context.Context) ([]tools.Tool, error) {
+	allTools, err := f.ToolSet.Tools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range allTools {
+		allTools[i].Parallel = &f.parallel
+	}
+
+	return allTools, nil
+}
+
+// WithParallel overrides whether the tools of a toolset can run concurrently
+func WithParallel(inner tools.ToolSet, parallel *bool) tools.ToolSet {
+	if parallel == nil {
+		return inner
+	}
+
+	return &parallelTools{
+		ToolSet:  inner,
+		parallel: *parallel,
+	}
+}
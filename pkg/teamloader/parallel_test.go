@@ -0,0 +1,38 @@
+package teamloader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+func TestWithParallel(t *testing.T) {
+	t.Parallel()
+
+	inner := &mockToolSet{
+		toolsFunc: func(ctx context.Context) ([]tools.Tool, error) {
+			return []tools.Tool{
+				{Name: "read", Annotations: tools.ToolAnnotations{ReadOnlyHint: true}},
+				{Name: "write"},
+			}, nil
+		},
+	}
+
+	assert.Same(t, inner, WithParallel(inner, nil))
+
+	enabled := true
+	allTools, err := WithParallel(inner, &enabled).Tools(t.Context())
+	require.NoError(t, err)
+	assert.True(t, allTools[0].IsParallelSafe())
+	assert.True(t, allTools[1].IsParallelSafe())
+
+	disabled := false
+	allTools, err = WithParallel(inner, &disabled).Tools(t.Context())
+	require.NoError(t, err)
+	assert.False(t, allTools[0].IsParallelSafe())
+	assert.False(t, allTools[1].IsParallelSafe())
+}
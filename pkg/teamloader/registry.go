@@ -13,7 +13,9 @@ import (
 	"github.com/rumpl/rb/pkg/gateway"
 	"github.com/rumpl/rb/pkg/js"
 	"github.com/rumpl/rb/pkg/memory/database/sqlite"
+	"github.com/rumpl/rb/pkg/memory/embedding"
 	"github.com/rumpl/rb/pkg/path"
+	"github.com/rumpl/rb/pkg/sandbox"
 	"github.com/rumpl/rb/pkg/tools"
 	"github.com/rumpl/rb/pkg/tools/builtin"
 	"github.com/rumpl/rb/pkg/tools/mcp"
@@ -94,12 +96,19 @@ func createMemoryTool(ctx context.Context, toolset latest.Toolset, parentDir str
 		return nil, fmt.Errorf("failed to create memory database directory: %w", err)
 	}
 
-	db, err := sqlite.NewMemoryDatabase(validatedMemoryPath)
+	var dbOpts []sqlite.Opt
+	if toolset.Embeddings {
+		dbOpts = append(dbOpts, sqlite.WithEmbedder(embedding.NewHashing()))
+	}
+	db, err := sqlite.NewMemoryDatabase(validatedMemoryPath, dbOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create memory database: %w", err)
 	}
 
-	return builtin.NewMemoryTool(db), nil
+	return builtin.NewMemoryTool(db,
+		builtin.WithAgentScope(toolset.Scope == "agent"),
+		builtin.WithRecall(toolset.Recall),
+	), nil
 }
 
 func createThinkTool(ctx context.Context, toolset latest.Toolset, parentDir string, envProvider environment.Provider, runtimeConfig config.RuntimeConfig) (tools.ToolSet, error) {
@@ -107,12 +116,28 @@ func createThinkTool(ctx context.Context, toolset latest.Toolset, parentDir stri
 }
 
 func createShellTool(ctx context.Context, toolset latest.Toolset, parentDir string, envProvider environment.Provider, runtimeConfig config.RuntimeConfig) (tools.ToolSet, error) {
+	t, err := NewShellTool(ctx, toolset, envProvider, runtimeConfig)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// NewShellTool creates the shell tool of a shell toolset, opts are applied
+// after the options of the toolset
+func NewShellTool(ctx context.Context, toolset latest.Toolset, envProvider environment.Provider, runtimeConfig config.RuntimeConfig, opts ...builtin.ShellOpt) (*builtin.ShellTool, error) {
 	env, err := environment.ExpandAll(ctx, environment.ToValues(toolset.Env), envProvider)
 	if err != nil {
 		return nil, fmt.Errorf("failed to expand the tool's environment variables: %w", err)
 	}
-	env = append(env, os.Environ()...)
-	return builtin.NewShellTool(env), nil
+	sandboxConfig, err := getSandboxConfig(toolset, runtimeConfig)
+	if err != nil {
+		return nil, err
+	}
+	env = append(env, inheritedEnv(sandboxConfig)...)
+
+	opts = append([]builtin.ShellOpt{builtin.WithPersistentShell(toolset.Persistent), builtin.WithSandbox(sandboxConfig)}, opts...)
+	return builtin.NewShellTool(env, opts...), nil
 }
 
 func createScriptTool(ctx context.Context, toolset latest.Toolset, parentDir string, envProvider environment.Provider, runtimeConfig config.RuntimeConfig) (tools.ToolSet, error) {
@@ -124,11 +149,31 @@ func createScriptTool(ctx context.Context, toolset latest.Toolset, parentDir str
 	if err != nil {
 		return nil, fmt.Errorf("failed to expand the tool's environment variables: %w", err)
 	}
-	env = append(env, os.Environ()...)
-	return builtin.NewScriptShellTool(toolset.Shell, env), nil
+	sandboxConfig, err := getSandboxConfig(toolset, runtimeConfig)
+	if err != nil {
+		return nil, err
+	}
+	env = append(env, inheritedEnv(sandboxConfig)...)
+
+	return builtin.NewScriptShellTool(toolset.Shell, env, builtin.WithScriptSandbox(sandboxConfig)), nil
 }
 
-func createFilesystemTool(ctx context.Context, toolset latest.Toolset, parentDir string, envProvider environment.Provider, runtimeConfig config.RuntimeConfig) (tools.ToolSet, error) {
+// inheritedEnv returns the variables of rb's environment the commands of a
+// toolset get on top of its env: sandboxed commands only get the allowed ones
+func inheritedEnv(sandboxConfig *sandbox.Config) []string {
+	if sandboxConfig != nil {
+		return sandbox.Environ()
+	}
+	return os.Environ()
+}
+
+// getSandboxConfig returns the sandbox the commands of a shell or script toolset
+// run in, or nil if they run directly on the host.
+func getSandboxConfig(toolset latest.Toolset, runtimeConfig config.RuntimeConfig) (*sandbox.Config, error) {
+	if toolset.Sandbox == nil && !runtimeConfig.Sandbox {
+		return nil, nil
+	}
+
 	wd := runtimeConfig.WorkingDir
 	if wd == "" {
 		var err error
@@ -138,14 +183,48 @@ func createFilesystemTool(ctx context.Context, toolset latest.Toolset, parentDir
 		}
 	}
 
-	var opts []builtin.FileSystemOpt
+	cfg := &sandbox.Config{
+		Writable: []string{wd},
+	}
+	if toolset.Sandbox != nil {
+		cfg.Network = toolset.Sandbox.Network
+		for _, path := range toolset.Sandbox.Writable {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(wd, path)
+			}
+			cfg.Writable = append(cfg.Writable, path)
+		}
+	}
+
+	return cfg, nil
+}
+
+func createFilesystemTool(ctx context.Context, toolset latest.Toolset, parentDir string, envProvider environment.Provider, runtimeConfig config.RuntimeConfig) (tools.ToolSet, error) {
+	t, err := NewFilesystemTool(toolset, runtimeConfig)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// NewFilesystemTool creates the filesystem tool of a filesystem toolset, opts
+// are applied after the options of the toolset
+func NewFilesystemTool(toolset latest.Toolset, runtimeConfig config.RuntimeConfig, opts ...builtin.FileSystemOpt) (*builtin.FilesystemTool, error) {
+	wd := runtimeConfig.WorkingDir
+	if wd == "" {
+		var err error
+		wd, err = os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get working directory: %w", err)
+		}
+	}
 
 	// Handle ignore_vcs configuration (default to true)
 	ignoreVCS := true
 	if toolset.IgnoreVCS != nil {
 		ignoreVCS = *toolset.IgnoreVCS
 	}
-	opts = append(opts, builtin.WithIgnoreVCS(ignoreVCS))
+	toolsetOpts := []builtin.FileSystemOpt{builtin.WithIgnoreVCS(ignoreVCS)}
 
 	// Handle post-edit commands
 	if len(toolset.PostEdit) > 0 {
@@ -156,10 +235,10 @@ func createFilesystemTool(ctx context.Context, toolset latest.Toolset, parentDir
 				Cmd:  pe.Cmd,
 			}
 		}
-		opts = append(opts, builtin.WithPostEditCommands(postEditConfigs))
+		toolsetOpts = append(toolsetOpts, builtin.WithPostEditCommands(postEditConfigs))
 	}
 
-	return builtin.NewFilesystemTool([]string{wd}, opts...), nil
+	return builtin.NewFilesystemTool([]string{wd}, append(toolsetOpts, opts...)...), nil
 }
 
 func createAPITool(ctx context.Context, toolset latest.Toolset, parentDir string, envProvider environment.Provider, runtimeConfig config.RuntimeConfig) (tools.ToolSet, error) {
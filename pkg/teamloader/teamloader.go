@@ -11,10 +11,13 @@ import (
 	"github.com/rumpl/rb/pkg/agent"
 	"github.com/rumpl/rb/pkg/config"
 	latest "github.com/rumpl/rb/pkg/config/v2"
+	"github.com/rumpl/rb/pkg/contextwindow"
 	"github.com/rumpl/rb/pkg/environment"
 	"github.com/rumpl/rb/pkg/js"
 	"github.com/rumpl/rb/pkg/model/provider"
 	"github.com/rumpl/rb/pkg/model/provider/options"
+	"github.com/rumpl/rb/pkg/model/provider/replay"
+	"github.com/rumpl/rb/pkg/permissions"
 	"github.com/rumpl/rb/pkg/team"
 	"github.com/rumpl/rb/pkg/tools"
 	"github.com/rumpl/rb/pkg/tools/builtin"
@@ -149,6 +152,14 @@ func LoadFrom(ctx context.Context, source AgentSource, runtimeConfig config.Runt
 		return nil, err
 	}
 
+	// Like env files, the scripts of fake models are relative to the agent config file
+	for name, model := range cfg.Models {
+		if model.Provider == "fake" && model.Model != "" && !filepath.IsAbs(model.Model) {
+			model.Model = filepath.Join(parentDir, model.Model)
+			cfg.Models[name] = model
+		}
+	}
+
 	// Early check for required env vars before loading models and tools.
 	if err := config.CheckRequiredEnvVars(ctx, cfg, env, runtimeConfig); err != nil {
 		return nil, err
@@ -167,10 +178,21 @@ func LoadFrom(ctx context.Context, source AgentSource, runtimeConfig config.Runt
 			agent.WithAddEnvironmentInfo(agentConfig.AddEnvironmentInfo),
 			agent.WithAddPromptFiles(agentConfig.AddPromptFiles),
 			agent.WithMaxIterations(agentConfig.MaxIterations),
+			agent.WithMaxCost(agentConfig.MaxCost),
+			agent.WithMaxTokensTotal(agentConfig.MaxTokensTotal),
 			agent.WithNumHistoryItems(agentConfig.NumHistoryItems),
+			agent.WithMaxParallelToolCalls(agentConfig.MaxParallelToolCalls),
+			agent.WithMaxRetries(agentConfig.MaxRetries),
 			agent.WithCommands(js.Expand(ctx, agentConfig.Commands, env)),
 		}
 
+		if agentConfig.Permissions != nil {
+			opts = append(opts, agent.WithPermissions(getPermissionsForAgent(agentConfig.Permissions)))
+		}
+		if agentConfig.Context != nil {
+			opts = append(opts, agent.WithContextWindow(getContextWindowForAgent(agentConfig.Context)))
+		}
+
 		models, err := getModelsForAgent(ctx, cfg, &agentConfig, env, runtimeConfig)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get models: %w", err)
@@ -179,6 +201,20 @@ func LoadFrom(ctx context.Context, source AgentSource, runtimeConfig config.Runt
 			opts = append(opts, agent.WithModel(model))
 		}
 
+		fallbackModels, err := getFallbackModelsForAgent(ctx, cfg, &agentConfig, env, runtimeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get fallback models: %w", err)
+		}
+		opts = append(opts, agent.WithFallbackModels(fallbackModels...))
+
+		if agentConfig.Compaction != nil {
+			compaction, err := getCompactionForAgent(ctx, cfg, &agentConfig, env, runtimeConfig)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get compaction model: %w", err)
+			}
+			opts = append(opts, agent.WithCompaction(compaction))
+		}
+
 		agentTools, warnings := getToolsForAgent(ctx, &agentConfig, parentDir, env, runtimeConfig, loadOpts.toolsetRegistry)
 		if len(warnings) > 0 {
 			opts = append(opts, agent.WithLoadTimeWarnings(warnings))
@@ -236,17 +272,22 @@ func getModelsForAgent(ctx context.Context, cfg *latest.Config, a *latest.AgentC
 	var models []provider.Provider
 
 	for name := range strings.SplitSeq(a.Model, ",") {
-		modelCfg, exists := cfg.Models[name]
-		if !exists {
-			return nil, fmt.Errorf("model '%s' not found in configuration", name)
+		model, err := getModel(ctx, cfg, name, a, env, runtimeConfig)
+		if err != nil {
+			return nil, err
 		}
 
-		opts := []options.Opt{options.WithGateway(runtimeConfig.ModelsGateway)}
-		if a.StructuredOutput != nil {
-			opts = append(opts, options.WithStructuredOutput(a.StructuredOutput))
-		}
+		models = append(models, model)
+	}
+
+	return models, nil
+}
+
+func getFallbackModelsForAgent(ctx context.Context, cfg *latest.Config, a *latest.AgentConfig, env environment.Provider, runtimeConfig config.RuntimeConfig) ([]provider.Provider, error) {
+	var models []provider.Provider
 
-		model, err := provider.New(ctx, &modelCfg, env, opts...)
+	for _, name := range a.FallbackModels {
+		model, err := getModel(ctx, cfg, name, a, env, runtimeConfig)
 		if err != nil {
 			return nil, err
 		}
@@ -257,6 +298,67 @@ func getModelsForAgent(ctx context.Context, cfg *latest.Config, a *latest.AgentC
 	return models, nil
 }
 
+func getCompactionForAgent(ctx context.Context, cfg *latest.Config, a *latest.AgentConfig, env environment.Provider, runtimeConfig config.RuntimeConfig) (agent.Compaction, error) {
+	compaction := agent.Compaction{
+		Structured: a.Compaction.Mode == "structured",
+		KeepTurns:  a.Compaction.KeepTurns,
+	}
+	if a.Compaction.Model == "" {
+		return compaction, nil
+	}
+
+	model, err := getModel(ctx, cfg, a.Compaction.Model, a, env, runtimeConfig)
+	if err != nil {
+		return agent.Compaction{}, err
+	}
+	compaction.Model = model
+
+	return compaction, nil
+}
+
+func getModel(ctx context.Context, cfg *latest.Config, name string, a *latest.AgentConfig, env environment.Provider, runtimeConfig config.RuntimeConfig) (provider.Provider, error) {
+	modelCfg, exists := cfg.Models[name]
+	if !exists {
+		return nil, fmt.Errorf("model '%s' not found in configuration", name)
+	}
+
+	opts := []options.Opt{options.WithGateway(runtimeConfig.ModelsGateway)}
+	if a.StructuredOutput != nil {
+		opts = append(opts, options.WithStructuredOutput(a.StructuredOutput))
+	}
+
+	return replay.NewModel(ctx, &modelCfg, env, runtimeConfig, opts...)
+}
+
+func getContextWindowForAgent(cfg *latest.ContextConfig) contextwindow.Config {
+	var strategies []contextwindow.Strategy
+	for _, strategy := range cfg.Strategies {
+		strategies = append(strategies, contextwindow.Strategy(strategy))
+	}
+
+	return contextwindow.Config{
+		Limit:               cfg.Limit,
+		Threshold:           cfg.Threshold,
+		Strategies:          strategies,
+		MaxToolOutputTokens: cfg.MaxToolOutputTokens,
+	}
+}
+
+func getPermissionsForAgent(cfg *latest.PermissionsConfig) *permissions.Checker {
+	toRules := func(rules []latest.PermissionRule) []permissions.Rule {
+		var result []permissions.Rule
+		for _, rule := range rules {
+			result = append(result, permissions.Rule{
+				Tool: rule.Tool,
+				Args: rule.Args,
+			})
+		}
+		return result
+	}
+
+	return permissions.NewChecker(toRules(cfg.Allow), toRules(cfg.Ask), toRules(cfg.Deny))
+}
+
 // getToolsForAgent returns the tool definitions for an agent based on its configuration
 func getToolsForAgent(ctx context.Context, a *latest.AgentConfig, parentDir string, envProvider environment.Provider, runtimeConfig config.RuntimeConfig, registry *ToolsetRegistry) ([]tools.ToolSet, []string) {
 	var (
@@ -267,7 +369,11 @@ func getToolsForAgent(ctx context.Context, a *latest.AgentConfig, parentDir stri
 	for i := range a.Toolsets {
 		toolset := a.Toolsets[i]
 
-		tool, err := registry.CreateTool(ctx, toolset, parentDir, envProvider, runtimeConfig)
+		var tool tools.ToolSet
+		steps, err := outputTransformSteps(toolset.OutputTransforms)
+		if err == nil {
+			tool, err = registry.CreateTool(ctx, toolset, parentDir, envProvider, runtimeConfig)
+		}
 		if err != nil {
 			// Collect error but continue loading other toolsets
 			slog.Warn("Toolset configuration failed; skipping", "type", toolset.Type, "ref", toolset.Ref, "command", toolset.Command, "error", err)
@@ -278,6 +384,9 @@ func getToolsForAgent(ctx context.Context, a *latest.AgentConfig, parentDir stri
 		wrapped := WithToolsFilter(tool, toolset.Tools...)
 		wrapped = WithInstructions(wrapped, toolset.Instruction)
 		wrapped = WithToon(wrapped, toolset.Toon)
+		wrapped = WithOutputTransforms(wrapped, steps)
+		wrapped = WithParallel(wrapped, toolset.Parallel)
+		wrapped = WithMaxOutputSize(wrapped, toolset.MaxOutputSize)
 
 		toolSets = append(toolSets, wrapped)
 	}
@@ -8,6 +8,10 @@ import (
 
 	"github.com/rumpl/rb/pkg/config"
 	latest "github.com/rumpl/rb/pkg/config/v2"
+	"github.com/rumpl/rb/pkg/modelsdev"
+	"github.com/rumpl/rb/pkg/runtime"
+	"github.com/rumpl/rb/pkg/sandbox"
+	"github.com/rumpl/rb/pkg/session"
 )
 
 type noEnvProvider struct{}
@@ -84,3 +88,58 @@ func TestToolsetInstructions(t *testing.T) {
 	expected := "Dummy fetch tool instruction"
 	require.Equal(t, expected, instructions)
 }
+
+func TestGetSandboxConfig(t *testing.T) {
+	t.Parallel()
+
+	runtimeConfig := config.RuntimeConfig{WorkingDir: "/work"}
+
+	cfg, err := getSandboxConfig(latest.Toolset{Type: "shell"}, runtimeConfig)
+	require.NoError(t, err)
+	require.Nil(t, cfg)
+
+	cfg, err = getSandboxConfig(latest.Toolset{
+		Type:    "shell",
+		Sandbox: &latest.SandboxConfig{Network: true, Writable: []string{"cache", "/opt/data"}},
+	}, runtimeConfig)
+	require.NoError(t, err)
+	require.Equal(t, &sandbox.Config{Writable: []string{"/work", "/work/cache", "/opt/data"}, Network: true}, cfg)
+
+	// --sandbox sandboxes all the shell and script tools
+	runtimeConfig.Sandbox = true
+	cfg, err = getSandboxConfig(latest.Toolset{Type: "script"}, runtimeConfig)
+	require.NoError(t, err)
+	require.Equal(t, &sandbox.Config{Writable: []string{"/work"}}, cfg)
+}
+
+type noModelStore struct{}
+
+func (noModelStore) GetModel(context.Context, string) (*modelsdev.Model, error) {
+	return nil, nil
+}
+
+func TestFakeModels(t *testing.T) {
+	t.Parallel()
+
+	team, err := Load(t.Context(), "testdata/fake.yaml", config.RuntimeConfig{})
+	require.NoError(t, err)
+
+	rt, err := runtime.New(team, runtime.WithModelStore(noModelStore{}), runtime.WithSessionCompaction(false))
+	require.NoError(t, err)
+
+	sess := session.New(session.WithUserMessage("", "Write a haiku about Go"), session.WithTitle("Fake models"), session.WithToolsApproved(true))
+	_, err = rt.Run(t.Context(), sess)
+	require.NoError(t, err)
+
+	require.Equal(t, "Here is your haiku.", sess.GetLastAssistantMessageContent())
+	require.Equal(t, 150, sess.InputTokens)
+
+	var toolResults []string
+	for _, msg := range sess.GetAllMessages() {
+		if msg.Message.Role == "tool" {
+			toolResults = append(toolResults, msg.Message.Content)
+		}
+	}
+	require.Len(t, toolResults, 1)
+	require.Contains(t, toolResults[0], "Gophers dig deep tunnels")
+}
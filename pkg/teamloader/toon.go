@@ -1,73 +1,23 @@
 package teamloader
 
 import (
-	"context"
-	"encoding/json"
 	"regexp"
 	"strings"
 
-	"github.com/alpkeskin/gotoon"
-
 	"github.com/rumpl/rb/pkg/tools"
+	"github.com/rumpl/rb/pkg/tools/transform"
 )
 
-type toonTools struct {
-	tools.ToolSet
-	toolRegexps []*regexp.Regexp
-}
-
-func (f *toonTools) Tools(ctx context.Context) ([]tools.Tool, error) {
-	allTools, err := f.ToolSet.Tools(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	for i, tool := range allTools {
-		for _, regex := range f.toolRegexps {
-			if !regex.MatchString(tool.Name) {
-				continue
-			}
-
-			handler := tool.Handler
-			tool.Handler = func(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
-				res, err := handler(ctx, toolCall)
-				if err != nil {
-					return res, err
-				}
-
-				var o map[string]any
-				err = json.Unmarshal([]byte(res.Output), &o)
-				if err != nil {
-					return res, nil
-				}
-
-				tooned, err := gotoon.Encode(o)
-				if err != nil {
-					return res, err
-				}
-
-				res.Output = tooned
-				return res, nil
-			}
-			allTools[i] = tool
-		}
-	}
-
-	return allTools, nil
-}
-
+// WithToon encodes the JSON in the outputs of the tools whose name matches one
+// of the comma-separated regular expressions in TOON
 func WithToon(inner tools.ToolSet, toon string) tools.ToolSet {
 	if toon == "" {
 		return inner
 	}
 
-	var toolRegexps []*regexp.Regexp
-
+	step := transform.Step{Transformer: transform.Toon()}
 	for toolName := range strings.SplitSeq(toon, ",") {
-		toolRegexps = append(toolRegexps, regexp.MustCompile(strings.TrimSpace(toolName)))
-	}
-	return &toonTools{
-		ToolSet:     inner,
-		toolRegexps: toolRegexps,
+		step.Tools = append(step.Tools, regexp.MustCompile(strings.TrimSpace(toolName)))
 	}
+	return WithOutputTransforms(inner, []transform.Step{step})
 }
@@ -0,0 +1,98 @@
+package teamloader
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	latest "github.com/rumpl/rb/pkg/config/v2"
+	"github.com/rumpl/rb/pkg/tools"
+	"github.com/rumpl/rb/pkg/tools/transform"
+)
+
+type transformedTools struct {
+	tools.ToolSet
+	steps []transform.Step
+}
+
+func (f *transformedTools) Unwrap() tools.ToolSet {
+	return f.ToolSet
+}
+
+func (f *transformedTools) Tools(ctx context.Context) ([]tools.Tool, error) {
+	allTools, err := f.ToolSet.Tools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, tool := range allTools {
+		matches := false
+		for j := range f.steps {
+			matches = matches || f.steps[j].Matches(tool.Name)
+		}
+		if !matches || tool.Handler == nil {
+			continue
+		}
+
+		handler := tool.Handler
+		allTools[i].Handler = func(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
+			res, err := handler(ctx, toolCall)
+			if err != nil || res == nil {
+				return res, err
+			}
+
+			res.Output = transform.Apply(ctx, f.steps, tool.Name, res.Output)
+			return res, nil
+		}
+	}
+
+	return allTools, nil
+}
+
+// WithOutputTransforms rewrites the outputs of the tools of a toolset with the
+// steps that match them, in order
+func WithOutputTransforms(inner tools.ToolSet, steps []transform.Step) tools.ToolSet {
+	if len(steps) == 0 {
+		return inner
+	}
+
+	return &transformedTools{
+		ToolSet: inner,
+		steps:   steps,
+	}
+}
+
+// outputTransformSteps creates the steps of the output transforms of a toolset
+func outputTransformSteps(transforms []latest.OutputTransform) ([]transform.Step, error) {
+	var steps []transform.Step
+	for _, cfg := range transforms {
+		var transformer transform.Transformer
+		switch cfg.Type {
+		case "toon":
+			transformer = transform.Toon()
+		case "select":
+			var err error
+			transformer, err = transform.Select(cfg.Fields)
+			if err != nil {
+				return nil, err
+			}
+		case "truncate":
+			transformer = transform.Truncate(cfg.MaxLines, cfg.MaxBytes)
+		case "markdown":
+			transformer = transform.Markdown()
+		default:
+			return nil, fmt.Errorf("unknown output transform %q", cfg.Type)
+		}
+
+		step := transform.Step{Transformer: transformer}
+		for _, pattern := range cfg.Tools {
+			regex, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid output transform tool pattern %q: %w", pattern, err)
+			}
+			step.Tools = append(step.Tools, regex)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
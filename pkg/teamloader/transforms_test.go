@@ -0,0 +1,58 @@
+package teamloader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	latest "github.com/rumpl/rb/pkg/config/v2"
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+func TestWithOutputTransforms(t *testing.T) {
+	t.Parallel()
+
+	steps, err := outputTransformSteps([]latest.OutputTransform{
+		{Type: "select", Tools: []string{"^list_"}, Fields: []string{".items[].name"}},
+		{Type: "toon"},
+		{Type: "truncate", Tools: []string{"^read_"}, MaxLines: 1},
+	})
+	require.NoError(t, err)
+
+	inner := &mockToolSet{
+		toolsFunc: func(ctx context.Context) ([]tools.Tool, error) {
+			return []tools.Tool{
+				{Name: "list_items", Handler: mockHandler(`{"items": [{"name": "a", "size": 1}, {"name": "b", "size": 2}]}`)},
+				{Name: "read_file", Handler: mockHandler("first\nsecond\nthird")},
+			}, nil
+		},
+	}
+
+	allTools, err := WithOutputTransforms(inner, steps).Tools(t.Context())
+	require.NoError(t, err)
+	require.Len(t, allTools, 2)
+
+	result, err := allTools[0].Handler(t.Context(), tools.ToolCall{})
+	require.NoError(t, err)
+	assert.Equal(t, "items[2]{name}:\n  a\n  b", result.Output)
+
+	result, err = allTools[1].Handler(t.Context(), tools.ToolCall{})
+	require.NoError(t, err)
+	assert.Equal(t, "first\n[truncated, 6 of 18 bytes shown]", result.Output)
+}
+
+func TestWithOutputTransformsWithoutSteps(t *testing.T) {
+	t.Parallel()
+
+	inner := &mockToolSet{}
+	assert.Same(t, inner, WithOutputTransforms(inner, nil))
+}
+
+func TestOutputTransformStepsInvalidPattern(t *testing.T) {
+	t.Parallel()
+
+	_, err := outputTransformSteps([]latest.OutputTransform{{Type: "toon", Tools: []string{"("}}})
+	require.Error(t, err)
+}
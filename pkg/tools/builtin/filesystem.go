@@ -43,10 +43,39 @@ type PostEditConfig struct {
 	Cmd  string // Command to execute (with $path placeholder)
 }
 
+// FileAccess reads and writes the files the filesystem tools work on
+type FileAccess interface {
+	ReadFile(ctx context.Context, path string) ([]byte, error)
+	// WriteFile writes the file, creating its parent directories
+	WriteFile(ctx context.Context, path string, data []byte) error
+	// Buffered reports whether the content of the file can differ from the
+	// local disk, like an open buffer with unsaved changes
+	Buffered(path string) bool
+}
+
+// localFileAccess accesses the files on the local disk
+type localFileAccess struct{}
+
+func (localFileAccess) ReadFile(_ context.Context, path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (localFileAccess) WriteFile(_ context.Context, path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (localFileAccess) Buffered(string) bool {
+	return false
+}
+
 type FilesystemTool struct {
 	tools.ElicitationTool
 
 	allowedDirectories []string
+	files              FileAccess
 	postEditCommands   []PostEditConfig
 	ignoreVCS          bool
 	repoMatchers       map[string]gitignore.Matcher // map from repo root to matcher
@@ -68,9 +97,18 @@ func WithIgnoreVCS(ignoreVCS bool) FileSystemOpt {
 	}
 }
 
+// WithFileAccess reads and writes the files through files instead of the
+// local disk. Listing and walking directories still use the local disk.
+func WithFileAccess(files FileAccess) FileSystemOpt {
+	return func(t *FilesystemTool) {
+		t.files = files
+	}
+}
+
 func NewFilesystemTool(allowedDirectories []string, opts ...FileSystemOpt) *FilesystemTool {
 	t := &FilesystemTool{
 		allowedDirectories: allowedDirectories,
+		files:              localFileAccess{},
 		repoMatchers:       make(map[string]gitignore.Matcher),
 	}
 	for _, opt := range opts {
@@ -566,7 +604,7 @@ func (t *FilesystemTool) handleEditFile(ctx context.Context, toolCall tools.Tool
 		return &tools.ToolCallResult{Output: fmt.Sprintf("Error: %s", err)}, nil
 	}
 
-	content, err := os.ReadFile(args.Path)
+	content, err := t.files.ReadFile(ctx, args.Path)
 	if err != nil {
 		return &tools.ToolCallResult{Output: fmt.Sprintf("Error reading file: %s", err)}, nil
 	}
@@ -583,7 +621,7 @@ func (t *FilesystemTool) handleEditFile(ctx context.Context, toolCall tools.Tool
 		changes = append(changes, fmt.Sprintf("Edit %d: Replaced %d characters", i+1, len(edit.OldText)))
 	}
 
-	if err := os.WriteFile(args.Path, []byte(modifiedContent), 0o644); err != nil {
+	if err := t.files.WriteFile(ctx, args.Path, []byte(modifiedContent)); err != nil {
 		return &tools.ToolCallResult{Output: fmt.Sprintf("Error writing file: %s", err)}, nil
 	}
 
@@ -805,7 +843,7 @@ func (t *FilesystemTool) handleMoveFile(_ context.Context, toolCall tools.ToolCa
 	return &tools.ToolCallResult{Output: fmt.Sprintf("Successfully moved %s to %s", args.Source, args.Destination)}, nil
 }
 
-func (t *FilesystemTool) handleReadFile(_ context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
+func (t *FilesystemTool) handleReadFile(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
 	var args ReadFileArgs
 	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
 		return nil, fmt.Errorf("failed to parse arguments: %w", err)
@@ -815,7 +853,7 @@ func (t *FilesystemTool) handleReadFile(_ context.Context, toolCall tools.ToolCa
 		return &tools.ToolCallResult{Output: fmt.Sprintf("Error: %s", err)}, nil
 	}
 
-	content, err := os.ReadFile(args.Path)
+	content, err := t.files.ReadFile(ctx, args.Path)
 	if err != nil {
 		return &tools.ToolCallResult{Output: fmt.Sprintf("Error reading file: %s", err)}, nil
 	}
@@ -849,7 +887,7 @@ func (t *FilesystemTool) handleReadMultipleFiles(ctx context.Context, toolCall t
 			continue
 		}
 
-		content, err := os.ReadFile(path)
+		content, err := t.files.ReadFile(ctx, path)
 		if err != nil {
 			contents = append(contents, PathContent{
 				Path:    path,
@@ -948,7 +986,7 @@ func (t *FilesystemTool) handleSearchFiles(_ context.Context, toolCall tools.Too
 	}, nil
 }
 
-func (t *FilesystemTool) handleSearchFilesContent(_ context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
+func (t *FilesystemTool) handleSearchFilesContent(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
 	var args SearchFilesContentArgs
 	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
 		return nil, fmt.Errorf("failed to parse arguments: %w", err)
@@ -1006,7 +1044,13 @@ func (t *FilesystemTool) handleSearchFilesContent(_ context.Context, toolCall to
 			return nil
 		}
 
-		content, err := os.ReadFile(path)
+		// Reading every file through the file access is slow, only the
+		// buffered files can differ from the local disk
+		files := FileAccess(localFileAccess{})
+		if t.files.Buffered(path) {
+			files = t.files
+		}
+		content, err := files.ReadFile(ctx, path)
 		if err != nil {
 			return nil
 		}
@@ -1065,13 +1109,7 @@ func (t *FilesystemTool) handleWriteFile(ctx context.Context, toolCall tools.Too
 		return &tools.ToolCallResult{Output: fmt.Sprintf("Error: %s", err)}, nil
 	}
 
-	// Create parent directory structure if it doesn't exist
-	dir := filepath.Dir(args.Path)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return &tools.ToolCallResult{Output: fmt.Sprintf("Error creating directory structure: %s", err)}, nil
-	}
-
-	if err := os.WriteFile(args.Path, []byte(args.Content), 0o644); err != nil {
+	if err := t.files.WriteFile(ctx, args.Path, []byte(args.Content)); err != nil {
 		return &tools.ToolCallResult{Output: fmt.Sprintf("Error writing file: %s", err)}, nil
 	}
 
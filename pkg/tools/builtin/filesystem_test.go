@@ -1,6 +1,7 @@
 package builtin
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -406,6 +407,73 @@ func TestFilesystemTool_EditFile(t *testing.T) {
 	assert.Contains(t, result.Output, "old text not found")
 }
 
+// bufferedFiles is a file access where some files have unsaved content
+type bufferedFiles struct {
+	buffers map[string]string
+	written map[string]string
+	read    []string
+}
+
+func (f *bufferedFiles) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	f.read = append(f.read, path)
+	if content, ok := f.buffers[path]; ok {
+		return []byte(content), nil
+	}
+	return localFileAccess{}.ReadFile(ctx, path)
+}
+
+func (f *bufferedFiles) WriteFile(_ context.Context, path string, data []byte) error {
+	f.written[path] = string(data)
+	return nil
+}
+
+func (f *bufferedFiles) Buffered(path string) bool {
+	_, ok := f.buffers[path]
+	return ok
+}
+
+func TestFilesystemTool_FileAccess(t *testing.T) {
+	tmpDir := t.TempDir()
+	buffered := filepath.Join(tmpDir, "buffered.txt")
+	onDisk := filepath.Join(tmpDir, "disk.txt")
+	require.NoError(t, os.WriteFile(buffered, []byte("saved content"), 0o644))
+	require.NoError(t, os.WriteFile(onDisk, []byte("disk content"), 0o644))
+
+	files := &bufferedFiles{
+		buffers: map[string]string{buffered: "unsaved content"},
+		written: map[string]string{},
+	}
+	tool := NewFilesystemTool([]string{tmpDir}, WithFileAccess(files))
+
+	result := callHandler(t, getToolHandler(t, tool, "read_file"), map[string]any{"path": buffered})
+	assert.Equal(t, "unsaved content", result.Output)
+
+	result = callHandler(t, getToolHandler(t, tool, "read_multiple_files"), map[string]any{"paths": []string{buffered, onDisk}})
+	assert.Contains(t, result.Output, "unsaved content")
+	assert.Contains(t, result.Output, "disk content")
+
+	files.read = nil
+	result = callHandler(t, getToolHandler(t, tool, "search_files_content"), map[string]any{"path": tmpDir, "query": "content"})
+	assert.Contains(t, result.Output, "buffered.txt:1:9: unsaved content")
+	assert.Contains(t, result.Output, "disk.txt:1:6: disk content")
+	assert.Equal(t, []string{buffered}, files.read)
+
+	result = callHandler(t, getToolHandler(t, tool, "edit_file"), map[string]any{
+		"path":  buffered,
+		"edits": []map[string]any{{"oldText": "unsaved", "newText": "edited"}},
+	})
+	assert.Contains(t, result.Output, "File edited successfully")
+	assert.Equal(t, "edited content", files.written[buffered])
+
+	result = callHandler(t, getToolHandler(t, tool, "write_file"), map[string]any{"path": onDisk, "content": "new content"})
+	assert.Contains(t, result.Output, "File written successfully")
+	assert.Equal(t, "new content", files.written[onDisk])
+
+	content, err := os.ReadFile(onDisk)
+	require.NoError(t, err)
+	assert.Equal(t, "disk content", string(content))
+}
+
 func TestFilesystemTool_SearchFiles(t *testing.T) {
 	tmpDir := t.TempDir()
 
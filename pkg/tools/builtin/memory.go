@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/rumpl/rb/pkg/memory/database"
@@ -11,33 +12,89 @@ import (
 )
 
 const (
-	ToolNameAddMemory    = "add_memory"
-	ToolNameGetMemories  = "get_memories"
-	ToolNameDeleteMemory = "delete_memory"
+	ToolNameAddMemory      = "add_memory"
+	ToolNameGetMemories    = "get_memories"
+	ToolNameSearchMemories = "search_memories"
+	ToolNameUpdateMemory   = "update_memory"
+	ToolNameDeleteMemory   = "delete_memory"
 )
 
+const defaultSearchLimit = 10
+
 type DB interface {
 	AddMemory(ctx context.Context, memory database.UserMemory) error
-	GetMemories(ctx context.Context) ([]database.UserMemory, error)
+	SearchMemories(ctx context.Context, query database.Query) ([]database.UserMemory, error)
+	UpdateMemory(ctx context.Context, memory database.UserMemory) error
 	DeleteMemory(ctx context.Context, memory database.UserMemory) error
 }
 
 type MemoryTool struct {
 	tools.ElicitationTool
 	db DB
+	// agentScope keeps the memories of each agent apart, on top of the shared memories
+	agentScope bool
+	// recall is the number of relevant memories added to the system prompt
+	recall int
 }
 
 // Make sure Memory Tool implements the ToolSet Interface
-var _ tools.ToolSet = (*MemoryTool)(nil)
+var (
+	_ tools.ToolSet         = (*MemoryTool)(nil)
+	_ tools.ContextProvider = (*MemoryTool)(nil)
+)
+
+type MemoryOpt func(*MemoryTool)
+
+// WithAgentScope saves the memories for the agent that adds them only, the
+// agent still sees the shared memories
+func WithAgentScope(agentScope bool) MemoryOpt {
+	return func(t *MemoryTool) {
+		t.agentScope = agentScope
+	}
+}
 
-func NewMemoryTool(manager DB) *MemoryTool {
-	return &MemoryTool{
+// WithRecall adds up to limit memories relevant to the last message of the
+// user to the system prompt
+func WithRecall(limit int) MemoryOpt {
+	return func(t *MemoryTool) {
+		t.recall = limit
+	}
+}
+
+func NewMemoryTool(manager DB, opts ...MemoryOpt) *MemoryTool {
+	t := &MemoryTool{
 		db: manager,
 	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
 }
 
 type AddMemoryArgs struct {
-	Memory string `json:"memory" jsonschema:"The memory content to store"`
+	Memory   string   `json:"memory" jsonschema:"The memory content to store"`
+	Category string   `json:"category,omitempty" jsonschema:"The category of the memory, like preferences, facts or projects"`
+	Tags     []string `json:"tags,omitempty" jsonschema:"Tags to find the memory with"`
+}
+
+type GetMemoriesArgs struct {
+	Category string   `json:"category,omitempty" jsonschema:"Only return the memories of this category"`
+	Tags     []string `json:"tags,omitempty" jsonschema:"Only return the memories with all these tags"`
+	Limit    int      `json:"limit,omitempty" jsonschema:"The maximum number of memories to return, the latest first (default: all)"`
+}
+
+type SearchMemoriesArgs struct {
+	Query    string   `json:"query" jsonschema:"What to search the memories for"`
+	Category string   `json:"category,omitempty" jsonschema:"Only search the memories of this category"`
+	Tags     []string `json:"tags,omitempty" jsonschema:"Only search the memories with all these tags"`
+	Limit    int      `json:"limit,omitempty" jsonschema:"The maximum number of memories to return (default: 10)"`
+}
+
+type UpdateMemoryArgs struct {
+	ID       string   `json:"id" jsonschema:"The ID of the memory to update"`
+	Memory   string   `json:"memory,omitempty" jsonschema:"The new content of the memory"`
+	Category string   `json:"category,omitempty" jsonschema:"The new category of the memory"`
+	Tags     []string `json:"tags,omitempty" jsonschema:"The new tags of the memory, they replace the current ones"`
 }
 
 type DeleteMemoryArgs struct {
@@ -47,11 +104,13 @@ type DeleteMemoryArgs struct {
 func (t *MemoryTool) Instructions() string {
 	return `## Using the memory tool
 
-Before taking any action or responding to the user use the "get_memories" tool to remember things about the user.
+Before taking any action or responding to the user use the "search_memories" tool to remember things about the user that are related to the request.
 Do not talk about using the tool, just use it.
 
 ## Rules
-- Use the memory tool generously to remember things about the user.`
+- Use the memory tool generously to remember things about the user.
+- Give memories a category and tags so that they are easy to find.
+- When something you remember changes, update the memory with "update_memory" instead of adding a new one.`
 }
 
 func (t *MemoryTool) Tools(context.Context) ([]tools.Tool, error) {
@@ -70,7 +129,8 @@ func (t *MemoryTool) Tools(context.Context) ([]tools.Tool, error) {
 		{
 			Name:         ToolNameGetMemories,
 			Category:     "memory",
-			Description:  "Retrieve all stored memories",
+			Description:  "Retrieve the stored memories, the latest first. Prefer search_memories to find the memories related to a topic.",
+			Parameters:   tools.MustSchemaFor[GetMemoriesArgs](),
 			OutputSchema: tools.MustSchemaFor[[]database.UserMemory](),
 			Handler:      t.handleGetMemories,
 			Annotations: tools.ToolAnnotations{
@@ -78,6 +138,29 @@ func (t *MemoryTool) Tools(context.Context) ([]tools.Tool, error) {
 				Title:        "Get Memories",
 			},
 		},
+		{
+			Name:         ToolNameSearchMemories,
+			Category:     "memory",
+			Description:  "Search the stored memories, the most relevant first",
+			Parameters:   tools.MustSchemaFor[SearchMemoriesArgs](),
+			OutputSchema: tools.MustSchemaFor[[]database.UserMemory](),
+			Handler:      t.handleSearchMemories,
+			Annotations: tools.ToolAnnotations{
+				ReadOnlyHint: true,
+				Title:        "Search Memories",
+			},
+		},
+		{
+			Name:         ToolNameUpdateMemory,
+			Category:     "memory",
+			Description:  "Update the content, category or tags of a memory by ID",
+			Parameters:   tools.MustSchemaFor[UpdateMemoryArgs](),
+			OutputSchema: tools.MustSchemaFor[string](),
+			Handler:      t.handleUpdateMemory,
+			Annotations: tools.ToolAnnotations{
+				Title: "Update Memory",
+			},
+		},
 		{
 			Name:         ToolNameDeleteMemory,
 			Category:     "memory",
@@ -92,6 +175,48 @@ func (t *MemoryTool) Tools(context.Context) ([]tools.Tool, error) {
 	}, nil
 }
 
+// ProvideContext implements [tools.ContextProvider], it lists the memories
+// relevant to the query
+func (t *MemoryTool) ProvideContext(ctx context.Context, query string) (string, error) {
+	if t.recall <= 0 || strings.TrimSpace(query) == "" {
+		return "", nil
+	}
+
+	memories, err := t.db.SearchMemories(ctx, database.Query{
+		Text:  query,
+		Agent: t.agent(ctx),
+		Limit: t.recall,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to search memories: %w", err)
+	}
+	if len(memories) == 0 {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("## Relevant memories\n\nThese memories may be related to the request of the user:\n")
+	for _, memory := range memories {
+		fmt.Fprintf(&sb, "- [%s] %s", memory.ID, memory.Memory)
+		if memory.Category != "" {
+			fmt.Fprintf(&sb, " (category: %s)", memory.Category)
+		}
+		if len(memory.Tags) > 0 {
+			fmt.Fprintf(&sb, " (tags: %s)", strings.Join(memory.Tags, ", "))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// agent returns the agent the memories are scoped to, empty for shared memories
+func (t *MemoryTool) agent(ctx context.Context) string {
+	if !t.agentScope {
+		return ""
+	}
+	return tools.AgentName(ctx)
+}
+
 func (t *MemoryTool) handleAddMemory(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
 	var args AddMemoryArgs
 	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
@@ -102,6 +227,9 @@ func (t *MemoryTool) handleAddMemory(ctx context.Context, toolCall tools.ToolCal
 		ID:        fmt.Sprintf("%d", time.Now().UnixNano()),
 		CreatedAt: time.Now().Format(time.RFC3339),
 		Memory:    args.Memory,
+		Category:  args.Category,
+		Tags:      args.Tags,
+		Agent:     t.agent(ctx),
 	}
 
 	if err := t.db.AddMemory(ctx, memory); err != nil {
@@ -113,19 +241,73 @@ func (t *MemoryTool) handleAddMemory(ctx context.Context, toolCall tools.ToolCal
 	}, nil
 }
 
-func (t *MemoryTool) handleGetMemories(ctx context.Context, _ tools.ToolCall) (*tools.ToolCallResult, error) {
-	memories, err := t.db.GetMemories(ctx)
+func (t *MemoryTool) handleGetMemories(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
+	var args GetMemoriesArgs
+	if toolCall.Function.Arguments != "" {
+		if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+			return nil, fmt.Errorf("failed to parse arguments: %w", err)
+		}
+	}
+
+	memories, err := t.db.SearchMemories(ctx, database.Query{
+		Category: args.Category,
+		Tags:     args.Tags,
+		Agent:    t.agent(ctx),
+		Limit:    args.Limit,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get memories: %w", err)
 	}
 
-	result, err := json.Marshal(memories)
+	return memoriesResult(memories)
+}
+
+func (t *MemoryTool) handleSearchMemories(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
+	var args SearchMemoriesArgs
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+		return nil, fmt.Errorf("failed to parse arguments: %w", err)
+	}
+
+	limit := args.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	memories, err := t.db.SearchMemories(ctx, database.Query{
+		Text:     args.Query,
+		Category: args.Category,
+		Tags:     args.Tags,
+		Agent:    t.agent(ctx),
+		Limit:    limit,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal memories: %w", err)
+		return nil, fmt.Errorf("failed to search memories: %w", err)
+	}
+
+	return memoriesResult(memories)
+}
+
+func (t *MemoryTool) handleUpdateMemory(ctx context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
+	var args UpdateMemoryArgs
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+		return nil, fmt.Errorf("failed to parse arguments: %w", err)
+	}
+
+	memory := database.UserMemory{
+		ID:        args.ID,
+		UpdatedAt: time.Now().Format(time.RFC3339),
+		Memory:    args.Memory,
+		Category:  args.Category,
+		Tags:      args.Tags,
+		Agent:     t.agent(ctx),
+	}
+
+	if err := t.db.UpdateMemory(ctx, memory); err != nil {
+		return nil, fmt.Errorf("failed to update memory: %w", err)
 	}
 
 	return &tools.ToolCallResult{
-		Output: string(result),
+		Output: fmt.Sprintf("Memory with ID %s updated successfully", args.ID),
 	}, nil
 }
 
@@ -136,7 +318,8 @@ func (t *MemoryTool) handleDeleteMemory(ctx context.Context, toolCall tools.Tool
 	}
 
 	memory := database.UserMemory{
-		ID: args.ID,
+		ID:    args.ID,
+		Agent: t.agent(ctx),
 	}
 
 	if err := t.db.DeleteMemory(ctx, memory); err != nil {
@@ -148,6 +331,21 @@ func (t *MemoryTool) handleDeleteMemory(ctx context.Context, toolCall tools.Tool
 	}, nil
 }
 
+func memoriesResult(memories []database.UserMemory) (*tools.ToolCallResult, error) {
+	if memories == nil {
+		memories = []database.UserMemory{}
+	}
+
+	result, err := json.Marshal(memories)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal memories: %w", err)
+	}
+
+	return &tools.ToolCallResult{
+		Output: string(result),
+	}, nil
+}
+
 func (t *MemoryTool) Start(context.Context) error {
 	return nil
 }
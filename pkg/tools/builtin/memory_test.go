@@ -24,11 +24,16 @@ func (m *MockDB) AddMemory(ctx context.Context, memory database.UserMemory) erro
 	return args.Error(0)
 }
 
-func (m *MockDB) GetMemories(ctx context.Context) ([]database.UserMemory, error) {
-	args := m.Called(ctx)
+func (m *MockDB) SearchMemories(ctx context.Context, query database.Query) ([]database.UserMemory, error) {
+	args := m.Called(ctx, query)
 	return args.Get(0).([]database.UserMemory), args.Error(1)
 }
 
+func (m *MockDB) UpdateMemory(ctx context.Context, memory database.UserMemory) error {
+	args := m.Called(ctx, memory)
+	return args.Error(0)
+}
+
 func (m *MockDB) DeleteMemory(ctx context.Context, memory database.UserMemory) error {
 	args := m.Called(ctx, memory)
 	return args.Error(0)
@@ -56,7 +61,7 @@ func TestMemoryTool_Tools(t *testing.T) {
 
 	allTools, err := tool.Tools(t.Context())
 	require.NoError(t, err)
-	require.Len(t, allTools, 3)
+	require.Len(t, allTools, 5)
 	for _, tool := range allTools {
 		assert.NotNil(t, tool.Handler)
 		assert.Equal(t, "memory", tool.Category)
@@ -64,7 +69,9 @@ func TestMemoryTool_Tools(t *testing.T) {
 
 	assert.Equal(t, "add_memory", allTools[0].Name)
 	assert.Equal(t, "get_memories", allTools[1].Name)
-	assert.Equal(t, "delete_memory", allTools[2].Name)
+	assert.Equal(t, "search_memories", allTools[2].Name)
+	assert.Equal(t, "update_memory", allTools[3].Name)
+	assert.Equal(t, "delete_memory", allTools[4].Name)
 
 	schema, err := json.Marshal(allTools[0].Parameters)
 	require.NoError(t, err)
@@ -74,6 +81,17 @@ func TestMemoryTool_Tools(t *testing.T) {
 		"memory": {
 			"description": "The memory content to store",
 			"type": "string"
+		},
+		"category": {
+			"description": "The category of the memory, like preferences, facts or projects",
+			"type": "string"
+		},
+		"tags": {
+			"description": "Tags to find the memory with",
+			"type": "array",
+			"items": {
+				"type": "string"
+			}
 		}
 	},
 	"additionalProperties": false,
@@ -82,9 +100,7 @@ func TestMemoryTool_Tools(t *testing.T) {
 	]
 }`, string(schema))
 
-	assert.Nil(t, allTools[1].Parameters)
-
-	schema, err = json.Marshal(allTools[2].Parameters)
+	schema, err = json.Marshal(allTools[4].Parameters)
 	require.NoError(t, err)
 	assert.JSONEq(t, `{
 	"type": "object",
@@ -158,7 +174,7 @@ func TestMemoryTool_HandleGetMemories(t *testing.T) {
 			Memory:    "memory 2",
 		},
 	}
-	manager.On("GetMemories", mock.Anything).Return(memories, nil)
+	manager.On("SearchMemories", mock.Anything, database.Query{}).Return(memories, nil)
 
 	toolCall := tools.ToolCall{
 		Function: tools.FunctionCall{
@@ -185,7 +201,7 @@ func TestMemoryTool_HandleDeleteMemory(t *testing.T) {
 	tool := NewMemoryTool(manager)
 
 	manager.On("DeleteMemory", mock.Anything, mock.MatchedBy(func(memory database.UserMemory) bool {
-		return memory.ID == "1"
+		return memory.ID == "1" && memory.Agent == ""
 	})).Return(nil)
 
 	args := DeleteMemoryArgs{
@@ -208,6 +224,79 @@ func TestMemoryTool_HandleDeleteMemory(t *testing.T) {
 	manager.AssertExpectations(t)
 }
 
+func TestMemoryTool_HandleSearchMemories(t *testing.T) {
+	manager := new(MockDB)
+	tool := NewMemoryTool(manager, WithAgentScope(true))
+
+	manager.On("SearchMemories", mock.Anything, database.Query{
+		Text:  "favorite color",
+		Tags:  []string{"preferences"},
+		Agent: "root",
+		Limit: defaultSearchLimit,
+	}).Return([]database.UserMemory(nil), nil)
+
+	toolCall := tools.ToolCall{
+		Function: tools.FunctionCall{
+			Name:      "search_memories",
+			Arguments: `{"query": "favorite color", "tags": ["preferences"]}`,
+		},
+	}
+
+	result, err := tool.handleSearchMemories(tools.WithAgentName(t.Context(), "root"), toolCall)
+
+	require.NoError(t, err)
+	assert.Equal(t, "[]", result.Output)
+	manager.AssertExpectations(t)
+}
+
+func TestMemoryTool_HandleUpdateMemory(t *testing.T) {
+	manager := new(MockDB)
+	tool := NewMemoryTool(manager)
+
+	manager.On("UpdateMemory", mock.Anything, mock.MatchedBy(func(memory database.UserMemory) bool {
+		return memory.ID == "1" && memory.Memory == "likes green" && memory.UpdatedAt != "" && memory.Tags == nil
+	})).Return(nil)
+	manager.On("UpdateMemory", mock.Anything, mock.MatchedBy(func(memory database.UserMemory) bool {
+		return memory.ID == "2"
+	})).Return(database.ErrNotFound)
+
+	result, err := tool.handleUpdateMemory(t.Context(), tools.ToolCall{Function: tools.FunctionCall{Arguments: `{"id": "1", "memory": "likes green"}`}})
+	require.NoError(t, err)
+	assert.Equal(t, "Memory with ID 1 updated successfully", result.Output)
+
+	_, err = tool.handleUpdateMemory(t.Context(), tools.ToolCall{Function: tools.FunctionCall{Arguments: `{"id": "2", "memory": "likes red"}`}})
+	require.ErrorIs(t, err, database.ErrNotFound)
+	manager.AssertExpectations(t)
+}
+
+func TestMemoryTool_ProvideContext(t *testing.T) {
+	manager := new(MockDB)
+
+	text, err := NewMemoryTool(manager).ProvideContext(t.Context(), "What is my favorite color?")
+	require.NoError(t, err)
+	assert.Empty(t, text, "recall is disabled by default")
+
+	manager.On("SearchMemories", mock.Anything, database.Query{Text: "What is my favorite color?", Limit: 3}).Return([]database.UserMemory{
+		{ID: "1", Memory: "The user likes green", Category: "preferences", Tags: []string{"color"}},
+		{ID: "2", Memory: "The user lives in Paris"},
+	}, nil)
+
+	tool := NewMemoryTool(manager, WithRecall(3))
+	text, err = tool.ProvideContext(t.Context(), "What is my favorite color?")
+	require.NoError(t, err)
+	assert.Equal(t, `## Relevant memories
+
+These memories may be related to the request of the user:
+- [1] The user likes green (category: preferences) (tags: color)
+- [2] The user lives in Paris
+`, text)
+
+	text, err = tool.ProvideContext(t.Context(), " ")
+	require.NoError(t, err)
+	assert.Empty(t, text)
+	manager.AssertExpectations(t)
+}
+
 func TestMemoryTool_InvalidArguments(t *testing.T) {
 	manager := new(MockDB)
 	tool := NewMemoryTool(manager)
@@ -0,0 +1,75 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+const ToolNameReadResource = "read_resource"
+
+// maxListedResources is how many resources the description of the tool lists
+const maxListedResources = 50
+
+type ReadResourceTool struct {
+	tools.ElicitationTool
+	resources []tools.Resource
+}
+
+// Make sure Read Resource Tool implements the ToolSet Interface
+var _ tools.ToolSet = (*ReadResourceTool)(nil)
+
+type ReadResourceArgs struct {
+	URI string `json:"uri" jsonschema:"The URI of the resource to read."`
+}
+
+// NewReadResourceTool creates the tool reading the resources of the toolsets of
+// an agent, its description lists them
+func NewReadResourceTool(resources []tools.Resource) *ReadResourceTool {
+	return &ReadResourceTool{
+		resources: resources,
+	}
+}
+
+func (t *ReadResourceTool) Instructions() string {
+	return ""
+}
+
+func (t *ReadResourceTool) Tools(context.Context) ([]tools.Tool, error) {
+	var description strings.Builder
+	description.WriteString("Read a resource of the MCP servers by its URI. The available resources are:\n")
+	for i, r := range t.resources {
+		if i == maxListedResources {
+			fmt.Fprintf(&description, "- and %d more\n", len(t.resources)-maxListedResources)
+			break
+		}
+		fmt.Fprintf(&description, "- %s (%s)", r.URI, r.Name)
+		if r.Description != "" {
+			fmt.Fprintf(&description, ": %s", r.Description)
+		}
+		description.WriteString("\n")
+	}
+
+	return []tools.Tool{
+		{
+			Name:        ToolNameReadResource,
+			Category:    "resources",
+			Description: strings.TrimSuffix(description.String(), "\n"),
+			Parameters:  tools.MustSchemaFor[ReadResourceArgs](),
+			Annotations: tools.ToolAnnotations{
+				ReadOnlyHint: true,
+				Title:        "Read Resource",
+			},
+		},
+	}, nil
+}
+
+func (t *ReadResourceTool) Start(context.Context) error {
+	return nil
+}
+
+func (t *ReadResourceTool) Stop(context.Context) error {
+	return nil
+}
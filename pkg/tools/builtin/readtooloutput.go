@@ -0,0 +1,54 @@
+package builtin
+
+import (
+	"context"
+
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+const ToolNameReadToolOutput = "read_tool_output"
+
+type ReadToolOutputTool struct {
+	tools.ElicitationTool
+}
+
+// Make sure Read Tool Output Tool implements the ToolSet Interface
+var _ tools.ToolSet = (*ReadToolOutputTool)(nil)
+
+type ReadToolOutputArgs struct {
+	Handle string `json:"handle" jsonschema:"The handle of the tool output, as given in the truncated tool result."`
+	Offset int    `json:"offset,omitempty" jsonschema:"The byte offset to start reading from (optional, defaults to 0). Offsets inside a multi-byte character start at that character."`
+	Length int    `json:"length,omitempty" jsonschema:"The number of bytes to read (optional)."`
+}
+
+func NewReadToolOutputTool() *ReadToolOutputTool {
+	return &ReadToolOutputTool{}
+}
+
+func (t *ReadToolOutputTool) Instructions() string {
+	return ""
+}
+
+func (t *ReadToolOutputTool) Tools(context.Context) ([]tools.Tool, error) {
+	return []tools.Tool{
+		{
+			Name:     ToolNameReadToolOutput,
+			Category: "tool output",
+			Description: `Read a part of a tool output that was too large to be returned in full.
+            Use the handle given in the truncated tool result and page through the output with offset and length.`,
+			Parameters: tools.MustSchemaFor[ReadToolOutputArgs](),
+			Annotations: tools.ToolAnnotations{
+				ReadOnlyHint: true,
+				Title:        "Read Tool Output",
+			},
+		},
+	}, nil
+}
+
+func (t *ReadToolOutputTool) Start(context.Context) error {
+	return nil
+}
+
+func (t *ReadToolOutputTool) Stop(context.Context) error {
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	latest "github.com/rumpl/rb/pkg/config/v2"
+	"github.com/rumpl/rb/pkg/sandbox"
 	"github.com/rumpl/rb/pkg/tools"
 )
 
@@ -17,11 +18,21 @@ type ScriptShellTool struct {
 	tools.ElicitationTool
 	shellTools map[string]latest.ScriptShellToolConfig
 	env        []string
+	sandbox    *sandbox.Config
 }
 
 var _ tools.ToolSet = (*ScriptShellTool)(nil)
 
-func NewScriptShellTool(shellTools map[string]latest.ScriptShellToolConfig, env []string) *ScriptShellTool {
+type ScriptShellOpt func(*ScriptShellTool)
+
+// WithScriptSandbox runs the scripts in a sandbox, see the sandbox package
+func WithScriptSandbox(cfg *sandbox.Config) ScriptShellOpt {
+	return func(t *ScriptShellTool) {
+		t.sandbox = cfg
+	}
+}
+
+func NewScriptShellTool(shellTools map[string]latest.ScriptShellToolConfig, env []string, opts ...ScriptShellOpt) *ScriptShellTool {
 	for _, tool := range shellTools {
 		// If no required array was set, all arguments are required
 		if tool.Required == nil {
@@ -31,10 +42,15 @@ func NewScriptShellTool(shellTools map[string]latest.ScriptShellToolConfig, env
 			}
 		}
 	}
-	return &ScriptShellTool{
+	t := &ScriptShellTool{
 		shellTools: shellTools,
 		env:        env,
 	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
 }
 
 func (t *ScriptShellTool) Instructions() string {
@@ -64,6 +80,11 @@ func (t *ScriptShellTool) Instructions() string {
 		}
 	}
 
+	if t.sandbox != nil {
+		instructions.WriteString(sandboxInstructions(t.sandbox))
+		instructions.WriteString("\n")
+	}
+
 	return instructions.String()
 }
 
@@ -125,6 +146,14 @@ func (t *ScriptShellTool) execute(ctx context.Context, toolConfig *latest.Script
 		}
 	}
 
+	if t.sandbox != nil {
+		if err := sandbox.Wrap(cmd, *t.sandbox); err != nil {
+			return &tools.ToolCallResult{
+				Output: fmt.Sprintf("Error executing command '%s': %s", toolConfig.Cmd, err),
+			}, nil
+		}
+	}
+
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return &tools.ToolCallResult{
@@ -9,12 +9,17 @@ import (
 	"os/exec"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/rumpl/rb/pkg/sandbox"
 	"github.com/rumpl/rb/pkg/tools"
 )
 
-const ToolNameShell = "shell"
+const (
+	ToolNameShell      = "shell"
+	ToolNameResetShell = "reset_shell"
+)
 
 type ShellTool struct {
 	tools.ElicitationTool
@@ -29,6 +34,51 @@ type shellHandler struct {
 	shellArgsPrefix []string
 	env             []string
 	timeout         time.Duration
+
+	// persistent makes commands run in a long-lived shell per session
+	persistent bool
+	shellsMu   sync.Mutex
+	shells     map[string]*persistentShell
+
+	processes *processManager
+
+	// sandbox, if set, runs the commands in a sandbox
+	sandbox *sandbox.Config
+
+	// runner, if set, runs the commands instead of a local shell
+	runner CommandRunner
+}
+
+// CommandRunner runs shell commands somewhere else than in a local shell. The
+// output combines stdout and stderr, the error is set when the command
+// couldn't run or exited with a non-zero status.
+type CommandRunner interface {
+	RunCommand(ctx context.Context, command, cwd string) (string, error)
+}
+
+type ShellOpt func(*ShellTool)
+
+// WithPersistentShell runs the commands of a session in the same shell process,
+// so that the working directory and the environment are kept between commands.
+func WithPersistentShell(persistent bool) ShellOpt {
+	return func(t *ShellTool) {
+		t.handler.persistent = persistent
+	}
+}
+
+// WithSandbox runs the commands in a sandbox, see the sandbox package
+func WithSandbox(cfg *sandbox.Config) ShellOpt {
+	return func(t *ShellTool) {
+		t.handler.sandbox = cfg
+	}
+}
+
+// WithCommandRunner runs the commands with runner instead of a local shell.
+// Persistent shells and sandboxes don't apply to these commands.
+func WithCommandRunner(runner CommandRunner) ShellOpt {
+	return func(t *ShellTool) {
+		t.handler.runner = runner
+	}
 }
 
 type RunShellArgs struct {
@@ -49,6 +99,18 @@ func (h *shellHandler) RunShell(ctx context.Context, toolCall tools.ToolCall) (*
 		effectiveTimeout = time.Duration(params.Timeout) * time.Second
 	}
 
+	if h.runner != nil {
+		return &tools.ToolCallResult{
+			Output: h.runWithRunner(ctx, params, effectiveTimeout),
+		}, nil
+	}
+
+	if h.persistent {
+		return &tools.ToolCallResult{
+			Output: h.runPersistent(ctx, params, effectiveTimeout),
+		}, nil
+	}
+
 	// Create timeout context
 	timeoutCtx, cancel := context.WithTimeout(ctx, effectiveTimeout)
 	defer cancel()
@@ -64,6 +126,11 @@ func (h *shellHandler) RunShell(ctx context.Context, toolCall tools.ToolCall) (*
 	}
 
 	cmd.SysProcAttr = platformSpecificSysProcAttr()
+	if err := h.sandboxed(cmd); err != nil {
+		return &tools.ToolCallResult{
+			Output: fmt.Sprintf("Error starting command: %s", err),
+		}, nil
+	}
 
 	var outBuf bytes.Buffer
 	cmd.Stdout = &outBuf
@@ -125,7 +192,48 @@ func (h *shellHandler) RunShell(ctx context.Context, toolCall tools.ToolCall) (*
 	}
 }
 
-func NewShellTool(env []string) *ShellTool {
+// runWithRunner runs a command with the command runner and formats its output
+// like the output of the local commands
+func (h *shellHandler) runWithRunner(ctx context.Context, params RunShellArgs, timeout time.Duration) string {
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	output, err := h.runner.RunCommand(timeoutCtx, params.Cmd, params.Cwd)
+	switch {
+	case ctx.Err() != nil:
+		return "Command cancelled"
+	case timeoutCtx.Err() != nil:
+		return fmt.Sprintf("Command timed out after %v\nOutput: %s", timeout, output)
+	case err != nil:
+		return fmt.Sprintf("Error executing command: %s\nOutput: %s", err, output)
+	case strings.TrimSpace(output) == "":
+		return "<no output>"
+	default:
+		return fmt.Sprintf("Output: %s", output)
+	}
+}
+
+// sandboxed makes cmd run in the sandbox, if any
+func (h *shellHandler) sandboxed(cmd *exec.Cmd) error {
+	if h.sandbox == nil {
+		return nil
+	}
+	return sandbox.Wrap(cmd, *h.sandbox)
+}
+
+func (h *shellHandler) ResetShell(ctx context.Context, _ tools.ToolCall) (*tools.ToolCallResult, error) {
+	if !h.resetPersistentShell(tools.SessionID(ctx)) {
+		return &tools.ToolCallResult{
+			Output: "No shell was running, the next command will start a new shell",
+		}, nil
+	}
+
+	return &tools.ToolCallResult{
+		Output: "The shell was reset, the next command will start a new shell",
+	}, nil
+}
+
+func NewShellTool(env []string, opts ...ShellOpt) *ShellTool {
 	var shell string
 	var argsPrefix []string
 
@@ -155,18 +263,51 @@ func NewShellTool(env []string) *ShellTool {
 		argsPrefix = []string{"-c"}
 	}
 
-	return &ShellTool{
+	t := &ShellTool{
 		handler: &shellHandler{
 			shell:           shell,
 			shellArgsPrefix: argsPrefix,
 			env:             env,
 			timeout:         30 * time.Second,
+			shells:          make(map[string]*persistentShell),
+			processes:       &processManager{},
 		},
 	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
 }
 
 func (t *ShellTool) Instructions() string {
-	return `# Shell Tool Usage Guide
+	instructions := shellInstructions
+	if t.handler.persistent {
+		instructions = strings.Replace(instructions, shellIsolation, persistentShellIsolation, 1)
+	}
+	if t.handler.sandbox != nil {
+		instructions += "\n\n" + sandboxInstructions(t.handler.sandbox)
+	}
+	return instructions
+}
+
+// sandboxInstructions tells the agent what sandboxed commands can access
+func sandboxInstructions(cfg *sandbox.Config) string {
+	network := "Network access is disabled."
+	if cfg.Network {
+		network = "Network access is allowed."
+	}
+	return fmt.Sprintf("**Sandbox**: Commands run in a sandbox. The filesystem is read-only except for /tmp, which starts empty, and these paths: %s. %s",
+		strings.Join(cfg.Writable, ", "), network)
+}
+
+const (
+	shellIsolation           = "**Command Isolation**: Each tool call creates a fresh shell session - no state persists between executions."
+	persistentShellIsolation = "**Persistent Session**: All tool calls run in the same shell session. The working directory, exported variables and activated environments persist between executions. " +
+		"\"cwd\" changes the directory of the session. Use the \"reset_shell\" tool to start over with a fresh shell."
+)
+
+const shellInstructions = `# Shell Tool Usage Guide
 
 Execute shell commands in the user's environment with full control over working directories and command parameters.
 
@@ -181,10 +322,12 @@ On Unix-like systems, ${SHELL} is used or /bin/sh as fallback.
 - Override with "cwd" parameter for targeted command execution
 - Supports both absolute and relative paths
 
-**Command Isolation**: Each tool call creates a fresh shell session - no state persists between executions.
+` + shellIsolation + `
 
 **Timeout Protection**: Commands have a default 30-second timeout to prevent hanging. For longer operations, specify a custom timeout.
 
+**Background Processes**: Use "start_background_process" for commands that don't exit on their own, like dev servers and watchers. Read their output with "read_process_output", list them with "list_processes" and stop them with "stop_process". They are stopped when the session ends.
+
 ## Parameter Reference
 
 | Parameter | Type   | Required | Description |
@@ -237,10 +380,9 @@ EOF" }
 
 Commands that exit with non-zero status codes will return error information along with any output produced before failure.
 Commands that exceed their timeout will be terminated automatically.`
-}
 
 func (t *ShellTool) Tools(context.Context) ([]tools.Tool, error) {
-	return []tools.Tool{
+	shellTools := []tools.Tool{
 		{
 			Name:         ToolNameShell,
 			Category:     "shell",
@@ -252,13 +394,34 @@ func (t *ShellTool) Tools(context.Context) ([]tools.Tool, error) {
 				Title: "Run Shell Command",
 			},
 		},
-	}, nil
+	}
+
+	if t.handler.persistent {
+		shellTools[0].Description = `Executes the given shell command in a persistent shell session. The working directory and the environment are kept between commands.`
+		shellTools = append(shellTools, tools.Tool{
+			Name:         ToolNameResetShell,
+			Category:     "shell",
+			Description:  `Resets the persistent shell session, discarding its working directory, environment and background processes.`,
+			Parameters:   tools.MustSchemaFor[struct{}](),
+			OutputSchema: tools.MustSchemaFor[string](),
+			Handler:      t.handler.ResetShell,
+			Annotations: tools.ToolAnnotations{
+				Title: "Reset Shell",
+			},
+		})
+	}
+
+	return append(shellTools, t.handler.backgroundTools()...), nil
 }
 
 func (t *ShellTool) Start(context.Context) error {
+	t.handler.processes.register()
 	return nil
 }
 
 func (t *ShellTool) Stop(context.Context) error {
+	t.handler.closePersistentShells()
+	t.handler.processes.stopAll()
+	t.handler.processes.unregister()
 	return nil
 }
@@ -0,0 +1,438 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+const (
+	ToolNameStartBackgroundProcess = "start_background_process"
+	ToolNameReadProcessOutput      = "read_process_output"
+	ToolNameListProcesses          = "list_processes"
+	ToolNameStopProcess            = "stop_process"
+)
+
+const (
+	// processOutputSize is how much of the output of each background process is kept
+	processOutputSize = 1024 * 1024
+	// processStopTimeout is how long stop_process waits for a process to exit
+	processStopTimeout = 5 * time.Second
+)
+
+type StartBackgroundProcessArgs struct {
+	Cmd string `json:"cmd" jsonschema:"The shell command to start in the background"`
+	Cwd string `json:"cwd,omitempty" jsonschema:"The working directory to start the command in (optional)"`
+}
+
+type ProcessArgs struct {
+	ID string `json:"id" jsonschema:"The ID of the background process"`
+}
+
+type ReadProcessOutputArgs struct {
+	ID  string `json:"id" jsonschema:"The ID of the background process"`
+	All bool   `json:"all,omitempty" jsonschema:"Return all the buffered output instead of the output produced since the last read"`
+}
+
+// ProcessInfo describes a background process
+type ProcessInfo struct {
+	ID        string
+	Cmd       string
+	Cwd       string
+	PID       int
+	StartedAt time.Time
+	Running   bool
+	ExitCode  int
+}
+
+func (p ProcessInfo) status() string {
+	if p.Running {
+		return fmt.Sprintf("running (pid %d)", p.PID)
+	}
+	return fmt.Sprintf("exited (code %d)", p.ExitCode)
+}
+
+// processOutput keeps the last processOutputSize bytes written by a process in a ring buffer
+type processOutput struct {
+	mu      sync.Mutex
+	buf     []byte
+	start   int // index in buf of the oldest byte, once buf is full
+	written int // number of bytes written since the process started
+}
+
+func (o *processOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	n := len(p)
+	o.written += n
+	if len(p) > processOutputSize {
+		p = p[len(p)-processOutputSize:]
+	}
+
+	// Fill the buffer, then overwrite the oldest bytes
+	room := min(processOutputSize-len(o.buf), len(p))
+	o.buf = append(o.buf, p[:room]...)
+	p = p[room:]
+	for len(p) > 0 {
+		copied := copy(o.buf[o.start:], p)
+		o.start = (o.start + copied) % processOutputSize
+		p = p[copied:]
+	}
+
+	return n, nil
+}
+
+// readFrom returns the output written after offset, the offset of the end of the
+// output and the number of bytes after offset that were already discarded.
+func (o *processOutput) readFrom(offset int) (output string, end, missed int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	dropped := o.written - len(o.buf)
+	if offset < dropped {
+		missed = dropped - offset
+		offset = dropped
+	}
+
+	oldest, newest := o.buf[o.start:], o.buf[:o.start]
+	if i := offset - dropped; i < len(oldest) {
+		output = string(oldest[i:]) + string(newest)
+	} else {
+		output = string(newest[i-len(oldest):])
+	}
+	return output, o.written, missed
+}
+
+type backgroundProcess struct {
+	info       ProcessInfo
+	cmd        *exec.Cmd
+	pg         *processGroup
+	output     *processOutput
+	readOffset int
+	done       chan struct{}
+}
+
+// processManager runs the background processes of a shell toolset
+type processManager struct {
+	mu        sync.Mutex
+	processes []*backgroundProcess
+	nextID    int
+}
+
+// processManagers are the managers of all the started shell toolsets
+var processManagers = struct {
+	sync.Mutex
+	managers map[*processManager]struct{}
+}{managers: make(map[*processManager]struct{})}
+
+// BackgroundProcesses returns the background processes started by all the shell toolsets
+func BackgroundProcesses() []ProcessInfo {
+	processManagers.Lock()
+	defer processManagers.Unlock()
+
+	var all []ProcessInfo
+	for m := range processManagers.managers {
+		all = append(all, m.list()...)
+	}
+	slices.SortFunc(all, func(a, b ProcessInfo) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+	return all
+}
+
+func (m *processManager) register() {
+	processManagers.Lock()
+	defer processManagers.Unlock()
+	processManagers.managers[m] = struct{}{}
+}
+
+func (m *processManager) unregister() {
+	processManagers.Lock()
+	defer processManagers.Unlock()
+	delete(processManagers.managers, m)
+}
+
+func (m *processManager) start(h *shellHandler, command, cwd string) (*backgroundProcess, error) {
+	cmd := exec.Command(h.shell, append(slices.Clone(h.shellArgsPrefix), command)...)
+	cmd.Env = h.env
+	if cwd != "" {
+		cmd.Dir = cwd
+	} else if wd, err := os.Getwd(); err == nil {
+		cmd.Dir = wd
+	}
+	cmd.SysProcAttr = platformSpecificSysProcAttr()
+	// Don't wait forever for the output of children that left the process group
+	cmd.WaitDelay = time.Second
+	if err := h.sandboxed(cmd); err != nil {
+		return nil, err
+	}
+
+	output := &processOutput{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	pg, err := createProcessGroup(cmd.Process)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.nextID++
+	p := &backgroundProcess{
+		info: ProcessInfo{
+			ID:        fmt.Sprintf("p%d", m.nextID),
+			Cmd:       command,
+			Cwd:       cmd.Dir,
+			PID:       cmd.Process.Pid,
+			StartedAt: time.Now(),
+			Running:   true,
+		},
+		cmd:    cmd,
+		pg:     pg,
+		output: output,
+		done:   make(chan struct{}),
+	}
+	m.processes = append(m.processes, p)
+	m.mu.Unlock()
+
+	go func() {
+		_ = cmd.Wait()
+
+		m.mu.Lock()
+		p.info.Running = false
+		p.info.ExitCode = cmd.ProcessState.ExitCode()
+		m.mu.Unlock()
+
+		close(p.done)
+	}()
+
+	return p, nil
+}
+
+func (m *processManager) get(id string) (*backgroundProcess, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range m.processes {
+		if p.info.ID == id {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+func (m *processManager) infoOf(p *backgroundProcess) ProcessInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return p.info
+}
+
+func (m *processManager) list() []ProcessInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	infos := make([]ProcessInfo, 0, len(m.processes))
+	for _, p := range m.processes {
+		infos = append(infos, p.info)
+	}
+	return infos
+}
+
+// stop kills the process group of a process and waits for it to exit
+func (m *processManager) stop(p *backgroundProcess) error {
+	select {
+	case <-p.done:
+		return nil
+	default:
+	}
+
+	if err := kill(p.cmd.Process, p.pg); err != nil {
+		return err
+	}
+
+	select {
+	case <-p.done:
+		return nil
+	case <-time.After(processStopTimeout):
+		_ = p.cmd.Process.Kill()
+		return errors.New("the process didn't exit in time and was killed")
+	}
+}
+
+// stopAll stops all the running processes
+func (m *processManager) stopAll() {
+	m.mu.Lock()
+	processes := slices.Clone(m.processes)
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, p := range processes {
+		wg.Go(func() {
+			_ = m.stop(p)
+		})
+	}
+	wg.Wait()
+}
+
+func (h *shellHandler) StartBackgroundProcess(_ context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
+	var params StartBackgroundProcessArgs
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &params); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	p, err := h.processes.start(h, params.Cmd, params.Cwd)
+	if err != nil {
+		return &tools.ToolCallResult{
+			Output: fmt.Sprintf("Error starting command: %s", err),
+		}, nil
+	}
+
+	return &tools.ToolCallResult{
+		Output: fmt.Sprintf("Started background process %s (pid %d). Use %s to read its output.", p.info.ID, p.info.PID, ToolNameReadProcessOutput),
+	}, nil
+}
+
+func (h *shellHandler) ReadProcessOutput(_ context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
+	var params ReadProcessOutputArgs
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &params); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	p, ok := h.processes.get(params.ID)
+	if !ok {
+		return &tools.ToolCallResult{
+			Output: fmt.Sprintf("No background process with ID %q", params.ID),
+		}, nil
+	}
+
+	// Get the status first so that the output of a process that exited is complete
+	info := h.processes.infoOf(p)
+
+	h.processes.mu.Lock()
+	offset := p.readOffset
+	if params.All {
+		offset = 0
+	}
+	output, end, missed := p.output.readFrom(offset)
+	p.readOffset = end
+	h.processes.mu.Unlock()
+
+	var result strings.Builder
+	fmt.Fprintf(&result, "Process %s: %s\n", info.ID, info.status())
+	if missed > 0 {
+		fmt.Fprintf(&result, "[... %d bytes of older output were discarded ...]\n", missed)
+	}
+	if output == "" {
+		result.WriteString("<no new output>")
+	} else {
+		result.WriteString("Output: ")
+		result.WriteString(output)
+	}
+
+	return &tools.ToolCallResult{Output: result.String()}, nil
+}
+
+func (h *shellHandler) ListProcesses(context.Context, tools.ToolCall) (*tools.ToolCallResult, error) {
+	processes := h.processes.list()
+	if len(processes) == 0 {
+		return &tools.ToolCallResult{Output: "No background processes"}, nil
+	}
+
+	var result strings.Builder
+	for _, p := range processes {
+		fmt.Fprintf(&result, "%s: %s (%s, started at %s in %s)\n", p.ID, p.Cmd, p.status(), p.StartedAt.Format(time.TimeOnly), p.Cwd)
+	}
+
+	return &tools.ToolCallResult{Output: result.String()}, nil
+}
+
+func (h *shellHandler) StopProcess(_ context.Context, toolCall tools.ToolCall) (*tools.ToolCallResult, error) {
+	var params ProcessArgs
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &params); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	p, ok := h.processes.get(params.ID)
+	if !ok {
+		return &tools.ToolCallResult{
+			Output: fmt.Sprintf("No background process with ID %q", params.ID),
+		}, nil
+	}
+
+	if err := h.processes.stop(p); err != nil {
+		return &tools.ToolCallResult{
+			Output: fmt.Sprintf("Error stopping process %s: %s", p.info.ID, err),
+		}, nil
+	}
+
+	info := h.processes.infoOf(p)
+	return &tools.ToolCallResult{
+		Output: fmt.Sprintf("Stopped process %s: %s", info.ID, info.status()),
+	}, nil
+}
+
+func (h *shellHandler) backgroundTools() []tools.Tool {
+	return []tools.Tool{
+		{
+			Name:         ToolNameStartBackgroundProcess,
+			Category:     "shell",
+			Description:  `Starts the given shell command in the background and returns immediately. Use it for dev servers, watchers and other long-running commands.`,
+			Parameters:   tools.MustSchemaFor[StartBackgroundProcessArgs](),
+			OutputSchema: tools.MustSchemaFor[string](),
+			Handler:      h.StartBackgroundProcess,
+			Annotations: tools.ToolAnnotations{
+				Title: "Start Background Process",
+			},
+		},
+		{
+			Name:         ToolNameReadProcessOutput,
+			Category:     "shell",
+			Description:  `Returns the status of a background process and the output it produced since the last read.`,
+			Parameters:   tools.MustSchemaFor[ReadProcessOutputArgs](),
+			OutputSchema: tools.MustSchemaFor[string](),
+			Handler:      h.ReadProcessOutput,
+			// Not read-only: a read consumes the output, the next read only returns what follows
+			Annotations: tools.ToolAnnotations{
+				Title: "Read Process Output",
+			},
+		},
+		{
+			Name:         ToolNameListProcesses,
+			Category:     "shell",
+			Description:  `Lists the background processes and their status.`,
+			Parameters:   tools.MustSchemaFor[struct{}](),
+			OutputSchema: tools.MustSchemaFor[string](),
+			Handler:      h.ListProcesses,
+			Annotations: tools.ToolAnnotations{
+				ReadOnlyHint: true,
+				Title:        "List Background Processes",
+			},
+		},
+		{
+			Name:         ToolNameStopProcess,
+			Category:     "shell",
+			Description:  `Stops a background process and all the processes it started.`,
+			Parameters:   tools.MustSchemaFor[ProcessArgs](),
+			OutputSchema: tools.MustSchemaFor[string](),
+			Handler:      h.StopProcess,
+			Annotations: tools.ToolAnnotations{
+				Title: "Stop Background Process",
+			},
+		},
+	}
+}
@@ -0,0 +1,300 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+// persistentShell is a long-lived shell process that keeps its working directory
+// and its environment between commands.
+type persistentShell struct {
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	pg     *processGroup
+	stdin  io.WriteCloser
+	output *shellOutput
+	exited chan struct{}
+}
+
+// shellOutput collects the output of a persistent shell and signals new writes
+type shellOutput struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	notify chan struct{}
+}
+
+func (o *shellOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	o.buf.Write(p)
+	o.mu.Unlock()
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+func (o *shellOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+func (o *shellOutput) Reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf.Reset()
+}
+
+// persistentShellPath returns the shell used for persistent sessions. Only POSIX
+// shells are supported since commands are wrapped with sh syntax. Bash is preferred
+// because, unlike dash, it doesn't exit on syntax errors.
+func persistentShellPath() string {
+	if path, err := exec.LookPath("bash"); err == nil {
+		return path
+	}
+	return "/bin/sh"
+}
+
+func startPersistentShell(h *shellHandler) (*persistentShell, error) {
+	if runtime.GOOS == "windows" {
+		return nil, errors.New("persistent shell sessions are not supported on Windows")
+	}
+
+	cmd := exec.Command(persistentShellPath())
+	cmd.Env = h.env
+	if wd, err := os.Getwd(); err == nil {
+		cmd.Dir = wd
+	}
+	cmd.SysProcAttr = platformSpecificSysProcAttr()
+	if err := h.sandboxed(cmd); err != nil {
+		return nil, err
+	}
+
+	output := &shellOutput{notify: make(chan struct{}, 1)}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	pg, err := createProcessGroup(cmd.Process)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return nil, err
+	}
+
+	s := &persistentShell{
+		cmd:    cmd,
+		pg:     pg,
+		stdin:  stdin,
+		output: output,
+		exited: make(chan struct{}),
+	}
+	go func() {
+		_ = cmd.Wait()
+		close(s.exited)
+	}()
+
+	return s, nil
+}
+
+// errShellExited is returned when the shell process exits while running a command,
+// e.g. because the command called `exit`.
+var errShellExited = errors.New("shell exited")
+
+// Run runs a command in the shell and returns its output and its exit code.
+// Commands are delimited with a random sentinel that the shell prints, along
+// with the exit code, once the command is done.
+func (s *persistentShell) Run(ctx context.Context, command string, timeout time.Duration) (string, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.exited:
+		return "", 0, errShellExited
+	default:
+	}
+
+	id := strings.ReplaceAll(uuid.New().String(), "-", "")
+	delimiter := "RB_CMD_" + id
+	sentinel := "__RB_DONE_" + id + "__"
+
+	// Run the command with eval so that syntax errors don't leave the shell waiting for
+	// more input, and with stdin closed so that it can't read the following commands.
+	script := fmt.Sprintf("eval \"$(cat <<'%s'\n%s\n%s\n)\" </dev/null 2>&1\nprintf '\\n%s %%d\\n' \"$?\"\n", delimiter, command, delimiter, sentinel)
+
+	s.output.Reset()
+	if _, err := s.stdin.Write([]byte(script)); err != nil {
+		return "", 0, errShellExited
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		if output, exitCode, ok := parseSentinel(s.output.String(), sentinel); ok {
+			return output, exitCode, nil
+		}
+
+		select {
+		case <-s.output.notify:
+		case <-s.exited:
+			// Collect what was written before the shell exited
+			if output, exitCode, ok := parseSentinel(s.output.String(), sentinel); ok {
+				return output, exitCode, nil
+			}
+			return s.output.String(), 0, errShellExited
+		case <-timer.C:
+			s.close()
+			return s.output.String(), 0, context.DeadlineExceeded
+		case <-ctx.Done():
+			s.close()
+			return s.output.String(), 0, ctx.Err()
+		}
+	}
+}
+
+// parseSentinel returns the output that precedes the sentinel and the exit code that follows it
+func parseSentinel(output, sentinel string) (string, int, bool) {
+	idx := strings.Index(output, "\n"+sentinel+" ")
+	if idx == -1 {
+		return "", 0, false
+	}
+
+	rest := output[idx+len(sentinel)+2:]
+	end := strings.IndexByte(rest, '\n')
+	if end == -1 {
+		return "", 0, false
+	}
+
+	exitCode, err := strconv.Atoi(rest[:end])
+	if err != nil {
+		return "", 0, false
+	}
+
+	return output[:idx], exitCode, true
+}
+
+// close kills the shell and all the processes it started. It doesn't wait for
+// the running command, if any, which then returns errShellExited.
+func (s *persistentShell) close() {
+	select {
+	case <-s.exited:
+		return
+	default:
+	}
+	_ = kill(s.cmd.Process, s.pg)
+	<-s.exited
+}
+
+// runPersistent runs the command in the persistent shell of the session
+func (h *shellHandler) runPersistent(ctx context.Context, params RunShellArgs, timeout time.Duration) string {
+	sessionID := tools.SessionID(ctx)
+
+	shell, err := h.persistentShell(sessionID)
+	if err != nil {
+		return fmt.Sprintf("Error starting shell: %s", err)
+	}
+
+	command := params.Cmd
+	if params.Cwd != "" {
+		command = fmt.Sprintf("cd %s && %s", shellQuote(params.Cwd), command)
+	}
+
+	output, exitCode, err := shell.Run(ctx, command, timeout)
+	switch {
+	case errors.Is(err, errShellExited):
+		h.discardPersistentShell(sessionID, shell)
+		return fmt.Sprintf("The shell exited, a new shell will be started for the next command\nOutput: %s", output)
+	case errors.Is(err, context.DeadlineExceeded):
+		h.discardPersistentShell(sessionID, shell)
+		return fmt.Sprintf("Command timed out after %v, the shell was restarted and its state was lost\nOutput: %s", timeout, output)
+	case err != nil:
+		h.discardPersistentShell(sessionID, shell)
+		return "Command cancelled"
+	case exitCode != 0:
+		return fmt.Sprintf("Error executing command: exit status %d\nOutput: %s", exitCode, output)
+	case strings.TrimSpace(output) == "":
+		return "<no output>"
+	default:
+		return fmt.Sprintf("Output: %s", output)
+	}
+}
+
+// persistentShell returns the shell of the session, starting it if needed
+func (h *shellHandler) persistentShell(sessionID string) (*persistentShell, error) {
+	h.shellsMu.Lock()
+	defer h.shellsMu.Unlock()
+
+	if shell, ok := h.shells[sessionID]; ok {
+		return shell, nil
+	}
+
+	shell, err := startPersistentShell(h)
+	if err != nil {
+		return nil, err
+	}
+	h.shells[sessionID] = shell
+	return shell, nil
+}
+
+// resetPersistentShell kills the shell of the session, the next command starts a new one
+func (h *shellHandler) resetPersistentShell(sessionID string) bool {
+	h.shellsMu.Lock()
+	shell, ok := h.shells[sessionID]
+	delete(h.shells, sessionID)
+	h.shellsMu.Unlock()
+
+	if ok {
+		shell.close()
+	}
+	return ok
+}
+
+// discardPersistentShell forgets a shell that exited or was killed, unless it was already replaced
+func (h *shellHandler) discardPersistentShell(sessionID string, shell *persistentShell) {
+	h.shellsMu.Lock()
+	if h.shells[sessionID] == shell {
+		delete(h.shells, sessionID)
+	}
+	h.shellsMu.Unlock()
+
+	shell.close()
+}
+
+// closePersistentShells kills the shells of all the sessions
+func (h *shellHandler) closePersistentShells() {
+	h.shellsMu.Lock()
+	shells := h.shells
+	h.shells = make(map[string]*persistentShell)
+	h.shellsMu.Unlock()
+
+	for _, shell := range shells {
+		shell.close()
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
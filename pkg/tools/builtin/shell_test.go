@@ -1,8 +1,15 @@
 package builtin
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -32,7 +39,7 @@ func TestShellTool_Tools(t *testing.T) {
 	allTools, err := tool.Tools(t.Context())
 
 	require.NoError(t, err)
-	assert.Len(t, allTools, 1)
+	assert.Len(t, allTools, 5)
 	for _, tool := range allTools {
 		assert.NotNil(t, tool.Handler)
 		assert.Equal(t, "shell", tool.Category)
@@ -84,7 +91,7 @@ func TestShellTool_HandlerEcho(t *testing.T) {
 
 	tls, err := tool.Tools(t.Context())
 	require.NoError(t, err)
-	require.Len(t, tls, 1)
+	require.NotEmpty(t, tls)
 
 	handler := tls[0].Handler
 
@@ -114,7 +121,7 @@ func TestShellTool_HandlerWithCwd(t *testing.T) {
 
 	tls, err := tool.Tools(t.Context())
 	require.NoError(t, err)
-	require.Len(t, tls, 1)
+	require.NotEmpty(t, tls)
 
 	handler := tls[0].Handler
 
@@ -148,7 +155,7 @@ func TestShellTool_HandlerError(t *testing.T) {
 
 	tls, err := tool.Tools(t.Context())
 	require.NoError(t, err)
-	require.Len(t, tls, 1)
+	require.NotEmpty(t, tls)
 
 	handler := tls[0].Handler
 
@@ -172,12 +179,62 @@ func TestShellTool_HandlerError(t *testing.T) {
 	assert.Contains(t, result.Output, "Error executing command")
 }
 
+// fakeRunner returns the same output and error for every command
+type fakeRunner struct {
+	output string
+	err    error
+	wait   bool
+
+	command, cwd string
+}
+
+func (r *fakeRunner) RunCommand(ctx context.Context, command, cwd string) (string, error) {
+	r.command, r.cwd = command, cwd
+	if r.wait {
+		<-ctx.Done()
+		return r.output, ctx.Err()
+	}
+	return r.output, r.err
+}
+
+func TestShellTool_CommandRunner(t *testing.T) {
+	tests := []struct {
+		name     string
+		runner   *fakeRunner
+		timeout  int
+		expected string
+	}{
+		{name: "output", runner: &fakeRunner{output: "hello\n"}, expected: "Output: hello\n"},
+		{name: "no output", runner: &fakeRunner{}, expected: "<no output>"},
+		{name: "error", runner: &fakeRunner{output: "boom", err: errors.New("exit status 2")}, expected: "Error executing command: exit status 2\nOutput: boom"},
+		{name: "timeout", runner: &fakeRunner{output: "partial", wait: true}, timeout: 1, expected: "Command timed out after 1s\nOutput: partial"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool := NewShellTool(nil, WithCommandRunner(tt.runner), WithPersistentShell(true))
+
+			tls, err := tool.Tools(t.Context())
+			require.NoError(t, err)
+
+			args, err := json.Marshal(RunShellArgs{Cmd: "echo hello", Cwd: "src", Timeout: tt.timeout})
+			require.NoError(t, err)
+
+			result, err := tls[0].Handler(t.Context(), tools.ToolCall{Function: tools.FunctionCall{Name: "shell", Arguments: string(args)}})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Output)
+			assert.Equal(t, "echo hello", tt.runner.command)
+			assert.Equal(t, "src", tt.runner.cwd)
+		})
+	}
+}
+
 func TestShellTool_InvalidArguments(t *testing.T) {
 	tool := NewShellTool(nil)
 
 	tls, err := tool.Tools(t.Context())
 	require.NoError(t, err)
-	require.Len(t, tls, 1)
+	require.NotEmpty(t, tls)
 
 	handler := tls[0].Handler
 
@@ -230,3 +287,184 @@ func TestShellTool_ParametersAreObjects(t *testing.T) {
 		assert.Equal(t, "object", m["type"])
 	}
 }
+
+func runShellCommand(t *testing.T, ctx context.Context, handler tools.ToolHandler, args RunShellArgs) string {
+	t.Helper()
+
+	argsBytes, err := json.Marshal(args)
+	require.NoError(t, err)
+
+	result, err := handler(ctx, tools.ToolCall{Function: tools.FunctionCall{Name: "shell", Arguments: string(argsBytes)}})
+	require.NoError(t, err)
+	return result.Output
+}
+
+func TestShellTool_Persistent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("persistent shells are not supported on Windows")
+	}
+
+	tool := NewShellTool(os.Environ(), WithPersistentShell(true))
+	t.Cleanup(func() { _ = tool.Stop(t.Context()) })
+
+	allTools, err := tool.Tools(t.Context())
+	require.NoError(t, err)
+	require.Len(t, allTools, 6)
+	assert.Equal(t, "reset_shell", allTools[1].Name)
+	assert.Contains(t, tool.Instructions(), "Persistent Session")
+
+	ctx := tools.WithSessionID(t.Context(), "session-1")
+	shell := allTools[0].Handler
+	tmpDir := t.TempDir()
+
+	// The working directory and the environment are kept between calls
+	assert.Equal(t, "<no output>", runShellCommand(t, ctx, shell, RunShellArgs{Cmd: "export RB_TEST_VAR=hello", Cwd: tmpDir}))
+	output := runShellCommand(t, ctx, shell, RunShellArgs{Cmd: "pwd; echo $RB_TEST_VAR"})
+	assert.Equal(t, "Output: "+tmpDir+"\nhello\n", output)
+
+	// Exit codes and syntax errors are reported without losing the shell
+	assert.Contains(t, runShellCommand(t, ctx, shell, RunShellArgs{Cmd: "false"}), "exit status 1")
+	runShellCommand(t, ctx, shell, RunShellArgs{Cmd: "if then"})
+	assert.Equal(t, "Output: hello\n", runShellCommand(t, ctx, shell, RunShellArgs{Cmd: "echo $RB_TEST_VAR"}))
+
+	// Other sessions get their own shell
+	otherCtx := tools.WithSessionID(t.Context(), "session-2")
+	assert.Equal(t, "<no output>", runShellCommand(t, otherCtx, shell, RunShellArgs{Cmd: "echo $RB_TEST_VAR | tr -d '\\n'"}))
+
+	// Resetting the shell discards its state
+	result, err := allTools[1].Handler(ctx, tools.ToolCall{Function: tools.FunctionCall{Name: "reset_shell", Arguments: "{}"}})
+	require.NoError(t, err)
+	assert.Contains(t, result.Output, "The shell was reset")
+	assert.Equal(t, "<no output>", runShellCommand(t, ctx, shell, RunShellArgs{Cmd: "echo $RB_TEST_VAR | tr -d '\\n'"}))
+}
+
+func TestShellTool_PersistentExitAndTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("persistent shells are not supported on Windows")
+	}
+
+	tool := NewShellTool(os.Environ(), WithPersistentShell(true))
+	t.Cleanup(func() { _ = tool.Stop(t.Context()) })
+
+	allTools, err := tool.Tools(t.Context())
+	require.NoError(t, err)
+	shell := allTools[0].Handler
+	ctx := tools.WithSessionID(t.Context(), "session-1")
+
+	assert.Contains(t, runShellCommand(t, ctx, shell, RunShellArgs{Cmd: "exit 3"}), "The shell exited")
+	assert.Equal(t, "Output: still working\n", runShellCommand(t, ctx, shell, RunShellArgs{Cmd: "echo still working"}))
+
+	assert.Contains(t, runShellCommand(t, ctx, shell, RunShellArgs{Cmd: "echo before; sleep 10", Timeout: 1}), "Command timed out after 1s")
+	assert.Equal(t, "Output: still working\n", runShellCommand(t, ctx, shell, RunShellArgs{Cmd: "echo still working"}))
+}
+
+func callShellTool(t *testing.T, allTools []tools.Tool, name string, args any) string {
+	t.Helper()
+
+	argsBytes, err := json.Marshal(args)
+	require.NoError(t, err)
+
+	for _, tool := range allTools {
+		if tool.Name == name {
+			result, err := tool.Handler(t.Context(), tools.ToolCall{Function: tools.FunctionCall{Name: name, Arguments: string(argsBytes)}})
+			require.NoError(t, err)
+			return result.Output
+		}
+	}
+
+	require.Failf(t, "tool not found", "no tool named %s", name)
+	return ""
+}
+
+func TestShellTool_BackgroundProcesses(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands need a POSIX shell")
+	}
+
+	tool := NewShellTool(os.Environ())
+	require.NoError(t, tool.Start(t.Context()))
+	t.Cleanup(func() { _ = tool.Stop(t.Context()) })
+
+	allTools, err := tool.Tools(t.Context())
+	require.NoError(t, err)
+
+	output := callShellTool(t, allTools, ToolNameStartBackgroundProcess, StartBackgroundProcessArgs{Cmd: "echo ready; sleep 30"})
+	assert.Contains(t, output, "Started background process p1")
+
+	// Only the output produced since the last read is returned
+	assert.Eventually(t, func() bool {
+		return strings.Contains(callShellTool(t, allTools, ToolNameReadProcessOutput, ProcessArgs{ID: "p1"}), "Output: ready\n")
+	}, 5*time.Second, 50*time.Millisecond)
+	output = callShellTool(t, allTools, ToolNameReadProcessOutput, ProcessArgs{ID: "p1"})
+	assert.Contains(t, output, "Process p1: running")
+	assert.Contains(t, output, "<no new output>")
+	assert.Contains(t, callShellTool(t, allTools, ToolNameReadProcessOutput, ReadProcessOutputArgs{ID: "p1", All: true}), "Output: ready\n")
+
+	assert.Contains(t, callShellTool(t, allTools, ToolNameListProcesses, struct{}{}), "p1: echo ready; sleep 30 (running")
+
+	processes := BackgroundProcesses()
+	require.Len(t, processes, 1)
+	assert.True(t, processes[0].Running)
+
+	assert.Contains(t, callShellTool(t, allTools, ToolNameStopProcess, ProcessArgs{ID: "p1"}), "Stopped process p1: exited")
+	assert.Contains(t, callShellTool(t, allTools, ToolNameReadProcessOutput, ProcessArgs{ID: "p1"}), "Process p1: exited")
+	assert.Contains(t, callShellTool(t, allTools, ToolNameStopProcess, ProcessArgs{ID: "p2"}), `No background process with ID "p2"`)
+}
+
+func TestShellTool_StopKillsBackgroundProcesses(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands need a POSIX shell")
+	}
+
+	tool := NewShellTool(os.Environ())
+	require.NoError(t, tool.Start(t.Context()))
+
+	allTools, err := tool.Tools(t.Context())
+	require.NoError(t, err)
+
+	callShellTool(t, allTools, ToolNameStartBackgroundProcess, StartBackgroundProcessArgs{Cmd: "sleep 30 & sleep 30"})
+	require.Len(t, BackgroundProcesses(), 1)
+
+	require.NoError(t, tool.Stop(t.Context()))
+	assert.Empty(t, BackgroundProcesses())
+	assert.Contains(t, callShellTool(t, allTools, ToolNameListProcesses, struct{}{}), "exited")
+}
+
+func TestProcessOutput_KeepsTheEnd(t *testing.T) {
+	var output processOutput
+
+	_, _ = output.Write([]byte(strings.Repeat("a", processOutputSize)))
+	_, _ = output.Write([]byte("end"))
+
+	data, end, missed := output.readFrom(0)
+	assert.Len(t, data, processOutputSize)
+	assert.True(t, strings.HasSuffix(data, "end"))
+	assert.Equal(t, processOutputSize+3, end)
+	assert.Equal(t, 3, missed)
+
+	data, _, missed = output.readFrom(end)
+	assert.Empty(t, data)
+	assert.Zero(t, missed)
+}
+
+func TestProcessOutput_Wraps(t *testing.T) {
+	var output processOutput
+
+	var written strings.Builder
+	for i := range 3 * processOutputSize / 1000 {
+		chunk := strings.Repeat(strconv.Itoa(i%10), 1000)
+		written.WriteString(chunk)
+		_, _ = output.Write([]byte(chunk))
+	}
+	all := written.String()
+
+	data, end, missed := output.readFrom(0)
+	assert.Equal(t, all[len(all)-processOutputSize:], data)
+	assert.Equal(t, len(all), end)
+	assert.Equal(t, len(all)-processOutputSize, missed)
+
+	offset := len(all) - 1500
+	data, _, missed = output.readFrom(offset)
+	assert.Equal(t, all[offset:], data)
+	assert.Zero(t, missed)
+}
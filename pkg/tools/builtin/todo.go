@@ -46,6 +46,8 @@ type UpdateTodoArgs struct {
 }
 
 type todoHandler struct {
+	// mu serializes todo creation so that concurrent calls get distinct IDs
+	mu    sync.Mutex
 	todos *concurrent.Map[string, Todo]
 }
 
@@ -86,6 +88,9 @@ func (h *todoHandler) createTodo(_ context.Context, toolCall tools.ToolCall) (*t
 		return nil, fmt.Errorf("invalid arguments: %w", err)
 	}
 
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	id := fmt.Sprintf("todo_%d", h.todos.Length()+1)
 	h.todos.Store(id, Todo{
 		ID:          id,
@@ -104,6 +109,9 @@ func (h *todoHandler) createTodos(_ context.Context, toolCall tools.ToolCall) (*
 		return nil, fmt.Errorf("invalid arguments: %w", err)
 	}
 
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	ids := make([]string, len(params.Descriptions))
 	start := h.todos.Length()
 	for i, desc := range params.Descriptions {
@@ -70,6 +70,11 @@ func (t *GatewayToolset) Tools(ctx context.Context) ([]tools.Tool, error) {
 	return t.cmdToolset.Tools(ctx)
 }
 
+// Unwrap returns the MCP toolset of the gateway, for its resources and prompts
+func (t *GatewayToolset) Unwrap() tools.ToolSet {
+	return t.cmdToolset
+}
+
 func (t *GatewayToolset) Start(ctx context.Context) error {
 	return t.cmdToolset.Start(ctx)
 }
@@ -1,6 +1,7 @@
 package mcp
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
@@ -20,6 +21,12 @@ type mcpClient interface {
 	Initialize(ctx context.Context, request *mcp.InitializeRequest) (*mcp.InitializeResult, error)
 	ListTools(ctx context.Context, request *mcp.ListToolsParams) iter.Seq2[*mcp.Tool, error]
 	CallTool(ctx context.Context, request *mcp.CallToolParams) (*mcp.CallToolResult, error)
+	ListResources(ctx context.Context, request *mcp.ListResourcesParams) iter.Seq2[*mcp.Resource, error]
+	ReadResource(ctx context.Context, request *mcp.ReadResourceParams) (*mcp.ReadResourceResult, error)
+	Subscribe(ctx context.Context, request *mcp.SubscribeParams) error
+	ListPrompts(ctx context.Context, request *mcp.ListPromptsParams) iter.Seq2[*mcp.Prompt, error]
+	GetPrompt(ctx context.Context, request *mcp.GetPromptParams) (*mcp.GetPromptResult, error)
+	SetResourceUpdatedHandler(handler func(uri string))
 	SetElicitationHandler(handler tools.ElicitationHandler)
 	SetOAuthSuccessHandler(handler func())
 	Close(ctx context.Context) error
@@ -30,10 +37,15 @@ type Toolset struct {
 	mcpClient    mcpClient
 	logID        string
 	instructions string
+	capabilities *mcp.ServerCapabilities
 	started      atomic.Bool
 }
 
-var _ tools.ToolSet = (*Toolset)(nil)
+var (
+	_ tools.ToolSet          = (*Toolset)(nil)
+	_ tools.ResourceProvider = (*Toolset)(nil)
+	_ tools.PromptProvider   = (*Toolset)(nil)
+)
 
 // NewToolsetCommand creates a new MCP toolset from a command.
 func NewToolsetCommand(command string, args, env []string) *Toolset {
@@ -109,6 +121,7 @@ func (ts *Toolset) Start(ctx context.Context) error {
 
 	slog.Debug("Started MCP toolset successfully", "server", ts.logID)
 	ts.instructions = result.Instructions
+	ts.capabilities = result.Capabilities
 	ts.started.Store(true)
 	return nil
 }
@@ -187,6 +200,136 @@ func (ts *Toolset) callTool(ctx context.Context, toolCall tools.ToolCall) (*tool
 	return result, nil
 }
 
+// Resources lists the resources of the MCP server
+func (ts *Toolset) Resources(ctx context.Context) ([]tools.Resource, error) {
+	if !ts.started.Load() {
+		return nil, errors.New("toolset not started")
+	}
+	if ts.capabilities == nil || ts.capabilities.Resources == nil {
+		return nil, nil
+	}
+
+	var resources []tools.Resource
+	for r, err := range ts.mcpClient.ListResources(ctx, &mcp.ListResourcesParams{}) {
+		if err != nil {
+			return nil, err
+		}
+
+		resources = append(resources, tools.Resource{
+			URI:         r.URI,
+			Name:        cmp.Or(r.Title, r.Name),
+			Description: r.Description,
+			MIMEType:    r.MIMEType,
+		})
+	}
+
+	slog.Debug("Listed MCP resources", "server", ts.logID, "count", len(resources))
+	return resources, nil
+}
+
+// ReadResource reads a resource of the MCP server, binary contents are
+// described rather than returned
+func (ts *Toolset) ReadResource(ctx context.Context, uri string) (string, error) {
+	if !ts.started.Load() {
+		return "", errors.New("toolset not started")
+	}
+
+	slog.Debug("Reading MCP resource", "server", ts.logID, "uri", uri)
+
+	resp, err := ts.mcpClient.ReadResource(ctx, &mcp.ReadResourceParams{URI: uri})
+	if err != nil {
+		return "", fmt.Errorf("failed to read resource %s: %w", uri, err)
+	}
+
+	var parts []string
+	for _, content := range resp.Contents {
+		if content.Blob != nil {
+			parts = append(parts, fmt.Sprintf("[binary content of %s: %s, %d bytes]", content.URI, cmp.Or(content.MIMEType, "unknown type"), len(content.Blob)))
+			continue
+		}
+		parts = append(parts, content.Text)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// SubscribeResource subscribes to the updates of a resource, it does nothing
+// when the MCP server doesn't support subscriptions
+func (ts *Toolset) SubscribeResource(ctx context.Context, uri string) error {
+	if !ts.started.Load() {
+		return errors.New("toolset not started")
+	}
+	if ts.capabilities == nil || ts.capabilities.Resources == nil || !ts.capabilities.Resources.Subscribe {
+		return nil
+	}
+
+	slog.Debug("Subscribing to MCP resource", "server", ts.logID, "uri", uri)
+	return ts.mcpClient.Subscribe(ctx, &mcp.SubscribeParams{URI: uri})
+}
+
+func (ts *Toolset) SetResourceUpdatedHandler(handler func(uri string)) {
+	ts.mcpClient.SetResourceUpdatedHandler(handler)
+}
+
+// Prompts lists the prompts of the MCP server
+func (ts *Toolset) Prompts(ctx context.Context) ([]tools.Prompt, error) {
+	if !ts.started.Load() {
+		return nil, errors.New("toolset not started")
+	}
+	if ts.capabilities == nil || ts.capabilities.Prompts == nil {
+		return nil, nil
+	}
+
+	var prompts []tools.Prompt
+	for p, err := range ts.mcpClient.ListPrompts(ctx, &mcp.ListPromptsParams{}) {
+		if err != nil {
+			return nil, err
+		}
+
+		prompt := tools.Prompt{
+			Name:        p.Name,
+			Description: p.Description,
+		}
+		for _, arg := range p.Arguments {
+			prompt.Arguments = append(prompt.Arguments, tools.PromptArgument{
+				Name:        arg.Name,
+				Description: arg.Description,
+				Required:    arg.Required,
+			})
+		}
+		prompts = append(prompts, prompt)
+	}
+
+	slog.Debug("Listed MCP prompts", "server", ts.logID, "count", len(prompts))
+	return prompts, nil
+}
+
+// GetPrompt returns the text of the messages of a prompt of the MCP server
+func (ts *Toolset) GetPrompt(ctx context.Context, name string, arguments map[string]string) (string, error) {
+	if !ts.started.Load() {
+		return "", errors.New("toolset not started")
+	}
+
+	slog.Debug("Getting MCP prompt", "server", ts.logID, "prompt", name)
+
+	resp, err := ts.mcpClient.GetPrompt(ctx, &mcp.GetPromptParams{Name: name, Arguments: arguments})
+	if err != nil {
+		return "", fmt.Errorf("failed to get prompt %s: %w", name, err)
+	}
+
+	var parts []string
+	for _, message := range resp.Messages {
+		switch content := message.Content.(type) {
+		case *mcp.TextContent:
+			parts = append(parts, content.Text)
+		case *mcp.EmbeddedResource:
+			if content.Resource != nil && content.Resource.Text != "" {
+				parts = append(parts, content.Resource.Text)
+			}
+		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
 func (ts *Toolset) Stop(ctx context.Context) error {
 	slog.Debug("Stopping MCP toolset", "server", ts.logID)
 
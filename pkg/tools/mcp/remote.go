@@ -14,14 +14,15 @@ import (
 )
 
 type remoteMCPClient struct {
-	session             *mcp.ClientSession
-	url                 string
-	transportType       string
-	headers             map[string]string
-	tokenStore          OAuthTokenStore
-	elicitationHandler  tools.ElicitationHandler
-	oauthSuccessHandler func()
-	mu                  sync.RWMutex
+	session                *mcp.ClientSession
+	url                    string
+	transportType          string
+	headers                map[string]string
+	tokenStore             OAuthTokenStore
+	elicitationHandler     tools.ElicitationHandler
+	oauthSuccessHandler    func()
+	resourceUpdatedHandler func(uri string)
+	mu                     sync.RWMutex
 }
 
 func newRemoteClient(url, transportType string, headers map[string]string, tokenStore OAuthTokenStore) *remoteMCPClient {
@@ -102,7 +103,8 @@ func (c *remoteMCPClient) Initialize(ctx context.Context, _ *mcp.InitializeReque
 	}
 
 	opts := &mcp.ClientOptions{
-		ElicitationHandler: c.handleElicitationRequest,
+		ElicitationHandler:     c.handleElicitationRequest,
+		ResourceUpdatedHandler: c.handleResourceUpdated,
 	}
 
 	client := mcp.NewClient(impl, opts)
@@ -170,6 +172,83 @@ func (c *remoteMCPClient) CallTool(ctx context.Context, params *mcp.CallToolPara
 	return session.CallTool(ctx, params)
 }
 
+func (c *remoteMCPClient) ListResources(ctx context.Context, params *mcp.ListResourcesParams) iter.Seq2[*mcp.Resource, error] {
+	c.mu.RLock()
+	session := c.session
+	c.mu.RUnlock()
+
+	if session == nil {
+		return func(yield func(*mcp.Resource, error) bool) {
+			yield(nil, fmt.Errorf("session not initialized"))
+		}
+	}
+
+	return session.Resources(ctx, params)
+}
+
+func (c *remoteMCPClient) ReadResource(ctx context.Context, params *mcp.ReadResourceParams) (*mcp.ReadResourceResult, error) {
+	c.mu.RLock()
+	session := c.session
+	c.mu.RUnlock()
+
+	if session == nil {
+		return nil, fmt.Errorf("session not initialized")
+	}
+
+	return session.ReadResource(ctx, params)
+}
+
+func (c *remoteMCPClient) Subscribe(ctx context.Context, params *mcp.SubscribeParams) error {
+	c.mu.RLock()
+	session := c.session
+	c.mu.RUnlock()
+
+	if session == nil {
+		return fmt.Errorf("session not initialized")
+	}
+
+	return session.Subscribe(ctx, params)
+}
+
+func (c *remoteMCPClient) ListPrompts(ctx context.Context, params *mcp.ListPromptsParams) iter.Seq2[*mcp.Prompt, error] {
+	c.mu.RLock()
+	session := c.session
+	c.mu.RUnlock()
+
+	if session == nil {
+		return func(yield func(*mcp.Prompt, error) bool) {
+			yield(nil, fmt.Errorf("session not initialized"))
+		}
+	}
+
+	return session.Prompts(ctx, params)
+}
+
+func (c *remoteMCPClient) GetPrompt(ctx context.Context, params *mcp.GetPromptParams) (*mcp.GetPromptResult, error) {
+	c.mu.RLock()
+	session := c.session
+	c.mu.RUnlock()
+
+	if session == nil {
+		return nil, fmt.Errorf("session not initialized")
+	}
+
+	return session.GetPrompt(ctx, params)
+}
+
+// handleResourceUpdated forwards the resources/updated notifications of the MCP server
+func (c *remoteMCPClient) handleResourceUpdated(_ context.Context, req *mcp.ResourceUpdatedNotificationRequest) {
+	slog.Debug("MCP resource updated", "url", c.url, "uri", req.Params.URI)
+
+	c.mu.RLock()
+	handler := c.resourceUpdatedHandler
+	c.mu.RUnlock()
+
+	if handler != nil {
+		handler(req.Params.URI)
+	}
+}
+
 // requestUserConsent requests user consent to start the OAuth flow via elicitation
 func (c *remoteMCPClient) requestUserConsent(ctx context.Context) (bool, error) {
 	result, err := c.requestElicitation(ctx, &mcp.ElicitParams{
@@ -202,3 +281,9 @@ func (c *remoteMCPClient) SetOAuthSuccessHandler(handler func()) {
 	c.oauthSuccessHandler = handler
 	c.mu.Unlock()
 }
+
+func (c *remoteMCPClient) SetResourceUpdatedHandler(handler func(uri string)) {
+	c.mu.Lock()
+	c.resourceUpdatedHandler = handler
+	c.mu.Unlock()
+}
@@ -0,0 +1,125 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/tools"
+)
+
+// connectToolset connects a started toolset to an in-memory MCP server
+func connectToolset(t *testing.T, server *mcp.Server) *Toolset {
+	t.Helper()
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(t.Context(), serverTransport, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = serverSession.Close() })
+
+	client := &stdioMCPClient{}
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "test"}, &mcp.ClientOptions{
+		ResourceUpdatedHandler: client.handleResourceUpdated,
+	}).Connect(t.Context(), clientTransport, nil)
+	require.NoError(t, err)
+	client.session = session
+	t.Cleanup(func() { _ = session.Close() })
+
+	ts := &Toolset{
+		mcpClient:    client,
+		logID:        "test",
+		capabilities: session.InitializeResult().Capabilities,
+	}
+	ts.started.Store(true)
+	return ts
+}
+
+func TestResources(t *testing.T) {
+	t.Parallel()
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "server"}, &mcp.ServerOptions{
+		SubscribeHandler:   func(context.Context, *mcp.SubscribeRequest) error { return nil },
+		UnsubscribeHandler: func(context.Context, *mcp.UnsubscribeRequest) error { return nil },
+	})
+	server.AddResource(&mcp.Resource{URI: "docs://readme", Name: "readme", Description: "The readme", MIMEType: "text/markdown"},
+		func(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+			return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{{URI: req.Params.URI, Text: "# Readme"}}}, nil
+		})
+	server.AddResource(&mcp.Resource{URI: "docs://logo", Name: "logo"},
+		func(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+			return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{{URI: req.Params.URI, MIMEType: "image/png", Blob: []byte{1, 2, 3}}}}, nil
+		})
+	ts := connectToolset(t, server)
+
+	resources, err := ts.Resources(t.Context())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []tools.Resource{
+		{URI: "docs://readme", Name: "readme", Description: "The readme", MIMEType: "text/markdown"},
+		{URI: "docs://logo", Name: "logo"},
+	}, resources)
+
+	content, err := ts.ReadResource(t.Context(), "docs://readme")
+	require.NoError(t, err)
+	assert.Equal(t, "# Readme", content)
+
+	content, err = ts.ReadResource(t.Context(), "docs://logo")
+	require.NoError(t, err)
+	assert.Equal(t, "[binary content of docs://logo: image/png, 3 bytes]", content)
+
+	_, err = ts.ReadResource(t.Context(), "docs://missing")
+	require.Error(t, err)
+
+	updated := make(chan string, 1)
+	ts.SetResourceUpdatedHandler(func(uri string) { updated <- uri })
+	require.NoError(t, ts.SubscribeResource(t.Context(), "docs://readme"))
+	require.NoError(t, server.ResourceUpdated(t.Context(), &mcp.ResourceUpdatedNotificationParams{URI: "docs://readme"}))
+	assert.Equal(t, "docs://readme", <-updated)
+}
+
+func TestResourcesWithoutCapability(t *testing.T) {
+	t.Parallel()
+
+	ts := connectToolset(t, mcp.NewServer(&mcp.Implementation{Name: "server"}, nil))
+
+	resources, err := ts.Resources(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, resources)
+
+	prompts, err := ts.Prompts(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, prompts)
+
+	require.NoError(t, ts.SubscribeResource(t.Context(), "docs://readme"))
+}
+
+func TestPrompts(t *testing.T) {
+	t.Parallel()
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "server"}, nil)
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "review",
+		Description: "Review a file",
+		Arguments:   []*mcp.PromptArgument{{Name: "file", Description: "The file", Required: true}},
+	}, func(_ context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return &mcp.GetPromptResult{Messages: []*mcp.PromptMessage{
+			{Role: "user", Content: &mcp.TextContent{Text: "Review " + req.Params.Arguments["file"]}},
+			{Role: "user", Content: &mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: "docs://style", Text: "Use tabs"}}},
+		}}, nil
+	})
+	ts := connectToolset(t, server)
+
+	prompts, err := ts.Prompts(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []tools.Prompt{{
+		Name:        "review",
+		Description: "Review a file",
+		Arguments:   []tools.PromptArgument{{Name: "file", Description: "The file", Required: true}},
+	}}, prompts)
+
+	text, err := ts.GetPrompt(t.Context(), "review", map[string]string{"file": "main.go"})
+	require.NoError(t, err)
+	assert.Equal(t, "Review main.go\n\nUse tabs", text)
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"iter"
+	"log/slog"
 	"os/exec"
+	"sync"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
@@ -18,6 +20,9 @@ type stdioMCPClient struct {
 	args    []string
 	env     []string
 	session *mcp.ClientSession
+
+	resourceUpdatedHandler func(uri string)
+	mu                     sync.RWMutex
 }
 
 func newStdioCmdClient(command string, args, env []string) *stdioMCPClient {
@@ -37,7 +42,9 @@ func (c *stdioMCPClient) Initialize(ctx context.Context, _ *mcp.InitializeReques
 	client := mcp.NewClient(&mcp.Implementation{
 		Name:    "rb",
 		Version: "1.0.0",
-	}, nil)
+	}, &mcp.ClientOptions{
+		ResourceUpdatedHandler: c.handleResourceUpdated,
+	})
 
 	cmd := exec.CommandContext(ctx, c.command, c.args...)
 	cmd.Env = c.env
@@ -78,6 +85,69 @@ func (c *stdioMCPClient) CallTool(ctx context.Context, request *mcp.CallToolPara
 	return c.session.CallTool(ctx, request)
 }
 
+func (c *stdioMCPClient) ListResources(ctx context.Context, request *mcp.ListResourcesParams) iter.Seq2[*mcp.Resource, error] {
+	if c.session == nil {
+		return func(yield func(*mcp.Resource, error) bool) {
+			yield(nil, fmt.Errorf("session not initialized"))
+		}
+	}
+
+	return c.session.Resources(ctx, request)
+}
+
+func (c *stdioMCPClient) ReadResource(ctx context.Context, request *mcp.ReadResourceParams) (*mcp.ReadResourceResult, error) {
+	if c.session == nil {
+		return nil, fmt.Errorf("session not initialized")
+	}
+
+	return c.session.ReadResource(ctx, request)
+}
+
+func (c *stdioMCPClient) Subscribe(ctx context.Context, request *mcp.SubscribeParams) error {
+	if c.session == nil {
+		return fmt.Errorf("session not initialized")
+	}
+
+	return c.session.Subscribe(ctx, request)
+}
+
+func (c *stdioMCPClient) ListPrompts(ctx context.Context, request *mcp.ListPromptsParams) iter.Seq2[*mcp.Prompt, error] {
+	if c.session == nil {
+		return func(yield func(*mcp.Prompt, error) bool) {
+			yield(nil, fmt.Errorf("session not initialized"))
+		}
+	}
+
+	return c.session.Prompts(ctx, request)
+}
+
+func (c *stdioMCPClient) GetPrompt(ctx context.Context, request *mcp.GetPromptParams) (*mcp.GetPromptResult, error) {
+	if c.session == nil {
+		return nil, fmt.Errorf("session not initialized")
+	}
+
+	return c.session.GetPrompt(ctx, request)
+}
+
+// handleResourceUpdated forwards the resources/updated notifications of the MCP server
+func (c *stdioMCPClient) handleResourceUpdated(_ context.Context, req *mcp.ResourceUpdatedNotificationRequest) {
+	slog.Debug("MCP resource updated", "command", c.command, "uri", req.Params.URI)
+
+	c.mu.RLock()
+	handler := c.resourceUpdatedHandler
+	c.mu.RUnlock()
+
+	if handler != nil {
+		handler(req.Params.URI)
+	}
+}
+
+func (c *stdioMCPClient) SetResourceUpdatedHandler(handler func(uri string)) {
+	c.mu.Lock()
+	c.resourceUpdatedHandler = handler
+	c.mu.Unlock()
+}
+
 func (c *stdioMCPClient) SetElicitationHandler(tools.ElicitationHandler) {}
 
 func (c *stdioMCPClient) SetOAuthSuccessHandler(func()) {}
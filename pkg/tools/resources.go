@@ -0,0 +1,44 @@
+package tools
+
+import "context"
+
+// Resource is a document a toolset lets the agents and the users read, like the
+// resources of MCP servers
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MIMEType    string `json:"mime_type,omitempty"`
+}
+
+// ResourceProvider is implemented by toolsets that expose resources
+type ResourceProvider interface {
+	Resources(ctx context.Context) ([]Resource, error)
+	// ReadResource returns the content of a resource
+	ReadResource(ctx context.Context, uri string) (string, error)
+	// SubscribeResource asks for the handler set with SetResourceUpdatedHandler
+	// to be called when the resource changes
+	SubscribeResource(ctx context.Context, uri string) error
+	SetResourceUpdatedHandler(handler func(uri string))
+}
+
+// Prompt is a prompt template a toolset offers to the users
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptArgument is an argument of a prompt template
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptProvider is implemented by toolsets that offer prompts
+type PromptProvider interface {
+	Prompts(ctx context.Context) ([]Prompt, error)
+	// GetPrompt returns the text of a prompt filled with the arguments
+	GetPrompt(ctx context.Context, name string, arguments map[string]string) (string, error)
+}
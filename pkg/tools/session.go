@@ -0,0 +1,42 @@
+package tools
+
+import "context"
+
+type sessionIDKey struct{}
+
+// WithSessionID returns a context carrying the ID of the session tools are called from
+func WithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionIDKey{}, sessionID)
+}
+
+// SessionID returns the ID of the session a tool is called from, or an empty string if unknown
+func SessionID(ctx context.Context) string {
+	sessionID, _ := ctx.Value(sessionIDKey{}).(string)
+	return sessionID
+}
+
+type agentNameKey struct{}
+
+// WithAgentName returns a context carrying the name of the agent tools are called by
+func WithAgentName(ctx context.Context, agentName string) context.Context {
+	return context.WithValue(ctx, agentNameKey{}, agentName)
+}
+
+// AgentName returns the name of the agent a tool is called by, or an empty string if unknown
+func AgentName(ctx context.Context) string {
+	agentName, _ := ctx.Value(agentNameKey{}).(string)
+	return agentName
+}
+
+type toolCallIDKey struct{}
+
+// WithToolCallID returns a context carrying the ID of the tool call being handled
+func WithToolCallID(ctx context.Context, toolCallID string) context.Context {
+	return context.WithValue(ctx, toolCallIDKey{}, toolCallID)
+}
+
+// ToolCallID returns the ID of the tool call being handled, or an empty string if unknown
+func ToolCallID(ctx context.Context) string {
+	toolCallID, _ := ctx.Value(toolCallIDKey{}).(string)
+	return toolCallID
+}
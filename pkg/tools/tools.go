@@ -35,6 +35,20 @@ type Tool struct {
 	Annotations  ToolAnnotations `json:"annotations"`
 	OutputSchema any             `json:"outputSchema"`
 	Handler      ToolHandler     `json:"-"`
+	// Parallel overrides whether the runtime may run this tool concurrently with
+	// other tool calls. When nil, only read-only tools are run concurrently.
+	Parallel *bool `json:"-"`
+	// MaxOutputSize is the size, in bytes, above which the runtime saves the
+	// output of the tool to a file instead of the session. 0 means the default.
+	MaxOutputSize int `json:"-"`
+}
+
+// IsParallelSafe returns true if the tool can run concurrently with other tool calls
+func (t *Tool) IsParallelSafe() bool {
+	if t.Parallel != nil {
+		return *t.Parallel
+	}
+	return t.Annotations.ReadOnlyHint
 }
 
 type ToolAnnotations mcp.ToolAnnotations
@@ -66,3 +80,34 @@ type ToolSet interface {
 	SetElicitationHandler(handler ElicitationHandler)
 	SetOAuthSuccessHandler(handler func())
 }
+
+// ContextProvider is implemented by toolsets that add context relevant to the
+// conversation to the system prompt of the agent, query is the last message
+// of the user
+type ContextProvider interface {
+	ProvideContext(ctx context.Context, query string) (string, error)
+}
+
+// Unwrapper is implemented by toolsets that wrap another toolset, like the
+// ones filtering its tools or changing its instructions
+type Unwrapper interface {
+	Unwrap() ToolSet
+}
+
+// As returns the first toolset of the chain of wrapped toolsets that
+// implements T, starting with ts itself
+func As[T any](ts ToolSet) (T, bool) {
+	for ts != nil {
+		if t, ok := ts.(T); ok {
+			return t, true
+		}
+		unwrapper, ok := ts.(Unwrapper)
+		if !ok {
+			break
+		}
+		ts = unwrapper.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
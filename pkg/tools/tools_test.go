@@ -0,0 +1,41 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type contextToolSet struct {
+	ElicitationTool
+}
+
+func (t *contextToolSet) Tools(context.Context) ([]Tool, error) { return nil, nil }
+func (t *contextToolSet) Instructions() string                  { return "" }
+func (t *contextToolSet) Start(context.Context) error           { return nil }
+func (t *contextToolSet) Stop(context.Context) error            { return nil }
+func (t *contextToolSet) ProvideContext(context.Context, string) (string, error) {
+	return "context", nil
+}
+
+type wrapperToolSet struct {
+	ToolSet
+}
+
+func (w *wrapperToolSet) Unwrap() ToolSet {
+	return w.ToolSet
+}
+
+func TestAs(t *testing.T) {
+	t.Parallel()
+
+	inner := &contextToolSet{}
+
+	provider, ok := As[ContextProvider](&wrapperToolSet{&wrapperToolSet{inner}})
+	assert.True(t, ok)
+	assert.Same(t, inner, provider)
+
+	_, ok = As[PromptProvider](&wrapperToolSet{inner})
+	assert.False(t, ok)
+}
@@ -0,0 +1,240 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// segment is a step of a path: a key of an object, an index of an array or,
+// when all is set, every element of an array
+type segment struct {
+	key   string
+	index int
+	all   bool
+	isKey bool
+}
+
+type selectFields struct {
+	paths [][]segment
+}
+
+// Select keeps the fields of the JSON outputs that the jq-style paths select,
+// like .name, .items[].id or .items[0].tags, in the structure of the output.
+// Outputs that aren't JSON or where no path matches are unchanged.
+func Select(paths []string) (Transformer, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("select needs at least one field")
+	}
+
+	t := &selectFields{}
+	for _, path := range paths {
+		segments, err := parsePath(path)
+		if err != nil {
+			return nil, err
+		}
+		t.paths = append(t.paths, segments)
+	}
+	return t, nil
+}
+
+func (t *selectFields) Name() string {
+	return "select"
+}
+
+func (t *selectFields) Transform(output string) string {
+	var value any
+	if err := json.Unmarshal([]byte(output), &value); err != nil {
+		return output
+	}
+
+	var selected any
+	found := false
+	for _, path := range t.paths {
+		if v, ok := project(value, path); ok {
+			selected = merge(selected, v)
+			found = true
+		}
+	}
+	if !found {
+		return output
+	}
+
+	buf, err := json.Marshal(compact(selected))
+	if err != nil {
+		return output
+	}
+	return string(buf)
+}
+
+// parsePath parses a path made of .key, [index] and [] segments
+func parsePath(path string) ([]segment, error) {
+	if path == "" || (path[0] != '.' && path[0] != '[') {
+		return nil, fmt.Errorf("invalid field %q, it must start with . or [", path)
+	}
+
+	var segments []segment
+	rest := path
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				if rest == "" && len(segments) == 0 {
+					// "." selects the whole value
+					return nil, nil
+				}
+				return nil, fmt.Errorf("invalid field %q, empty key", path)
+			}
+			segments = append(segments, segment{key: rest[:end], isKey: true})
+			rest = rest[end:]
+
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid field %q, missing ]", path)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			if inner == "" {
+				segments = append(segments, segment{all: true})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid field %q, bad index %q", path, inner)
+				}
+				segments = append(segments, segment{index: index})
+			}
+			rest = rest[end+1:]
+
+		default:
+			return nil, fmt.Errorf("invalid field %q, unexpected %q", path, rest[0])
+		}
+	}
+	return segments, nil
+}
+
+// missing stands for the elements of arrays a path doesn't select, so that the
+// projections of several paths line up when they are merged
+type missing struct{}
+
+// project returns the parts of value the path selects, keeping their structure
+func project(value any, path []segment) (any, bool) {
+	if len(path) == 0 {
+		return value, true
+	}
+
+	seg, rest := path[0], path[1:]
+	switch {
+	case seg.isKey:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		child, ok := obj[seg.key]
+		if !ok {
+			return nil, false
+		}
+		sub, ok := project(child, rest)
+		if !ok {
+			return nil, false
+		}
+		return map[string]any{seg.key: sub}, true
+
+	case seg.all:
+		arr, ok := value.([]any)
+		if !ok {
+			return nil, false
+		}
+		result := make([]any, len(arr))
+		found := false
+		for i, element := range arr {
+			result[i] = missing{}
+			if sub, ok := project(element, rest); ok {
+				result[i] = sub
+				found = true
+			}
+		}
+		return result, found
+
+	default:
+		arr, ok := value.([]any)
+		if !ok || seg.index >= len(arr) {
+			return nil, false
+		}
+		sub, ok := project(arr[seg.index], rest)
+		if !ok {
+			return nil, false
+		}
+		result := make([]any, len(arr))
+		for i := range result {
+			result[i] = missing{}
+		}
+		result[seg.index] = sub
+		return result, true
+	}
+}
+
+// merge combines the projections of two paths of the same value
+func merge(a, b any) any {
+	switch a := a.(type) {
+	case map[string]any:
+		bm, ok := b.(map[string]any)
+		if !ok {
+			return b
+		}
+		for k, v := range bm {
+			if existing, ok := a[k]; ok {
+				a[k] = merge(existing, v)
+			} else {
+				a[k] = v
+			}
+		}
+		return a
+
+	case []any:
+		ba, ok := b.([]any)
+		if !ok || len(ba) != len(a) {
+			return b
+		}
+		for i := range a {
+			switch {
+			case a[i] == missing{}:
+				a[i] = ba[i]
+			case ba[i] != missing{}:
+				a[i] = merge(a[i], ba[i])
+			}
+		}
+		return a
+
+	default:
+		return b
+	}
+}
+
+// compact removes the elements no path selected from the arrays
+func compact(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for k, v := range value {
+			value[k] = compact(v)
+		}
+		return value
+
+	case []any:
+		result := make([]any, 0, len(value))
+		for _, v := range value {
+			if v != (missing{}) {
+				result = append(result, compact(v))
+			}
+		}
+		return result
+
+	default:
+		return value
+	}
+}
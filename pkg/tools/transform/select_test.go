@@ -0,0 +1,86 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelect(t *testing.T) {
+	t.Parallel()
+
+	output := `{"name": "rb", "items": [{"id": 1, "tags": ["a", "b"], "size": 10}, {"id": 2, "tags": ["c"]}], "meta": {"total": 2}}`
+
+	testcases := []struct {
+		name     string
+		paths    []string
+		output   string
+		expected string
+	}{
+		{
+			name:     "key",
+			paths:    []string{".name"},
+			output:   output,
+			expected: `{"name":"rb"}`,
+		},
+		{
+			name:     "every element",
+			paths:    []string{".items[].id"},
+			output:   output,
+			expected: `{"items":[{"id":1},{"id":2}]}`,
+		},
+		{
+			name:     "index",
+			paths:    []string{".items[0].tags"},
+			output:   output,
+			expected: `{"items":[{"tags":["a","b"]}]}`,
+		},
+		{
+			name:     "merged paths",
+			paths:    []string{".items[].id", ".items[].size", ".meta.total"},
+			output:   output,
+			expected: `{"items":[{"id":1,"size":10},{"id":2}],"meta":{"total":2}}`,
+		},
+		{
+			name:     "top-level array",
+			paths:    []string{"[].id"},
+			output:   `[{"id": 1, "x": true}, {"id": 2}]`,
+			expected: `[{"id":1},{"id":2}]`,
+		},
+		{
+			name:     "no match",
+			paths:    []string{".missing"},
+			output:   output,
+			expected: output,
+		},
+		{
+			name:     "not json",
+			paths:    []string{".name"},
+			output:   "plain text",
+			expected: "plain text",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			transformer, err := Select(tc.paths)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, transformer.Transform(tc.output))
+		})
+	}
+}
+
+func TestSelectInvalidPaths(t *testing.T) {
+	t.Parallel()
+
+	for _, path := range []string{"", "name", ".items[", ".items[x]", ".items[-1]", ".a..b"} {
+		_, err := Select([]string{path})
+		assert.Error(t, err, path)
+	}
+
+	_, err := Select(nil)
+	require.Error(t, err)
+}
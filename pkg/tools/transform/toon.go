@@ -0,0 +1,91 @@
+package transform
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/alpkeskin/gotoon"
+)
+
+type toon struct{}
+
+// Toon encodes the JSON objects and arrays of the outputs in TOON, the
+// Token-Oriented Object Notation. The JSON can be the whole output or blocks
+// that start a line in text, like the pretty-printed results of a command.
+func Toon() Transformer {
+	return toon{}
+}
+
+func (toon) Name() string {
+	return "toon"
+}
+
+func (toon) Transform(output string) string {
+	if encoded, ok := encodeToon(output); ok {
+		return encoded
+	}
+
+	var sb strings.Builder
+	rest := output
+	for rest != "" {
+		line, next, found := strings.Cut(rest, "\n")
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			start := len(rest) - len(strings.TrimLeft(rest, " \t"))
+			if encoded, n, ok := encodeLeadingJSON(rest[start:]); ok {
+				sb.WriteString(encoded)
+				rest = rest[start+n:]
+				continue
+			}
+		}
+
+		sb.WriteString(line)
+		if found {
+			sb.WriteString("\n")
+		}
+		rest = next
+	}
+
+	encoded := sb.String()
+	if len(encoded) >= len(output) {
+		return output
+	}
+	return encoded
+}
+
+// encodeToon encodes a text that is a single JSON object or array, it isn't
+// encoded when TOON isn't shorter
+func encodeToon(text string) (string, bool) {
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return "", false
+	}
+	switch value.(type) {
+	case map[string]any, []any:
+	default:
+		return "", false
+	}
+
+	encoded, err := gotoon.Encode(value)
+	if err != nil || len(encoded) >= len(text) {
+		return "", false
+	}
+	return encoded, true
+}
+
+// encodeLeadingJSON encodes the JSON object or array text starts with and
+// returns the number of bytes of JSON it replaced
+func encodeLeadingJSON(text string) (string, int, bool) {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	var raw json.RawMessage
+	if err := decoder.Decode(&raw); err != nil {
+		return "", 0, false
+	}
+
+	n := int(decoder.InputOffset())
+	encoded, ok := encodeToon(text[:n])
+	if !ok {
+		return "", 0, false
+	}
+	return encoded, n, true
+}
@@ -0,0 +1,153 @@
+// Package transform rewrites the outputs of tools before the agents see them,
+// to spend fewer tokens on them: TOON encoding of JSON, projection of JSON
+// fields, truncation and conversion of HTML to markdown.
+package transform
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+
+	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rumpl/rb/pkg/contextwindow"
+)
+
+// Transformer rewrites the output of a tool, it returns the output unchanged
+// when it doesn't apply to it
+type Transformer interface {
+	// Name identifies the transformer in the logs and the metrics
+	Name() string
+	Transform(output string) string
+}
+
+// Step applies a transformer to the outputs of the tools whose name matches
+// one of the patterns, or of all the tools when there are no patterns
+type Step struct {
+	Tools       []*regexp.Regexp
+	Transformer Transformer
+}
+
+// Matches returns whether the step applies to the outputs of a tool
+func (s *Step) Matches(toolName string) bool {
+	if len(s.Tools) == 0 {
+		return true
+	}
+	for _, pattern := range s.Tools {
+		if pattern.MatchString(toolName) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply runs the steps that match the tool on its output, in order, and
+// records how many tokens each of them saved
+func Apply(ctx context.Context, steps []Step, toolName, output string) string {
+	for i := range steps {
+		if !steps[i].Matches(toolName) {
+			continue
+		}
+
+		transformer := steps[i].Transformer
+		before := contextwindow.EstimateTextTokens(output)
+		output = transformer.Transform(output)
+		after := contextwindow.EstimateTextTokens(output)
+
+		record(ctx, transformer.Name(), toolName, before, after)
+	}
+	return output
+}
+
+var tokensSaved = sync.OnceValue(func() metric.Int64Counter {
+	counter, err := otel.Meter("github.com/rumpl/rb/pkg/tools/transform").Int64Counter(
+		"rb.tool.output.tokens_saved",
+		metric.WithDescription("Estimated number of tokens the output transformers saved"),
+		metric.WithUnit("{token}"),
+	)
+	if err != nil {
+		slog.Warn("Failed to create the tokens saved counter", "error", err)
+	}
+	return counter
+})
+
+// record adds the tokens a transformer saved to the metrics and to the span of
+// the tool call
+func record(ctx context.Context, transformer, toolName string, before, after int) {
+	attrs := []attribute.KeyValue{
+		attribute.String("transformer", transformer),
+		attribute.String("tool.name", toolName),
+	}
+
+	// Counters only go up, a transformer that makes the output bigger saves nothing
+	if counter := tokensSaved(); counter != nil {
+		counter.Add(ctx, int64(max(before-after, 0)), metric.WithAttributes(attrs...))
+	}
+	trace.SpanFromContext(ctx).AddEvent("tool.output.transform", trace.WithAttributes(append(attrs,
+		attribute.Int("tokens.before", before),
+		attribute.Int("tokens.after", after),
+	)...))
+	slog.Debug("Transformed tool output", "transformer", transformer, "tool", toolName, "tokens_before", before, "tokens_after", after)
+}
+
+type truncate struct {
+	maxLines int
+	maxBytes int
+}
+
+// Truncate keeps the first maxLines lines and the first maxBytes bytes of the
+// outputs, a limit of 0 doesn't apply
+func Truncate(maxLines, maxBytes int) Transformer {
+	return &truncate{maxLines: maxLines, maxBytes: maxBytes}
+}
+
+func (t *truncate) Name() string {
+	return "truncate"
+}
+
+func (t *truncate) Transform(output string) string {
+	truncated := output
+	if t.maxLines > 0 {
+		if lines := strings.SplitAfter(truncated, "\n"); len(lines) > t.maxLines {
+			truncated = strings.Join(lines[:t.maxLines], "")
+		}
+	}
+	if t.maxBytes > 0 && len(truncated) > t.maxBytes {
+		truncated = strings.ToValidUTF8(truncated[:t.maxBytes], "")
+	}
+
+	if len(truncated) == len(output) {
+		return output
+	}
+	return fmt.Sprintf("%s\n[truncated, %d of %d bytes shown]", strings.TrimSuffix(truncated, "\n"), len(truncated), len(output))
+}
+
+type markdown struct{}
+
+// Markdown converts the outputs that are HTML documents or fragments to markdown
+func Markdown() Transformer {
+	return markdown{}
+}
+
+func (markdown) Name() string {
+	return "markdown"
+}
+
+func (markdown) Transform(output string) string {
+	if !strings.HasPrefix(strings.TrimSpace(output), "<") {
+		return output
+	}
+
+	converted, err := htmltomarkdown.ConvertString(output)
+	if err != nil {
+		return output
+	}
+	return converted
+}
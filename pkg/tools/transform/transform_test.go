@@ -0,0 +1,79 @@
+package transform
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+
+	steps := []Step{
+		{Tools: []*regexp.Regexp{regexp.MustCompile("^fetch$")}, Transformer: Markdown()},
+		{Transformer: Truncate(0, 10)},
+	}
+
+	assert.Equal(t, "**bold** a\n[truncated, 10 of 17 bytes shown]", Apply(t.Context(), steps, "fetch", "<b>bold</b> and more"))
+	assert.Equal(t, "<b>bold</b\n[truncated, 10 of 20 bytes shown]", Apply(t.Context(), steps, "other", "<b>bold</b> and more"))
+	assert.Equal(t, "short", Apply(t.Context(), steps, "other", "short"))
+}
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "a\nb\n[truncated, 4 of 6 bytes shown]", Truncate(2, 0).Transform("a\nb\nc\n"))
+	assert.Equal(t, "a\nb\nc\n", Truncate(3, 0).Transform("a\nb\nc\n"))
+	assert.Equal(t, "h\n[truncated, 1 of 3 bytes shown]", Truncate(0, 2).Transform("hé"))
+}
+
+func TestMarkdown(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "# Title\n\nSome *text*", Markdown().Transform("<h1>Title</h1><p>Some <em>text</em></p>"))
+	assert.Equal(t, "not html", Markdown().Transform("not html"))
+}
+
+func TestToon(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		output   string
+		expected string
+	}{
+		{
+			name:     "object",
+			output:   `{"key": "value", "number": 42}`,
+			expected: "key: value\nnumber: 42",
+		},
+		{
+			name:     "array",
+			output:   `[{"id": 1, "name": "a"}, {"id": 2, "name": "b"}]`,
+			expected: "[2]{id,name}:\n  1,a\n  2,b",
+		},
+		{
+			name:     "mixed content",
+			output:   "Found 2 items:\n[{\"id\": 1, \"name\": \"a\"}, {\"id\": 2, \"name\": \"b\"}]\ndone",
+			expected: "Found 2 items:\n[2]{id,name}:\n  1,a\n  2,b\ndone",
+		},
+		{
+			name:     "not json",
+			output:   "plain text {not json}",
+			expected: "plain text {not json}",
+		},
+		{
+			name:     "scalar",
+			output:   `"a string"`,
+			expected: `"a string"`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, Toon().Transform(tc.output))
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/rumpl/rb/pkg/app"
 	"github.com/rumpl/rb/pkg/feedback"
+	"github.com/rumpl/rb/pkg/tools"
 	"github.com/rumpl/rb/pkg/tui/core"
 	"github.com/rumpl/rb/pkg/tui/styles"
 	"github.com/rumpl/rb/pkg/tui/types"
@@ -15,16 +16,29 @@ import (
 // Session commands
 type (
 	NewSessionMsg             struct{}
+	ForkSessionMsg            struct{}
 	EvalSessionMsg            struct{}
 	CompactSessionMsg         struct{}
 	CopySessionToClipboardMsg struct{}
+	SearchSessionsMsg         struct{}
+	PickSessionMsg            struct{}
 )
 
+// LoadSessionMsg replaces the current session with a saved session
+type LoadSessionMsg struct {
+	ID string
+}
+
 // Agent commands
 type AgentCommandMsg struct {
 	Command string
 }
 
+// PromptCommandMsg runs a prompt of the MCP servers of the agent
+type PromptCommandMsg struct {
+	Prompt tools.Prompt
+}
+
 // CommandCategory represents a category of commands
 type Category struct {
 	Name     string
@@ -57,6 +71,36 @@ func BuiltInSessionCommands() []Item {
 				return core.CmdHandler(NewSessionMsg{})
 			},
 		},
+		{
+			ID:           "session.fork",
+			Label:        "Fork",
+			SlashCommand: "/fork",
+			Description:  "Continue the current conversation in a new session",
+			Category:     "Session",
+			Execute: func() tea.Cmd {
+				return core.CmdHandler(ForkSessionMsg{})
+			},
+		},
+		{
+			ID:           "session.sessions",
+			Label:        "Sessions",
+			SlashCommand: "/sessions",
+			Description:  "Switch to one of the saved sessions of this agent",
+			Category:     "Session",
+			Execute: func() tea.Cmd {
+				return core.CmdHandler(PickSessionMsg{})
+			},
+		},
+		{
+			ID:           "session.search",
+			Label:        "Search",
+			SlashCommand: "/search",
+			Description:  "Search past sessions and jump to one",
+			Category:     "Session",
+			Execute: func() tea.Cmd {
+				return core.CmdHandler(SearchSessionsMsg{})
+			},
+		},
 		{
 			ID:           "session.compact",
 			Label:        "Compact",
@@ -155,7 +199,7 @@ func BuildCommandCategories(ctx context.Context, application *app.App) []Categor
 
 	agentCommands := application.CurrentAgentCommands(ctx)
 	if len(agentCommands) == 0 {
-		return categories
+		return append(categories, promptCommands(ctx, application)...)
 	}
 
 	commands := make([]Item, 0, len(agentCommands))
@@ -183,5 +227,38 @@ func BuildCommandCategories(ctx context.Context, application *app.App) []Categor
 		Commands: commands,
 	})
 
-	return categories
+	return append(categories, promptCommands(ctx, application)...)
+}
+
+// promptCommands returns the category of the prompts of the MCP servers of the
+// agent, they run as /commands
+func promptCommands(ctx context.Context, application *app.App) []Category {
+	prompts := application.CurrentAgentPrompts(ctx)
+	if len(prompts) == 0 {
+		return nil
+	}
+
+	commands := make([]Item, 0, len(prompts))
+	for _, prompt := range prompts {
+		description := prompt.Description
+		if len(description) > 60 {
+			description = description[:57] + "..."
+		}
+
+		commands = append(commands, Item{
+			ID:           "mcp.prompt." + prompt.Name,
+			Label:        prompt.Name,
+			Description:  description,
+			Category:     "MCP Prompts",
+			SlashCommand: "/" + prompt.Name,
+			Execute: func() tea.Cmd {
+				return core.CmdHandler(PromptCommandMsg{Prompt: prompt})
+			},
+		})
+	}
+
+	return []Category{{
+		Name:     "MCP Prompts",
+		Commands: commands,
+	}}
 }
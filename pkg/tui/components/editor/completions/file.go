@@ -1,14 +1,24 @@
 package completions
 
 import (
+	"context"
+
+	"github.com/rumpl/rb/pkg/app"
 	"github.com/rumpl/rb/pkg/fsx"
 	"github.com/rumpl/rb/pkg/tui/components/completion"
 )
 
-type fileCompletion struct{}
+type fileCompletion struct {
+	app *app.App
+}
 
-func NewFileCompletion() Completion {
-	return &fileCompletion{}
+// NewFileCompletion completes the files of the working directory and the
+// resources of the MCP servers of the agent, the resources stay mentioned
+// with @uri so that their content is sent with the message
+func NewFileCompletion(a *app.App) Completion {
+	return &fileCompletion{
+		app: a,
+	}
 }
 
 func (c *fileCompletion) AutoSubmit() bool {
@@ -24,16 +34,24 @@ func (c *fileCompletion) Trigger() string {
 }
 
 func (c *fileCompletion) Items() []completion.Item {
+	var items []completion.Item
+	for _, r := range c.app.CurrentAgentResources(context.Background()) {
+		items = append(items, completion.Item{
+			Label:       r.Name,
+			Description: r.URI,
+			Value:       "@" + r.URI,
+		})
+	}
+
 	files, err := fsx.ListDirectory(".", 0)
 	if err != nil {
-		return nil
+		return items
 	}
-	items := make([]completion.Item, len(files))
-	for i, f := range files {
-		items[i] = completion.Item{
+	for _, f := range files {
+		items = append(items, completion.Item{
 			Label: f,
 			Value: f,
-		}
+		})
 	}
 
 	return items
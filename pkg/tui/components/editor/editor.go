@@ -34,6 +34,7 @@ type Editor interface {
 	layout.Focusable
 	SetWorking(working bool) tea.Cmd
 	AcceptSuggestion() bool
+	SetValue(value string)
 }
 
 // editor implements [Editor]
@@ -351,6 +352,13 @@ func (e *editor) Blur() tea.Cmd {
 	return nil
 }
 
+// SetValue replaces the content of the editor
+func (e *editor) SetValue(value string) {
+	e.textarea.SetValue(value)
+	e.textarea.MoveToEnd()
+	e.refreshSuggestion()
+}
+
 func (e *editor) SetWorking(working bool) tea.Cmd {
 	e.working = working
 	return nil
@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
 
 	"github.com/rumpl/rb/pkg/tui/core/layout"
 	"github.com/rumpl/rb/pkg/tui/styles"
@@ -37,8 +38,7 @@ func (c *Component) Update(msg tea.Msg) (layout.Model, tea.Cmd) {
 }
 
 func (c *Component) View() string {
-	theme := c.themeManager.GetTheme()
-	return theme.UserMessageBorderStyle.Width(c.width).Render(c.message.Content)
+	return c.style().Width(c.width).Render(c.message.Content)
 }
 
 func (c *Component) SetSize(width, height int) tea.Cmd {
@@ -52,11 +52,19 @@ func (c *Component) GetSize() (width, height int) {
 }
 
 func (c *Component) Height(width int) int {
-	theme := c.themeManager.GetTheme()
-	content := theme.UserMessageBorderStyle.Width(width).Render(c.message.Content)
+	content := c.style().Width(width).Render(c.message.Content)
 	return strings.Count(content, "\n") + 1
 }
 
+// style returns the border style of the message, highlighted when the message is selected
+func (c *Component) style() lipgloss.Style {
+	theme := c.themeManager.GetTheme()
+	if c.message.Selected {
+		return theme.UserMessageBorderStyle.BorderForeground(theme.Colors.Accent)
+	}
+	return theme.UserMessageBorderStyle
+}
+
 func (c *Component) SetMessage(msg *types.Message) {
 	c.message = msg
 }
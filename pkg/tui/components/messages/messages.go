@@ -13,7 +13,9 @@ import (
 	"github.com/mattn/go-runewidth"
 
 	"github.com/rumpl/rb/pkg/app"
+	"github.com/rumpl/rb/pkg/chat"
 	"github.com/rumpl/rb/pkg/runtime"
+	"github.com/rumpl/rb/pkg/session"
 	"github.com/rumpl/rb/pkg/tools"
 	"github.com/rumpl/rb/pkg/tui/components/markdown"
 	"github.com/rumpl/rb/pkg/tui/components/message"
@@ -33,6 +35,15 @@ type StreamCancelledMsg struct {
 	ShowMessage bool // Whether to show a cancellation message after cleanup
 }
 
+// EditMessageMsg is sent when the user chooses to edit a previous user message
+type EditMessageMsg struct {
+	// Index is the position of the message among the user messages of the conversation
+	Index   int
+	Content string
+	// KeepFork asks to keep the conversation that follows the message as a fork
+	KeepFork bool
+}
+
 // AutoScrollTickMsg triggers auto-scroll during selection
 type AutoScrollTickMsg struct {
 	Direction int // -1 for up, 1 for down
@@ -55,6 +66,9 @@ type Model interface {
 	AddToolResult(msg *runtime.ToolCallResponseEvent, status types.ToolStatus) tea.Cmd
 	AppendToLastMessage(agentName string, messageType types.MessageType, content string) tea.Cmd
 	AddShellOutputMessage(content string) tea.Cmd
+	LoadSession(sess *session.Session) tea.Cmd
+	RemoveFromUserMessage(index int)
+	ClearMessageSelection()
 
 	ScrollToBottom() tea.Cmd
 }
@@ -112,6 +126,8 @@ type model struct {
 	totalHeight   int                  // Total height of all content in lines
 
 	selection selectionState
+	// selectedMessage is the index of the user message selected for editing, -1 if none
+	selectedMessage int
 
 	sessionState *service.SessionState
 	themeManager *styles.Manager
@@ -123,13 +139,14 @@ type model struct {
 // New creates a new message list component
 func New(a *app.App, sessionState *service.SessionState, themeManager *styles.Manager) Model {
 	return &model{
-		width:         120,
-		height:        24,
-		app:           a,
-		renderedItems: make(map[int]renderedItem),
-		sessionState:  sessionState,
-		themeManager:  themeManager,
-		scrollbar:     scrollbar.New(themeManager),
+		width:           120,
+		height:          24,
+		app:             a,
+		renderedItems:   make(map[int]renderedItem),
+		selectedMessage: -1,
+		sessionState:    sessionState,
+		themeManager:    themeManager,
+		scrollbar:       scrollbar.New(themeManager),
 	}
 }
 
@@ -137,12 +154,13 @@ func New(a *app.App, sessionState *service.SessionState, themeManager *styles.Ma
 // This is a lightweight version that doesn't require app or session state management
 func NewScrollableView(width, height int, sessionState *service.SessionState, themeManager *styles.Manager) Model {
 	return &model{
-		width:         width,
-		height:        height,
-		renderedItems: make(map[int]renderedItem),
-		sessionState:  sessionState,
-		themeManager:  themeManager,
-		scrollbar:     scrollbar.New(themeManager),
+		width:           width,
+		height:          height,
+		renderedItems:   make(map[int]renderedItem),
+		selectedMessage: -1,
+		sessionState:    sessionState,
+		themeManager:    themeManager,
+		scrollbar:       scrollbar.New(themeManager),
 	}
 }
 
@@ -262,7 +280,22 @@ func (m *model) Update(msg tea.Msg) (layout.Model, tea.Cmd) {
 		switch msg.String() {
 		case "esc":
 			m.clearSelection()
+			m.ClearMessageSelection()
 			return m, nil
+		case "e":
+			m.selectPreviousUserMessage()
+			return m, nil
+		case "enter", "f":
+			if m.selectedMessage < 0 {
+				break
+			}
+			msg := EditMessageMsg{
+				Index:    m.userMessageIndex(m.selectedMessage),
+				Content:  m.messages[m.selectedMessage].Content,
+				KeepFork: msg.String() == "f",
+			}
+			m.ClearMessageSelection()
+			return m, core.CmdHandler(msg)
 		case "up", "k":
 			m.scrollUp()
 			return m, nil
@@ -392,6 +425,14 @@ func (m *model) Bindings() []key.Binding {
 			key.WithKeys("down"),
 			key.WithHelp("↓", "down"),
 		),
+		key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "select message to edit"),
+		),
+		key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter/f", "edit (f keeps a fork)"),
+		),
 	}
 }
 
@@ -717,6 +758,111 @@ func (m *model) AppendToLastMessage(agentName string, messageType types.MessageT
 	}
 }
 
+// LoadSession adds the existing conversation of a session to the chat
+func (m *model) LoadSession(sess *session.Session) tea.Cmd {
+	var cmds []tea.Cmd
+
+	for _, msg := range sess.GetAllMessages() {
+		if msg.Implicit {
+			continue
+		}
+
+		switch msg.Message.Role {
+		case chat.MessageRoleUser:
+			cmds = append(cmds, m.AddUserMessage(msg.Message.Content))
+		case chat.MessageRoleAssistant:
+			if msg.Message.ReasoningContent != "" {
+				cmds = append(cmds, m.AppendToLastMessage(msg.AgentName, types.MessageTypeAssistantReasoning, msg.Message.ReasoningContent))
+			}
+			if msg.Message.Content != "" {
+				cmds = append(cmds, m.AppendToLastMessage(msg.AgentName, types.MessageTypeAssistant, msg.Message.Content))
+			}
+			for _, toolCall := range msg.Message.ToolCalls {
+				cmds = append(cmds, m.AddOrUpdateToolCall(msg.AgentName, toolCall, tools.Tool{Name: toolCall.Function.Name}, types.ToolStatusCompleted))
+			}
+		case chat.MessageRoleTool:
+			cmds = append(cmds, m.AddToolResult(&runtime.ToolCallResponseEvent{
+				ToolCall: tools.ToolCall{ID: msg.Message.ToolCallID},
+				Response: msg.Message.Content,
+			}, types.ToolStatusCompleted))
+		}
+	}
+
+	return tea.Batch(append(cmds, m.ScrollToBottom())...)
+}
+
+// RemoveFromUserMessage removes the index-th user message and everything after it
+func (m *model) RemoveFromUserMessage(index int) {
+	m.ClearMessageSelection()
+
+	for i, msg := range m.messages {
+		if msg.Type == types.MessageTypeUser && m.userMessageIndex(i) == index {
+			m.messages = m.messages[:i]
+			m.views = m.views[:i]
+			m.invalidateAllItems()
+			return
+		}
+	}
+}
+
+// ClearMessageSelection deselects the user message selected for editing
+func (m *model) ClearMessageSelection() {
+	if m.selectedMessage < 0 {
+		return
+	}
+
+	if m.selectedMessage < len(m.messages) {
+		m.messages[m.selectedMessage].Selected = false
+		m.invalidateItem(m.selectedMessage)
+	}
+	m.selectedMessage = -1
+}
+
+// selectPreviousUserMessage selects the user message before the current selection,
+// starting from the most recent one
+func (m *model) selectPreviousUserMessage() {
+	start := len(m.messages) - 1
+	if m.selectedMessage >= 0 {
+		start = m.selectedMessage - 1
+	}
+
+	for i := start; i >= 0; i-- {
+		if m.messages[i].Type != types.MessageTypeUser {
+			continue
+		}
+
+		m.ClearMessageSelection()
+		m.selectedMessage = i
+		m.messages[i].Selected = true
+		m.invalidateItem(i)
+		m.scrollToMessage(i)
+		return
+	}
+}
+
+// userMessageIndex returns the position of the message at index among the user messages
+func (m *model) userMessageIndex(index int) int {
+	n := 0
+	for _, msg := range m.messages[:index] {
+		if msg.Type == types.MessageTypeUser {
+			n++
+		}
+	}
+	return n
+}
+
+// scrollToMessage scrolls so that the message at index is at the top of the viewport
+func (m *model) scrollToMessage(index int) {
+	offset := 0
+	for i := range index {
+		item := m.renderItem(i, m.views[i])
+		if item.view != "" {
+			offset += item.height + 1
+		}
+	}
+	m.setScrollOffset(offset)
+}
+
 // ScrollToBottom scrolls to the bottom of the chat
 func (m *model) ScrollToBottom() tea.Cmd {
 	return func() tea.Msg {
@@ -7,9 +7,11 @@ import (
 
 	tea "charm.land/bubbletea/v2"
 	"charm.land/lipgloss/v2"
+	"github.com/mattn/go-runewidth"
 
 	"github.com/rumpl/rb/pkg/runtime"
 	"github.com/rumpl/rb/pkg/tools"
+	"github.com/rumpl/rb/pkg/tools/builtin"
 	"github.com/rumpl/rb/pkg/tui/components/spinner"
 	"github.com/rumpl/rb/pkg/tui/components/tool/todotool"
 	"github.com/rumpl/rb/pkg/tui/core/layout"
@@ -48,6 +50,7 @@ type model struct {
 	mode         Mode
 	sessionTitle string
 	themeManager *styles.Manager
+	processes    func() []builtin.ProcessInfo
 }
 
 func New(manager *service.TodoManager, themeManager *styles.Manager) Model {
@@ -59,6 +62,7 @@ func New(manager *service.TodoManager, themeManager *styles.Manager) Model {
 		spinner:      spinner.New(spinner.ModeSpinnerOnly, themeManager),
 		sessionTitle: "New session",
 		themeManager: themeManager,
+		processes:    builtin.BackgroundProcesses,
 	}
 }
 
@@ -168,7 +172,7 @@ func (m *model) verticalView() string {
 	topContent += "\n" + m.workingIndicator()
 
 	m.todoComp.SetSize(m.width)
-	todoContent := strings.TrimSuffix(m.todoComp.Render(), "\n")
+	todoContent := strings.TrimSuffix(m.processesView()+m.todoComp.Render(), "\n")
 
 	// Calculate available height for content
 	availableHeight := m.height - 2 // Account for borders
@@ -203,6 +207,34 @@ func (m *model) workingIndicator() string {
 	return ""
 }
 
+// processesView renders the running background processes started by the shell tools
+func (m *model) processesView() string {
+	var running []builtin.ProcessInfo
+	for _, p := range m.processes() {
+		if p.Running {
+			running = append(running, p)
+		}
+	}
+	if len(running) == 0 {
+		return ""
+	}
+
+	theme := m.themeManager.GetTheme()
+
+	var content strings.Builder
+	content.WriteString(theme.HighlightStyle.Render("Processes"))
+	content.WriteString("\n")
+
+	for _, p := range running {
+		line := runewidth.Truncate(fmt.Sprintf("%s %s", p.ID, p.Cmd), max(m.width-2, 3), "...")
+		content.WriteString(theme.ActiveStyle.Render("●") + " " + line)
+		content.WriteString("\n")
+	}
+	content.WriteString("\n")
+
+	return content.String()
+}
+
 func (m *model) tokenUsage() string {
 	theme := m.themeManager.GetTheme()
 	totalTokens := m.usage.InputTokens + m.usage.OutputTokens
@@ -0,0 +1,130 @@
+package dialog
+
+import (
+	"fmt"
+	"strings"
+
+	"charm.land/bubbles/v2/key"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+
+	"github.com/rumpl/rb/pkg/runtime"
+	"github.com/rumpl/rb/pkg/tui/core"
+	"github.com/rumpl/rb/pkg/tui/core/layout"
+	"github.com/rumpl/rb/pkg/tui/styles"
+)
+
+type budgetExceededDialog struct {
+	width, height int
+	event         *runtime.BudgetExceededEvent
+	keyMap        maxIterationsKeyMap
+	themeManager  *styles.Manager
+}
+
+// NewBudgetExceededDialog creates a dialog asking whether to extend the budget of the session or stop
+func NewBudgetExceededDialog(event *runtime.BudgetExceededEvent, themeManager *styles.Manager) Dialog {
+	return &budgetExceededDialog{
+		event:        event,
+		keyMap:       defaultMaxIterationsKeyMap(),
+		themeManager: themeManager,
+	}
+}
+
+// SetSize implements [Dialog].
+func (d *budgetExceededDialog) SetSize(width, height int) tea.Cmd {
+	d.width = width
+	d.height = height
+	return nil
+}
+
+// Init initializes the budget exceeded dialog
+func (d *budgetExceededDialog) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles messages for the budget exceeded dialog
+func (d *budgetExceededDialog) Update(msg tea.Msg) (layout.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		d.width = msg.Width
+		d.height = msg.Height
+		return d, nil
+
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, d.keyMap.Yes):
+			return d, tea.Sequence(core.CmdHandler(CloseDialogMsg{}), core.CmdHandler(RuntimeResumeMsg{Response: runtime.ResumeTypeApprove}))
+		case key.Matches(msg, d.keyMap.No):
+			return d, tea.Sequence(core.CmdHandler(CloseDialogMsg{}), core.CmdHandler(RuntimeResumeMsg{Response: runtime.ResumeTypeReject}))
+		}
+		if msg.String() == "ctrl+c" {
+			return d, tea.Quit
+		}
+	}
+
+	return d, nil
+}
+
+// Position returns the dialog position (centered)
+func (d *budgetExceededDialog) Position() (row, col int) {
+	dialogContent := d.View()
+	dialogWidth := lipgloss.Width(dialogContent)
+	dialogHeight := lipgloss.Height(dialogContent)
+
+	col = max(0, min((d.width-dialogWidth)/2, d.width-dialogWidth))
+	row = max(0, min((d.height-dialogHeight)/2, d.height-dialogHeight))
+
+	return row, col
+}
+
+// View renders the budget exceeded dialog
+func (d *budgetExceededDialog) View() string {
+	theme := d.themeManager.GetTheme()
+	// clamped width: ~60% of screen, bounded by [36, 84] and screen margin
+	dialogWidth := d.width * 60 / 100
+	if dialogWidth < 36 {
+		dialogWidth = max(20, min(d.width-4, 36))
+	}
+	if dialogWidth > 84 {
+		dialogWidth = min(84, d.width-4)
+	}
+
+	padX := 2
+	padY := 1
+	contentWidth := max(10, dialogWidth-(padX*2)-2)
+
+	dialogStyle := theme.DialogWarningStyle.
+		Padding(padY, padX).
+		Width(dialogWidth)
+
+	title := theme.DialogTitleWarningStyle.
+		Width(contentWidth).
+		Render("Budget Exceeded")
+
+	separator := theme.DialogSeparatorStyle.
+		Align(lipgloss.Center).
+		Width(contentWidth).
+		Render(strings.Repeat("─", max(1, contentWidth)))
+
+	label := "Cost"
+	if d.event.Budget == runtime.BudgetTokens {
+		label = "Tokens"
+	}
+	infoText := fmt.Sprintf("%s: %s of %s", label, runtime.FormatBudget(d.event.Budget, d.event.Used), runtime.FormatBudget(d.event.Budget, d.event.Limit))
+	infoSection := theme.DialogContentStyle.Render(wrapDisplayText(infoText, contentWidth))
+
+	message := theme.DialogContentStyle.Render(wrapDisplayText(fmt.Sprintf("The session used its %s budget.", d.event.Budget), contentWidth))
+
+	question := theme.DialogQuestionStyle.
+		Width(contentWidth).
+		Render(wrapDisplayText("Do you want to extend the budget and continue?", contentWidth))
+
+	options := theme.DialogOptionsStyle.
+		Width(contentWidth).
+		Render(wrapDisplayText("[Y]es    [N]o", contentWidth))
+
+	parts := []string{title, separator, infoSection, "", message, "", question, "", options}
+
+	content := lipgloss.JoinVertical(lipgloss.Left, parts...)
+	return dialogStyle.Render(content)
+}
@@ -0,0 +1,181 @@
+package dialog
+
+import (
+	"fmt"
+	"strings"
+
+	"charm.land/bubbles/v2/key"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/tui/commands"
+	"github.com/rumpl/rb/pkg/tui/core"
+	"github.com/rumpl/rb/pkg/tui/core/layout"
+	"github.com/rumpl/rb/pkg/tui/styles"
+)
+
+// sessionPickerDialog implements Dialog to pick one of the saved sessions of the agent
+type sessionPickerDialog struct {
+	width, height int
+	sessions      []*session.Session
+	currentID     string
+	selected      int
+	offset        int
+	keyMap        commandPaletteKeyMap
+	themeManager  *styles.Manager
+}
+
+// NewSessionPickerDialog creates a new session picker dialog, currentID is the ID of the session in use
+func NewSessionPickerDialog(sessions []*session.Session, currentID string, themeManager *styles.Manager) Dialog {
+	keyMap := defaultCommandPaletteKeyMap()
+	keyMap.Enter.SetHelp("enter", "open")
+
+	return &sessionPickerDialog{
+		sessions:     sessions,
+		currentID:    currentID,
+		keyMap:       keyMap,
+		themeManager: themeManager,
+	}
+}
+
+// Init initializes the session picker dialog
+func (d *sessionPickerDialog) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles messages for the session picker dialog
+func (d *sessionPickerDialog) Update(msg tea.Msg) (layout.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		d.width = msg.Width
+		d.height = msg.Height
+		return d, nil
+
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, d.keyMap.Escape):
+			return d, core.CmdHandler(CloseDialogMsg{})
+
+		case key.Matches(msg, d.keyMap.Up):
+			if d.selected > 0 {
+				d.selected--
+			}
+			return d, nil
+
+		case key.Matches(msg, d.keyMap.Down):
+			if d.selected < len(d.sessions)-1 {
+				d.selected++
+			}
+			return d, nil
+
+		case key.Matches(msg, d.keyMap.Enter):
+			if d.selected >= 0 && d.selected < len(d.sessions) {
+				id := d.sessions[d.selected].ID
+				return d, tea.Sequence(core.CmdHandler(CloseDialogMsg{}), core.CmdHandler(commands.LoadSessionMsg{ID: id}))
+			}
+			return d, nil
+
+		case msg.String() == "ctrl+c":
+			return d, tea.Quit
+		}
+	}
+
+	return d, nil
+}
+
+// View renders the session picker dialog
+func (d *sessionPickerDialog) View() string {
+	theme := d.themeManager.GetTheme()
+	dialogWidth := max(min(d.width*80/100, 100), 50)
+
+	maxHeight := min(d.height*70/100, 30)
+	contentWidth := dialogWidth - 6
+
+	title := theme.DialogTitleStyle.Width(contentWidth).Render("Sessions")
+
+	separator := theme.DialogSeparatorStyle.
+		Width(contentWidth).
+		Render(strings.Repeat("─", contentWidth))
+
+	// Scroll so that the selected session stays visible
+	maxItems := max(1, maxHeight-6)
+	if d.selected < d.offset {
+		d.offset = d.selected
+	}
+	if d.selected >= d.offset+maxItems {
+		d.offset = d.selected - maxItems + 1
+	}
+
+	var sessionList []string
+	for i := d.offset; i < len(d.sessions) && i < d.offset+maxItems; i++ {
+		sessionList = append(sessionList, d.renderSession(d.sessions[i], i == d.selected, contentWidth))
+	}
+
+	if len(d.sessions) == 0 {
+		sessionList = append(sessionList, theme.DialogContentStyle.
+			Italic(true).
+			Align(lipgloss.Center).
+			Width(contentWidth).
+			Render("No saved sessions for this agent"))
+	}
+
+	help := theme.DialogHelpStyle.
+		Width(contentWidth).
+		Render("↑/↓ navigate • enter open • esc close")
+
+	parts := []string{
+		title,
+		separator,
+	}
+	parts = append(parts, sessionList...)
+	parts = append(parts, "", help)
+
+	return theme.DialogStyle.
+		Width(dialogWidth).
+		Render(lipgloss.JoinVertical(lipgloss.Left, parts...))
+}
+
+// renderSession renders the title, date, cost and number of messages of a session
+func (d *sessionPickerDialog) renderSession(sess *session.Session, selected bool, width int) string {
+	theme := d.themeManager.GetTheme()
+
+	title := sess.Title
+	if title == "" {
+		title = "Untitled session"
+	}
+	if sess.ID == d.currentID {
+		title += " (current)"
+	}
+	details := fmt.Sprintf("%s • $%.4f • %d messages", sess.CreatedAt.Format("2006-01-02 15:04"), sess.Cost, len(sess.GetAllMessages()))
+
+	// Keep the details visible, truncate the title
+	titleWidth := max(10, width-lipgloss.Width(details)-5)
+	title = ansi.Truncate(title, titleWidth, "…")
+	line := "  " + title + strings.Repeat(" ", titleWidth-lipgloss.Width(title)+3) + details
+
+	if selected {
+		return theme.PaletteSelectedStyle.Render(line)
+	}
+	return theme.PaletteUnselectedStyle.Render(line)
+}
+
+// Position calculates the position to center the dialog
+func (d *sessionPickerDialog) Position() (row, col int) {
+	dialogWidth := max(min(d.width*80/100, 100), 50)
+
+	maxHeight := min(d.height*70/100, 30)
+
+	// Center the dialog
+	row = max(0, (d.height-maxHeight)/2)
+	col = max(0, (d.width-dialogWidth)/2)
+	return row, col
+}
+
+// SetSize implements Dialog
+func (d *sessionPickerDialog) SetSize(width, height int) tea.Cmd {
+	d.width = width
+	d.height = height
+	return nil
+}
@@ -0,0 +1,32 @@
+package dialog
+
+import (
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/tui/commands"
+	"github.com/rumpl/rb/pkg/tui/styles"
+)
+
+func TestSessionPickerDialog(t *testing.T) {
+	sessions := []*session.Session{
+		{ID: "first", Title: "First", Cost: 0.25},
+		{ID: "second", Title: "Second"},
+	}
+	d := NewSessionPickerDialog(sessions, "first", styles.NewManager(styles.ThemeDark)).(*sessionPickerDialog)
+	d.SetSize(120, 40)
+
+	view := d.View()
+	require.Contains(t, view, "First (current)")
+	require.Contains(t, view, "$0.2500")
+
+	d.Update(tea.KeyPressMsg{Code: tea.KeyDown})
+	d.Update(tea.KeyPressMsg{Code: tea.KeyDown})
+	require.Equal(t, 1, d.selected)
+
+	_, cmd := d.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	require.Contains(t, collectSequence(cmd), commands.LoadSessionMsg{ID: "second"})
+}
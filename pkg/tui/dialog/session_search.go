@@ -0,0 +1,235 @@
+package dialog
+
+import (
+	"context"
+	"strings"
+
+	"charm.land/bubbles/v2/key"
+	"charm.land/bubbles/v2/textinput"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/tui/commands"
+	"github.com/rumpl/rb/pkg/tui/core"
+	"github.com/rumpl/rb/pkg/tui/core/layout"
+	"github.com/rumpl/rb/pkg/tui/styles"
+)
+
+// SessionSearchFunc searches the saved sessions
+type SessionSearchFunc func(ctx context.Context, query string) ([]session.SearchResult, error)
+
+// sessionSearchResultsMsg carries the results of a search
+type sessionSearchResultsMsg struct {
+	query   string
+	results []session.SearchResult
+	err     error
+}
+
+// sessionSearchDialog implements Dialog to search past sessions and jump to one
+type sessionSearchDialog struct {
+	width, height int
+	textInput     textinput.Model
+	search        SessionSearchFunc
+	results       []session.SearchResult
+	err           error
+	selected      int
+	keyMap        commandPaletteKeyMap
+	themeManager  *styles.Manager
+}
+
+// NewSessionSearchDialog creates a new session search dialog
+func NewSessionSearchDialog(search SessionSearchFunc, themeManager *styles.Manager) Dialog {
+	ti := textinput.New()
+	ti.Placeholder = "Type to search past sessions..."
+	ti.Focus()
+	ti.CharLimit = 200
+	ti.SetWidth(50)
+
+	keyMap := defaultCommandPaletteKeyMap()
+	keyMap.Enter.SetHelp("enter", "open")
+
+	return &sessionSearchDialog{
+		textInput:    ti,
+		search:       search,
+		keyMap:       keyMap,
+		themeManager: themeManager,
+	}
+}
+
+// Init initializes the session search dialog
+func (d *sessionSearchDialog) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// Update handles messages for the session search dialog
+func (d *sessionSearchDialog) Update(msg tea.Msg) (layout.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		d.width = msg.Width
+		d.height = msg.Height
+		return d, nil
+
+	case sessionSearchResultsMsg:
+		// Results of a previous query can arrive after the ones of the current query
+		if msg.query != d.textInput.Value() {
+			return d, nil
+		}
+		d.results = msg.results
+		d.err = msg.err
+		d.selected = 0
+		return d, nil
+
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, d.keyMap.Escape):
+			return d, core.CmdHandler(CloseDialogMsg{})
+
+		case key.Matches(msg, d.keyMap.Up):
+			if d.selected > 0 {
+				d.selected--
+			}
+			return d, nil
+
+		case key.Matches(msg, d.keyMap.Down):
+			if d.selected < len(d.results)-1 {
+				d.selected++
+			}
+			return d, nil
+
+		case key.Matches(msg, d.keyMap.Enter):
+			if d.selected >= 0 && d.selected < len(d.results) {
+				id := d.results[d.selected].Session.ID
+				return d, tea.Sequence(core.CmdHandler(CloseDialogMsg{}), core.CmdHandler(commands.LoadSessionMsg{ID: id}))
+			}
+			return d, nil
+
+		case msg.String() == "ctrl+c":
+			return d, tea.Quit
+
+		default:
+			previous := d.textInput.Value()
+			var cmd tea.Cmd
+			d.textInput, cmd = d.textInput.Update(msg)
+			if d.textInput.Value() == previous {
+				return d, cmd
+			}
+			return d, tea.Batch(cmd, d.runSearch(d.textInput.Value()))
+		}
+	}
+
+	return d, nil
+}
+
+// runSearch searches the sessions in the background
+func (d *sessionSearchDialog) runSearch(query string) tea.Cmd {
+	if strings.TrimSpace(query) == "" {
+		d.results = nil
+		d.err = nil
+		return nil
+	}
+
+	search := d.search
+	return func() tea.Msg {
+		results, err := search(context.Background(), query)
+		return sessionSearchResultsMsg{query: query, results: results, err: err}
+	}
+}
+
+// View renders the session search dialog
+func (d *sessionSearchDialog) View() string {
+	theme := d.themeManager.GetTheme()
+	dialogWidth := max(min(d.width*80/100, 100), 50)
+
+	maxHeight := min(d.height*70/100, 30)
+	contentWidth := dialogWidth - 6
+
+	title := theme.DialogTitleStyle.Width(contentWidth).Render("Search Sessions")
+
+	d.textInput.SetWidth(contentWidth)
+	searchInput := d.textInput.View()
+
+	separator := theme.DialogSeparatorStyle.
+		Width(contentWidth).
+		Render(strings.Repeat("─", contentWidth))
+
+	var resultList []string
+	maxItems := max(1, (maxHeight-8)/2)
+	for i, result := range d.results {
+		if i >= maxItems {
+			break
+		}
+		resultList = append(resultList, d.renderResult(result, i == d.selected, contentWidth)...)
+	}
+
+	var message string
+	switch {
+	case d.err != nil:
+		message = "Search failed: " + d.err.Error()
+	case strings.TrimSpace(d.textInput.Value()) == "":
+		message = "Search the titles, messages and tool calls of past sessions"
+	case len(d.results) == 0:
+		message = "No sessions found"
+	}
+	if message != "" {
+		resultList = append(resultList, "", theme.DialogContentStyle.
+			Italic(true).
+			Align(lipgloss.Center).
+			Width(contentWidth).
+			Render(message))
+	}
+
+	help := theme.DialogHelpStyle.
+		Width(contentWidth).
+		Render("↑/↓ navigate • enter open • esc close")
+
+	parts := []string{
+		title,
+		"",
+		searchInput,
+		separator,
+	}
+	parts = append(parts, resultList...)
+	parts = append(parts, "", help)
+
+	return theme.DialogStyle.
+		Width(dialogWidth).
+		Render(lipgloss.JoinVertical(lipgloss.Left, parts...))
+}
+
+// renderResult renders the title and date of a session, followed by the snippet of the match
+func (d *sessionSearchDialog) renderResult(result session.SearchResult, selected bool, width int) []string {
+	theme := d.themeManager.GetTheme()
+
+	title := result.Session.Title
+	if title == "" {
+		title = "Untitled session"
+	}
+	header := ansi.Truncate("  "+title+" - "+result.Session.CreatedAt.Format("2006-01-02 15:04"), width, "…")
+	snippet := ansi.Truncate("    "+strings.Join(strings.Fields(result.Snippet), " "), width, "…")
+
+	if selected {
+		return []string{theme.PaletteSelectedStyle.Render(header), theme.PaletteDescStyle.Render(snippet)}
+	}
+	return []string{theme.PaletteUnselectedStyle.Render(header), theme.PaletteDescStyle.Render(snippet)}
+}
+
+// Position calculates the position to center the dialog
+func (d *sessionSearchDialog) Position() (row, col int) {
+	dialogWidth := max(min(d.width*80/100, 100), 50)
+
+	maxHeight := min(d.height*70/100, 30)
+
+	// Center the dialog
+	row = max(0, (d.height-maxHeight)/2)
+	col = max(0, (d.width-dialogWidth)/2)
+	return row, col
+}
+
+// SetSize implements Dialog
+func (d *sessionSearchDialog) SetSize(width, height int) tea.Cmd {
+	d.width = width
+	d.height = height
+	return nil
+}
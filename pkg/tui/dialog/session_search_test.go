@@ -0,0 +1,56 @@
+package dialog
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rumpl/rb/pkg/session"
+	"github.com/rumpl/rb/pkg/tui/commands"
+	"github.com/rumpl/rb/pkg/tui/styles"
+)
+
+func TestSessionSearchDialog(t *testing.T) {
+	search := func(_ context.Context, query string) ([]session.SearchResult, error) {
+		return []session.SearchResult{{Session: &session.Session{ID: "session-" + query, Title: query}, Snippet: "[" + query + "]"}}, nil
+	}
+	d := NewSessionSearchDialog(search, styles.NewManager(styles.ThemeDark)).(*sessionSearchDialog)
+
+	_, cmd := d.Update(tea.KeyPressMsg{Code: 'a', Text: "a"})
+	require.NotNil(t, cmd)
+	_, cmd = d.Update(tea.KeyPressMsg{Code: 'b', Text: "b"})
+	require.NotNil(t, cmd)
+	require.Equal(t, "ab", d.textInput.Value())
+
+	// Results of an older query are ignored
+	d.Update(sessionSearchResultsMsg{query: "ab", results: []session.SearchResult{{Session: &session.Session{ID: "session-ab"}}}})
+	d.Update(sessionSearchResultsMsg{query: "a", results: []session.SearchResult{{Session: &session.Session{ID: "session-a"}}}})
+	require.Len(t, d.results, 1)
+	require.Equal(t, "session-ab", d.results[0].Session.ID)
+
+	_, cmd = d.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	require.NotNil(t, cmd)
+	msgs := collectSequence(cmd)
+	require.Contains(t, msgs, commands.LoadSessionMsg{ID: "session-ab"})
+}
+
+// collectSequence runs the commands of a tea.Sequence and returns their messages
+func collectSequence(cmd tea.Cmd) []tea.Msg {
+	msg := cmd()
+	// tea.Sequence returns an unexported slice of commands
+	v := reflect.ValueOf(msg)
+	if v.Kind() != reflect.Slice {
+		return []tea.Msg{msg}
+	}
+
+	var msgs []tea.Msg
+	for i := range v.Len() {
+		if c, ok := v.Index(i).Interface().(tea.Cmd); ok && c != nil {
+			msgs = append(msgs, collectSequence(c)...)
+		}
+	}
+	return msgs
+}
@@ -24,7 +24,7 @@ type (
 
 // ToolConfirmationResponse represents the user's response to tool confirmation
 type ToolConfirmationResponse struct {
-	Response string // "approve", "reject", or "approve-session"
+	Response string // "approve", "reject", "approve-call", "approve-tool" or "approve-session"
 }
 
 type toolConfirmationDialog struct {
@@ -62,7 +62,7 @@ func (d *toolConfirmationDialog) SetSize(width, height int) tea.Cmd {
 	question := theme.DialogQuestionStyle.Width(contentWidth).Render("Do you want to allow this tool call?")
 	questionHeight := lipgloss.Height(question)
 
-	options := theme.DialogOptionsStyle.Width(contentWidth).Render("[Y]es    [N]o    [A]ll (approve all tools this session)")
+	options := theme.DialogOptionsStyle.Width(contentWidth).Render("[Y]es    [N]o    [S]ame (approve these arguments this session)    [T]ool (approve any call of this tool this session)    [A]ll (approve all tools this session)")
 	optionsHeight := lipgloss.Height(options)
 
 	// Calculate available height for scroll view
@@ -75,9 +75,11 @@ func (d *toolConfirmationDialog) SetSize(width, height int) tea.Cmd {
 
 // toolConfirmationKeyMap defines key bindings for tool confirmation dialog
 type toolConfirmationKeyMap struct {
-	Yes key.Binding
-	No  key.Binding
-	All key.Binding
+	Yes  key.Binding
+	No   key.Binding
+	Same key.Binding
+	Tool key.Binding
+	All  key.Binding
 }
 
 // defaultToolConfirmationKeyMap returns default key bindings
@@ -91,6 +93,14 @@ func defaultToolConfirmationKeyMap() toolConfirmationKeyMap {
 			key.WithKeys("n", "N"),
 			key.WithHelp("N", "reject"),
 		),
+		Same: key.NewBinding(
+			key.WithKeys("s", "S"),
+			key.WithHelp("S", "always approve these arguments"),
+		),
+		Tool: key.NewBinding(
+			key.WithKeys("t", "T"),
+			key.WithHelp("T", "always approve this tool, whatever its arguments"),
+		),
 		All: key.NewBinding(
 			key.WithKeys("a", "A"),
 			key.WithHelp("A", "approve all"),
@@ -140,6 +150,10 @@ func (d *toolConfirmationDialog) Update(msg tea.Msg) (layout.Model, tea.Cmd) {
 			return d, tea.Sequence(core.CmdHandler(CloseDialogMsg{}), core.CmdHandler(RuntimeResumeMsg{Response: runtime.ResumeTypeApprove}))
 		case key.Matches(msg, d.keyMap.No):
 			return d, tea.Sequence(core.CmdHandler(CloseDialogMsg{}), core.CmdHandler(RuntimeResumeMsg{Response: runtime.ResumeTypeReject}))
+		case key.Matches(msg, d.keyMap.Same):
+			return d, tea.Sequence(core.CmdHandler(CloseDialogMsg{}), core.CmdHandler(RuntimeResumeMsg{Response: runtime.ResumeTypeApproveCall}))
+		case key.Matches(msg, d.keyMap.Tool):
+			return d, tea.Sequence(core.CmdHandler(CloseDialogMsg{}), core.CmdHandler(RuntimeResumeMsg{Response: runtime.ResumeTypeApproveTool}))
 		case key.Matches(msg, d.keyMap.All):
 			return d, tea.Sequence(core.CmdHandler(CloseDialogMsg{}), core.CmdHandler(RuntimeResumeMsg{Response: runtime.ResumeTypeApproveSession}))
 		}
@@ -191,7 +205,7 @@ func (d *toolConfirmationDialog) View() string {
 	argumentsSection := d.scrollView.View()
 
 	question := theme.DialogQuestionStyle.Width(contentWidth).Render("Do you want to allow this tool call?")
-	options := theme.DialogOptionsStyle.Width(contentWidth).Render("[Y]es    [N]o    [A]ll (approve all tools this session)")
+	options := theme.DialogOptionsStyle.Width(contentWidth).Render("[Y]es    [N]o    [S]ame (approve these arguments this session)    [T]ool (approve any call of this tool this session)    [A]ll (approve all tools this session)")
 
 	// Combine all parts with proper spacing
 	parts := []string{title, separator}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"charm.land/bubbles/v2/help"
@@ -14,6 +15,8 @@ import (
 	"github.com/rumpl/rb/pkg/app"
 	"github.com/rumpl/rb/pkg/history"
 	"github.com/rumpl/rb/pkg/runtime"
+	"github.com/rumpl/rb/pkg/tools"
+	"github.com/rumpl/rb/pkg/tui/commands"
 	"github.com/rumpl/rb/pkg/tui/components/editor"
 	"github.com/rumpl/rb/pkg/tui/components/messages"
 	"github.com/rumpl/rb/pkg/tui/components/notification"
@@ -65,6 +68,9 @@ type chatPage struct {
 	msgCancel       context.CancelFunc
 	streamCancelled bool
 
+	// editing is the previous user message being edited, if any
+	editing *messages.EditMessageMsg
+
 	// Key map
 	keyMap KeyMap
 
@@ -129,6 +135,11 @@ func (p *chatPage) Init() tea.Cmd {
 		cmds = append(cmds, p.messages.AddWelcomeMessage(welcomeMsg))
 	}
 
+	// Show the conversation of sessions that already have messages, e.g. forked sessions
+	if sess := p.app.Session(); sess != nil {
+		cmds = append(cmds, p.messages.LoadSession(sess))
+	}
+
 	cmds = append(cmds,
 		p.sidebar.Init(),
 		p.messages.Init(),
@@ -186,6 +197,10 @@ func (p *chatPage) Update(msg tea.Msg) (layout.Model, tea.Cmd) {
 		case key.Matches(msg, p.keyMap.Tab):
 			p.switchFocus()
 			return p, nil
+		case key.Matches(msg, p.keyMap.Cancel) && p.editing != nil:
+			p.editing = nil
+			p.editor.SetValue("")
+			return p, nil
 		case key.Matches(msg, p.keyMap.Cancel):
 			// Cancel current message processing if active
 			cmd := p.cancelStream(true)
@@ -213,9 +228,37 @@ func (p *chatPage) Update(msg tea.Msg) (layout.Model, tea.Cmd) {
 		return p, cmd
 
 	case editor.SendMsg:
+		if p.editing != nil {
+			cmd := p.processEdit(*p.editing, msg.Content)
+			return p, cmd
+		}
 		cmd := p.processMessage(msg.Content)
 		return p, cmd
 
+	case commands.PromptCommandMsg:
+		command := "/" + msg.Prompt.Name
+		if !slices.ContainsFunc(msg.Prompt.Arguments, func(arg tools.PromptArgument) bool { return arg.Required }) {
+			return p, p.processMessage(command)
+		}
+
+		// Let the user type the arguments of the prompt
+		p.editor.SetValue(command + " ")
+		if p.focusedPanel != PanelEditor {
+			p.switchFocus()
+		}
+		return p, core.CmdHandler(notification.ShowMsg{Text: "Type the arguments of the prompt: " + runtime.PromptUsage(msg.Prompt)})
+
+	case messages.EditMessageMsg:
+		p.editing = &msg
+		p.editor.SetValue(msg.Content)
+		if p.focusedPanel != PanelEditor {
+			p.switchFocus()
+		}
+		return p, core.CmdHandler(notification.ShowMsg{Text: "Editing a previous message: send it to rerun the conversation from there, esc to cancel."})
+
+	case editRunStoppedMsg:
+		return p, p.rerunEdit(msg.edit, msg.content)
+
 	case messages.StreamCancelledMsg:
 		model, cmd := p.messages.Update(msg)
 		p.messages = model.(messages.Model)
@@ -312,6 +355,15 @@ func (p *chatPage) Update(msg tea.Msg) (layout.Model, tea.Cmd) {
 			Model: dialog.NewMaxIterationsDialog(msg.MaxIterations, p.app, p.themeManager),
 		})
 
+		return p, tea.Batch(spinnerCmd, dialogCmd)
+	case *runtime.BudgetExceededEvent:
+		spinnerCmd := p.setWorking(false)
+
+		// Ask whether to extend the budget or stop
+		dialogCmd := core.CmdHandler(dialog.OpenDialogMsg{
+			Model: dialog.NewBudgetExceededDialog(msg, p.themeManager),
+		})
+
 		return p, tea.Batch(spinnerCmd, dialogCmd)
 	case *runtime.ElicitationRequestEvent:
 		spinnerCmd := p.setWorking(false)
@@ -532,6 +584,43 @@ func (p *chatPage) processMessage(content string) tea.Cmd {
 	return p.messages.ScrollToBottom()
 }
 
+// editRunStoppedMsg is sent once the run was stopped to edit a previous user message
+type editRunStoppedMsg struct {
+	edit    messages.EditMessageMsg
+	content string
+}
+
+// processEdit stops the current run, the edit is applied once it stopped
+func (p *chatPage) processEdit(edit messages.EditMessageMsg, content string) tea.Cmd {
+	p.editing = nil
+
+	if p.msgCancel != nil {
+		p.msgCancel()
+		p.msgCancel = nil
+	}
+
+	return p.app.StopRun(editRunStoppedMsg{edit: edit, content: content})
+}
+
+// rerunEdit replaces a previous user message with content and reruns the conversation from there
+func (p *chatPage) rerunEdit(edit messages.EditMessageMsg, content string) tea.Cmd {
+	var ctx context.Context
+	ctx, p.msgCancel = context.WithCancel(context.Background())
+
+	if err := p.app.EditUserMessage(ctx, p.msgCancel, edit.Index, content, edit.KeepFork); err != nil {
+		return core.CmdHandler(notification.ShowMsg{Text: "Failed to edit message: " + err.Error(), Type: notification.TypeError})
+	}
+	p.messages.RemoveFromUserMessage(edit.Index)
+
+	if edit.KeepFork {
+		return tea.Batch(
+			p.messages.ScrollToBottom(),
+			core.CmdHandler(notification.ShowMsg{Text: "The previous conversation was kept as a fork."}),
+		)
+	}
+	return p.messages.ScrollToBottom()
+}
+
 // CompactSession generates a summary and compacts the session history
 func (p *chatPage) CompactSession() tea.Cmd {
 	// Cancel any active stream without showing cancellation message
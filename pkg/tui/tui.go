@@ -100,7 +100,7 @@ func (a *appModel) Init() tea.Cmd {
 	if firstMessage := a.application.FirstMessage(); firstMessage != nil {
 		cmds = append(cmds, func() tea.Msg {
 			return editor.SendMsg{
-				Content: a.application.ResolveCommand(context.Background(), *firstMessage),
+				Content: *firstMessage,
 			}
 		})
 	}
@@ -170,6 +170,51 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		return a, tea.Batch(a.Init(), a.handleWindowResize(a.wWidth, a.wHeight))
 
+	case commands.ForkSessionMsg:
+		if err := a.application.ForkSession(); err != nil {
+			return a, core.CmdHandler(notification.ShowMsg{Text: "Failed to fork session: " + err.Error(), Type: notification.TypeError})
+		}
+		a.sessionState = service.NewSessionState()
+		a.chatPage = chat.New(a.application, a.sessionState, a.themeManager)
+		a.dialog = dialog.New()
+		a.statusBar = statusbar.New(a.chatPage, a.themeManager)
+
+		return a, tea.Batch(
+			a.dialog.Init(),
+			a.chatPage.Init(),
+			a.handleWindowResize(a.wWidth, a.wHeight),
+			core.CmdHandler(notification.ShowMsg{Text: "Forked into a new session."}),
+		)
+
+	case commands.SearchSessionsMsg:
+		return a, core.CmdHandler(dialog.OpenDialogMsg{
+			Model: dialog.NewSessionSearchDialog(a.application.SearchSessions, a.themeManager),
+		})
+
+	case commands.PickSessionMsg:
+		sessions, err := a.application.Sessions(context.Background())
+		if err != nil {
+			return a, core.CmdHandler(notification.ShowMsg{Text: "Failed to list sessions: " + err.Error(), Type: notification.TypeError})
+		}
+		return a, core.CmdHandler(dialog.OpenDialogMsg{
+			Model: dialog.NewSessionPickerDialog(sessions, a.application.Session().ID, a.themeManager),
+		})
+
+	case commands.LoadSessionMsg:
+		if err := a.application.LoadSession(context.Background(), msg.ID); err != nil {
+			return a, core.CmdHandler(notification.ShowMsg{Text: "Failed to load session: " + err.Error(), Type: notification.TypeError})
+		}
+		a.sessionState = service.NewSessionState()
+		a.chatPage = chat.New(a.application, a.sessionState, a.themeManager)
+		a.dialog = dialog.New()
+		a.statusBar = statusbar.New(a.chatPage, a.themeManager)
+
+		return a, tea.Batch(
+			a.dialog.Init(),
+			a.chatPage.Init(),
+			a.handleWindowResize(a.wWidth, a.wHeight),
+		)
+
 	case commands.EvalSessionMsg:
 		evalFile, _ := evaluation.Save(a.application.Session())
 		return a, core.CmdHandler(notification.ShowMsg{Text: fmt.Sprintf("Eval saved to file %s", evalFile)})
@@ -190,8 +235,11 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a, core.CmdHandler(notification.ShowMsg{Text: "Conversation copied to clipboard."})
 
 	case commands.AgentCommandMsg:
-		resolvedCommand := a.application.ResolveCommand(context.Background(), msg.Command)
-		return a, core.CmdHandler(editor.SendMsg{Content: resolvedCommand})
+		// The command is resolved to its prompt when the message is sent
+		return a, core.CmdHandler(editor.SendMsg{Content: msg.Command})
+
+	case app.ResourceUpdatedMsg:
+		return a, core.CmdHandler(notification.ShowMsg{Text: "Resource " + msg.URI + " was updated, its new content will be sent with the next message."})
 
 	case commands.OpenURLMsg:
 		_ = browser.Open(context.Background(), msg.URL)
@@ -42,6 +42,7 @@ type Message struct {
 	ToolCall       tools.ToolCall // Associated tool call for tool messages
 	ToolDefinition tools.Tool     // Definition of the tool being called
 	ToolStatus     ToolStatus     // Status for tool calls
+	Selected       bool           // Whether the message is selected for editing
 }
 
 func Agent(typ MessageType, agentName, content string) *Message {